@@ -23,9 +23,49 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/recommendations": {
+        "/api/v1/auth/token": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Exchange basic auth credentials for an HS256 JWT to send as \"Authorization: Bearer \u003ctoken\u003e\" on protected routes. Only registered when AUTH_MODE=jwt",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Issue a bearer token",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.TokenResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/brokerages/stats": {
             "get": {
-                "description": "Get top recommended stocks based on the recommendation algorithm",
+                "description": "Get per-brokerage recommendation counts, average score and buy/hold/sell breakdown, ordered by total recommendations",
                 "consumes": [
                     "application/json"
                 ],
@@ -33,15 +73,19 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "recommendations"
+                    "stats"
                 ],
-                "summary": "Get stock recommendations",
+                "summary": "Get brokerage statistics",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Maximum recommendations",
-                        "name": "limit",
+                        "enum": [
+                            "total_recommendations",
+                            "avg_score"
+                        ],
+                        "type": "string",
+                        "default": "total_recommendations",
+                        "description": "Order descending by this field",
+                        "name": "sort_by",
                         "in": "query"
                     }
                 ],
@@ -49,7 +93,28 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/httpapi.SuccessResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/stockviewer.BrokerageStats"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
                         }
                     },
                     "500": {
@@ -61,9 +126,36 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/stocks": {
+        "/api/v1/openapi.json": {
             "get": {
-                "description": "Get a paginated list of stocks with optional filters",
+                "description": "Returns the raw OpenAPI/Swagger JSON document, the same one served at /swagger/doc.json",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Download the API specification",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/recommendations": {
+            "get": {
+                "description": "Get a page of the top recommended stocks based on the recommendation algorithm, optionally only those rated by one brokerage. The ranking holds at most 100 recommendations",
                 "consumes": [
                     "application/json"
                 ],
@@ -71,64 +163,85 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "stocks"
+                    "recommendations"
                 ],
-                "summary": "List stocks",
+                "summary": "Get stock recommendations",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Filter by ticker symbol",
-                        "name": "ticker",
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Filter by company name",
-                        "name": "company",
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Recommendations per page (max 100)",
+                        "name": "page_size",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Filter by brokerage",
-                        "name": "brokerage",
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Deprecated: page size when page_size is not given; 0 or out-of-range values use the default",
+                        "name": "limit",
                         "in": "query"
                     },
                     {
+                        "enum": [
+                            "en",
+                            "es"
+                        ],
                         "type": "string",
-                        "description": "Filter by rating",
-                        "name": "rating",
+                        "description": "Locale of the reasons, overrides Accept-Language",
+                        "name": "lang",
                         "in": "query"
                     },
                     {
+                        "enum": [
+                            "compact",
+                            "normal",
+                            "full"
+                        ],
                         "type": "string",
-                        "description": "Filter by action",
-                        "name": "action",
+                        "description": "compact drops the breakdown and keeps one reason; full adds the price target move",
+                        "name": "verbosity",
                         "in": "query"
                     },
                     {
+                        "enum": [
+                            "USD"
+                        ],
                         "type": "string",
-                        "description": "Sort by field (ticker, company, recommend_score, created_at)",
-                        "name": "sort_by",
+                        "description": "Display currency of amounts in reasons",
+                        "name": "currency",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Sort order (ASC, DESC)",
-                        "name": "sort_order",
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Also rank cold-start tickers (flagged with cold_start); not supported with brokerage",
+                        "name": "include_new",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "default": 1,
-                        "description": "Page number",
-                        "name": "page",
+                        "type": "string",
+                        "description": "Only rank stocks rated by this brokerage (exact name, e.g. Goldman Sachs)",
+                        "name": "brokerage",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "default": 20,
-                        "description": "Items per page",
-                        "name": "page_size",
+                        "enum": [
+                            "A",
+                            "B",
+                            "C",
+                            "D",
+                            "F"
+                        ],
+                        "type": "string",
+                        "description": "Only rank recommendations of this score tier: A 80+, B 65+, C 50+, D 35+, F below",
+                        "name": "tier",
                         "in": "query"
                     }
                 ],
@@ -136,11 +249,11 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/httpapi.PaginatedSuccessResponse"
+                            "$ref": "#/definitions/httpapi.PaginatedRecommendationResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid parameters, or unknown ones when STRICT_QUERY_PARAMS=enforce",
                         "schema": {
                             "$ref": "#/definitions/httpapi.ErrorResponse"
                         }
@@ -154,9 +267,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/stocks/filters": {
+        "/api/v1/recommendations/new-coverage": {
             "get": {
-                "description": "Get available filter options for stocks (brokerages, ratings, actions)",
+                "description": "List cold-start tickers, those with too few entries or too little history to be ranked with established coverage, ranked by the strength of their initiating rating and then by target upside. Empty when no cold-start threshold is configured",
                 "consumes": [
                     "application/json"
                 ],
@@ -164,9 +277,18 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "stocks"
+                    "recommendations"
+                ],
+                "summary": "Get newly covered tickers",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Maximum recommendations (1-100); 0 or out-of-range values use the default",
+                        "name": "limit",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get available filters",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -174,6 +296,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/httpapi.SuccessResponse"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -183,9 +311,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/stocks/search": {
+        "/api/v1/stats": {
             "get": {
-                "description": "Search stocks by ticker or company name",
+                "description": "Get headline numbers for a dashboard: stock count, distinct tickers and brokerages, average recommend score and last sync time",
                 "consumes": [
                     "application/json"
                 ],
@@ -193,36 +321,26 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "stocks"
-                ],
-                "summary": "Search stocks",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Search query",
-                        "name": "q",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Maximum results",
-                        "name": "limit",
-                        "in": "query"
-                    }
+                    "stats"
                 ],
+                "summary": "Get summary statistics",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/httpapi.SuccessResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/stockviewer.StatsResponse"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "500": {
@@ -234,9 +352,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/stocks/{id}": {
+        "/api/v1/stats/brokerages": {
             "get": {
-                "description": "Get detailed information about a specific stock",
+                "description": "Get per-brokerage ratings issued, average score and buy/hold/sell breakdown, ordered by average score",
                 "consumes": [
                     "application/json"
                 ],
@@ -244,27 +362,46 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "stocks"
+                    "stats"
                 ],
-                "summary": "Get stock by ID",
+                "summary": "Compare brokerages",
                 "parameters": [
                     {
+                        "enum": [
+                            "total_recommendations",
+                            "avg_score"
+                        ],
                         "type": "string",
-                        "description": "Stock ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "default": "avg_score",
+                        "description": "Order descending by this field",
+                        "name": "sort_by",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/httpapi.SuccessResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/stockviewer.BrokerageStats"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/httpapi.ErrorResponse"
                         }
@@ -278,14 +415,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/sync": {
-            "post": {
-                "security": [
-                    {
-                        "BasicAuth": []
-                    }
-                ],
-                "description": "Fetch and synchronize stocks from the external KarenAI API",
+        "/api/v1/stats/data-quality": {
+            "get": {
+                "description": "List the stocks quarantined because the feed sent a price target that could not be parsed (null, an object, a boolean or an unreadable string). Their targets are stored as null and data_issues says what was received. Issues are counted by field and shape, most frequent first, next to the schema warnings of the latest sync",
                 "consumes": [
                     "application/json"
                 ],
@@ -293,26 +425,26 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "sync"
+                    "stats"
                 ],
-                "summary": "Sync stocks from external API",
+                "summary": "Get data quality report",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/httpapi.SyncResponse"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
-                        "schema": {
-                            "$ref": "#/definitions/httpapi.ErrorResponse"
-                        }
-                    },
-                    "409": {
-                        "description": "Sync already in progress",
-                        "schema": {
-                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/stockviewer.DataQualityReport"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "500": {
@@ -324,9 +456,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/health": {
+        "/api/v1/stats/score-distribution": {
             "get": {
-                "description": "Returns detailed health status of the service",
+                "description": "Get the number of stocks per recommend_score range of 10 points, from 0-10 to 90-100",
                 "consumes": [
                     "application/json"
                 ],
@@ -334,22 +466,43 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "health"
+                    "stats"
                 ],
-                "summary": "Detailed health check",
+                "summary": "Get recommend score distribution",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/httpapi.SuccessResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/stockviewer.ScoreBucket"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/ping": {
+        "/api/v1/stocks": {
             "get": {
-                "description": "Returns pong to verify the service is running",
+                "description": "Get a paginated list of stocks with optional filters",
                 "consumes": [
                     "application/json"
                 ],
@@ -357,80 +510,2256 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "health"
+                    "stocks"
                 ],
-                "summary": "Health check endpoint",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/httpapi.SuccessResponse"
-                        }
-                    }
-                }
-            }
-        }
-    },
-    "definitions": {
-        "httpapi.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string"
-                },
-                "message": {
-                    "type": "string"
-                }
-            }
-        },
-        "httpapi.PaginatedSuccessResponse": {
-            "type": "object",
-            "properties": {
-                "data": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/stockviewer.Stock"
-                    }
-                },
-                "page": {
-                    "type": "integer"
-                },
-                "page_size": {
-                    "type": "integer"
-                },
-                "total_items": {
-                    "type": "integer"
-                },
-                "total_pages": {
-                    "type": "integer"
-                }
+                "summary": "List stocks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by ticker symbol (substring match unless ticker_exact is set)",
+                        "name": "ticker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Match the ticker exactly (case-insensitive) instead of by substring",
+                        "name": "ticker_exact",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by company name",
+                        "name": "company",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search within analyst notes; :none matches stocks without notes and :any stocks with notes",
+                        "name": "notes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by sector (set by the enrichment step)",
+                        "name": "sector",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by industry (set by the enrichment step)",
+                        "name": "industry",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by brokerage",
+                        "name": "brokerage",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by rating (rating_to)",
+                        "name": "rating",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by previous rating; combine with rating to select a transition",
+                        "name": "rating_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by action",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "positive",
+                            "negative",
+                            "neutral"
+                        ],
+                        "type": "string",
+                        "description": "Filter by action sentiment",
+                        "name": "action_category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Filter by any of these actions; repeat the parameter for each value",
+                        "name": "actions",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "ticker"
+                        ],
+                        "type": "string",
+                        "description": "Return only the most recent entry per ticker; counts and pages cover distinct tickers",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include stocks whose rating is not recognized (hidden by default)",
+                        "name": "include_unrated",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated sort fields, later fields break ties (ticker, company, brokerage, recommend_score, created_at, updated_at)",
+                        "name": "sort_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated directions (ASC, DESC) matching sort_by by position; missing ones default to DESC",
+                        "name": "sort_order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Items per page, between 1 and MAX_PAGE_SIZE (100 by default); other values are rejected",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Attach a prefetch_token to each item for use with GET /api/v1/stocks/{id}",
+                        "name": "prefetch",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.PaginatedSuccessResponse"
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "RFC 5988 links to the first, prev, next and last pages"
+                            },
+                            "X-Page": {
+                                "type": "integer",
+                                "description": "Page number served"
+                            },
+                            "X-Page-Size": {
+                                "type": "integer",
+                                "description": "Page size after clamping"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Total number of matching stocks"
+                            },
+                            "X-Total-Pages": {
+                                "type": "integer",
+                                "description": "Total number of pages"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters, or unknown ones when STRICT_QUERY_PARAMS=enforce",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stocks/changes": {
+            "get": {
+                "description": "List stocks with updated_at after since, oldest first, ties ordered by id. Pass next_since and next_after_id from the response as since and after_id on the next poll, so a batch ending among stocks that share updated_at resumes with the rest of them",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "List recently changed stocks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "RFC3339 timestamp, e.g. 2024-05-01T00:00:00Z",
+                        "name": "since",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the last stock seen at since; only stocks at since with a greater ID are listed",
+                        "name": "after_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum results (capped at 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ChangesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stocks/filters": {
+            "get": {
+                "description": "Get available filter options for stocks (brokerages, ratings, previous ratings, actions). With counts=true the response also carries each brokerage, rating and action with its number of stocks, narrowed by the same filter parameters as GET /api/v1/stocks; each facet ignores its own parameter so the alternatives stay visible",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get available filters",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only return brokerage/rating values starting with this prefix (case-insensitive)",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include per-value stock counts",
+                        "name": "counts",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Narrow the counts by ticker symbol",
+                        "name": "ticker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Narrow the counts by company name",
+                        "name": "company",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Narrow the counts by sector",
+                        "name": "sector",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Narrow the counts by industry",
+                        "name": "industry",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Narrow the rating and action counts by brokerage",
+                        "name": "brokerage",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Narrow the brokerage and action counts by rating",
+                        "name": "rating",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Narrow the brokerage and rating counts by action",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "positive",
+                            "negative",
+                            "neutral"
+                        ],
+                        "type": "string",
+                        "description": "Narrow the brokerage and rating counts by action sentiment",
+                        "name": "action_category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count stocks whose rating is not recognized",
+                        "name": "include_unrated",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/stockviewer.FiltersResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stocks/filters/{field}": {
+            "get": {
+                "description": "Get every distinct value of a filter field with the number of stocks that have it, most common first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Page through a filter field's values",
+                "parameters": [
+                    {
+                        "enum": [
+                            "brokerages",
+                            "ratings",
+                            "ratings_from"
+                        ],
+                        "type": "string",
+                        "description": "Filter field",
+                        "name": "field",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return values starting with this prefix (case-insensitive)",
+                        "name": "query",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of values (max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of values to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/stockviewer.FilterValues"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stocks/new": {
+            "get": {
+                "description": "List tickers whose first-ever entry was created during the most recent completed sync, or since the given timestamp, oldest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "List newly covered tickers",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Use the window of the most recent completed sync",
+                        "name": "since_last_sync",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC3339 timestamp; required when since_last_sync is false",
+                        "name": "since",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.NewTickersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stocks/query": {
+            "post": {
+                "description": "Same as GET /api/v1/stocks, but takes the filter as a JSON body so large filter sets do not have to fit in a URL. page_size must be between 1 and MAX_PAGE_SIZE (100 by default)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Query stocks",
+                "parameters": [
+                    {
+                        "description": "Stock filter",
+                        "name": "filter",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/stockviewer.StockFilter"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Attach a prefetch_token to each item for use with GET /api/v1/stocks/{id}",
+                        "name": "prefetch",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.PaginatedSuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Body over MAX_BODY_BYTES",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stocks/search": {
+            "get": {
+                "description": "Search stocks by ticker or company name. Whole words are matched with full-text search along with tickers starting with the query, ranked an exact ticker first, then ticker prefixes, then by relevance; when that finds nothing, as for a company fragment, the query is matched as a substring. With fuzzy=true, near matches by trigram similarity, or edit distance without pg_trgm, are included so typos still find results. Each result has a match_type of exact, text or fuzzy. An empty q is rejected unless SEARCH_EMPTY_QUERY=recent, which lists the newest entries instead",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Search stocks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search query; required unless SEARCH_EMPTY_QUERY=recent",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Maximum results",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include trigram-similar matches",
+                        "name": "fuzzy",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters, or unknown ones when STRICT_QUERY_PARAMS=enforce",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stocks/stats/actions": {
+            "get": {
+                "description": "Get the number of stocks per analyst action, most common first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Get action distribution",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/stockviewer.ActionDistribution"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stocks/stats/ratings": {
+            "get": {
+                "description": "Get the number of stocks per current rating, most common first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Get rating distribution",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/stockviewer.RatingDistribution"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stocks/summaries": {
+            "get": {
+                "description": "Get the price target consensus of up to 50 tickers in one call, computed with a single grouped query. Each summary is the same as GET /api/v1/tickers/{ticker}/consensus returns; summaries follow the order of tickers, upper-cased and without repeats, and a ticker without targets in the window has a count of 0",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get price target summaries for several tickers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated tickers, e.g. AAPL,MSFT",
+                        "name": "tickers",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 90,
+                        "description": "Window in days",
+                        "name": "days",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/stockviewer.TargetConsensus"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stocks/{id}": {
+            "get": {
+                "description": "Get detailed information about a specific stock",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get stock by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Stock ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Token from a list response; serves the row from the list snapshot while it is still valid",
+                        "name": "prefetch_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Manually set recommend_score (0-100) and/or notes. An overridden score is kept by later syncs. Other fields are rejected",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Override a stock's score or notes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Stock ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to override",
+                        "name": "update",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/stockviewer.StockUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/stockviewer.Stock"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the admin role",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Body over MAX_BODY_BYTES",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stocks/{ticker}/history": {
+            "get": {
+                "description": "List every analyst entry recorded for a ticker, across brokerages and dates, newest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get ticker history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ticker symbol",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Items per page, between 1 and MAX_PAGE_SIZE",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.PaginatedSuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Start a background sync from the external KarenAI API; poll GET /api/v1/sync/status for the result",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sync"
+                ],
+                "summary": "Sync stocks from external API",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Replays the response of an earlier accepted POST with the same key instead of starting another sync; at most 255 characters",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.SyncResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Idempotency-Key too long",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the admin role",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Sync already in progress",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync/runs": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "List the most recent persisted sync runs, newest first. Runs left in progress by an instance that died are reported as aborted",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sync"
+                ],
+                "summary": "List sync runs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Maximum runs (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/stockviewer.SyncRun"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync/status": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the state of the current or most recent sync (idle, in_progress, completed, partial, error or aborted). A partial sync saved the stocks received before the external API failed and reports that failure in error. When the external API failed, upstream_service and upstream_status name it and the HTTP status it answered with. After a restart this is the latest persisted run",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sync"
+                ],
+                "summary": "Get sync status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.SyncResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sync/stream": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Follow the running sync as Server-Sent Events. Each event's data is a JSON object: {\"event\":\"progress\",\"processed\":50,\"total\":200} after every saved batch, then {\"event\":\"completed\",\"status\":{...}} once the sync has finished, after which the stream closes. When no sync is running the completed event for the most recent one is sent right away",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "sync"
+                ],
+                "summary": "Stream sync progress",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.SyncProgressEvent"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tickers/{ticker}/consensus": {
+            "get": {
+                "description": "Get min, max, median, mean and spread of the analyst price targets for a ticker over a recent window; entries without a target are excluded. momentum tells whether the targets are rising or falling, ordered by when each entry was first observed, with magnitude as the trend's percent change of the mean target per 30 days; it is omitted with fewer than two targets",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get price target consensus for a ticker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ticker symbol",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 90,
+                        "description": "Window in days",
+                        "name": "days",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpapi.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/stockviewer.TargetConsensus"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/views": {
+            "get": {
+                "description": "List the built-in and admin-defined saved views",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "views"
+                ],
+                "summary": "List saved views",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.SuccessResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Store an admin-defined view. The filter is validated against the same rules as POST /api/v1/stocks/query; built-in slugs are reserved",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "views"
+                ],
+                "summary": "Create or replace a saved view",
+                "parameters": [
+                    {
+                        "description": "View definition",
+                        "name": "view",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/stockviewer.SavedView"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the admin role",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Body over MAX_BODY_BYTES",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/views/{slug}": {
+            "get": {
+                "description": "Run a saved view's filter, sort and page size and return the matching stocks with the view's title and description",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "views"
+                ],
+                "summary": "Resolve a saved view",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "View slug",
+                        "name": "slug",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ViewResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replace an admin-defined view. The slug in the path takes precedence over the body",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "views"
+                ],
+                "summary": "Update a saved view",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "View slug",
+                        "name": "slug",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "View definition",
+                        "name": "view",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/stockviewer.SavedView"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the admin role",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Body over MAX_BODY_BYTES",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Remove an admin-defined view. Built-in views cannot be deleted",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "views"
+                ],
+                "summary": "Delete a saved view",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "View slug",
+                        "name": "slug",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the admin role",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Reports that the process is up without touching the database; use /ready to know whether it can serve traffic. sync_success is the share of sync runs in the rolling SYNC_SUCCESS_WINDOW that completed, as computed after the last sync; it is omitted until one has been computed and its rate is null when no run finished in the window",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.SuccessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ping": {
+            "get": {
+                "description": "Returns pong to verify the service is running",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Health check endpoint",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.SuccessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ready": {
+            "get": {
+                "description": "Reports whether the instance can serve traffic: the database must answer a ping and its schema must be within the range this binary supports",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.SuccessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httpapi.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "httpapi.ChangesResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.Stock"
+                    }
+                },
+                "next_after_id": {
+                    "type": "string"
+                },
+                "next_since": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpapi.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpapi.NewTickersResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.NewTicker"
+                    }
+                },
+                "from": {
+                    "type": "string",
+                    "example": "2024-05-01T12:00:00Z"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "2024-05-01T12:03:10Z"
+                }
+            }
+        },
+        "httpapi.PaginatedRecommendationResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.StockRecommendation"
+                    }
+                },
+                "has_next": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "has_prev": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "next_page": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "page": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "page_size": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "prev_page": {
+                    "type": "integer"
+                },
+                "total_items": {
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 100
+                },
+                "total_pages": {
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 10
+                }
+            }
+        },
+        "httpapi.PaginatedSuccessResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.Stock"
+                    }
+                },
+                "has_next": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "has_prev": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "next_page": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "page": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "page_size": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "prev_page": {
+                    "type": "integer"
+                },
+                "total_items": {
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 1250
+                },
+                "total_pages": {
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 63
+                }
+            }
+        },
+        "httpapi.SuccessResponse": {
+            "type": "object",
+            "properties": {
+                "data": {},
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpapi.SyncProgressEvent": {
+            "type": "object",
+            "properties": {
+                "event": {
+                    "type": "string",
+                    "example": "progress"
+                },
+                "processed": {
+                    "type": "integer",
+                    "example": 50
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 200
+                }
+            }
+        },
+        "httpapi.SyncResponse": {
+            "type": "object",
+            "properties": {
+                "duplicate_records": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "last_sync": {
+                    "type": "string"
+                },
+                "new_records": {
+                    "type": "integer"
+                },
+                "schema_warnings": {
+                    "description": "SchemaWarnings counts entries quarantined for unusable field shapes,\nkeyed by \"field:shape\".",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total_records": {
+                    "type": "integer"
+                },
+                "updated_records": {
+                    "type": "integer"
+                },
+                "upstream_service": {
+                    "description": "UpstreamService and UpstreamStatus name the external API that failed\nthe sync and the HTTP status it answered with, so upstream outages are\ntold apart from ours.",
+                    "type": "string",
+                    "example": "karenai"
+                },
+                "upstream_status": {
+                    "type": "integer",
+                    "example": 503
+                }
+            }
+        },
+        "httpapi.TokenResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string",
+                    "example": "2024-05-01T13:00:00Z"
+                },
+                "expires_in": {
+                    "description": "ExpiresIn is the token's remaining lifetime in seconds.",
+                    "type": "integer",
+                    "example": 3600
+                },
+                "role": {
+                    "type": "string",
+                    "example": "admin"
+                },
+                "token_type": {
+                    "type": "string",
+                    "example": "Bearer"
+                }
+            }
+        },
+        "httpapi.ViewResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.Stock"
+                    }
+                },
+                "has_next": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "has_prev": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "next_page": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "page": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "page_size": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "prev_page": {
+                    "type": "integer"
+                },
+                "total_items": {
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 1250
+                },
+                "total_pages": {
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 63
+                },
+                "view": {
+                    "$ref": "#/definitions/stockviewer.SavedView"
+                }
+            }
+        },
+        "stockviewer.ActionDistribution": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "stockviewer.BrokerageStats": {
+            "type": "object",
+            "properties": {
+                "avg_score": {
+                    "type": "number"
+                },
+                "brokerage": {
+                    "type": "string"
+                },
+                "buy_count": {
+                    "type": "integer"
+                },
+                "hold_count": {
+                    "type": "integer"
+                },
+                "sell_count": {
+                    "type": "integer"
+                },
+                "total_recommendations": {
+                    "type": "integer"
+                }
+            }
+        },
+        "stockviewer.DataIssue": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "raw": {
+                    "type": "string"
+                },
+                "shape": {
+                    "type": "string"
+                }
+            }
+        },
+        "stockviewer.DataIssueCount": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "shape": {
+                    "type": "string"
+                }
+            }
+        },
+        "stockviewer.DataQualityReport": {
+            "type": "object",
+            "properties": {
+                "issues": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.DataIssueCount"
+                    }
+                },
+                "last_sync_warnings": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "quarantined_stocks": {
+                    "type": "integer"
+                },
+                "stocks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.Stock"
+                    }
+                }
+            }
+        },
+        "stockviewer.FilterCounts": {
+            "type": "object",
+            "properties": {
+                "actions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.ValueCount"
+                    }
+                },
+                "brokerages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.ValueCount"
+                    }
+                },
+                "ratings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.ValueCount"
+                    }
+                }
+            }
+        },
+        "stockviewer.FilterHasMore": {
+            "type": "object",
+            "properties": {
+                "brokerages": {
+                    "type": "boolean"
+                },
+                "ratings": {
+                    "type": "boolean"
+                },
+                "ratings_from": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "stockviewer.FilterValues": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "has_more": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "values": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.ValueCount"
+                    }
+                }
+            }
+        },
+        "stockviewer.FiltersResponse": {
+            "type": "object",
+            "properties": {
+                "actions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "brokerages": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "counts": {
+                    "description": "Counts is only filled in when FiltersQuery.Counts is set.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/stockviewer.FilterCounts"
+                        }
+                    ]
+                },
+                "has_more": {
+                    "$ref": "#/definitions/stockviewer.FilterHasMore"
+                },
+                "ratings": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "ratings_from": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
             }
         },
-        "httpapi.SuccessResponse": {
+        "stockviewer.MatchType": {
+            "type": "string",
+            "enum": [
+                "exact",
+                "text",
+                "fuzzy"
+            ],
+            "x-enum-varnames": [
+                "MatchExact",
+                "MatchText",
+                "MatchFuzzy"
+            ]
+        },
+        "stockviewer.MomentumDirection": {
+            "type": "string",
+            "enum": [
+                "rising",
+                "falling",
+                "flat"
+            ],
+            "x-enum-varnames": [
+                "MomentumRising",
+                "MomentumFalling",
+                "MomentumFlat"
+            ]
+        },
+        "stockviewer.NewTicker": {
             "type": "object",
             "properties": {
-                "data": {},
-                "message": {
+                "company": {
+                    "type": "string"
+                },
+                "first_seen": {
+                    "type": "string"
+                },
+                "ticker": {
                     "type": "string"
                 }
             }
         },
-        "httpapi.SyncResponse": {
+        "stockviewer.RatingDistribution": {
             "type": "object",
             "properties": {
-                "last_sync": {
+                "count": {
+                    "type": "integer"
+                },
+                "rating": {
+                    "type": "string"
+                }
+            }
+        },
+        "stockviewer.SavedView": {
+            "type": "object",
+            "properties": {
+                "built_in": {
+                    "type": "boolean"
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "new_records": {
-                    "type": "integer"
+                "description": {
+                    "type": "string"
                 },
-                "status": {
+                "filter": {
+                    "$ref": "#/definitions/stockviewer.StockFilter"
+                },
+                "slug": {
                     "type": "string"
                 },
-                "total_records": {
+                "title": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "stockviewer.ScoreBreakdown": {
+            "type": "object",
+            "properties": {
+                "action_component": {
+                    "type": "number"
+                },
+                "price_target_component": {
+                    "type": "number"
+                },
+                "rating_component": {
+                    "type": "number"
+                },
+                "recency_factor": {
+                    "type": "number"
+                }
+            }
+        },
+        "stockviewer.ScoreBucket": {
+            "type": "object",
+            "properties": {
+                "bucket_max": {
+                    "type": "number"
+                },
+                "bucket_min": {
+                    "type": "number"
+                },
+                "count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "stockviewer.StatsResponse": {
+            "type": "object",
+            "properties": {
+                "avg_recommend_score": {
+                    "type": "number"
+                },
+                "distinct_brokerages": {
                     "type": "integer"
                 },
-                "updated_records": {
+                "distinct_tickers": {
+                    "type": "integer"
+                },
+                "last_sync": {
+                    "type": "string"
+                },
+                "total_stocks": {
                     "type": "integer"
                 }
             }
@@ -450,9 +2779,34 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "data_issues": {
+                    "description": "DataIssues lists the fields of a quarantined entry that the feed sent\nin an unusable shape; it is empty for clean entries.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stockviewer.DataIssue"
+                    }
+                },
                 "id": {
                     "type": "string"
                 },
+                "industry": {
+                    "type": "string"
+                },
+                "match_type": {
+                    "description": "MatchType is set on search results only.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/stockviewer.MatchType"
+                        }
+                    ]
+                },
+                "notes": {
+                    "description": "Notes and ScoreOverridden are set by analysts through the admin API;\nsyncs keep them, and keep RecommendScore while it is overridden.",
+                    "type": "string"
+                },
+                "prefetch_token": {
+                    "type": "string"
+                },
                 "rating_from": {
                     "type": "string"
                 },
@@ -462,7 +2816,15 @@ const docTemplate = `{
                 "recommend_score": {
                     "type": "number"
                 },
+                "score_overridden": {
+                    "type": "boolean"
+                },
+                "sector": {
+                    "description": "Sector and Industry come from the optional enrichment step of a sync;\nthey are empty when enrichment is disabled or has nothing for the\nticker.",
+                    "type": "string"
+                },
                 "target_from": {
+                    "description": "TargetFrom and TargetTo are nil when the feed sent a value that could\nnot be parsed; DataIssues then says what was received. Zero means the\nfeed had no target.",
                     "type": "number"
                 },
                 "target_to": {
@@ -473,13 +2835,257 @@ const docTemplate = `{
                 },
                 "updated_at": {
                     "type": "string"
+                },
+                "upside_percent": {
+                    "description": "UpsidePercent is derived from the targets when the stock is encoded;\nit is omitted when there is no previous target to compare against.",
+                    "type": "number"
+                }
+            }
+        },
+        "stockviewer.StockFilter": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "action_category": {
+                    "type": "string"
+                },
+                "actions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "brokerage": {
+                    "type": "string"
+                },
+                "company": {
+                    "type": "string"
+                },
+                "group_by": {
+                    "type": "string"
+                },
+                "include_unrated": {
+                    "type": "boolean"
+                },
+                "industry": {
+                    "type": "string"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "page_size": {
+                    "type": "integer"
+                },
+                "rating": {
+                    "type": "string"
+                },
+                "rating_from": {
+                    "type": "string"
+                },
+                "sector": {
+                    "type": "string"
+                },
+                "sort_by": {
+                    "type": "string"
+                },
+                "sort_order": {
+                    "type": "string"
+                },
+                "ticker": {
+                    "type": "string"
+                },
+                "ticker_exact": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "stockviewer.StockRecommendation": {
+            "type": "object",
+            "properties": {
+                "breakdown": {
+                    "description": "Breakdown is omitted from compact responses.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/stockviewer.ScoreBreakdown"
+                        }
+                    ]
+                },
+                "cold_start": {
+                    "description": "ColdStart marks a ticker with too little history for its score to be\ncompared with established ones; see TickerCoverage.",
+                    "type": "boolean"
+                },
+                "confidence": {
+                    "description": "Confidence grades from 0 to 1 how much data backs Score: a recognized\nrating, both price targets, the number of distinct brokerages covering\nthe ticker and how closely their recent price targets agree. A high\nScore with low Confidence rests on little or conflicting data.",
+                    "type": "number"
+                },
+                "rank": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "score": {
+                    "type": "number"
+                },
+                "stock": {
+                    "$ref": "#/definitions/stockviewer.Stock"
+                },
+                "tier": {
+                    "description": "Tier grades Score from A (80 and up) to F (below 35).",
+                    "type": "string"
+                }
+            }
+        },
+        "stockviewer.StockUpdate": {
+            "type": "object",
+            "properties": {
+                "notes": {
+                    "type": "string"
+                },
+                "recommend_score": {
+                    "type": "number"
+                }
+            }
+        },
+        "stockviewer.SyncRun": {
+            "type": "object",
+            "properties": {
+                "duplicate_records": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "heartbeat_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "instance_id": {
+                    "type": "string"
+                },
+                "new_records": {
+                    "type": "integer"
+                },
+                "note": {
+                    "description": "Note explains a status set by someone other than the run itself, such\nas an abort during startup recovery.",
+                    "type": "string"
+                },
+                "schema_warnings": {
+                    "description": "SchemaWarnings is SyncStatus.SchemaWarnings of the finished run.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total_records": {
+                    "type": "integer"
+                },
+                "updated_records": {
+                    "type": "integer"
+                },
+                "upstream_service": {
+                    "description": "UpstreamService and UpstreamStatus are those of SyncStatus.",
+                    "type": "string"
+                },
+                "upstream_status": {
+                    "type": "integer"
+                }
+            }
+        },
+        "stockviewer.TargetConsensus": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "max": {
+                    "type": "number"
+                },
+                "mean": {
+                    "type": "number"
+                },
+                "median": {
+                    "type": "number"
+                },
+                "min": {
+                    "type": "number"
+                },
+                "momentum": {
+                    "description": "Momentum is omitted when the window has fewer than two targets\nobserved at different times.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/stockviewer.TargetMomentum"
+                        }
+                    ]
+                },
+                "spread": {
+                    "type": "number"
+                },
+                "ticker": {
+                    "type": "string"
+                },
+                "window_days": {
+                    "type": "integer"
+                }
+            }
+        },
+        "stockviewer.TargetMomentum": {
+            "type": "object",
+            "properties": {
+                "direction": {
+                    "$ref": "#/definitions/stockviewer.MomentumDirection"
+                },
+                "entries": {
+                    "type": "integer"
+                },
+                "magnitude": {
+                    "type": "number"
+                }
+            }
+        },
+        "stockviewer.ValueCount": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "value": {
+                    "type": "string"
                 }
             }
         }
     },
     "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "A static key from API_KEYS",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
         "BasicAuth": {
             "type": "basic"
+        },
+        "BearerAuth": {
+            "description": "\"Bearer \u003ctoken\u003e\" from POST /api/v1/auth/token; only with AUTH_MODE=jwt",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
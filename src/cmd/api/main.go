@@ -10,17 +10,27 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/authtoken"
 	"github.com/user/go-stock-viewer-back/src/stockviewer/config"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/deadletters"
 	"github.com/user/go-stock-viewer-back/src/stockviewer/httpapi"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/idempotency"
 	"github.com/user/go-stock-viewer-back/src/stockviewer/integrations/karenai"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/outbox"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/prefetch"
 	"github.com/user/go-stock-viewer-back/src/stockviewer/recommendation"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/scheduler"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/schema"
 	"github.com/user/go-stock-viewer-back/src/stockviewer/stocks"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/syncprogress"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/syncruns"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/tracing"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/views"
 
 	_ "github.com/user/go-stock-viewer-back/docs"
 )
@@ -41,35 +51,176 @@ import (
 
 // @securityDefinitions.basic  BasicAuth
 
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 "Bearer <token>" from POST /api/v1/auth/token; only with AUTH_MODE=jwt
+
+// @securityDefinitions.apikey  ApiKeyAuth
+// @in                          header
+// @name                        X-API-Key
+// @description                 A static key from API_KEYS
+
 func main() {
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.OTLPEndpoint)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
 
 	db, err := initDatabase(cfg.Database)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	stocksStorage, err := stocks.NewStorage(db)
+	pageLimits := stockviewer.PageLimits{
+		DefaultPageSize:          cfg.Server.DefaultPageSize,
+		MaxPageSize:              cfg.Server.MaxPageSize,
+		AuthenticatedMaxPageSize: cfg.Server.AuthenticatedMaxPageSize,
+	}
+
+	schemaStorage, err := schema.NewStorage(db)
 	if err != nil {
-		log.Fatalf("Failed to initialize stocks storage: %v", err)
+		log.Fatalf("Failed to initialize schema storage: %v", err)
 	}
+	migrator := schema.NewMigrator(
+		schemaStorage,
+		schema.Migrations(),
+		schema.Supported,
+		schema.WithAllowContract(cfg.Database.AllowContractMigrations),
+	)
+	if err := migrator.Migrate(context.Background()); err != nil {
+		log.Fatalf("Database schema is not compatible with this binary: %v", err)
+	}
+
+	stocksStorage := stocks.NewStorage(
+		db,
+		stocks.WithStoragePageLimits(pageLimits),
+		stocks.WithTrigramThreshold(cfg.Server.SearchTrigramThreshold),
+	)
+	outboxStorage := outbox.NewStorage(db)
+	viewsStorage := views.NewStorage(db)
+	syncRunsStorage := syncruns.NewStorage(db)
+	deadLettersStorage := deadletters.NewStorage(db)
 
 	karenaiClient := karenai.NewClient(
 		cfg.External.KarenAIBaseURL,
 		cfg.External.KarenAIToken,
+		karenai.WithFallbackBaseURL(cfg.External.KarenAIFallbackBaseURL),
+		karenai.WithErrorBodyLimit(cfg.External.ErrorBodyLimit),
+		karenai.WithMaxPages(cfg.External.KarenAIMaxPages),
+		karenai.WithDebug(cfg.Server.Mode == "debug"),
 	)
 
-	stocksService := stocks.NewService(stocksStorage, karenaiClient)
-	recommendationService := recommendation.NewService(stocksStorage)
+	scoreWeights := recommendation.ScoreWeights{
+		Rating:      cfg.Recommendation.RatingWeight,
+		Action:      cfg.Recommendation.ActionWeight,
+		PriceTarget: cfg.Recommendation.PriceTargetWeight,
+	}
+	if err := scoreWeights.Validate(); err != nil {
+		log.Fatalf("Invalid recommendation weights: %v", err)
+	}
+	priceTargetBaseline, err := recommendation.ParsePriceTargetBaseline(cfg.Recommendation.PriceTargetBaseline)
+	if err != nil {
+		log.Fatalf("Invalid RECOMMENDATION_PRICE_TARGET_BASELINE: %v", err)
+	}
+	scoreRounding, ok := stockviewer.ParseRounding(cfg.Recommendation.ScoreRounding)
+	if !ok {
+		log.Fatalf("Invalid SCORE_ROUNDING %q: must be half_up, half_even or floor", cfg.Recommendation.ScoreRounding)
+	}
+
+	recommendationService := recommendation.NewService(
+		stocksStorage,
+		recommendation.WithScoreWeights(scoreWeights),
+		recommendation.WithLatestPerTicker(cfg.Recommendation.LatestPerTicker),
+		recommendation.WithRecencyDecayDays(cfg.Recommendation.RecencyDecayDays),
+		recommendation.WithTieEpsilon(cfg.Recommendation.TieEpsilon),
+		recommendation.WithPriceTargetBaseline(priceTargetBaseline),
+		recommendation.WithRounding(scoreRounding),
+		recommendation.WithColdStart(cfg.Recommendation.ColdStartMinRecords, cfg.Recommendation.ColdStartMinDays),
+		recommendation.WithCacheTTL(cfg.Recommendation.CacheTTL),
+	)
+
+	syncProgress := syncprogress.NewBroadcaster()
+	stocksOptions := []stocks.Option{
+		stocks.WithMaxFilterValues(cfg.Server.MaxFilterValues),
+		stocks.WithExcludeUnrated(cfg.Server.ExcludeUnrated),
+		stocks.WithPageLimits(pageLimits),
+		stocks.WithSaveRetry(cfg.Sync.SaveRetries, cfg.Sync.SaveBackoff),
+		stocks.WithSyncRuns(syncRunsStorage, cfg.Sync.InstanceID, cfg.Sync.HeartbeatInterval),
+		stocks.WithSuccessRateWindow(cfg.Sync.SuccessRateWindow),
+		stocks.WithSyncProgress(syncProgress.Publish),
+		stocks.WithCacheInvalidation(recommendationService),
+		stocks.WithDeadLetters(deadLettersStorage),
+		stocks.WithRounding(scoreRounding),
+	}
+	if cfg.Sync.EnableEnrichment {
+		// No sector/industry source is integrated yet; the no-op enricher
+		// keeps the step in place until one is.
+		stocksOptions = append(stocksOptions, stocks.WithEnricher(stocks.NoopEnricher{}))
+	}
+	stocksService := stocks.NewService(stocksStorage, karenaiClient, stocksOptions...)
+	if _, err := stocksService.RecoverAbandonedSyncs(context.Background()); err != nil {
+		log.Printf("Failed to recover abandoned sync runs: %v", err)
+	}
+
+	viewsService := views.NewService(
+		viewsStorage,
+		stocksService,
+		views.WithCacheTTL(cfg.Views.CacheTTL),
+		views.WithPageLimits(pageLimits),
+	)
+
+	var prefetchStore *prefetch.Store
+	if cfg.Prefetch.Secret != "" {
+		prefetchStore = prefetch.NewStore(cfg.Prefetch.Secret, cfg.Prefetch.TTL)
+	}
+
+	var tokens *authtoken.Signer
+	if cfg.Auth.Mode == string(httpapi.AuthModeJWT) {
+		tokens = authtoken.NewSigner(cfg.Auth.JWTSecret, cfg.Auth.JWTTTL)
+	}
+
+	apiKeys := make([]httpapi.APIKey, 0, len(cfg.Auth.APIKeys))
+	for _, apiKey := range cfg.Auth.APIKeys {
+		apiKeys = append(apiKeys, httpapi.APIKey{Key: apiKey.Key, Role: stockviewer.Role(apiKey.Role)})
+	}
+
+	var idempotencyStore *idempotency.Store
+	if cfg.Sync.IdempotencyTTL > 0 {
+		idempotencyStore = idempotency.NewStore(cfg.Sync.IdempotencyTTL, cfg.Sync.IdempotencyKeys)
+	}
 
 	api := httpapi.New(httpapi.Config{
-		StocksService:         stocksService,
-		RecommendationService: recommendationService,
-		BasicAuthUser:         cfg.Auth.Username,
-		BasicAuthPassword:     cfg.Auth.Password,
+		StocksService:            stocksService,
+		RecommendationService:    recommendationService,
+		ViewsService:             viewsService,
+		BasicAuthUser:            cfg.Auth.Username,
+		BasicAuthPassword:        cfg.Auth.Password,
+		AdminUser:                cfg.Auth.AdminUsername,
+		AdminPassword:            cfg.Auth.AdminPassword,
+		BasicAuthRealm:           cfg.Auth.Realm,
+		AuthMode:                 httpapi.AuthMode(cfg.Auth.Mode),
+		Tokens:                   tokens,
+		APIKeys:                  apiKeys,
+		Prefetch:                 prefetchStore,
+		DefaultPageSize:          cfg.Server.DefaultPageSize,
+		MaxPageSize:              cfg.Server.MaxPageSize,
+		AuthenticatedMaxPageSize: cfg.Server.AuthenticatedMaxPageSize,
+		MaxBodyBytes:             cfg.Server.MaxBodyBytes,
+		ReadinessCheck:           migrator.Check,
+		SwaggerEnabled:           cfg.Server.SwaggerEnabled,
+		StrictQuery:              httpapi.StrictQueryMode(cfg.Server.StrictQueryParams),
+		EmptySearch:              httpapi.EmptySearchMode(cfg.Server.SearchEmptyQuery),
+		SyncProgress:             syncProgress,
+		Idempotency:              idempotencyStore,
 	})
 
 	gin.SetMode(cfg.Server.Mode)
@@ -77,8 +228,6 @@ func main() {
 
 	api.ConfigureRoutes(router)
 
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-
 	server := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
 		Handler:      router,
@@ -88,24 +237,57 @@ func main() {
 
 	go func() {
 		log.Printf("Starting server on port %s", cfg.Server.Port)
-		log.Printf("Swagger docs available at http://localhost:%s/swagger/index.html", cfg.Server.Port)
+		if cfg.Server.SwaggerEnabled {
+			log.Printf("Swagger docs available at http://localhost:%s/swagger/index.html", cfg.Server.Port)
+		}
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
 
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	schedulerDone := make(chan struct{})
+	if cfg.Sync.Interval > 0 {
+		go func() {
+			defer close(schedulerDone)
+			scheduler.New(stocksService, cfg.Sync.Interval).Run(schedulerCtx)
+		}()
+	} else {
+		close(schedulerDone)
+	}
+
+	outboxWorker := outbox.NewWorker(outboxStorage, 10*time.Second, 5)
+	outboxWorker.Handle(stockviewer.EventSyncCompleted, func(ctx context.Context, event stockviewer.OutboxEvent) error {
+		log.Printf("Sync completed: %s", event.Payload)
+		return nil
+	})
+	outboxCtx, stopOutbox := context.WithCancel(context.Background())
+	outboxDone := make(chan struct{})
+	go func() {
+		defer close(outboxDone)
+		outboxWorker.Run(outboxCtx)
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	log.Println("Shutting down server...")
 
+	stopScheduler()
+	<-schedulerDone
+	stopOutbox()
+	<-outboxDone
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
 
 	log.Println("Server exited properly")
 }
@@ -118,11 +300,18 @@ func initDatabase(cfg config.DatabaseConfig) (*gorm.DB, error) {
 	for i := 0; i < maxRetries; i++ {
 		db, err = gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
 			Logger: logger.Default.LogMode(logger.Info),
+			NowFunc: func() time.Time {
+				return time.Now().UTC()
+			},
 		})
 		if err == nil {
 			sqlDB, err := db.DB()
 			if err == nil && sqlDB.Ping() == nil {
-				log.Println("Database connection established")
+				sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+				sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+				sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime())
+				log.Printf("Database connection established (pool: %d open, %d idle, %s lifetime)",
+					cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime())
 				return db, nil
 			}
 		}
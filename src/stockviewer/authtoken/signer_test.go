@@ -0,0 +1,77 @@
+package authtoken
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func newTestSigner(now *time.Time) *Signer {
+	signer := NewSigner(testSecret, time.Hour)
+	signer.now = func() time.Time { return *now }
+	return signer
+}
+
+func TestVerify_IssuedToken(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	signer := newTestSigner(&now)
+
+	token, err := signer.Issue("admin", "admin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !token.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected expiry %v, got %v", now.Add(time.Hour), token.ExpiresAt)
+	}
+
+	claims, err := signer.Verify(token.Value)
+	if err != nil {
+		t.Fatalf("expected token to verify, got %v", err)
+	}
+	if claims.Subject != "admin" || claims.IssuedAt != now.Unix() || claims.Role != "admin" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestVerify_Expired(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	signer := newTestSigner(&now)
+
+	token, _ := signer.Issue("admin", "admin")
+	now = now.Add(time.Hour)
+
+	if _, err := signer.Verify(token.Value); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("expected ErrExpiredToken, got %v", err)
+	}
+}
+
+func TestVerify_Rejected(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	signer := newTestSigner(&now)
+	token, _ := signer.Issue("admin", "admin")
+	parts := strings.Split(token.Value, ".")
+
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"root","iat":0,"exp":9999999999}`))
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	other, _ := NewSigner("another-secret-another-secret-xx", time.Hour).Issue("admin", "admin")
+
+	tests := map[string]string{
+		"empty":           "",
+		"not a jwt":       "abc",
+		"altered payload": parts[0] + "." + forged + "." + parts[2],
+		"alg none":        none + "." + parts[1] + ".",
+		"other secret":    other.Value,
+		"bad signature":   parts[0] + "." + parts[1] + ".!!!",
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := signer.Verify(value); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+}
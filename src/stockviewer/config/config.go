@@ -1,42 +1,265 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	External ExternalConfig
-	Auth     AuthConfig
+	Server         ServerConfig         `yaml:"server"`
+	Database       DatabaseConfig       `yaml:"database"`
+	External       ExternalConfig       `yaml:"external"`
+	Auth           AuthConfig           `yaml:"auth"`
+	Sync           SyncConfig           `yaml:"sync"`
+	Prefetch       PrefetchConfig       `yaml:"prefetch"`
+	Views          ViewsConfig          `yaml:"views"`
+	Recommendation RecommendationConfig `yaml:"recommendation"`
+	Tracing        TracingConfig        `yaml:"tracing"`
 }
 
 type ServerConfig struct {
-	Port         string
-	Mode         string
-	ReadTimeout  int
-	WriteTimeout int
+	Port            string `yaml:"port"`
+	Mode            string `yaml:"mode"`
+	ReadTimeout     int    `yaml:"read_timeout"`
+	WriteTimeout    int    `yaml:"write_timeout"`
+	MaxFilterValues int    `yaml:"max_filter_values"`
+	ExcludeUnrated  bool   `yaml:"exclude_unrated"`
+	DefaultPageSize int    `yaml:"default_page_size"`
+	MaxPageSize     int    `yaml:"max_page_size"`
+	// AuthenticatedMaxPageSize is the page size cap for callers with valid
+	// credentials; zero or less keeps MaxPageSize.
+	AuthenticatedMaxPageSize int `yaml:"authenticated_max_page_size"`
+	// MaxBodyBytes caps request bodies; zero or less uses the HTTP layer's
+	// default of 1MB.
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+	// SwaggerEnabled serves the Swagger UI; it defaults to on outside
+	// release mode.
+	SwaggerEnabled bool `yaml:"swagger_enabled"`
+	// SearchTrigramThreshold is the minimum pg_trgm similarity for
+	// fuzzy=true searches.
+	SearchTrigramThreshold float64 `yaml:"search_trigram_threshold"`
+	// StrictQueryParams is what list, search and recommendation requests do
+	// with query parameters they do not declare: off, log or enforce.
+	StrictQueryParams string `yaml:"strict_query_params"`
+	// SearchEmptyQuery is what a search with an empty q does: reject
+	// answers 400 and recent lists the newest entries.
+	SearchEmptyQuery string `yaml:"search_empty_query"`
+}
+
+// Validate checks the server settings.
+func (s ServerConfig) Validate() error {
+	switch s.StrictQueryParams {
+	case "off", "log", "enforce":
+	default:
+		return fmt.Errorf("STRICT_QUERY_PARAMS must be one of off, log, enforce, got %q", s.StrictQueryParams)
+	}
+	switch s.SearchEmptyQuery {
+	case "reject", "recent":
+	default:
+		return fmt.Errorf("SEARCH_EMPTY_QUERY must be one of reject, recent, got %q", s.SearchEmptyQuery)
+	}
+	return nil
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"name"`
+	SSLMode  string `yaml:"ssl_mode"`
+	// AllowContractMigrations lets startup apply migrations that break
+	// binaries from earlier releases.
+	AllowContractMigrations bool `yaml:"allow_contract_migrations"`
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetimeSeconds size the
+	// connection pool; idle connections are capped by open ones.
+	MaxOpenConns           int `yaml:"max_open_conns"`
+	MaxIdleConns           int `yaml:"max_idle_conns"`
+	ConnMaxLifetimeSeconds int `yaml:"conn_max_lifetime_seconds"`
+}
+
+// Validate checks the connection pool settings.
+func (d DatabaseConfig) Validate() error {
+	if d.MaxOpenConns <= 0 {
+		return fmt.Errorf("DB_MAX_OPEN_CONNS must be positive, got %d", d.MaxOpenConns)
+	}
+	if d.MaxIdleConns <= 0 {
+		return fmt.Errorf("DB_MAX_IDLE_CONNS must be positive, got %d", d.MaxIdleConns)
+	}
+	if d.MaxIdleConns > d.MaxOpenConns {
+		return fmt.Errorf("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", d.MaxIdleConns, d.MaxOpenConns)
+	}
+	if d.ConnMaxLifetimeSeconds <= 0 {
+		return fmt.Errorf("DB_CONN_MAX_LIFETIME must be positive, got %d", d.ConnMaxLifetimeSeconds)
+	}
+	return nil
+}
+
+// ConnMaxLifetime is ConnMaxLifetimeSeconds as a duration.
+func (d DatabaseConfig) ConnMaxLifetime() time.Duration {
+	return time.Duration(d.ConnMaxLifetimeSeconds) * time.Second
 }
 
 type ExternalConfig struct {
-	KarenAIBaseURL string
-	KarenAIToken   string
+	KarenAIBaseURL         string `yaml:"karenai_base_url"`
+	KarenAIToken           string `yaml:"karenai_token"`
+	KarenAIFallbackBaseURL string `yaml:"karenai_fallback_base_url"`
+	// KarenAIMaxPages caps how many pages a sync reads, between 1 and
+	// MaxKarenAIPages.
+	KarenAIMaxPages int `yaml:"karenai_max_pages"`
+	ErrorBodyLimit  int `yaml:"error_body_limit"`
+}
+
+// MaxKarenAIPages is the largest accepted KARENAI_MAX_PAGES.
+const MaxKarenAIPages = 10000
+
+// Validate checks the external API settings.
+func (e ExternalConfig) Validate() error {
+	if e.KarenAIMaxPages < 1 || e.KarenAIMaxPages > MaxKarenAIPages {
+		return fmt.Errorf("KARENAI_MAX_PAGES must be between 1 and %d, got %d", MaxKarenAIPages, e.KarenAIMaxPages)
+	}
+	return nil
 }
 
 type AuthConfig struct {
-	Username string
-	Password string
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// AdminUsername and AdminPassword are an optional second credential
+	// set. Once set, it alone may change data and Username becomes a
+	// read-only viewer; until then Username is the admin.
+	AdminUsername string `yaml:"admin_username"`
+	AdminPassword string `yaml:"admin_password"`
+	// Realm is sent in the WWW-Authenticate header of 401 responses.
+	Realm string `yaml:"realm"`
+	// Mode is how protected routes authenticate: basic or jwt. With jwt,
+	// POST /auth/token exchanges the basic credentials for a token signed
+	// with JWTSecret that lasts JWTTTL.
+	Mode      string        `yaml:"mode"`
+	JWTSecret string        `yaml:"jwt_secret"`
+	JWTTTL    time.Duration `yaml:"jwt_ttl"`
+	// APIKeys are static keys protected routes accept in the X-API-Key
+	// header, whatever the Mode.
+	APIKeys []APIKeyConfig `yaml:"api_keys"`
+}
+
+// APIKeyConfig is one API_KEYS entry, "key" or "key:role"; the role is
+// viewer unless it says admin.
+type APIKeyConfig struct {
+	Key  string `yaml:"key"`
+	Role string `yaml:"role"`
+}
+
+// minAPIKeyLength keeps guessable keys out of API_KEYS.
+const minAPIKeyLength = 16
+
+// minJWTSecretLength matches authtoken.MinSecretLength.
+const minJWTSecretLength = 32
+
+// Validate checks the auth settings.
+func (a AuthConfig) Validate() error {
+	if (a.AdminUsername == "") != (a.AdminPassword == "") {
+		return fmt.Errorf("ADMIN_AUTH_USER and ADMIN_AUTH_PASSWORD must be set together")
+	}
+	if a.AdminUsername != "" && a.AdminUsername == a.Username {
+		return fmt.Errorf("ADMIN_AUTH_USER must differ from BASIC_AUTH_USER")
+	}
+
+	seen := make(map[string]bool, len(a.APIKeys))
+	for _, apiKey := range a.APIKeys {
+		if len(apiKey.Key) < minAPIKeyLength {
+			return fmt.Errorf("API_KEYS entries must be at least %d characters", minAPIKeyLength)
+		}
+		if apiKey.Role != "admin" && apiKey.Role != "viewer" {
+			return fmt.Errorf("API_KEYS roles must be admin or viewer, got %q", apiKey.Role)
+		}
+		if seen[apiKey.Key] {
+			return fmt.Errorf("API_KEYS lists a key more than once")
+		}
+		seen[apiKey.Key] = true
+	}
+
+	switch a.Mode {
+	case "basic":
+		return nil
+	case "jwt":
+		if len(a.JWTSecret) < minJWTSecretLength {
+			return fmt.Errorf("JWT_SECRET must be at least %d bytes when AUTH_MODE=jwt", minJWTSecretLength)
+		}
+		if a.JWTTTL <= 0 {
+			return fmt.Errorf("JWT_TTL must be positive, got %s", a.JWTTTL)
+		}
+		return nil
+	}
+	return fmt.Errorf("AUTH_MODE must be one of basic, jwt, got %q", a.Mode)
+}
+
+type SyncConfig struct {
+	Interval time.Duration `yaml:"interval"`
+	// SaveRetries and SaveBackoff control retrying batch writes that the
+	// database aborts with a retryable transaction error.
+	SaveRetries int           `yaml:"save_retries"`
+	SaveBackoff time.Duration `yaml:"save_backoff"`
+	// InstanceID names this process in persisted sync runs. Keep it stable
+	// across restarts of the same pod so leftover runs are recognised.
+	InstanceID string `yaml:"instance_id"`
+	// HeartbeatInterval is how often a running sync refreshes its run; runs
+	// that miss three heartbeats are aborted by the next instance to start.
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	// SuccessRateWindow is how far back the sync success rate reported by
+	// /health looks in the persisted runs.
+	SuccessRateWindow time.Duration `yaml:"success_rate_window"`
+	// IdempotencyTTL is how long POST /sync replays the response to an
+	// Idempotency-Key; zero ignores the header. IdempotencyKeys caps how many
+	// keys are remembered.
+	IdempotencyTTL  time.Duration `yaml:"idempotency_ttl"`
+	IdempotencyKeys int           `yaml:"idempotency_keys"`
+	// EnableEnrichment adds the sector/industry enrichment step to syncs.
+	EnableEnrichment bool `yaml:"enable_enrichment"`
+}
+
+type PrefetchConfig struct {
+	Secret string        `yaml:"secret"`
+	TTL    time.Duration `yaml:"ttl"`
+}
+
+type ViewsConfig struct {
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+type RecommendationConfig struct {
+	LatestPerTicker   bool    `yaml:"latest_per_ticker"`
+	RatingWeight      float64 `yaml:"rating_weight"`
+	ActionWeight      float64 `yaml:"action_weight"`
+	PriceTargetWeight float64 `yaml:"price_target_weight"`
+	RecencyDecayDays  float64 `yaml:"recency_decay_days"`
+	TieEpsilon        float64 `yaml:"tie_epsilon"`
+	// PriceTargetBaseline is what the price-target sub-score compares
+	// target_to against: target_from or prior_target.
+	PriceTargetBaseline string `yaml:"price_target_baseline"`
+	// ScoreRounding is how stored and recommendation scores are rounded to
+	// two decimals: half_up, half_even or floor.
+	ScoreRounding string `yaml:"score_rounding"`
+	// ColdStartMinRecords and ColdStartMinDays flag tickers with fewer
+	// entries or less history as cold start; zero disables each.
+	ColdStartMinRecords int     `yaml:"cold_start_min_records"`
+	ColdStartMinDays    float64 `yaml:"cold_start_min_days"`
+	// CacheTTL is how long top-scored candidates are cached; zero disables
+	// the cache.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+type TracingConfig struct {
+	// OTLPEndpoint is the OTLP/HTTP collector URL spans are exported to;
+	// empty disables tracing.
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
 }
 
 func (d DatabaseConfig) DSN() string {
@@ -46,31 +269,245 @@ func (d DatabaseConfig) DSN() string {
 	)
 }
 
+// Load reads the configuration from environment variables over the built-in
+// defaults. When CONFIG_FILE is set, the file it names provides the defaults
+// instead; see LoadFromFile. Load only fails on values it cannot parse;
+// Config.Validate checks the result.
 func Load() (*Config, error) {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return LoadFromFile(path)
+	}
+	return load(defaults(), false)
+}
+
+// LoadFromFile reads the YAML file at path over the built-in defaults, then
+// applies environment variables over it, so a file can hold the shared
+// settings and the environment the per-deployment ones and secrets. Keys the
+// file does not know are rejected; config.example.yaml lists them all.
+func LoadFromFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	cfg := defaults()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	for i := range cfg.Auth.APIKeys {
+		if cfg.Auth.APIKeys[i].Role == "" {
+			cfg.Auth.APIKeys[i].Role = "viewer"
+		}
+	}
+
+	// swagger_enabled defaults by the final mode unless the file sets it.
+	var explicit struct {
+		Server struct {
+			SwaggerEnabled *bool `yaml:"swagger_enabled"`
+		} `yaml:"server"`
+	}
+	if err := yaml.Unmarshal(data, &explicit); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return load(cfg, explicit.Server.SwaggerEnabled != nil)
+}
+
+// defaults is the configuration with nothing set.
+func defaults() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port:         getEnv("SERVER_PORT", "8080"),
-			Mode:         getEnv("GIN_MODE", "debug"),
-			ReadTimeout:  getEnvInt("SERVER_READ_TIMEOUT", 30),
-			WriteTimeout: getEnvInt("SERVER_WRITE_TIMEOUT", 30),
+			Port:                   "8080",
+			Mode:                   "debug",
+			ReadTimeout:            30,
+			WriteTimeout:           30,
+			MaxFilterValues:        100,
+			ExcludeUnrated:         true,
+			DefaultPageSize:        20,
+			MaxPageSize:            100,
+			MaxBodyBytes:           1 << 20,
+			SearchTrigramThreshold: 0.3,
+			StrictQueryParams:      "off",
+			SearchEmptyQuery:       "reject",
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "26257"),
-			User:     getEnv("DB_USER", "root"),
-			Password: getEnv("DB_PASSWORD", ""),
-			DBName:   getEnv("DB_NAME", "stockviewer"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Host:                   "localhost",
+			Port:                   "26257",
+			User:                   "root",
+			DBName:                 "stockviewer",
+			SSLMode:                "disable",
+			MaxOpenConns:           25,
+			MaxIdleConns:           10,
+			ConnMaxLifetimeSeconds: 300,
 		},
 		External: ExternalConfig{
-			KarenAIBaseURL: getEnv("KARENAI_BASE_URL", "https://api.karenai.click"),
-			KarenAIToken:   getEnv("KARENAI_TOKEN", ""),
+			KarenAIBaseURL:  "https://api.karenai.click",
+			KarenAIMaxPages: 100,
+			ErrorBodyLimit:  512,
 		},
 		Auth: AuthConfig{
-			Username: getEnv("BASIC_AUTH_USER", "admin"),
-			Password: getEnvRequired("BASIC_AUTH_PASSWORD"),
+			Username: "admin",
+			Realm:    "Authorization Required",
+			Mode:     "basic",
+			JWTTTL:   time.Hour,
+		},
+		Sync: SyncConfig{
+			SaveRetries:       3,
+			SaveBackoff:       100 * time.Millisecond,
+			InstanceID:        defaultInstanceID(),
+			HeartbeatInterval: 15 * time.Second,
+			SuccessRateWindow: 24 * time.Hour,
+			IdempotencyTTL:    10 * time.Minute,
+			IdempotencyKeys:   1000,
+		},
+		Prefetch: PrefetchConfig{
+			TTL: time.Minute,
 		},
-	}, nil
+		Views: ViewsConfig{
+			CacheTTL: time.Minute,
+		},
+		Recommendation: RecommendationConfig{
+			RatingWeight:        0.40,
+			ActionWeight:        0.35,
+			PriceTargetWeight:   0.25,
+			RecencyDecayDays:    365,
+			PriceTargetBaseline: "target_from",
+			ScoreRounding:       "half_up",
+			CacheTTL:            2 * time.Minute,
+		},
+	}
+}
+
+// load applies environment variables over base. SwaggerEnabled keeps base's
+// value only when swaggerSet; otherwise it follows the final mode.
+func load(base *Config, swaggerSet bool) (*Config, error) {
+	mode := getEnv("GIN_MODE", base.Server.Mode)
+	swaggerEnabled := mode != "release"
+	if swaggerSet {
+		swaggerEnabled = base.Server.SwaggerEnabled
+	}
+	cfg := &Config{
+		Server: ServerConfig{
+			Port:                     getEnv("SERVER_PORT", base.Server.Port),
+			Mode:                     mode,
+			SwaggerEnabled:           getEnvBool("SWAGGER_ENABLED", swaggerEnabled),
+			ReadTimeout:              getEnvInt("SERVER_READ_TIMEOUT", base.Server.ReadTimeout),
+			WriteTimeout:             getEnvInt("SERVER_WRITE_TIMEOUT", base.Server.WriteTimeout),
+			MaxFilterValues:          getEnvInt("FILTERS_MAX_VALUES", base.Server.MaxFilterValues),
+			ExcludeUnrated:           getEnvBool("FILTERS_EXCLUDE_UNRATED", base.Server.ExcludeUnrated),
+			DefaultPageSize:          getEnvInt("DEFAULT_PAGE_SIZE", base.Server.DefaultPageSize),
+			MaxPageSize:              getEnvInt("MAX_PAGE_SIZE", base.Server.MaxPageSize),
+			AuthenticatedMaxPageSize: getEnvInt("MAX_PAGE_SIZE_AUTHENTICATED", base.Server.AuthenticatedMaxPageSize),
+			MaxBodyBytes:             int64(getEnvInt("MAX_BODY_BYTES", int(base.Server.MaxBodyBytes))),
+			SearchTrigramThreshold:   getEnvFloat("SEARCH_TRIGRAM_THRESHOLD", base.Server.SearchTrigramThreshold),
+			StrictQueryParams:        strings.ToLower(getEnv("STRICT_QUERY_PARAMS", base.Server.StrictQueryParams)),
+			SearchEmptyQuery:         strings.ToLower(getEnv("SEARCH_EMPTY_QUERY", base.Server.SearchEmptyQuery)),
+		},
+		Database: DatabaseConfig{
+			Host:                    getEnv("DB_HOST", base.Database.Host),
+			Port:                    getEnv("DB_PORT", base.Database.Port),
+			User:                    getEnv("DB_USER", base.Database.User),
+			Password:                getEnv("DB_PASSWORD", base.Database.Password),
+			DBName:                  getEnv("DB_NAME", base.Database.DBName),
+			SSLMode:                 getEnv("DB_SSLMODE", base.Database.SSLMode),
+			AllowContractMigrations: getEnvBool("ALLOW_CONTRACT_MIGRATIONS", base.Database.AllowContractMigrations),
+			MaxOpenConns:            getEnvInt("DB_MAX_OPEN_CONNS", base.Database.MaxOpenConns),
+			MaxIdleConns:            getEnvInt("DB_MAX_IDLE_CONNS", base.Database.MaxIdleConns),
+			ConnMaxLifetimeSeconds:  getEnvInt("DB_CONN_MAX_LIFETIME", base.Database.ConnMaxLifetimeSeconds),
+		},
+		External: ExternalConfig{
+			KarenAIBaseURL:         getEnv("KARENAI_BASE_URL", base.External.KarenAIBaseURL),
+			KarenAIToken:           getEnv("KARENAI_TOKEN", base.External.KarenAIToken),
+			KarenAIFallbackBaseURL: getEnv("KARENAI_FALLBACK_BASE_URL", base.External.KarenAIFallbackBaseURL),
+			KarenAIMaxPages:        getEnvInt("KARENAI_MAX_PAGES", base.External.KarenAIMaxPages),
+			ErrorBodyLimit:         getEnvInt("EXTERNAL_ERROR_BODY_LIMIT", base.External.ErrorBodyLimit),
+		},
+		Auth: AuthConfig{
+			Username:      getEnv("BASIC_AUTH_USER", base.Auth.Username),
+			Password:      getEnvRequired("BASIC_AUTH_PASSWORD", base.Auth.Password),
+			Realm:         getEnv("BASIC_AUTH_REALM", base.Auth.Realm),
+			AdminUsername: getEnv("ADMIN_AUTH_USER", base.Auth.AdminUsername),
+			AdminPassword: getEnv("ADMIN_AUTH_PASSWORD", base.Auth.AdminPassword),
+			Mode:          getEnv("AUTH_MODE", base.Auth.Mode),
+			JWTSecret:     getEnv("JWT_SECRET", base.Auth.JWTSecret),
+			JWTTTL:        getEnvDuration("JWT_TTL", base.Auth.JWTTTL),
+			APIKeys:       getEnvAPIKeys("API_KEYS", base.Auth.APIKeys),
+		},
+		Sync: SyncConfig{
+			Interval:          getEnvDuration("SYNC_INTERVAL", base.Sync.Interval),
+			SaveRetries:       getEnvInt("SYNC_SAVE_RETRIES", base.Sync.SaveRetries),
+			SaveBackoff:       getEnvDuration("SYNC_SAVE_BACKOFF", base.Sync.SaveBackoff),
+			InstanceID:        getEnv("INSTANCE_ID", base.Sync.InstanceID),
+			HeartbeatInterval: getEnvDuration("SYNC_HEARTBEAT_INTERVAL", base.Sync.HeartbeatInterval),
+			SuccessRateWindow: getEnvDuration("SYNC_SUCCESS_WINDOW", base.Sync.SuccessRateWindow),
+			EnableEnrichment:  getEnvBool("ENABLE_ENRICHMENT", base.Sync.EnableEnrichment),
+			IdempotencyTTL:    getEnvDuration("SYNC_IDEMPOTENCY_TTL", base.Sync.IdempotencyTTL),
+			IdempotencyKeys:   getEnvInt("SYNC_IDEMPOTENCY_KEYS", base.Sync.IdempotencyKeys),
+		},
+		Prefetch: PrefetchConfig{
+			Secret: getEnv("PREFETCH_SECRET", base.Prefetch.Secret),
+			TTL:    getEnvDuration("PREFETCH_TTL", base.Prefetch.TTL),
+		},
+		Views: ViewsConfig{
+			CacheTTL: getEnvDuration("VIEWS_CACHE_TTL", base.Views.CacheTTL),
+		},
+		Recommendation: RecommendationConfig{
+			LatestPerTicker:     getEnvBool("RECOMMENDATION_LATEST_PER_TICKER", base.Recommendation.LatestPerTicker),
+			RatingWeight:        getEnvFloat("RECOMMENDATION_RATING_WEIGHT", base.Recommendation.RatingWeight),
+			ActionWeight:        getEnvFloat("RECOMMENDATION_ACTION_WEIGHT", base.Recommendation.ActionWeight),
+			PriceTargetWeight:   getEnvFloat("RECOMMENDATION_PRICE_TARGET_WEIGHT", base.Recommendation.PriceTargetWeight),
+			RecencyDecayDays:    getEnvFloat("RECOMMENDATION_RECENCY_DECAY_DAYS", base.Recommendation.RecencyDecayDays),
+			TieEpsilon:          getEnvFloat("RECOMMENDATION_TIE_EPSILON", base.Recommendation.TieEpsilon),
+			PriceTargetBaseline: getEnv("RECOMMENDATION_PRICE_TARGET_BASELINE", base.Recommendation.PriceTargetBaseline),
+			ScoreRounding:       getEnv("SCORE_ROUNDING", base.Recommendation.ScoreRounding),
+			ColdStartMinRecords: getEnvInt("RECOMMENDATION_COLD_START_MIN_RECORDS", base.Recommendation.ColdStartMinRecords),
+			ColdStartMinDays:    getEnvFloat("RECOMMENDATION_COLD_START_MIN_DAYS", base.Recommendation.ColdStartMinDays),
+			CacheTTL:            getEnvDuration("RECOMMENDATION_CACHE_TTL", base.Recommendation.CacheTTL),
+		},
+		Tracing: TracingConfig{
+			OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", base.Tracing.OTLPEndpoint),
+		},
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the whole configuration, including the fields Load fills
+// with defaults that only make sense in development, and reports every
+// problem at once rather than the first.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.External.KarenAIToken == "" {
+		errs = append(errs, errors.New("KARENAI_TOKEN is required"))
+	}
+	if err := validatePort("SERVER_PORT", c.Server.Port); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validatePort("DB_PORT", c.Database.Port); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Server.ReadTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("SERVER_READ_TIMEOUT must be positive, got %d", c.Server.ReadTimeout))
+	}
+	if c.Server.WriteTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("SERVER_WRITE_TIMEOUT must be positive, got %d", c.Server.WriteTimeout))
+	}
+	for _, section := range []interface{ Validate() error }{c.Server, c.Database, c.External, c.Auth} {
+		if err := section.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validatePort checks that value is a TCP port number.
+func validatePort(key, value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("%s must be a number between 1 and 65535, got %q", key, value)
+	}
+	return nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -89,10 +526,75 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
-func getEnvRequired(key string) string {
-	value := os.Getenv(key)
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAPIKeys parses key with parseAPIKeys when it is set.
+func getEnvAPIKeys(key string, defaultValue []APIKeyConfig) []APIKeyConfig {
+	if value := os.Getenv(key); value != "" {
+		return parseAPIKeys(value)
+	}
+	return defaultValue
+}
+
+// parseAPIKeys splits a comma-separated API_KEYS value. Roles are checked by
+// AuthConfig.Validate.
+func parseAPIKeys(value string) []APIKeyConfig {
+	var keys []APIKeyConfig
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		apiKey := APIKeyConfig{Key: entry, Role: "viewer"}
+		if i := strings.LastIndex(entry, ":"); i >= 0 {
+			apiKey.Key, apiKey.Role = entry[:i], entry[i+1:]
+		}
+		keys = append(keys, apiKey)
+	}
+	return keys
+}
+
+// getEnvRequired panics when neither the environment nor the config file
+// sets key.
+func getEnvRequired(key, fileValue string) string {
+	value := getEnv(key, fileValue)
 	if value == "" {
 		panic(fmt.Sprintf("required environment variable %s is not set", key))
 	}
 	return value
 }
+
+// defaultInstanceID is the hostname, which stays the same when a pod's
+// container restarts.
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "stockviewer"
+	}
+	return hostname
+}
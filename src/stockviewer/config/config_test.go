@@ -0,0 +1,304 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExternalConfigValidate_MaxPages(t *testing.T) {
+	for pages, valid := range map[int]bool{0: false, 1: true, 100: true, MaxKarenAIPages: true, MaxKarenAIPages + 1: false} {
+		err := ExternalConfig{KarenAIMaxPages: pages}.Validate()
+		if (err == nil) != valid {
+			t.Errorf("KarenAIMaxPages=%d: expected valid=%v, got %v", pages, valid, err)
+		}
+	}
+}
+
+func TestLoad_ReportsEverySectionError(t *testing.T) {
+	t.Setenv("BASIC_AUTH_PASSWORD", "secret")
+	t.Setenv("KARENAI_TOKEN", "token")
+	t.Setenv("KARENAI_MAX_PAGES", "20000")
+	t.Setenv("STRICT_QUERY_PARAMS", "strict")
+	t.Setenv("DB_MAX_IDLE_CONNS", "50")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected Load to leave validation to Validate, got %v", err)
+	}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("expected the section errors to be reported")
+	}
+	for _, field := range []string{"KARENAI_MAX_PAGES", "STRICT_QUERY_PARAMS", "DB_MAX_IDLE_CONNS"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected %s in %q", field, err)
+		}
+	}
+}
+
+func TestLoad_MaxPages(t *testing.T) {
+	t.Setenv("BASIC_AUTH_PASSWORD", "secret")
+	t.Setenv("KARENAI_MAX_PAGES", "5")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.External.KarenAIMaxPages != 5 {
+		t.Errorf("expected 5 pages, got %d", cfg.External.KarenAIMaxPages)
+	}
+}
+
+func TestLoad_SwaggerEnabledDefault(t *testing.T) {
+	t.Setenv("BASIC_AUTH_PASSWORD", "secret")
+
+	tests := []struct {
+		mode, swagger string
+		want          bool
+	}{
+		{mode: "debug", want: true},
+		{mode: "release", want: false},
+		{mode: "release", swagger: "true", want: true},
+		{mode: "debug", swagger: "false", want: false},
+	}
+	for _, tt := range tests {
+		t.Setenv("GIN_MODE", tt.mode)
+		t.Setenv("SWAGGER_ENABLED", tt.swagger)
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Server.SwaggerEnabled != tt.want {
+			t.Errorf("GIN_MODE=%s SWAGGER_ENABLED=%q: expected %v, got %v", tt.mode, tt.swagger, tt.want, cfg.Server.SwaggerEnabled)
+		}
+	}
+}
+
+func TestServerConfigValidate_StrictQueryParams(t *testing.T) {
+	for mode, valid := range map[string]bool{"off": true, "log": true, "enforce": true, "": false, "strict": false} {
+		err := ServerConfig{StrictQueryParams: mode, SearchEmptyQuery: "reject"}.Validate()
+		if (err == nil) != valid {
+			t.Errorf("StrictQueryParams=%q: expected valid=%v, got %v", mode, valid, err)
+		}
+	}
+}
+
+func TestServerConfigValidate_SearchEmptyQuery(t *testing.T) {
+	for mode, valid := range map[string]bool{"reject": true, "recent": true, "": false, "top": false} {
+		err := ServerConfig{StrictQueryParams: "off", SearchEmptyQuery: mode}.Validate()
+		if (err == nil) != valid {
+			t.Errorf("SearchEmptyQuery=%q: expected valid=%v, got %v", mode, valid, err)
+		}
+	}
+}
+
+func TestDatabaseConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		config DatabaseConfig
+		valid  bool
+	}{
+		"valid":             {DatabaseConfig{MaxOpenConns: 25, MaxIdleConns: 10, ConnMaxLifetimeSeconds: 300}, true},
+		"idle equals open":  {DatabaseConfig{MaxOpenConns: 10, MaxIdleConns: 10, ConnMaxLifetimeSeconds: 300}, true},
+		"idle exceeds open": {DatabaseConfig{MaxOpenConns: 5, MaxIdleConns: 10, ConnMaxLifetimeSeconds: 300}, false},
+		"no open":           {DatabaseConfig{MaxOpenConns: 0, MaxIdleConns: 1, ConnMaxLifetimeSeconds: 300}, false},
+		"no idle":           {DatabaseConfig{MaxOpenConns: 25, MaxIdleConns: 0, ConnMaxLifetimeSeconds: 300}, false},
+		"negative lifetime": {DatabaseConfig{MaxOpenConns: 25, MaxIdleConns: 10, ConnMaxLifetimeSeconds: -1}, false},
+	}
+	for name, tt := range tests {
+		if err := tt.config.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid=%v, got %v", name, tt.valid, err)
+		}
+	}
+}
+
+func TestAuthConfigValidate(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+	apiKey := "0123456789abcdef"
+	tests := map[string]struct {
+		config AuthConfig
+		valid  bool
+	}{
+		"basic":                       {AuthConfig{Mode: "basic"}, true},
+		"jwt":                         {AuthConfig{Mode: "jwt", JWTSecret: secret, JWTTTL: time.Hour}, true},
+		"jwt without secret":          {AuthConfig{Mode: "jwt", JWTTTL: time.Hour}, false},
+		"jwt short secret":            {AuthConfig{Mode: "jwt", JWTSecret: "short", JWTTTL: time.Hour}, false},
+		"jwt without ttl":             {AuthConfig{Mode: "jwt", JWTSecret: secret}, false},
+		"unknown mode":                {AuthConfig{Mode: "oauth"}, false},
+		"admin set":                   {AuthConfig{Mode: "basic", Username: "viewer", AdminUsername: "root", AdminPassword: "pw"}, true},
+		"admin without password":      {AuthConfig{Mode: "basic", AdminUsername: "root"}, false},
+		"admin password without user": {AuthConfig{Mode: "basic", AdminPassword: "pw"}, false},
+		"admin same as basic user":    {AuthConfig{Mode: "basic", Username: "admin", AdminUsername: "admin", AdminPassword: "pw"}, false},
+		"api keys":                    {AuthConfig{Mode: "basic", APIKeys: []APIKeyConfig{{Key: apiKey, Role: "viewer"}, {Key: apiKey + "2", Role: "admin"}}}, true},
+		"api key too short":           {AuthConfig{Mode: "basic", APIKeys: []APIKeyConfig{{Key: "short", Role: "viewer"}}}, false},
+		"api key unknown role":        {AuthConfig{Mode: "basic", APIKeys: []APIKeyConfig{{Key: apiKey, Role: "owner"}}}, false},
+		"api key repeated":            {AuthConfig{Mode: "basic", APIKeys: []APIKeyConfig{{Key: apiKey, Role: "viewer"}, {Key: apiKey, Role: "admin"}}}, false},
+	}
+	for name, tt := range tests {
+		if err := tt.config.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid=%v, got %v", name, tt.valid, err)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Server:   ServerConfig{Port: "8080", ReadTimeout: 30, WriteTimeout: 30, StrictQueryParams: "off", SearchEmptyQuery: "reject"},
+			Database: DatabaseConfig{Port: "26257", MaxOpenConns: 25, MaxIdleConns: 10, ConnMaxLifetimeSeconds: 300},
+			External: ExternalConfig{KarenAIToken: "token", KarenAIMaxPages: 100},
+			Auth:     AuthConfig{Mode: "basic"},
+		}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("expected a valid config, got %v", err)
+	}
+
+	tests := map[string]struct {
+		change func(*Config)
+		want   []string
+	}{
+		"missing token":     {func(c *Config) { c.External.KarenAIToken = "" }, []string{"KARENAI_TOKEN"}},
+		"port not numeric":  {func(c *Config) { c.Server.Port = "http" }, []string{"SERVER_PORT"}},
+		"port zero":         {func(c *Config) { c.Server.Port = "0" }, []string{"SERVER_PORT"}},
+		"port too large":    {func(c *Config) { c.Database.Port = "65536" }, []string{"DB_PORT"}},
+		"read timeout zero": {func(c *Config) { c.Server.ReadTimeout = 0 }, []string{"SERVER_READ_TIMEOUT"}},
+		"write timeout negative": {
+			func(c *Config) { c.Server.WriteTimeout = -1 },
+			[]string{"SERVER_WRITE_TIMEOUT"},
+		},
+		"section invalid": {func(c *Config) { c.Auth.Mode = "oauth" }, []string{"AUTH_MODE"}},
+		"pool invalid":    {func(c *Config) { c.Database.MaxIdleConns = 30 }, []string{"DB_MAX_IDLE_CONNS"}},
+		"several": {
+			func(c *Config) {
+				c.External.KarenAIToken = ""
+				c.Server.Port = ""
+				c.Server.ReadTimeout = 0
+			},
+			[]string{"KARENAI_TOKEN", "SERVER_PORT", "SERVER_READ_TIMEOUT"},
+		},
+	}
+	for name, tt := range tests {
+		cfg := valid()
+		tt.change(cfg)
+		err := cfg.Validate()
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		for _, field := range tt.want {
+			if !strings.Contains(err.Error(), field) {
+				t.Errorf("%s: expected %s in %q", name, field, err)
+			}
+		}
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	got := parseAPIKeys(" reader-key , ,writer-key:admin,odd:key:viewer")
+	want := []APIKeyConfig{
+		{Key: "reader-key", Role: "viewer"},
+		{Key: "writer-key", Role: "admin"},
+		{Key: "odd:key", Role: "viewer"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if keys := parseAPIKeys(""); len(keys) != 0 {
+		t.Errorf("expected no keys, got %+v", keys)
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFromFile_EnvironmentOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  port: "9090"
+  mode: release
+auth:
+  password: from-file
+  api_keys:
+    - key: 0123456789abcdef
+sync:
+  interval: 30m
+recommendation:
+  score_rounding: floor
+`)
+	t.Setenv("BASIC_AUTH_PASSWORD", "")
+	t.Setenv("GIN_MODE", "")
+	t.Setenv("SWAGGER_ENABLED", "")
+	t.Setenv("SERVER_PORT", "7070")
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Server.Port != "7070" {
+		t.Errorf("expected SERVER_PORT to override the file, got %s", cfg.Server.Port)
+	}
+	if cfg.Server.SwaggerEnabled {
+		t.Error("expected swagger off in the file's release mode")
+	}
+	if cfg.Auth.Password != "from-file" {
+		t.Errorf("expected the file's password, got %q", cfg.Auth.Password)
+	}
+	if want := []APIKeyConfig{{Key: "0123456789abcdef", Role: "viewer"}}; !reflect.DeepEqual(cfg.Auth.APIKeys, want) {
+		t.Errorf("expected %+v, got %+v", want, cfg.Auth.APIKeys)
+	}
+	if cfg.Sync.Interval != 30*time.Minute {
+		t.Errorf("expected a 30m interval, got %s", cfg.Sync.Interval)
+	}
+	if cfg.Recommendation.ScoreRounding != "floor" {
+		t.Errorf("expected floor rounding, got %s", cfg.Recommendation.ScoreRounding)
+	}
+	if cfg.Server.MaxPageSize != 100 {
+		t.Errorf("expected the built-in default for keys the file omits, got %d", cfg.Server.MaxPageSize)
+	}
+}
+
+func TestLoadFromFile_Errors(t *testing.T) {
+	t.Setenv("BASIC_AUTH_PASSWORD", "secret")
+
+	if _, err := LoadFromFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if _, err := LoadFromFile(writeConfigFile(t, "server:\n  prot: \"8080\"\n")); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+	cfg, err := LoadFromFile(writeConfigFile(t, "server:\n  strict_query_params: strict\n"))
+	if err != nil {
+		t.Fatalf("expected Load to leave validation to Validate, got %v", err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "STRICT_QUERY_PARAMS") {
+		t.Errorf("expected the file's values to be validated, got %v", err)
+	}
+	if _, err := LoadFromFile(writeConfigFile(t, "")); err != nil {
+		t.Errorf("expected an empty file to keep the defaults, got %v", err)
+	}
+}
+
+func TestLoadFromFile_Example(t *testing.T) {
+	t.Setenv("BASIC_AUTH_PASSWORD", "secret")
+
+	cfg, err := LoadFromFile(filepath.Join("..", "..", "..", "config.example.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := defaults()
+	want.Auth.Password = "secret"
+	want.Server.SwaggerEnabled = true
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("expected config.example.yaml to hold the defaults:\nwant %+v\n got %+v", want, cfg)
+	}
+}
@@ -0,0 +1,151 @@
+package stockviewer
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// TargetConsensus summarizes the analyst price targets for a ticker over a
+// recent window. Spread is Max - Min and hints at how much analysts disagree.
+type TargetConsensus struct {
+	Ticker     string  `json:"ticker"`
+	Count      int64   `json:"count"`
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+	Median     float64 `json:"median"`
+	Mean       float64 `json:"mean"`
+	Spread     float64 `json:"spread"`
+	WindowDays int     `json:"window_days"`
+	// Momentum is omitted when the window has fewer than two targets
+	// observed at different times.
+	Momentum *TargetMomentum `json:"momentum,omitempty"`
+}
+
+// MaxConsensusTickers is how many tickers one bulk consensus request may
+// list.
+const MaxConsensusTickers = 50
+
+// MomentumDirection tells whether a ticker's price targets trend up or down.
+type MomentumDirection string
+
+const (
+	MomentumRising  MomentumDirection = "rising"
+	MomentumFalling MomentumDirection = "falling"
+	MomentumFlat    MomentumDirection = "flat"
+)
+
+// TargetMomentum is the trend of a ticker's price targets over time.
+// Magnitude is the least-squares slope of the targets against the time each
+// entry was first observed, as a percentage of the mean target per 30 days,
+// so tickers at different price levels compare. A slope that rounds to zero
+// is flat.
+type TargetMomentum struct {
+	Direction MomentumDirection `json:"direction"`
+	Magnitude float64           `json:"magnitude"`
+	Entries   int               `json:"entries"`
+}
+
+// momentumPeriod is the span Magnitude is expressed over.
+const momentumPeriod = 30 * 24 * time.Hour
+
+// MomentumFromHistory computes the target momentum of a ticker's entries.
+// Entries are ordered by CreatedAt, the time each was first observed; missing
+// (non-positive) targets are skipped. It returns nil when there are fewer
+// than two targets or they were all observed at the same time.
+func MomentumFromHistory(entries []Stock) *TargetMomentum {
+	type observation struct {
+		at     time.Time
+		target float64
+	}
+	var observations []observation
+	for _, entry := range entries {
+		if _, target := entry.Targets(); target > 0 {
+			observations = append(observations, observation{at: entry.CreatedAt, target: target})
+		}
+	}
+	if len(observations) < 2 {
+		return nil
+	}
+	sort.SliceStable(observations, func(i, j int) bool {
+		return observations[i].at.Before(observations[j].at)
+	})
+
+	// Times are measured in periods from the first observation to keep the
+	// sums small.
+	origin := observations[0].at
+	n := float64(len(observations))
+	var sumX, sumY float64
+	for _, o := range observations {
+		sumX += float64(o.at.Sub(origin)) / float64(momentumPeriod)
+		sumY += o.target
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var covariance, variance float64
+	for _, o := range observations {
+		dx := float64(o.at.Sub(origin))/float64(momentumPeriod) - meanX
+		covariance += dx * (o.target - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return nil
+	}
+
+	magnitude := math.Round(covariance/variance/meanY*100*100) / 100
+	momentum := &TargetMomentum{Magnitude: magnitude, Entries: len(observations), Direction: MomentumFlat}
+	switch {
+	case magnitude > 0:
+		momentum.Direction = MomentumRising
+	case magnitude < 0:
+		momentum.Direction = MomentumFalling
+	}
+	return momentum
+}
+
+// ConsensusFromTargets computes the consensus statistics in Go, for stores
+// that cannot aggregate them in SQL. Missing (non-positive) targets are
+// skipped; the median of an even count is the mean of the two middle values.
+func ConsensusFromTargets(targets []float64) TargetConsensus {
+	var valid []float64
+	for _, target := range targets {
+		if target > 0 {
+			valid = append(valid, target)
+		}
+	}
+
+	var consensus TargetConsensus
+	if len(valid) == 0 {
+		return consensus
+	}
+	sort.Float64s(valid)
+
+	var sum float64
+	for _, target := range valid {
+		sum += target
+	}
+
+	n := len(valid)
+	consensus.Count = int64(n)
+	consensus.Min = valid[0]
+	consensus.Max = valid[n-1]
+	consensus.Mean = sum / float64(n)
+	if n%2 == 1 {
+		consensus.Median = valid[n/2]
+	} else {
+		consensus.Median = (valid[n/2-1] + valid[n/2]) / 2
+	}
+	consensus.Spread = consensus.Max - consensus.Min
+	return consensus
+}
+
+// Rounded returns the consensus with prices rounded to cents.
+func (c TargetConsensus) Rounded() TargetConsensus {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	c.Min = round(c.Min)
+	c.Max = round(c.Max)
+	c.Median = round(c.Median)
+	c.Mean = round(c.Mean)
+	c.Spread = round(c.Spread)
+	return c
+}
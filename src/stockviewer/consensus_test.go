@@ -0,0 +1,95 @@
+package stockviewer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConsensusFromTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []float64
+		want    TargetConsensus
+	}{
+		{
+			name:    "odd count",
+			targets: []float64{210, 150, 185},
+			want:    TargetConsensus{Count: 3, Min: 150, Max: 210, Median: 185, Mean: 181.6666666666667, Spread: 60},
+		},
+		{
+			name:    "even count",
+			targets: []float64{150, 210, 180, 190},
+			want:    TargetConsensus{Count: 4, Min: 150, Max: 210, Median: 185, Mean: 182.5, Spread: 60},
+		},
+		{
+			name:    "missing targets are excluded",
+			targets: []float64{0, 120, -1, 100, 0},
+			want:    TargetConsensus{Count: 2, Min: 100, Max: 120, Median: 110, Mean: 110, Spread: 20},
+		},
+		{
+			name:    "no targets",
+			targets: []float64{0, 0},
+			want:    TargetConsensus{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ConsensusFromTargets(tt.targets)
+			if got.Rounded() != tt.want.Rounded() {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMomentumFromHistory(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(days int, target float64) Stock {
+		return Stock{TargetTo: Price(target), CreatedAt: start.AddDate(0, 0, days)}
+	}
+
+	tests := []struct {
+		name    string
+		entries []Stock
+		want    *TargetMomentum
+	}{
+		{
+			name:    "rising targets",
+			entries: []Stock{entry(0, 100), entry(30, 110), entry(60, 120)},
+			want:    &TargetMomentum{Direction: MomentumRising, Magnitude: 9.09, Entries: 3},
+		},
+		{
+			name:    "falling targets out of order",
+			entries: []Stock{entry(60, 80), entry(0, 120), entry(30, 100)},
+			want:    &TargetMomentum{Direction: MomentumFalling, Magnitude: -20, Entries: 3},
+		},
+		{
+			name:    "unchanged targets",
+			entries: []Stock{entry(0, 150), entry(45, 150)},
+			want:    &TargetMomentum{Direction: MomentumFlat, Magnitude: 0, Entries: 2},
+		},
+		{
+			name:    "missing targets are skipped",
+			entries: []Stock{entry(0, 100), entry(10, 0), {CreatedAt: start.AddDate(0, 0, 20)}, entry(30, 110)},
+			want:    &TargetMomentum{Direction: MomentumRising, Magnitude: 9.52, Entries: 2},
+		},
+		{
+			name:    "single target",
+			entries: []Stock{entry(0, 100)},
+		},
+		{
+			name:    "observed at the same time",
+			entries: []Stock{entry(0, 100), entry(0, 120)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MomentumFromHistory(tt.entries)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
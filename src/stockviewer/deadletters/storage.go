@@ -0,0 +1,24 @@
+package deadletters
+
+import (
+	"context"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"gorm.io/gorm"
+)
+
+type Storage struct {
+	db *gorm.DB
+}
+
+// NewStorage expects the schema to be in place; see package schema.
+func NewStorage(db *gorm.DB) *Storage {
+	return &Storage{db: db}
+}
+
+func (s *Storage) Record(ctx context.Context, letter stockviewer.DeadLetter) error {
+	if err := s.db.WithContext(ctx).Create(&letter).Error; err != nil {
+		return stockviewer.StorageError{Operation: "record_dead_letter", Err: err}
+	}
+	return nil
+}
@@ -13,8 +13,37 @@ var (
 	ErrDatabaseConnection = errors.New("database connection error")
 	ErrUnauthorized       = errors.New("unauthorized access")
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrViewNotFound       = errors.New("view not found")
+	ErrUnknownFilterField = errors.New("unknown filter field")
+	ErrSchemaTooOld       = errors.New("database schema is older than this binary supports")
+	ErrSchemaTooNew       = errors.New("database schema has contract migrations this binary does not support")
 )
 
+// retryableSQLStates are the Postgres/CockroachDB codes for transactions
+// aborted by contention, which succeed when run again: serialization_failure
+// (CockroachDB's "restart transaction") and deadlock_detected.
+var retryableSQLStates = map[string]bool{
+	"40001": true,
+	"40P01": true,
+}
+
+// SQLState returns the SQLSTATE code of the database error wrapped by err,
+// or "" when there is none. Drivers expose the code through a SQLState
+// method (pgconn.PgError, pq.Error).
+func SQLState(err error) string {
+	var sqlErr interface{ SQLState() string }
+	if errors.As(err, &sqlErr) {
+		return sqlErr.SQLState()
+	}
+	return ""
+}
+
+// IsRetryableTxError reports whether err wraps a database error whose
+// transaction can be retried as-is.
+func IsRetryableTxError(err error) bool {
+	return retryableSQLStates[SQLState(err)]
+}
+
 type StorageError struct {
 	Operation string
 	Err       error
@@ -46,6 +75,18 @@ func (e ExternalAPIError) Unwrap() error {
 	return e.Err
 }
 
+// ConversionError reports a feed item that could not be converted to a
+// stock. Payload is the item as received, for the dead-letter store.
+type ConversionError struct {
+	Source  string
+	Reason  string
+	Payload string
+}
+
+func (e ConversionError) Error() string {
+	return fmt.Sprintf("cannot convert %s item: %s", e.Source, e.Reason)
+}
+
 type ValidationError struct {
 	Field   string
 	Message string
@@ -1,52 +1,309 @@
 package httpapi
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/authtoken"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/idempotency"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/prefetch"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/query"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/syncprogress"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 type Config struct {
 	StocksService         stockviewer.StocksService
 	RecommendationService stockviewer.RecommendationService
-	BasicAuthUser         string
-	BasicAuthPassword     string
+	// ViewsService is optional; the /views routes are only registered when
+	// it is set.
+	ViewsService stockviewer.ViewsService
+	// BasicAuthUser and BasicAuthPassword get RoleAdmin, or RoleViewer when
+	// AdminUser is set.
+	BasicAuthUser     string
+	BasicAuthPassword string
+	// AdminUser and AdminPassword are an optional second credential set with
+	// RoleAdmin.
+	AdminUser     string
+	AdminPassword string
+	// BasicAuthRealm is sent in the WWW-Authenticate header of 401
+	// responses; empty uses DefaultBasicAuthRealm.
+	BasicAuthRealm string
+	// AuthMode says how protected routes authenticate; empty means
+	// AuthModeBasic.
+	AuthMode AuthMode
+	// APIKeys are static keys that protected routes also accept in the
+	// X-API-Key header, in place of the AuthMode credentials.
+	APIKeys []APIKey
+	// Tokens signs and verifies bearer tokens. It is required with
+	// AuthModeJWT, which also registers POST /auth/token.
+	Tokens   *authtoken.Signer
+	Prefetch *prefetch.Store
+	// DefaultPageSize and MaxPageSize bound list page sizes; zero values use
+	// stockviewer.DefaultPageLimits.
+	DefaultPageSize int
+	MaxPageSize     int
+	// AuthenticatedMaxPageSize raises MaxPageSize for callers that send
+	// valid credentials; zero keeps the same cap for everyone.
+	AuthenticatedMaxPageSize int
+	// ReadinessCheck is run by GET /ready once the database answers a ping;
+	// nil skips it.
+	ReadinessCheck func(ctx context.Context) error
+	// SyncProgress is optional; GET /sync/stream is only registered when it
+	// is set. It must be the broadcaster the stocks service publishes to.
+	SyncProgress *syncprogress.Broadcaster
+	// Idempotency is optional; without it POST /sync ignores the
+	// Idempotency-Key header.
+	Idempotency *idempotency.Store
+	// StrictQuery says what happens to query parameters that GetStocks,
+	// SearchStocks and GetRecommendations do not declare; empty means
+	// StrictQueryOff.
+	StrictQuery StrictQueryMode
+	// EmptySearch says what SearchStocks does with an empty q; empty means
+	// EmptySearchReject.
+	EmptySearch EmptySearchMode
+	// MaxBodyBytes caps request bodies under /api/v1; zero or less uses
+	// DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+	// SwaggerEnabled registers the Swagger UI under /swagger/. The spec at
+	// /api/v1/openapi.json is served either way.
+	SwaggerEnabled bool
+}
+
+// StrictQueryMode says what happens to query parameters a route does not
+// declare.
+type StrictQueryMode string
+
+const (
+	// StrictQueryOff ignores unknown parameters.
+	StrictQueryOff StrictQueryMode = "off"
+	// StrictQueryLog logs unknown parameters and serves the request as if
+	// they were absent, to find affected consumers before enforcing.
+	StrictQueryLog StrictQueryMode = "log"
+	// StrictQueryEnforce rejects requests with unknown parameters with a
+	// 400 listing them.
+	StrictQueryEnforce StrictQueryMode = "enforce"
+)
+
+// DefaultMaxBodyBytes is the request body limit when Config.MaxBodyBytes is
+// not set.
+const DefaultMaxBodyBytes = 1 << 20
+
+// EmptySearchMode says what GET /stocks/search does when q is empty.
+type EmptySearchMode string
+
+const (
+	// EmptySearchReject answers 400.
+	EmptySearchReject EmptySearchMode = "reject"
+	// EmptySearchRecent lists the newest entries, for UIs that show results
+	// before anything is typed.
+	EmptySearchRecent EmptySearchMode = "recent"
+)
+
+// AuthMode says how callers of protected routes prove who they are.
+type AuthMode string
+
+const (
+	// AuthModeBasic checks basic auth credentials on every request.
+	AuthModeBasic AuthMode = "basic"
+	// AuthModeJWT accepts only bearer tokens issued by POST /auth/token,
+	// which is the one route that still takes basic auth credentials.
+	AuthModeJWT AuthMode = "jwt"
+)
+
+// APIKey is a static key for server-to-server callers and the role it
+// grants.
+type APIKey struct {
+	Key  string
+	Role stockviewer.Role
 }
 
+// APIKeyHeader carries an API key.
+const APIKeyHeader = "X-API-Key"
+
+// preferenceParams are the query parameters RequestContextMiddleware reads
+// on every route.
+var preferenceParams = []string{"lang", "currency", "verbosity"}
+
+// defaultSyncStreamPoll is how often GET /sync/stream checks whether the sync
+// it follows has finished.
+const defaultSyncStreamPoll = time.Second
+
+// idempotencyKeyHeader lets a client retry POST /sync without starting a
+// second sync; idempotentReplayedHeader marks a replayed response.
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// DefaultBasicAuthRealm is the realm used when Config.BasicAuthRealm is empty.
+const DefaultBasicAuthRealm = "Authorization Required"
+
 type API struct {
 	stocksService         stockviewer.StocksService
 	recommendationService stockviewer.RecommendationService
-	basicAuthUser         string
-	basicAuthPassword     string
+	viewsService          stockviewer.ViewsService
+	credentials           []credential
+	apiKeys               []APIKey
+	basicAuthRealm        string
+	authMode              AuthMode
+	tokens                *authtoken.Signer
+	prefetch              *prefetch.Store
+	pageLimits            stockviewer.PageLimits
+	readinessCheck        func(ctx context.Context) error
+	swaggerEnabled        bool
+	syncProgress          *syncprogress.Broadcaster
+	syncStreamPoll        time.Duration
+	idempotency           *idempotency.Store
+	strictQuery           StrictQueryMode
+	emptySearch           EmptySearchMode
+	maxBodyBytes          int64
+}
+
+// credential is a basic auth user and password and the role they grant.
+type credential struct {
+	user     string
+	password string
+	role     stockviewer.Role
+}
+
+// credentialSets keeps the basic credentials as the admin until a separate
+// admin set is configured, so single-credential deployments keep full access.
+func credentialSets(cfg Config) []credential {
+	if cfg.AdminUser == "" {
+		return []credential{{cfg.BasicAuthUser, cfg.BasicAuthPassword, stockviewer.RoleAdmin}}
+	}
+	return []credential{
+		{cfg.BasicAuthUser, cfg.BasicAuthPassword, stockviewer.RoleViewer},
+		{cfg.AdminUser, cfg.AdminPassword, stockviewer.RoleAdmin},
+	}
 }
 
 func New(cfg Config) *API {
+	if cfg.BasicAuthRealm == "" {
+		cfg.BasicAuthRealm = DefaultBasicAuthRealm
+	}
+	if cfg.AuthMode == "" {
+		cfg.AuthMode = AuthModeBasic
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	}
 	return &API{
 		stocksService:         cfg.StocksService,
 		recommendationService: cfg.RecommendationService,
-		basicAuthUser:         cfg.BasicAuthUser,
-		basicAuthPassword:     cfg.BasicAuthPassword,
+		viewsService:          cfg.ViewsService,
+		credentials:           credentialSets(cfg),
+		apiKeys:               cfg.APIKeys,
+		basicAuthRealm:        cfg.BasicAuthRealm,
+		authMode:              cfg.AuthMode,
+		tokens:                cfg.Tokens,
+		prefetch:              cfg.Prefetch,
+		pageLimits: stockviewer.PageLimits{
+			DefaultPageSize:          cfg.DefaultPageSize,
+			MaxPageSize:              cfg.MaxPageSize,
+			AuthenticatedMaxPageSize: cfg.AuthenticatedMaxPageSize,
+		}.WithDefaults(),
+		readinessCheck: cfg.ReadinessCheck,
+		swaggerEnabled: cfg.SwaggerEnabled,
+		syncProgress:   cfg.SyncProgress,
+		syncStreamPoll: defaultSyncStreamPoll,
+		idempotency:    cfg.Idempotency,
+		strictQuery:    cfg.StrictQuery,
+		emptySearch:    cfg.EmptySearch,
+		maxBodyBytes:   cfg.MaxBodyBytes,
 	}
 }
 
 func (a *API) ConfigureRoutes(router *gin.Engine) {
-	router.Use(CORSMiddleware())
+	router.Use(otelgin.Middleware(tracing.ServiceName), CORSMiddleware(), a.RequestContextMiddleware())
 
 	router.GET("/ping", a.Ping)
 	router.GET("/health", a.HealthCheck)
+	router.GET("/ready", a.ReadinessCheck)
+	// Process counters such as sync_runs_aborted_total, in expvar's JSON
+	// format.
+	router.GET("/debug/vars", a.AuthMiddleware(), gin.WrapH(expvar.Handler()))
+	if a.swaggerEnabled {
+		router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	}
 
 	v1 := router.Group("/api/v1")
+	v1.Use(MaxBodyBytes(a.maxBodyBytes))
 	{
-		v1.GET("/stocks", a.GetStocks)
-		v1.GET("/stocks/search", a.SearchStocks)
+		v1.GET("/openapi.json", a.GetOpenAPISpec)
+
+		v1.GET("/stocks", a.StrictQuery(stockviewer.StockFilter{}, "prefetch"), a.GetStocks)
+		v1.POST("/stocks/query", a.QueryStocks)
+		v1.GET("/stocks/search", a.StrictQuery(searchParams{}), a.SearchStocks)
+		v1.GET("/stocks/changes", a.GetStockChanges)
+		v1.GET("/stocks/new", a.GetNewTickers)
+		v1.GET("/stocks/summaries", a.GetTargetConsensuses)
 		v1.GET("/stocks/:id", a.GetStockByID)
+		// Gin requires one wildcard name per path segment, so the ticker is
+		// bound as :id here; GetStockHistory reads it as a ticker.
+		v1.GET("/stocks/:id/history", a.GetStockHistory)
 		v1.GET("/stocks/filters", a.GetFilters)
+		v1.GET("/stocks/filters/:field", a.GetFilterValues)
+		v1.GET("/stocks/stats/ratings", a.GetRatingDistribution)
+		v1.GET("/stocks/stats/actions", a.GetActionDistribution)
 
-		v1.GET("/recommendations", a.GetRecommendations)
+		v1.GET("/tickers/:ticker/consensus", a.GetTargetConsensus)
+
+		v1.GET("/brokerages/stats", a.GetBrokerageStats)
+		v1.GET("/stats", a.GetStats)
+		v1.GET("/stats/score-distribution", a.GetScoreDistribution)
+		v1.GET("/stats/data-quality", a.GetDataQualityReport)
+		v1.GET("/stats/brokerages", a.GetBrokerageAnalytics)
+
+		v1.GET("/recommendations", a.StrictQuery(recommendationParams{}), a.GetRecommendations)
+		v1.GET("/recommendations/new-coverage", a.GetNewCoverage)
+
+		if a.viewsService != nil {
+			v1.GET("/views", a.ListViews)
+			v1.GET("/views/:slug", a.GetView)
+		}
+
+		if a.authMode == AuthModeJWT {
+			v1.POST("/auth/token", a.IssueToken)
+		}
 
 		protected := v1.Group("")
-		protected.Use(a.BasicAuthMiddleware())
+		protected.Use(a.AuthMiddleware())
 		{
-			protected.POST("/sync", a.SyncStocks)
+			protected.GET("/sync/status", a.GetSyncStatus)
+			protected.GET("/sync/runs", a.GetSyncRuns)
+			if a.syncProgress != nil {
+				protected.GET("/sync/stream", a.StreamSyncProgress)
+			}
+		}
+
+		// Routes that change data are for admins only; viewers get a 403.
+		admin := protected.Group("")
+		admin.Use(a.RequireRole(stockviewer.RoleAdmin))
+		{
+			admin.POST("/sync", a.SyncStocks)
+			admin.PATCH("/stocks/:id", a.UpdateStock)
+
+			if a.viewsService != nil {
+				admin.POST("/views", a.CreateView)
+				admin.PUT("/views/:slug", a.UpdateView)
+				admin.DELETE("/views/:slug", a.DeleteView)
+			}
 		}
 	}
 }
@@ -55,8 +312,9 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Accept-Language, X-Currency, X-Verbosity, Idempotency-Key, X-API-Key")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, Link, X-Total-Count, X-Page, X-Page-Size, X-Total-Pages, Idempotent-Replayed")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -67,12 +325,316 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-func (a *API) BasicAuthMiddleware() gin.HandlerFunc {
+// MaxBodyBytes caps request bodies at limit bytes. A declared Content-Length
+// over the limit is answered with 413 before the handler runs; a longer body
+// without one fails to read with *http.MaxBytesError, which respondError also
+// answers with 413.
+func MaxBodyBytes(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			status, resp := errorResponse(&http.MaxBytesError{Limit: limit})
+			c.AbortWithStatusJSON(status, resp)
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+		c.Next()
+	}
+}
+
+// RequestContextMiddleware parses the caller's preferences once and attaches
+// them to the request context as a stockviewer.RequestContext. For each
+// preference a query parameter wins over its header, which wins over the
+// default: lang over Accept-Language, currency over X-Currency and verbosity
+// over X-Verbosity. Accept-Language is negotiated, so unsupported languages
+// fall back to the default; any other unsupported value is a 400. The tier
+// comes only from the credentials.
+func (a *API) RequestContextMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prefs, err := a.parseRequestContext(c)
+		if err != nil {
+			c.AbortWithStatusJSON(errorResponse(err))
+			return
+		}
+
+		c.Request = c.Request.WithContext(stockviewer.WithRequestContext(c.Request.Context(), prefs))
+		c.Header("Content-Language", prefs.Locale)
+		c.Header("Vary", "Accept-Language")
+		c.Next()
+	}
+}
+
+// StrictQuery checks the request's query keys against the parameters
+// declared by the form tags of params, the preference parameters every
+// route accepts and extra. What happens to unknown keys depends on the
+// API's StrictQueryMode.
+func (a *API) StrictQuery(params any, extra ...string) gin.HandlerFunc {
+	allowed := append(query.FormKeys(params), preferenceParams...)
+	allowed = append(allowed, extra...)
+
+	return func(c *gin.Context) {
+		if a.strictQuery != StrictQueryLog && a.strictQuery != StrictQueryEnforce {
+			c.Next()
+			return
+		}
+		unknown := query.UnknownKeys(c.Request.URL.Query(), allowed)
+		if len(unknown) == 0 {
+			c.Next()
+			return
+		}
+
+		if a.strictQuery == StrictQueryEnforce {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid parameters",
+				Message: "unknown query parameters: " + strings.Join(unknown, ", "),
+			})
+			return
+		}
+		log.Printf("Unknown query parameters on %s %s: %s", c.Request.Method, c.FullPath(), strings.Join(unknown, ", "))
+		c.Next()
+	}
+}
+
+func (a *API) parseRequestContext(c *gin.Context) (stockviewer.RequestContext, error) {
+	prefs := stockviewer.DefaultRequestContext()
+
+	if locale, ok := negotiateLocale(c.GetHeader("Accept-Language")); ok {
+		prefs.Locale = locale
+	}
+	if lang := c.Query("lang"); lang != "" {
+		locale, ok := stockviewer.MatchLocale(lang)
+		if !ok {
+			return prefs, stockviewer.ValidationError{Field: "lang", Message: "must be one of: en, es"}
+		}
+		prefs.Locale = locale
+	}
+
+	if value, source := preference(c, "currency", "X-Currency"); source != "" {
+		currency, ok := stockviewer.ParseCurrency(value)
+		if !ok {
+			return prefs, stockviewer.ValidationError{Field: source, Message: "must be USD"}
+		}
+		prefs.Currency = currency
+	}
+
+	if value, source := preference(c, "verbosity", "X-Verbosity"); source != "" {
+		verbosity, ok := stockviewer.ParseVerbosity(value)
+		if !ok {
+			return prefs, stockviewer.ValidationError{Field: source, Message: "must be one of: compact, normal, full"}
+		}
+		prefs.Verbosity = verbosity
+	}
+
+	if role, ok := a.requestRole(c.Request); ok {
+		prefs.Tier = stockviewer.TierAuthenticated
+		prefs.Role = role
+	}
+
+	return prefs, nil
+}
+
+// requestRole returns the role of the request's credentials. A request that
+// sends an API key while keys are configured is judged by the key alone, as
+// WithAPIKey does.
+func (a *API) requestRole(r *http.Request) (stockviewer.Role, bool) {
+	if len(a.apiKeys) > 0 && r.Header.Get(APIKeyHeader) != "" {
+		return a.apiKeyRole(r)
+	}
+	if role, ok := a.credentialsRole(r); ok {
+		return role, true
+	}
+	return a.bearerTokenRole(r)
+}
+
+// apiKeyRole returns the role of the request's API key. Every configured
+// key is compared in constant time.
+func (a *API) apiKeyRole(r *http.Request) (stockviewer.Role, bool) {
+	key := r.Header.Get(APIKeyHeader)
+	var role stockviewer.Role
+	for _, apiKey := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey.Key)) == 1 && key != "" && role == "" {
+			role = apiKey.Role
+		}
+	}
+	return role, role != ""
+}
+
+// credentialsRole returns the role of the request's basic auth credentials.
+// Every configured user and password is compared, in constant time, so
+// neither the response nor its timing tells a wrong user apart from a wrong
+// password.
+func (a *API) credentialsRole(r *http.Request) (stockviewer.Role, bool) {
+	user, password, hasAuth := r.BasicAuth()
+	var role stockviewer.Role
+	for _, cred := range a.credentials {
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(cred.user)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(cred.password)) == 1
+		if hasAuth && userOK && passwordOK && role == "" {
+			role = cred.role
+		}
+	}
+	return role, role != ""
+}
+
+// bearerTokenRole returns the role of a valid, unexpired bearer token. It
+// never succeeds outside AuthModeJWT. Tokens without a known role claim get
+// RoleViewer, the least privileged.
+func (a *API) bearerTokenRole(r *http.Request) (stockviewer.Role, bool) {
+	if a.authMode != AuthModeJWT {
+		return "", false
+	}
+	token, ok := bearerToken(r)
+	if !ok {
+		return "", false
+	}
+	claims, err := a.tokens.Verify(token)
+	if err != nil {
+		return "", false
+	}
+	role, ok := stockviewer.ParseRole(claims.Role)
+	if !ok {
+		role = stockviewer.RoleViewer
+	}
+	return role, true
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// preference returns the query parameter, or the header when the parameter
+// is absent, together with the name of the one that was used.
+func preference(c *gin.Context, param, header string) (value, source string) {
+	if value := c.Query(param); value != "" {
+		return value, param
+	}
+	if value := c.GetHeader(header); value != "" {
+		return value, header
+	}
+	return "", ""
+}
+
+// negotiateLocale picks the supported locale with the highest weight in an
+// Accept-Language header; on equal weights the first listed wins.
+func negotiateLocale(header string) (string, bool) {
+	best, bestWeight := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if locale, ok := stockviewer.MatchLocale(tag); ok && weight > bestWeight {
+			best, bestWeight = locale, weight
+		}
+	}
+	return best, best != ""
+}
+
+// AuthMiddleware guards protected routes with the configured AuthMode and,
+// when API keys are configured, accepts an API key instead.
+func (a *API) AuthMiddleware() gin.HandlerFunc {
+	mode := a.BasicAuthMiddleware()
+	if a.authMode == AuthModeJWT {
+		mode = a.JWTMiddleware()
+	}
+	if len(a.apiKeys) == 0 {
+		return mode
+	}
+	return a.WithAPIKey(mode)
+}
+
+// WithAPIKey lets a route that next guards also accept an API key: requests
+// with an X-API-Key header are judged by APIKeyMiddleware alone, the rest by
+// next.
+func (a *API) WithAPIKey(next gin.HandlerFunc) gin.HandlerFunc {
+	apiKey := a.APIKeyMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader(APIKeyHeader) != "" {
+			apiKey(c)
+			return
+		}
+		next(c)
+	}
+}
+
+// APIKeyMiddleware rejects callers without a configured key in the
+// X-API-Key header. Missing and unknown keys get the same 401 body.
+func (a *API) APIKeyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := a.apiKeyRole(c.Request); !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "Unauthorized",
+				Message: "Invalid API key",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// JWTMiddleware rejects callers without a valid bearer token issued by
+// POST /auth/token. Basic auth credentials are not accepted in its place.
+func (a *API) JWTMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		user, password, hasAuth := c.Request.BasicAuth()
+		token, ok := bearerToken(c.Request)
+		if !ok {
+			c.Header("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", a.basicAuthRealm))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "Unauthorized",
+				Message: "Bearer token required",
+			})
+			return
+		}
 
-		if !hasAuth || user != a.basicAuthUser || password != a.basicAuthPassword {
-			c.Header("WWW-Authenticate", "Basic realm=Authorization Required")
+		if _, err := a.tokens.Verify(token); err != nil {
+			message := "Invalid token"
+			if errors.Is(err, authtoken.ErrExpiredToken) {
+				message = "Token expired"
+			}
+			c.Header("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\"", a.basicAuthRealm))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "Unauthorized",
+				Message: message,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole rejects authenticated callers without role with a 403. It must
+// run after AuthMiddleware, which turns away anonymous callers with a 401.
+func (a *API) RequireRole(role stockviewer.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if stockviewer.RoleFrom(c.Request.Context()) != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+				Error:   "Forbidden",
+				Message: fmt.Sprintf("Requires the %s role", role),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// BasicAuthMiddleware rejects callers without valid basic auth credentials.
+// Missing, unknown and wrong credentials all get the same 401 body.
+func (a *API) BasicAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := a.credentialsRole(c.Request); !ok {
+			c.Header("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", a.basicAuthRealm))
 			c.JSON(401, ErrorResponse{
 				Error:   "Unauthorized",
 				Message: "Invalid credentials",
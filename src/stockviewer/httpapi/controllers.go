@@ -1,11 +1,26 @@
 package httpapi
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/swaggo/swag"
 	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/idempotency"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/query"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/syncprogress"
 )
 
 // Ping godoc
@@ -24,67 +39,200 @@ func (a *API) Ping(c *gin.Context) {
 }
 
 // HealthCheck godoc
-// @Summary      Detailed health check
-// @Description  Returns detailed health status of the service
+// @Summary      Liveness check
+// @Description  Reports that the process is up without touching the database; use /ready to know whether it can serve traffic. sync_success is the share of sync runs in the rolling SYNC_SUCCESS_WINDOW that completed, as computed after the last sync; it is omitted until one has been computed and its rate is null when no run finished in the window
 // @Tags         health
 // @Accept       json
 // @Produce      json
 // @Success      200  {object}  SuccessResponse
 // @Router       /health [get]
 func (a *API) HealthCheck(c *gin.Context) {
+	health := map[string]any{
+		"status":  "healthy",
+		"service": "go-stock-viewer-back",
+	}
+	if rate := a.stocksService.GetSyncSuccessRate(c.Request.Context()); rate != nil {
+		health["sync_success"] = rate
+	}
+	c.JSON(http.StatusOK, SuccessResponse{Data: health})
+}
+
+// ReadinessCheck godoc
+// @Summary      Readiness check
+// @Description  Reports whether the instance can serve traffic: the database must answer a ping and its schema must be within the range this binary supports
+// @Tags         health
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  SuccessResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /ready [get]
+func (a *API) ReadinessCheck(c *gin.Context) {
+	if err := a.stocksService.Ping(c.Request.Context()); err != nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "Not ready",
+			Message: "database unreachable: " + err.Error(),
+		})
+		return
+	}
+	if a.readinessCheck != nil {
+		if err := a.readinessCheck(c.Request.Context()); err != nil {
+			c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+				Error:   "Not ready",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, SuccessResponse{
 		Data: map[string]string{
-			"status":  "healthy",
-			"service": "go-stock-viewer-back",
+			"status": "ready",
 		},
 	})
 }
 
+// GetOpenAPISpec godoc
+// @Summary      Download the API specification
+// @Description  Returns the raw OpenAPI/Swagger JSON document, the same one served at /swagger/doc.json
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  map[string]any
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/openapi.json [get]
+func (a *API) GetOpenAPISpec(c *gin.Context) {
+	doc, err := swag.ReadDoc()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(doc))
+}
+
 // GetStocks godoc
 // @Summary      List stocks
 // @Description  Get a paginated list of stocks with optional filters
 // @Tags         stocks
 // @Accept       json
 // @Produce      json
-// @Param        ticker     query     string  false  "Filter by ticker symbol"
+// @Param        ticker     query     string  false  "Filter by ticker symbol (substring match unless ticker_exact is set)"
+// @Param        ticker_exact query   bool    false  "Match the ticker exactly (case-insensitive) instead of by substring"
 // @Param        company    query     string  false  "Filter by company name"
+// @Param        notes      query     string  false  "Search within analyst notes; :none matches stocks without notes and :any stocks with notes"
+// @Param        sector     query     string  false  "Filter by sector (set by the enrichment step)"
+// @Param        industry   query     string  false  "Filter by industry (set by the enrichment step)"
 // @Param        brokerage  query     string  false  "Filter by brokerage"
-// @Param        rating     query     string  false  "Filter by rating"
+// @Param        rating     query     string  false  "Filter by rating (rating_to)"
+// @Param        rating_from query    string  false  "Filter by previous rating; combine with rating to select a transition"
 // @Param        action     query     string  false  "Filter by action"
-// @Param        sort_by    query     string  false  "Sort by field (ticker, company, recommend_score, created_at)"
-// @Param        sort_order query     string  false  "Sort order (ASC, DESC)"
+// @Param        action_category query string false "Filter by action sentiment"  Enums(positive, negative, neutral)
+// @Param        actions    query     []string  false  "Filter by any of these actions; repeat the parameter for each value"  collectionFormat(multi)
+// @Param        group_by   query     string  false  "Return only the most recent entry per ticker; counts and pages cover distinct tickers"  Enums(ticker)
+// @Param        include_unrated query bool   false  "Include stocks whose rating is not recognized (hidden by default)"
+// @Param        sort_by    query     string  false  "Comma-separated sort fields, later fields break ties (ticker, company, brokerage, recommend_score, created_at, updated_at)"
+// @Param        sort_order query     string  false  "Comma-separated directions (ASC, DESC) matching sort_by by position; missing ones default to DESC"
 // @Param        page       query     int     false  "Page number"  default(1)
-// @Param        page_size  query     int     false  "Items per page"  default(20)
+// @Param        page_size  query     int     false  "Items per page, between 1 and MAX_PAGE_SIZE (100 by default); other values are rejected"  default(20)
+// @Param        prefetch   query     bool    false  "Attach a prefetch_token to each item for use with GET /api/v1/stocks/{id}"
 // @Success      200  {object}  PaginatedSuccessResponse
-// @Failure      400  {object}  ErrorResponse
+// @Header       200  {string}   Link           "RFC 5988 links to the first, prev, next and last pages"
+// @Header       200  {integer}  X-Total-Count  "Total number of matching stocks"
+// @Header       200  {integer}  X-Page         "Page number served"
+// @Header       200  {integer}  X-Page-Size    "Page size after clamping"
+// @Header       200  {integer}  X-Total-Pages  "Total number of pages"
+// @Failure      400  {object}  ErrorResponse  "Invalid parameters, or unknown ones when STRICT_QUERY_PARAMS=enforce"
 // @Failure      500  {object}  ErrorResponse
 // @Router       /api/v1/stocks [get]
 func (a *API) GetStocks(c *gin.Context) {
-	var filter stockviewer.StockFilter
-	if err := c.ShouldBindQuery(&filter); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Invalid parameters",
-			Message: err.Error(),
-		})
+	filter, errs := query.ParseStockFilter(c.Request.URL.Query(), a.pageLimits.For(c.Request.Context()))
+	if len(errs) > 0 {
+		respondError(c, validationErrors(errs))
+		return
+	}
+
+	a.listStocks(c, filter, true)
+}
+
+// QueryStocks godoc
+// @Summary      Query stocks
+// @Description  Same as GET /api/v1/stocks, but takes the filter as a JSON body so large filter sets do not have to fit in a URL. page_size must be between 1 and MAX_PAGE_SIZE (100 by default)
+// @Tags         stocks
+// @Accept       json
+// @Produce      json
+// @Param        filter     body      stockviewer.StockFilter  true   "Stock filter"
+// @Param        prefetch   query     bool    false  "Attach a prefetch_token to each item for use with GET /api/v1/stocks/{id}"
+// @Success      200  {object}  PaginatedSuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      413  {object}  ErrorResponse  "Body over MAX_BODY_BYTES"
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stocks/query [post]
+func (a *API) QueryStocks(c *gin.Context) {
+	filter, err := decodeStockFilter(c.Request.Body, a.pageLimits.For(c.Request.Context()))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
+	a.listStocks(c, filter, false)
+}
+
+// listStocks runs filter through the stocks service and writes the paginated
+// response; GET /stocks and POST /stocks/query differ only in how the filter
+// is bound. linkHeaders adds Link and X-Total-Count headers, which only make
+// sense when the page is selected by the request URL.
+func (a *API) listStocks(c *gin.Context, filter stockviewer.StockFilter, linkHeaders bool) {
 	result, err := a.stocksService.GetStocks(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Internal server error",
-			Message: err.Error(),
-		})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, PaginatedSuccessResponse{
-		Data:       result.Data,
-		Page:       result.Page,
-		PageSize:   result.PageSize,
-		TotalItems: result.TotalItems,
-		TotalPages: result.TotalPages,
-	})
+	if a.prefetch != nil && c.Query("prefetch") == "true" {
+		etag := a.prefetch.Snapshot(result.Data)
+		for i := range result.Data {
+			result.Data[i].PrefetchToken = a.prefetch.Token(result.Data[i].ID, etag)
+		}
+		c.Header("ETag", `"`+etag+`"`)
+	}
+
+	if linkHeaders {
+		c.Header("X-Total-Count", strconv.FormatInt(result.TotalItems, 10))
+		c.Header("X-Page", strconv.Itoa(result.Page))
+		c.Header("X-Page-Size", strconv.Itoa(result.PageSize))
+		c.Header("X-Total-Pages", strconv.FormatInt(result.TotalPages, 10))
+		if links := paginationLinks(c.Request.URL, result); links != "" {
+			c.Header("Link", links)
+		}
+	}
+
+	c.JSON(http.StatusOK, newPaginatedSuccessResponse(result))
+}
+
+// paginationLinks builds an RFC 5988 Link header value with first, prev, next
+// and last relations for result. Each link is the request path and query
+// with only page replaced; prev and next are left out when there is no such
+// page, and all four when there are no pages at all.
+func paginationLinks(u *url.URL, result *stockviewer.PaginatedResponse) string {
+	if result.TotalPages < 1 {
+		return ""
+	}
+
+	link := func(page int64, rel string) string {
+		values := u.Query()
+		values.Set("page", strconv.FormatInt(page, 10))
+		target := url.URL{Path: u.Path, RawQuery: values.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, target.String(), rel)
+	}
+
+	links := []string{link(1, "first")}
+	if result.PrevPage != nil {
+		links = append(links, link(int64(*result.PrevPage), "prev"))
+	}
+	if result.NextPage != nil {
+		links = append(links, link(int64(*result.NextPage), "next"))
+	}
+	links = append(links, link(result.TotalPages, "last"))
+	return strings.Join(links, ", ")
 }
 
 // GetStockByID godoc
@@ -93,34 +241,107 @@ func (a *API) GetStocks(c *gin.Context) {
 // @Tags         stocks
 // @Accept       json
 // @Produce      json
-// @Param        id   path      string  true  "Stock ID"
+// @Param        id              path      string  true   "Stock ID"
+// @Param        prefetch_token  query     string  false  "Token from a list response; serves the row from the list snapshot while it is still valid"
 // @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
 // @Failure      404  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Router       /api/v1/stocks/{id} [get]
 func (a *API) GetStockByID(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Invalid ID",
-			Message: "Stock ID is required",
-		})
+		respondError(c, stockviewer.ValidationError{Field: "id", Message: "is required"})
+		return
+	}
+	if !isValidStockID(id) {
+		respondError(c, stockviewer.ValidationError{Field: "id", Message: "must be 32 hexadecimal characters"})
 		return
 	}
+	id = strings.ToLower(id)
 
-	stock, err := a.stocksService.GetStock(c.Request.Context(), id)
-	if err != nil {
-		if err == stockviewer.ErrStockNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Error:   "Not found",
-				Message: "Stock not found",
+	if token := c.Query("prefetch_token"); token != "" && a.prefetch != nil {
+		if stock, ok := a.prefetch.Lookup(token, id); ok {
+			c.Header("X-Prefetch", "hit")
+			c.JSON(http.StatusOK, SuccessResponse{
+				Data: stock,
 			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Internal server error",
-			Message: err.Error(),
-		})
+	}
+
+	stock, err := a.stocksService.GetStock(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: stock,
+	})
+}
+
+// isValidStockID reports whether id has the shape of a stock ID: the hex
+// encoding of an MD5 sum, as generated during sync.
+func isValidStockID(id string) bool {
+	if len(id) != hex.EncodedLen(md5.Size) {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// parseTimestampParam parses the RFC3339 query parameter field, reporting a
+// malformed value as a ValidationError on field.
+func parseTimestampParam(field, raw string) (time.Time, error) {
+	t, err := stockviewer.ParseTimestamp(raw)
+	if err == nil {
+		return t, nil
+	}
+	validationErr := stockviewer.ValidationError{Field: field, Message: err.Error()}
+	if errors.As(err, &validationErr) {
+		validationErr.Field = field
+	}
+	return time.Time{}, validationErr
+}
+
+// UpdateStock godoc
+// @Summary      Override a stock's score or notes
+// @Description  Manually set recommend_score (0-100) and/or notes. An overridden score is kept by later syncs. Other fields are rejected
+// @Tags         stocks
+// @Accept       json
+// @Produce      json
+// @Security     BasicAuth
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        id      path      string                   true  "Stock ID"
+// @Param        update  body      stockviewer.StockUpdate  true  "Fields to override"
+// @Success      200  {object}  SuccessResponse{data=stockviewer.Stock}
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse  "Requires the admin role"
+// @Failure      404  {object}  ErrorResponse
+// @Failure      413  {object}  ErrorResponse  "Body over MAX_BODY_BYTES"
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stocks/{id} [patch]
+func (a *API) UpdateStock(c *gin.Context) {
+	id := c.Param("id")
+	if !isValidStockID(id) {
+		respondError(c, stockviewer.ValidationError{Field: "id", Message: "must be 32 hexadecimal characters"})
+		return
+	}
+
+	var update stockviewer.StockUpdate
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&update); err != nil {
+		respondError(c, decodeError(err))
+		return
+	}
+
+	stock, err := a.stocksService.UpdateStock(c.Request.Context(), strings.ToLower(id), update)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -129,25 +350,64 @@ func (a *API) GetStockByID(c *gin.Context) {
 	})
 }
 
+// GetStockHistory godoc
+// @Summary      Get ticker history
+// @Description  List every analyst entry recorded for a ticker, across brokerages and dates, newest first
+// @Tags         stocks
+// @Accept       json
+// @Produce      json
+// @Param        ticker     path      string  true   "Ticker symbol"
+// @Param        page       query     int     false  "Page number"  default(1)
+// @Param        page_size  query     int     false  "Items per page, between 1 and MAX_PAGE_SIZE"  default(20)
+// @Success      200  {object}  PaginatedSuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stocks/{ticker}/history [get]
+func (a *API) GetStockHistory(c *gin.Context) {
+	params := c.Request.URL.Query()
+	filter, errs := query.ParseStockFilter(map[string][]string{
+		"page":      params["page"],
+		"page_size": params["page_size"],
+	}, a.pageLimits.For(c.Request.Context()))
+	if len(errs) > 0 {
+		respondError(c, validationErrors(errs))
+		return
+	}
+
+	result, err := a.stocksService.GetStockHistory(c.Request.Context(), c.Param("id"), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPaginatedSuccessResponse(result))
+}
+
+// searchParams declares the query parameters of SearchStocks for
+// StrictQuery.
+type searchParams struct {
+	Q     string `form:"q"`
+	Limit int    `form:"limit"`
+	Fuzzy bool   `form:"fuzzy"`
+}
+
 // SearchStocks godoc
 // @Summary      Search stocks
-// @Description  Search stocks by ticker or company name
+// @Description  Search stocks by ticker or company name. Whole words are matched with full-text search along with tickers starting with the query, ranked an exact ticker first, then ticker prefixes, then by relevance; when that finds nothing, as for a company fragment, the query is matched as a substring. With fuzzy=true, near matches by trigram similarity, or edit distance without pg_trgm, are included so typos still find results. Each result has a match_type of exact, text or fuzzy. An empty q is rejected unless SEARCH_EMPTY_QUERY=recent, which lists the newest entries instead
 // @Tags         stocks
 // @Accept       json
 // @Produce      json
-// @Param        q      query     string  true   "Search query"
+// @Param        q      query     string  false  "Search query; required unless SEARCH_EMPTY_QUERY=recent"
 // @Param        limit  query     int     false  "Maximum results"  default(10)
+// @Param        fuzzy  query     bool    false  "Include trigram-similar matches"  default(false)
 // @Success      200  {object}  SuccessResponse
-// @Failure      400  {object}  ErrorResponse
+// @Failure      400  {object}  ErrorResponse  "Invalid parameters, or unknown ones when STRICT_QUERY_PARAMS=enforce"
 // @Failure      500  {object}  ErrorResponse
 // @Router       /api/v1/stocks/search [get]
 func (a *API) SearchStocks(c *gin.Context) {
 	query := c.Query("q")
-	if query == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Invalid query",
-			Message: "Search query is required",
-		})
+	if query == "" && a.emptySearch != EmptySearchRecent {
+		respondError(c, stockviewer.ValidationError{Field: "q", Message: "is required"})
 		return
 	}
 
@@ -158,12 +418,19 @@ func (a *API) SearchStocks(c *gin.Context) {
 		}
 	}
 
-	stocks, err := a.stocksService.SearchStocks(c.Request.Context(), query, limit)
+	fuzzy := false
+	if raw := c.Query("fuzzy"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(c, stockviewer.ValidationError{Field: "fuzzy", Message: "must be a boolean"})
+			return
+		}
+		fuzzy = parsed
+	}
+
+	stocks, err := a.stocksService.SearchStocks(c.Request.Context(), query, limit, fuzzy)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Internal server error",
-			Message: err.Error(),
-		})
+		respondError(c, err)
 		return
 	}
 
@@ -172,22 +439,152 @@ func (a *API) SearchStocks(c *gin.Context) {
 	})
 }
 
+// GetStockChanges godoc
+// @Summary      List recently changed stocks
+// @Description  List stocks with updated_at after since, oldest first, ties ordered by id. Pass next_since and next_after_id from the response as since and after_id on the next poll, so a batch ending among stocks that share updated_at resumes with the rest of them
+// @Tags         stocks
+// @Accept       json
+// @Produce      json
+// @Param        since     query     string  true   "RFC3339 timestamp, e.g. 2024-05-01T00:00:00Z"
+// @Param        after_id  query     string  false  "ID of the last stock seen at since; only stocks at since with a greater ID are listed"
+// @Param        limit     query     int     false  "Maximum results (capped at 1000)"  default(100)
+// @Success      200  {object}  ChangesResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stocks/changes [get]
+func (a *API) GetStockChanges(c *gin.Context) {
+	raw := c.Query("since")
+	if raw == "" {
+		respondError(c, stockviewer.ValidationError{Field: "since", Message: "is required"})
+		return
+	}
+	since, err := parseTimestampParam("since", raw)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	limit := 0
+	if rawLimit := c.Query("limit"); rawLimit != "" {
+		limit, err = strconv.Atoi(rawLimit)
+		if err != nil {
+			respondError(c, stockviewer.ValidationError{Field: "limit", Message: "must be an integer"})
+			return
+		}
+	}
+
+	changes, err := a.stocksService.GetChanges(c.Request.Context(), since, c.Query("after_id"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ChangesResponse{
+		Data:        changes.Stocks,
+		Count:       len(changes.Stocks),
+		NextSince:   changes.NextSince.Format(time.RFC3339Nano),
+		NextAfterID: changes.NextAfterID,
+	})
+}
+
+// GetNewTickers godoc
+// @Summary      List newly covered tickers
+// @Description  List tickers whose first-ever entry was created during the most recent completed sync, or since the given timestamp, oldest first
+// @Tags         stocks
+// @Accept       json
+// @Produce      json
+// @Param        since_last_sync  query     bool    false  "Use the window of the most recent completed sync"  default(true)
+// @Param        since            query     string  false  "RFC3339 timestamp; required when since_last_sync is false"
+// @Success      200  {object}  NewTickersResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stocks/new [get]
+func (a *API) GetNewTickers(c *gin.Context) {
+	sinceLastSync := true
+	if raw := c.Query("since_last_sync"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(c, stockviewer.ValidationError{Field: "since_last_sync", Message: "must be a boolean"})
+			return
+		}
+		sinceLastSync = parsed
+	}
+
+	var since *time.Time
+	if !sinceLastSync {
+		raw := c.Query("since")
+		if raw == "" {
+			respondError(c, stockviewer.ValidationError{Field: "since", Message: "is required when since_last_sync is false"})
+			return
+		}
+		parsed, err := parseTimestampParam("since", raw)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		since = &parsed
+	}
+
+	tickers, err := a.stocksService.GetNewTickers(c.Request.Context(), since)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	resp := NewTickersResponse{
+		Data:  tickers.Tickers,
+		Count: len(tickers.Tickers),
+	}
+	if !tickers.To.IsZero() {
+		resp.From = tickers.From.Format(time.RFC3339Nano)
+		resp.To = tickers.To.Format(time.RFC3339Nano)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
 // GetFilters godoc
 // @Summary      Get available filters
-// @Description  Get available filter options for stocks (brokerages, ratings, actions)
+// @Description  Get available filter options for stocks (brokerages, ratings, previous ratings, actions). With counts=true the response also carries each brokerage, rating and action with its number of stocks, narrowed by the same filter parameters as GET /api/v1/stocks; each facet ignores its own parameter so the alternatives stay visible
 // @Tags         stocks
 // @Accept       json
 // @Produce      json
-// @Success      200  {object}  SuccessResponse
+// @Param        q          query     string  false  "Only return brokerage/rating values starting with this prefix (case-insensitive)"
+// @Param        counts     query     bool    false  "Include per-value stock counts"  default(false)
+// @Param        ticker     query     string  false  "Narrow the counts by ticker symbol"
+// @Param        company    query     string  false  "Narrow the counts by company name"
+// @Param        sector     query     string  false  "Narrow the counts by sector"
+// @Param        industry   query     string  false  "Narrow the counts by industry"
+// @Param        brokerage  query     string  false  "Narrow the rating and action counts by brokerage"
+// @Param        rating     query     string  false  "Narrow the brokerage and action counts by rating"
+// @Param        action     query     string  false  "Narrow the brokerage and rating counts by action"
+// @Param        action_category query string false "Narrow the brokerage and rating counts by action sentiment"  Enums(positive, negative, neutral)
+// @Param        include_unrated query bool   false  "Count stocks whose rating is not recognized"
+// @Success      200  {object}  SuccessResponse{data=stockviewer.FiltersResponse}
+// @Failure      400  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Router       /api/v1/stocks/filters [get]
 func (a *API) GetFilters(c *gin.Context) {
-	filters, err := a.stocksService.GetFilters(c.Request.Context())
+	filtersQuery := stockviewer.FiltersQuery{Prefix: c.Query("q")}
+	if raw := c.Query("counts"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(c, stockviewer.ValidationError{Field: "counts", Message: "must be a boolean"})
+			return
+		}
+		filtersQuery.Counts = parsed
+	}
+	if filtersQuery.Counts {
+		filter, errs := query.ParseStockFilter(c.Request.URL.Query(), a.pageLimits.For(c.Request.Context()))
+		if len(errs) > 0 {
+			respondError(c, validationErrors(errs))
+			return
+		}
+		filtersQuery.Filter = filter
+	}
+
+	filters, err := a.stocksService.GetFilters(c.Request.Context(), filtersQuery)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Internal server error",
-			Message: err.Error(),
-		})
+		respondError(c, err)
 		return
 	}
 
@@ -196,72 +593,881 @@ func (a *API) GetFilters(c *gin.Context) {
 	})
 }
 
-// GetRecommendations godoc
-// @Summary      Get stock recommendations
-// @Description  Get top recommended stocks based on the recommendation algorithm
-// @Tags         recommendations
+// GetFilterValues godoc
+// @Summary      Page through a filter field's values
+// @Description  Get every distinct value of a filter field with the number of stocks that have it, most common first
+// @Tags         stocks
 // @Accept       json
 // @Produce      json
-// @Param        limit  query     int     false  "Maximum recommendations"  default(10)
-// @Success      200  {object}  SuccessResponse
+// @Param        field   path      string  true   "Filter field"  Enums(brokerages, ratings, ratings_from)
+// @Param        query   query     string  false  "Only return values starting with this prefix (case-insensitive)"
+// @Param        limit   query     int     false  "Maximum number of values (max 500)"  default(50)
+// @Param        offset  query     int     false  "Number of values to skip"  default(0)
+// @Success      200  {object}  SuccessResponse{data=stockviewer.FilterValues}
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
-// @Router       /api/v1/recommendations [get]
-func (a *API) GetRecommendations(c *gin.Context) {
-	limit := 10
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
+// @Router       /api/v1/stocks/filters/{field} [get]
+func (a *API) GetFilterValues(c *gin.Context) {
+	distinct := stockviewer.DistinctQuery{Prefix: c.Query("query")}
+	params := []struct {
+		name   string
+		target *int
+	}{{"limit", &distinct.Limit}, {"offset", &distinct.Offset}}
+	for _, p := range params {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
 		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			respondError(c, stockviewer.ValidationError{Field: p.name, Message: "must be an integer"})
+			return
+		}
+		*p.target = n
 	}
 
-	recommendations, err := a.recommendationService.GetTopRecommendations(c.Request.Context(), limit)
+	values, err := a.stocksService.GetFilterValues(c.Request.Context(), c.Param("field"), distinct)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Internal server error",
-			Message: err.Error(),
-		})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse{
-		Data: recommendations,
+		Data: values,
 	})
 }
 
-// SyncStocks godoc
-// @Summary      Sync stocks from external API
-// @Description  Fetch and synchronize stocks from the external KarenAI API
-// @Tags         sync
+// GetBrokerageStats godoc
+// @Summary      Get brokerage statistics
+// @Description  Get per-brokerage recommendation counts, average score and buy/hold/sell breakdown, ordered by total recommendations
+// @Tags         stats
 // @Accept       json
 // @Produce      json
-// @Security     BasicAuth
-// @Success      200  {object}  SyncResponse
-// @Failure      401  {object}  ErrorResponse
-// @Failure      409  {object}  ErrorResponse  "Sync already in progress"
+// @Param        sort_by  query     string  false  "Order descending by this field"  Enums(total_recommendations, avg_score)  default(total_recommendations)
+// @Success      200  {object}  SuccessResponse{data=[]stockviewer.BrokerageStats}
+// @Failure      400  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
-// @Router       /api/v1/sync [post]
-func (a *API) SyncStocks(c *gin.Context) {
-	status, err := a.stocksService.SyncStocks(c.Request.Context())
+// @Router       /api/v1/brokerages/stats [get]
+func (a *API) GetBrokerageStats(c *gin.Context) {
+	a.brokerageStats(c, stockviewer.BrokerageSortTotal)
+}
+
+// GetBrokerageAnalytics godoc
+// @Summary      Compare brokerages
+// @Description  Get per-brokerage ratings issued, average score and buy/hold/sell breakdown, ordered by average score
+// @Tags         stats
+// @Accept       json
+// @Produce      json
+// @Param        sort_by  query     string  false  "Order descending by this field"  Enums(total_recommendations, avg_score)  default(avg_score)
+// @Success      200  {object}  SuccessResponse{data=[]stockviewer.BrokerageStats}
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stats/brokerages [get]
+func (a *API) GetBrokerageAnalytics(c *gin.Context) {
+	a.brokerageStats(c, stockviewer.BrokerageSortAvgScore)
+}
+
+func (a *API) brokerageStats(c *gin.Context, defaultSort string) {
+	stats, err := a.stocksService.GetBrokerageStats(c.Request.Context(), c.DefaultQuery("sort_by", defaultSort))
 	if err != nil {
-		if err == stockviewer.ErrSyncInProgress {
-			c.JSON(http.StatusConflict, ErrorResponse{
-				Error:   "Conflict",
-				Message: "Sync already in progress",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Internal server error",
-			Message: err.Error(),
-		})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, SyncResponse{
-		Status:         status.Status,
-		TotalRecords:   status.TotalRecords,
-		NewRecords:     status.NewRecords,
-		UpdatedRecords: status.UpdatedRecords,
-		LastSync:       status.LastSync.Format("2006-01-02T15:04:05Z07:00"),
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: stats,
+	})
+}
+
+// GetRatingDistribution godoc
+// @Summary      Get rating distribution
+// @Description  Get the number of stocks per current rating, most common first
+// @Tags         stats
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  SuccessResponse{data=[]stockviewer.RatingDistribution}
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stocks/stats/ratings [get]
+func (a *API) GetRatingDistribution(c *gin.Context) {
+	distribution, err := a.stocksService.GetRatingDistribution(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: distribution,
+	})
+}
+
+// GetActionDistribution godoc
+// @Summary      Get action distribution
+// @Description  Get the number of stocks per analyst action, most common first
+// @Tags         stats
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  SuccessResponse{data=[]stockviewer.ActionDistribution}
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stocks/stats/actions [get]
+func (a *API) GetActionDistribution(c *gin.Context) {
+	distribution, err := a.stocksService.GetActionDistribution(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: distribution,
 	})
 }
+
+// GetStats godoc
+// @Summary      Get summary statistics
+// @Description  Get headline numbers for a dashboard: stock count, distinct tickers and brokerages, average recommend score and last sync time
+// @Tags         stats
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  SuccessResponse{data=stockviewer.StatsResponse}
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stats [get]
+func (a *API) GetStats(c *gin.Context) {
+	stats, err := a.stocksService.GetStats(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: stats,
+	})
+}
+
+// GetScoreDistribution godoc
+// @Summary      Get recommend score distribution
+// @Description  Get the number of stocks per recommend_score range of 10 points, from 0-10 to 90-100
+// @Tags         stats
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  SuccessResponse{data=[]stockviewer.ScoreBucket}
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stats/score-distribution [get]
+func (a *API) GetScoreDistribution(c *gin.Context) {
+	distribution, err := a.stocksService.GetScoreDistribution(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: distribution,
+	})
+}
+
+// GetDataQualityReport godoc
+// @Summary      Get data quality report
+// @Description  List the stocks quarantined because the feed sent a price target that could not be parsed (null, an object, a boolean or an unreadable string). Their targets are stored as null and data_issues says what was received. Issues are counted by field and shape, most frequent first, next to the schema warnings of the latest sync
+// @Tags         stats
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  SuccessResponse{data=stockviewer.DataQualityReport}
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stats/data-quality [get]
+func (a *API) GetDataQualityReport(c *gin.Context) {
+	report, err := a.stocksService.GetDataQualityReport(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: report,
+	})
+}
+
+// GetTargetConsensus godoc
+// @Summary      Get price target consensus for a ticker
+// @Description  Get min, max, median, mean and spread of the analyst price targets for a ticker over a recent window; entries without a target are excluded. momentum tells whether the targets are rising or falling, ordered by when each entry was first observed, with magnitude as the trend's percent change of the mean target per 30 days; it is omitted with fewer than two targets
+// @Tags         stocks
+// @Accept       json
+// @Produce      json
+// @Param        ticker  path      string  true   "Ticker symbol"
+// @Param        days    query     int     false  "Window in days"  default(90)
+// @Success      200  {object}  SuccessResponse{data=stockviewer.TargetConsensus}
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/tickers/{ticker}/consensus [get]
+func (a *API) GetTargetConsensus(c *gin.Context) {
+	days := 0
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			respondError(c, stockviewer.ValidationError{Field: "days", Message: "must be an integer"})
+			return
+		}
+		days = parsed
+	}
+
+	consensus, err := a.stocksService.GetTargetConsensus(c.Request.Context(), c.Param("ticker"), days)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: consensus,
+	})
+}
+
+// GetTargetConsensuses godoc
+// @Summary      Get price target summaries for several tickers
+// @Description  Get the price target consensus of up to 50 tickers in one call, computed with a single grouped query. Each summary is the same as GET /api/v1/tickers/{ticker}/consensus returns; summaries follow the order of tickers, upper-cased and without repeats, and a ticker without targets in the window has a count of 0
+// @Tags         stocks
+// @Accept       json
+// @Produce      json
+// @Param        tickers  query     string  true   "Comma-separated tickers, e.g. AAPL,MSFT"
+// @Param        days     query     int     false  "Window in days"  default(90)
+// @Success      200  {object}  SuccessResponse{data=[]stockviewer.TargetConsensus}
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/stocks/summaries [get]
+func (a *API) GetTargetConsensuses(c *gin.Context) {
+	days := 0
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			respondError(c, stockviewer.ValidationError{Field: "days", Message: "must be an integer"})
+			return
+		}
+		days = parsed
+	}
+
+	tickers := strings.Split(c.Query("tickers"), ",")
+	consensuses, err := a.stocksService.GetTargetConsensuses(c.Request.Context(), tickers, days)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: consensuses,
+	})
+}
+
+// recommendationParams declares the query parameters of
+// GetRecommendations for StrictQuery; lang, verbosity and currency are
+// accepted on every route.
+type recommendationParams struct {
+	Limit      int    `form:"limit"`
+	IncludeNew bool   `form:"include_new"`
+	Brokerage  string `form:"brokerage"`
+	Page       int    `form:"page"`
+	PageSize   int    `form:"page_size"`
+	Tier       string `form:"tier"`
+}
+
+// GetRecommendations godoc
+// @Summary      Get stock recommendations
+// @Description  Get a page of the top recommended stocks based on the recommendation algorithm, optionally only those rated by one brokerage. The ranking holds at most 100 recommendations
+// @Tags         recommendations
+// @Accept       json
+// @Produce      json
+// @Param        page       query     int     false  "Page number"  default(1)
+// @Param        page_size  query     int     false  "Recommendations per page (max 100)"  default(10)
+// @Param        limit      query     int     false  "Deprecated: page size when page_size is not given; 0 or out-of-range values use the default"  default(10)
+// @Param        lang       query     string  false  "Locale of the reasons, overrides Accept-Language"  Enums(en, es)
+// @Param        verbosity  query     string  false  "compact drops the breakdown and keeps one reason; full adds the price target move"  Enums(compact, normal, full)
+// @Param        currency   query     string  false  "Display currency of amounts in reasons"  Enums(USD)
+// @Param        include_new  query   bool    false  "Also rank cold-start tickers (flagged with cold_start); not supported with brokerage"  default(false)
+// @Param        brokerage  query     string  false  "Only rank stocks rated by this brokerage (exact name, e.g. Goldman Sachs)"
+// @Param        tier       query     string  false  "Only rank recommendations of this score tier: A 80+, B 65+, C 50+, D 35+, F below"  Enums(A, B, C, D, F)
+// @Success      200  {object}  PaginatedRecommendationResponse
+// @Failure      400  {object}  ErrorResponse  "Invalid parameters, or unknown ones when STRICT_QUERY_PARAMS=enforce"
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/recommendations [get]
+func (a *API) GetRecommendations(c *gin.Context) {
+	filter := stockviewer.RecommendationFilter{Brokerage: c.Query("brokerage"), Tier: c.Query("tier")}
+	if raw := c.Query("include_new"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(c, stockviewer.ValidationError{Field: "include_new", Message: "must be true or false"})
+			return
+		}
+		filter.IncludeNew = parsed
+	}
+
+	// An empty brokerage means every brokerage to the service, so a
+	// brokerage parameter left blank is rejected here.
+	if brokerage, ok := c.GetQuery("brokerage"); ok && strings.TrimSpace(brokerage) == "" {
+		respondError(c, stockviewer.ValidationError{Field: "brokerage", Message: "is required"})
+		return
+	}
+
+	params := []struct {
+		name   string
+		target *int
+	}{{"page", &filter.Page}, {"page_size", &filter.PageSize}}
+	for _, p := range params {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			respondError(c, stockviewer.ValidationError{Field: p.name, Message: "must be an integer"})
+			return
+		}
+		// The service reads a zero page size as "not given", so an
+		// explicit page_size=0 is rejected here, as GetStocks does.
+		if n == 0 && p.name == "page_size" {
+			respondError(c, stockviewer.ValidationError{
+				Field:   "page_size",
+				Message: fmt.Sprintf("must be between 1 and %d", stockviewer.MaxRecommendations),
+			})
+			return
+		}
+		*p.target = n
+	}
+	// limit predates paging; it still sizes the page when page_size is
+	// not given.
+	if _, ok := c.GetQuery("page_size"); !ok {
+		filter.PageSize = recommendationLimit(c)
+	}
+
+	result, err := a.recommendationService.GetTopRecommendations(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	compactRecommendations(c, result.Data)
+	c.JSON(http.StatusOK, newPaginatedRecommendationResponse(result))
+}
+
+// GetNewCoverage godoc
+// @Summary      Get newly covered tickers
+// @Description  List cold-start tickers, those with too few entries or too little history to be ranked with established coverage, ranked by the strength of their initiating rating and then by target upside. Empty when no cold-start threshold is configured
+// @Tags         recommendations
+// @Accept       json
+// @Produce      json
+// @Param        limit  query     int     false  "Maximum recommendations (1-100); 0 or out-of-range values use the default"  default(10)
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/recommendations/new-coverage [get]
+func (a *API) GetNewCoverage(c *gin.Context) {
+	recommendations, err := a.recommendationService.GetNewCoverage(c.Request.Context(), recommendationLimit(c))
+	a.respondRecommendations(c, recommendations, err)
+}
+
+// recommendationLimit reads the limit parameter. Missing, unparseable and
+// out-of-range values, zero included, use DefaultRecommendationLimit as the
+// recommendation service does; limit=0 never asks for an empty list.
+func recommendationLimit(c *gin.Context) int {
+	limit := stockviewer.DefaultRecommendationLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= stockviewer.MaxRecommendations {
+			limit = l
+		}
+	}
+	return limit
+}
+
+func (a *API) respondRecommendations(c *gin.Context, recommendations []stockviewer.StockRecommendation, err error) {
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	compactRecommendations(c, recommendations)
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: recommendations,
+	})
+}
+
+// compactRecommendations drops the score breakdown from compact responses.
+func compactRecommendations(c *gin.Context, recommendations []stockviewer.StockRecommendation) {
+	if stockviewer.VerbosityFrom(c.Request.Context()) == stockviewer.VerbosityCompact {
+		for i := range recommendations {
+			recommendations[i].Breakdown = nil
+		}
+	}
+}
+
+// ListViews godoc
+// @Summary      List saved views
+// @Description  List the built-in and admin-defined saved views
+// @Tags         views
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  SuccessResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/views [get]
+func (a *API) ListViews(c *gin.Context) {
+	views, err := a.viewsService.ListViews(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: views,
+	})
+}
+
+// GetView godoc
+// @Summary      Resolve a saved view
+// @Description  Run a saved view's filter, sort and page size and return the matching stocks with the view's title and description
+// @Tags         views
+// @Accept       json
+// @Produce      json
+// @Param        slug  path      string  true   "View slug"
+// @Param        page  query     int     false  "Page number"  default(1)
+// @Success      200  {object}  ViewResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/views/{slug} [get]
+func (a *API) GetView(c *gin.Context) {
+	page := query.DefaultPage
+	if raw := c.Query("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			respondError(c, stockviewer.ValidationError{Field: "page", Message: "must be a positive integer"})
+			return
+		}
+		page = n
+	}
+
+	resolved, err := a.viewsService.ResolveView(c.Request.Context(), c.Param("slug"), page)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ViewResponse{
+		View:                     resolved.View,
+		PaginatedSuccessResponse: newPaginatedSuccessResponse(resolved.Result),
+	})
+}
+
+// CreateView godoc
+// @Summary      Create or replace a saved view
+// @Description  Store an admin-defined view. The filter is validated against the same rules as POST /api/v1/stocks/query; built-in slugs are reserved
+// @Tags         views
+// @Accept       json
+// @Produce      json
+// @Security     BasicAuth
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        view  body      stockviewer.SavedView  true  "View definition"
+// @Success      201  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse  "Requires the admin role"
+// @Failure      413  {object}  ErrorResponse  "Body over MAX_BODY_BYTES"
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/views [post]
+func (a *API) CreateView(c *gin.Context) {
+	var view stockviewer.SavedView
+	if err := c.ShouldBindJSON(&view); err != nil {
+		respondError(c, decodeError(err))
+		return
+	}
+
+	a.saveView(c, view, http.StatusCreated)
+}
+
+// UpdateView godoc
+// @Summary      Update a saved view
+// @Description  Replace an admin-defined view. The slug in the path takes precedence over the body
+// @Tags         views
+// @Accept       json
+// @Produce      json
+// @Security     BasicAuth
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        slug  path      string                 true  "View slug"
+// @Param        view  body      stockviewer.SavedView  true  "View definition"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse  "Requires the admin role"
+// @Failure      413  {object}  ErrorResponse  "Body over MAX_BODY_BYTES"
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/views/{slug} [put]
+func (a *API) UpdateView(c *gin.Context) {
+	var view stockviewer.SavedView
+	if err := c.ShouldBindJSON(&view); err != nil {
+		respondError(c, decodeError(err))
+		return
+	}
+	view.Slug = c.Param("slug")
+
+	a.saveView(c, view, http.StatusOK)
+}
+
+// DeleteView godoc
+// @Summary      Delete a saved view
+// @Description  Remove an admin-defined view. Built-in views cannot be deleted
+// @Tags         views
+// @Accept       json
+// @Produce      json
+// @Security     BasicAuth
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        slug  path      string  true  "View slug"
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse  "Requires the admin role"
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/views/{slug} [delete]
+func (a *API) DeleteView(c *gin.Context) {
+	if err := a.viewsService.DeleteView(c.Request.Context(), c.Param("slug")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (a *API) saveView(c *gin.Context, view stockviewer.SavedView, status int) {
+	saved, err := a.viewsService.SaveView(c.Request.Context(), view)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(status, SuccessResponse{
+		Data: saved,
+	})
+}
+
+// IssueToken godoc
+// @Summary      Issue a bearer token
+// @Description  Exchange basic auth credentials for an HS256 JWT to send as "Authorization: Bearer <token>" on protected routes. Only registered when AUTH_MODE=jwt
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BasicAuth
+// @Success      200  {object}  TokenResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/auth/token [post]
+func (a *API) IssueToken(c *gin.Context) {
+	role, ok := a.credentialsRole(c.Request)
+	if !ok {
+		c.Header("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", a.basicAuthRealm))
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Unauthorized",
+			Message: "Invalid credentials",
+		})
+		return
+	}
+
+	user, _, _ := c.Request.BasicAuth()
+	token, err := a.tokens.Issue(user, string(role))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken: token.Value,
+		TokenType:   "Bearer",
+		Role:        string(role),
+		ExpiresIn:   int(time.Until(token.ExpiresAt).Seconds()),
+		ExpiresAt:   token.ExpiresAt.Format(time.RFC3339),
+	})
+}
+
+// SyncStocks godoc
+// @Summary      Sync stocks from external API
+// @Description  Start a background sync from the external KarenAI API; poll GET /api/v1/sync/status for the result
+// @Tags         sync
+// @Accept       json
+// @Produce      json
+// @Security     BasicAuth
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        Idempotency-Key  header    string  false  "Replays the response of an earlier accepted POST with the same key instead of starting another sync; at most 255 characters"
+// @Success      202  {object}  SyncResponse
+// @Failure      400  {object}  ErrorResponse  "Idempotency-Key too long"
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse  "Requires the admin role"
+// @Failure      409  {object}  ErrorResponse  "Sync already in progress"
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/sync [post]
+func (a *API) SyncStocks(c *gin.Context) {
+	ctx := c.Request.Context()
+	key := c.GetHeader(idempotencyKeyHeader)
+	if a.idempotency == nil || key == "" {
+		writeSyncStart(c, a.startSync(ctx), false)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		respondError(c, stockviewer.ValidationError{
+			Field:   idempotencyKeyHeader,
+			Message: fmt.Sprintf("must be at most %d characters", maxIdempotencyKeyLength),
+		})
+		return
+	}
+
+	response, replayed := a.idempotency.Do(key, func() idempotency.Response {
+		return a.startSync(ctx)
+	})
+	writeSyncStart(c, response, replayed)
+}
+
+// startSync starts a sync and renders the reply to POST /sync, so it can be
+// stored and replayed for an idempotency key.
+func (a *API) startSync(ctx context.Context) idempotency.Response {
+	if err := a.stocksService.StartSync(ctx); err != nil {
+		return jsonResponse(errorResponse(err))
+	}
+
+	status, err := a.stocksService.GetSyncStatus(ctx)
+	if err != nil {
+		return jsonResponse(errorResponse(err))
+	}
+	return jsonResponse(http.StatusAccepted, newSyncResponse(status))
+}
+
+func writeSyncStart(c *gin.Context, response idempotency.Response, replayed bool) {
+	if replayed {
+		c.Header(idempotentReplayedHeader, "true")
+	}
+	if response.Status == http.StatusAccepted {
+		c.Header("Location", "/api/v1/sync/status")
+	}
+	c.Data(response.Status, "application/json; charset=utf-8", response.Body)
+}
+
+// jsonResponse encodes body as c.JSON would.
+func jsonResponse(status int, body any) idempotency.Response {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		status = http.StatusInternalServerError
+		encoded, _ = json.Marshal(ErrorResponse{
+			Error:   "Internal server error",
+			Message: internalErrorMessage,
+		})
+	}
+	return idempotency.Response{Status: status, Body: encoded}
+}
+
+// GetSyncStatus godoc
+// @Summary      Get sync status
+// @Description  Get the state of the current or most recent sync (idle, in_progress, completed, partial, error or aborted). A partial sync saved the stocks received before the external API failed and reports that failure in error. When the external API failed, upstream_service and upstream_status name it and the HTTP status it answered with. After a restart this is the latest persisted run
+// @Tags         sync
+// @Accept       json
+// @Produce      json
+// @Security     BasicAuth
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Success      200  {object}  SyncResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/sync/status [get]
+func (a *API) GetSyncStatus(c *gin.Context) {
+	status, err := a.stocksService.GetSyncStatus(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newSyncResponse(status))
+}
+
+// StreamSyncProgress godoc
+// @Summary      Stream sync progress
+// @Description  Follow the running sync as Server-Sent Events. Each event's data is a JSON object: {"event":"progress","processed":50,"total":200} after every saved batch, then {"event":"completed","status":{...}} once the sync has finished, after which the stream closes. When no sync is running the completed event for the most recent one is sent right away
+// @Tags         sync
+// @Produce      text/event-stream
+// @Security     BasicAuth
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Success      200  {object}  SyncProgressEvent
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/sync/stream [get]
+func (a *API) StreamSyncProgress(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// Subscribe before reading the status so no batch saved in between is
+	// missed.
+	updates, unsubscribe := a.syncProgress.Subscribe()
+	defer unsubscribe()
+
+	status, err := a.stocksService.GetSyncStatus(ctx)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// The stream outlives the server's write timeout.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	// An initial comment commits the headers so clients see the stream open
+	// before the first batch is saved.
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(a.syncStreamPoll)
+	defer ticker.Stop()
+
+	for status.Status == "in_progress" {
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-updates:
+			writeSyncEvent(c, newSyncProgressEvent(update))
+		case <-ticker.C:
+			if status, err = a.stocksService.GetSyncStatus(ctx); err != nil {
+				log.Printf("Error reading sync status for stream: %v", err)
+				return
+			}
+		}
+	}
+
+	// Batches reported just before the sync finished are still buffered.
+	for drained := false; !drained; {
+		select {
+		case update := <-updates:
+			writeSyncEvent(c, newSyncProgressEvent(update))
+		default:
+			drained = true
+		}
+	}
+	writeSyncEvent(c, SyncCompletedEvent{Event: "completed", Status: newSyncResponse(status)})
+}
+
+func newSyncProgressEvent(update syncprogress.Update) SyncProgressEvent {
+	return SyncProgressEvent{Event: "progress", Processed: update.Processed, Total: update.Estimated}
+}
+
+// writeSyncEvent writes event as the JSON data of one Server-Sent Event.
+func writeSyncEvent(c *gin.Context, event any) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding sync event: %v", err)
+		return
+	}
+	fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+	c.Writer.Flush()
+}
+
+// GetSyncRuns godoc
+// @Summary      List sync runs
+// @Description  List the most recent persisted sync runs, newest first. Runs left in progress by an instance that died are reported as aborted
+// @Tags         sync
+// @Accept       json
+// @Produce      json
+// @Security     BasicAuth
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        limit  query     int  false  "Maximum runs (max 100)"  default(20)
+// @Success      200  {object}  SuccessResponse{data=[]stockviewer.SyncRun}
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/sync/runs [get]
+func (a *API) GetSyncRuns(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			respondError(c, stockviewer.ValidationError{Field: "limit", Message: "must be an integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	runs, err := a.stocksService.GetSyncRuns(c.Request.Context(), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Data: runs,
+	})
+}
+
+func newSyncResponse(status *stockviewer.SyncStatus) SyncResponse {
+	resp := SyncResponse{
+		Status:           status.Status,
+		TotalRecords:     status.TotalRecords,
+		NewRecords:       status.NewRecords,
+		UpdatedRecords:   status.UpdatedRecords,
+		DuplicateRecords: status.DuplicateRecords,
+		Error:            status.Error,
+		UpstreamService:  status.UpstreamService,
+		UpstreamStatus:   status.UpstreamStatus,
+		SchemaWarnings:   status.SchemaWarnings,
+	}
+	if !status.LastSync.IsZero() {
+		resp.LastSync = status.LastSync.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
+
+// decodeStockFilter reads a JSON StockFilter from body and normalizes it the
+// same way query parameters are. Decoding problems are reported as
+// stockviewer.ValidationError so clients can tell which field was rejected.
+func decodeStockFilter(body io.Reader, limits stockviewer.PageLimits) (stockviewer.StockFilter, error) {
+	var filter stockviewer.StockFilter
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&filter); err != nil {
+		return filter, decodeError(err)
+	}
+
+	filter, errs := query.NormalizeStockFilter(filter, limits)
+	if len(errs) > 0 {
+		return filter, validationErrors(errs)
+	}
+	return filter, nil
+}
+
+// decodeError describes why a JSON body could not be decoded as a
+// stockviewer.ValidationError naming the field. A body over the size limit is
+// returned as is, so respondError answers 413 rather than 400.
+func decodeError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return stockviewer.ValidationError{
+			Field:   typeErr.Field,
+			Message: "must be of type " + typeErr.Type.String(),
+		}
+	}
+
+	// encoding/json has no typed error for unknown fields.
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return stockviewer.ValidationError{
+			Field:   strings.Trim(field, `"`),
+			Message: "is not a supported field",
+		}
+	}
+
+	if errors.Is(err, io.EOF) {
+		return stockviewer.ValidationError{Field: "body", Message: "must be a JSON object"}
+	}
+
+	return stockviewer.ValidationError{Field: "body", Message: err.Error()}
+}
@@ -0,0 +1,2002 @@
+package httpapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/authtoken"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/idempotency"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/mocks"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/prefetch"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/query"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/recommendation"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/stocks"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/syncprogress"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/views"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	_ "github.com/user/go-stock-viewer-back/docs"
+)
+
+func newTestRouter(cfg Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	repo := mocks.NewMockStocksRepository()
+	if cfg.StocksService == nil {
+		cfg.StocksService = stocks.NewService(repo, mocks.NewMockStocksFetcher())
+	}
+	if cfg.RecommendationService == nil {
+		cfg.RecommendationService = recommendation.NewService(repo)
+	}
+
+	router := gin.New()
+	New(cfg).ConfigureRoutes(router)
+	return router
+}
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	original := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(original) })
+	return &buf
+}
+
+func performRequest(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGetOpenAPISpec(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/openapi.json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var spec struct {
+		Swagger string `json:"swagger"`
+		Info    struct {
+			Title string `json:"title"`
+		} `json:"info"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("expected valid JSON spec, got error: %v", err)
+	}
+
+	if spec.Info.Title != "Stock Viewer API" {
+		t.Errorf("expected title 'Stock Viewer API', got '%s'", spec.Info.Title)
+	}
+	if spec.Swagger != "2.0" {
+		t.Errorf("expected swagger version 2.0, got '%s'", spec.Swagger)
+	}
+}
+
+func TestSwaggerRoute_Flag(t *testing.T) {
+	for enabled, want := range map[bool]int{true: http.StatusOK, false: http.StatusNotFound} {
+		router := newTestRouter(Config{SwaggerEnabled: enabled})
+
+		for _, path := range []string{"/swagger/index.html", "/swagger/doc.json"} {
+			if rec := performRequest(router, http.MethodGet, path); rec.Code != want {
+				t.Errorf("SwaggerEnabled=%v %s: expected status %d, got %d", enabled, path, want, rec.Code)
+			}
+		}
+		if rec := performRequest(router, http.MethodGet, "/api/v1/openapi.json"); rec.Code != http.StatusOK {
+			t.Errorf("SwaggerEnabled=%v: expected openapi.json to stay available, got %d", enabled, rec.Code)
+		}
+	}
+}
+
+// recordSpans installs a global tracer provider that keeps every span for
+// the rest of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	return recorder
+}
+
+func TestTracing_SpanPerRequest(t *testing.T) {
+	recorder := recordSpans(t)
+	router := newTestRouter(Config{})
+
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stocks/"+mocks.AAPLStockID, nil)
+	req.Header.Set("traceparent", parent)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator()) })
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "/api/v1/stocks/:id" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("expected a server span named after the route, got %q (%v)", span.Name(), span.SpanKind())
+	}
+	if span.Parent().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the span to continue the caller's trace, got %s", span.Parent().TraceID())
+	}
+}
+
+func performAuthRequest(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.SetBasicAuth("admin", "secret")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func waitForSync(t *testing.T, router *gin.Engine) SyncResponse {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rec := performAuthRequest(router, http.MethodGet, "/api/v1/sync/status")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+
+		var resp SyncResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Status != "in_progress" {
+			return resp
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sync did not finish in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSyncStocks_Accepted(t *testing.T) {
+	router := newTestRouter(Config{BasicAuthUser: "admin", BasicAuthPassword: "secret"})
+
+	rec := performAuthRequest(router, http.MethodPost, "/api/v1/sync")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rec.Code)
+	}
+	if rec.Header().Get("Location") != "/api/v1/sync/status" {
+		t.Errorf("expected Location header pointing at the status endpoint, got %q", rec.Header().Get("Location"))
+	}
+
+	resp := waitForSync(t, router)
+	if resp.Status != "completed" {
+		t.Fatalf("expected status completed, got %s (%s)", resp.Status, resp.Error)
+	}
+	if resp.TotalRecords != 3 {
+		t.Errorf("expected 3 records, got %d", resp.TotalRecords)
+	}
+}
+
+func TestSyncStocks_LastSyncIsRFC3339UTC(t *testing.T) {
+	original := time.Local
+	time.Local = time.FixedZone("UTC+3", 3*60*60)
+	defer func() { time.Local = original }()
+
+	router := newTestRouter(Config{BasicAuthUser: "admin", BasicAuthPassword: "secret"})
+
+	rec := performAuthRequest(router, http.MethodPost, "/api/v1/sync")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rec.Code)
+	}
+
+	resp := waitForSync(t, router)
+
+	lastSync, err := time.Parse(time.RFC3339, resp.LastSync)
+	if err != nil {
+		t.Fatalf("expected RFC3339 last_sync, got %q", resp.LastSync)
+	}
+	if !strings.HasSuffix(resp.LastSync, "Z") || lastSync.Location() != time.UTC {
+		t.Errorf("expected last_sync in UTC, got %q", resp.LastSync)
+	}
+}
+
+// countingFetcher counts how many syncs fetched from it.
+type countingFetcher struct {
+	*mocks.MockStocksFetcher
+	calls *atomic.Int32
+}
+
+func (f countingFetcher) FetchStocks(ctx context.Context) (<-chan stockviewer.StockOrError, error) {
+	f.calls.Add(1)
+	return f.MockStocksFetcher.FetchStocks(ctx)
+}
+
+func performSyncWithKey(router *gin.Engine, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sync", nil)
+	req.SetBasicAuth("admin", "secret")
+	req.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSyncStocks_IdempotencyKeyReplays(t *testing.T) {
+	fetcher := countingFetcher{MockStocksFetcher: mocks.NewMockStocksFetcher(), calls: &atomic.Int32{}}
+	router := newTestRouter(Config{
+		StocksService:     stocks.NewService(mocks.NewMockStocksRepository(), fetcher),
+		BasicAuthUser:     "admin",
+		BasicAuthPassword: "secret",
+		Idempotency:       idempotency.NewStore(time.Minute, 10),
+	})
+
+	first := performSyncWithKey(router, "nightly-1")
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", first.Code)
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("expected the first response not to be marked as replayed")
+	}
+	waitForSync(t, router)
+
+	second := performSyncWithKey(router, "nightly-1")
+	if second.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", second.Code)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("expected identical bodies, got %s and %s", first.Body, second.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected the replayed response to be marked")
+	}
+	if second.Header().Get("Location") != "/api/v1/sync/status" {
+		t.Errorf("expected Location header on the replay, got %q", second.Header().Get("Location"))
+	}
+	if calls := fetcher.calls.Load(); calls != 1 {
+		t.Errorf("expected one sync, got %d", calls)
+	}
+
+	if rec := performSyncWithKey(router, "nightly-2"); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rec.Code)
+	}
+	waitForSync(t, router)
+	if calls := fetcher.calls.Load(); calls != 2 {
+		t.Errorf("expected a new key to start another sync, got %d syncs", calls)
+	}
+}
+
+func TestSyncStocks_IdempotencyKeyTooLong(t *testing.T) {
+	router := newTestRouter(Config{
+		BasicAuthUser:     "admin",
+		BasicAuthPassword: "secret",
+		Idempotency:       idempotency.NewStore(time.Minute, 10),
+	})
+
+	rec := performSyncWithKey(router, strings.Repeat("k", 256))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+// gatedFetcher holds FetchStocks until release is closed, keeping a sync in
+// progress for as long as a test needs.
+type gatedFetcher struct {
+	*mocks.MockStocksFetcher
+	release chan struct{}
+}
+
+func (f gatedFetcher) FetchStocks(ctx context.Context) (<-chan stockviewer.StockOrError, error) {
+	<-f.release
+	return f.MockStocksFetcher.FetchStocks(ctx)
+}
+
+func TestStreamSyncProgress(t *testing.T) {
+	progress := syncprogress.NewBroadcaster()
+	fetcher := gatedFetcher{MockStocksFetcher: mocks.NewMockStocksFetcher(), release: make(chan struct{})}
+	api := New(Config{
+		StocksService:     stocks.NewService(mocks.NewMockStocksRepository(), fetcher, stocks.WithSyncProgress(progress.Publish)),
+		BasicAuthUser:     "admin",
+		BasicAuthPassword: "secret",
+		SyncProgress:      progress,
+	})
+	api.syncStreamPoll = 5 * time.Millisecond
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api.ConfigureRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	if rec := performAuthRequest(router, http.MethodPost, "/api/v1/sync"); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rec.Code)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/sync/stream", nil)
+	req.SetBasicAuth("admin", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	close(fetcher.release)
+
+	var events []map[string]any
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event map[string]any
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("invalid event data %q: %v", data, err)
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected a progress and a completed event, got %v", events)
+	}
+	if events[0]["event"] != "progress" || events[0]["processed"] != 3.0 || events[0]["total"] != 3.0 {
+		t.Errorf("unexpected progress event %v", events[0])
+	}
+	status, _ := events[1]["status"].(map[string]any)
+	if events[1]["event"] != "completed" || status["status"] != "completed" || status["total_records"] != 3.0 {
+		t.Errorf("unexpected completed event %v", events[1])
+	}
+}
+
+func TestStreamSyncProgress_NoSyncRunning(t *testing.T) {
+	router := newTestRouter(Config{BasicAuthUser: "admin", BasicAuthPassword: "secret", SyncProgress: syncprogress.NewBroadcaster()})
+
+	if rec := performRequest(router, http.MethodGet, "/api/v1/sync/stream"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without credentials, got %d", rec.Code)
+	}
+
+	rec := performAuthRequest(router, http.MethodGet, "/api/v1/sync/stream")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `data: {"event":"completed","status":{"status":"idle"`) {
+		t.Errorf("expected an immediate completed event, got %q", rec.Body.String())
+	}
+}
+
+func TestSyncStatus_ReportsUpstreamFailure(t *testing.T) {
+	upstreamErr := fmt.Errorf("fetching page 2: %w", stockviewer.ExternalAPIError{
+		Service:    "karenai",
+		StatusCode: http.StatusServiceUnavailable,
+		Message:    "maintenance",
+	})
+	tests := []struct {
+		name    string
+		fetcher *mocks.MockStocksFetcher
+		status  string
+	}{
+		{"no items", &mocks.MockStocksFetcher{ItemErrors: []error{upstreamErr}}, "error"},
+		{"some items", &mocks.MockStocksFetcher{Stocks: mocks.NewMockStocksFetcher().Stocks, ItemErrors: []error{upstreamErr}}, stockviewer.SyncPartial},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter(Config{
+				StocksService:     stocks.NewService(mocks.NewMockStocksRepository(), tt.fetcher),
+				BasicAuthUser:     "admin",
+				BasicAuthPassword: "secret",
+				SyncProgress:      syncprogress.NewBroadcaster(),
+			})
+
+			if rec := performAuthRequest(router, http.MethodPost, "/api/v1/sync"); rec.Code != http.StatusAccepted {
+				t.Fatalf("expected status 202, got %d", rec.Code)
+			}
+			resp := waitForSync(t, router)
+			if resp.Status != tt.status || resp.UpstreamService != "karenai" || resp.UpstreamStatus != http.StatusServiceUnavailable {
+				t.Errorf("expected %s from karenai 503, got %+v", tt.status, resp)
+			}
+
+			rec := performAuthRequest(router, http.MethodGet, "/api/v1/sync/stream")
+			if !strings.Contains(rec.Body.String(), `"upstream_service":"karenai","upstream_status":503`) {
+				t.Errorf("expected the completed event to name the upstream failure, got %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetStockByID_PrefetchToken(t *testing.T) {
+	repo := mocks.NewMockStocksRepository()
+	store := prefetch.NewStore("secret", time.Minute)
+	router := newTestRouter(Config{
+		StocksService: stocks.NewService(repo, mocks.NewMockStocksFetcher()),
+		Prefetch:      store,
+	})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stocks?prefetch=true")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if rec.Header().Get("ETag") == "" {
+		t.Error("expected ETag header on prefetch list response")
+	}
+
+	var list PaginatedSuccessResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	item := list.Data[0]
+	if item.PrefetchToken == "" {
+		t.Fatal("expected prefetch_token on list items")
+	}
+
+	rec = performRequest(router, http.MethodGet, "/api/v1/stocks/"+item.ID+"?prefetch_token="+item.PrefetchToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if rec.Header().Get("X-Prefetch") != "hit" {
+		t.Error("expected detail to be served from the list snapshot")
+	}
+}
+
+func TestGetStockByID_InvalidPrefetchTokenFallsBack(t *testing.T) {
+	router := newTestRouter(Config{Prefetch: prefetch.NewStore("secret", time.Minute)})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stocks/"+mocks.AAPLStockID+"?prefetch_token=bogus")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if rec.Header().Get("X-Prefetch") != "" {
+		t.Error("expected invalid token to fall back to the regular lookup")
+	}
+}
+
+func TestQueryStocks(t *testing.T) {
+	repo := mocks.NewMockStocksRepository()
+	router := newTestRouter(Config{StocksService: stocks.NewService(repo, mocks.NewMockStocksFetcher())})
+
+	body := `{"ticker":"aapl","actions":["upgraded by","target raised by"],"page":2,"page_size":5}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/stocks/query", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp PaginatedSuccessResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Page != 2 || resp.PageSize != 5 {
+		t.Errorf("expected page 2 of size 5, got page %d size %d", resp.Page, resp.PageSize)
+	}
+
+	if repo.LastFilter.Ticker != "aapl" {
+		t.Errorf("expected ticker 'aapl' to reach the repository, got '%s'", repo.LastFilter.Ticker)
+	}
+	if len(repo.LastFilter.Actions) != 2 {
+		t.Errorf("expected 2 actions to reach the repository, got %v", repo.LastFilter.Actions)
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	router := newTestRouter(Config{MaxBodyBytes: 64})
+	body := `{"ticker":"` + strings.Repeat("A", 100) + `"}`
+
+	for name, contentLength := range map[string]int64{"declared": int64(len(body)), "chunked": -1} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/stocks/query", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.ContentLength = contentLength
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected status 413, got %d: %s", name, rec.Code, rec.Body.String())
+			continue
+		}
+		var resp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", name, err)
+		}
+		if resp.Error != "Request too large" || !strings.Contains(resp.Message, "64 bytes") {
+			t.Errorf("%s: unexpected error response %+v", name, resp)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/stocks/query", strings.NewReader(`{"ticker":"aapl"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected a body under the limit to pass, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		error  string
+	}{
+		{stockviewer.ValidationError{Field: "page", Message: "must be positive"}, http.StatusBadRequest, "Invalid parameters"},
+		{validationErrors{{Field: "page"}, {Field: "sort_by"}}, http.StatusBadRequest, "Invalid parameters"},
+		{fmt.Errorf("lookup: %w", stockviewer.ErrStockNotFound), http.StatusNotFound, "Not found"},
+		{stockviewer.ErrViewNotFound, http.StatusNotFound, "Not found"},
+		{&http.MaxBytesError{Limit: 10}, http.StatusRequestEntityTooLarge, "Request too large"},
+		{fmt.Errorf("parse: %w", stockviewer.ErrInvalidFilter), http.StatusBadRequest, "Invalid parameters"},
+		{stockviewer.ErrUnknownFilterField, http.StatusNotFound, "Not found"},
+		{stockviewer.ErrSyncInProgress, http.StatusConflict, "Conflict"},
+		{stockviewer.ExternalAPIError{Service: "karenai", StatusCode: 503, Message: "unavailable"}, http.StatusBadGateway, "Bad gateway"},
+		{fmt.Errorf("fetch: %w", stockviewer.ErrExternalAPIFailure), http.StatusBadGateway, "Bad gateway"},
+		{stockviewer.StorageError{Operation: "get_all", Err: errors.New("timeout")}, http.StatusInternalServerError, "Internal server error"},
+		{errors.New("connection refused"), http.StatusInternalServerError, "Internal server error"},
+	}
+	for _, tt := range tests {
+		status, resp := errorResponse(tt.err)
+		if status != tt.status || resp.Error != tt.error {
+			t.Errorf("%v: expected %d %q, got %d %q", tt.err, tt.status, tt.error, status, resp.Error)
+		}
+	}
+}
+
+func TestErrorResponse_InternalErrorIsNotLeaked(t *testing.T) {
+	logs := captureLogs(t)
+	err := stockviewer.StorageError{Operation: "get_all", Err: errors.New("dial tcp 10.0.0.5:26257: connection refused")}
+
+	status, resp := errorResponse(err)
+	if status != http.StatusInternalServerError || resp.Message != "An unexpected error occurred" {
+		t.Errorf("expected the fixed 500 message, got %d %+v", status, resp)
+	}
+	if !strings.Contains(logs.String(), "10.0.0.5:26257") {
+		t.Errorf("expected the cause to be logged, got %q", logs.String())
+	}
+}
+
+func TestQueryStocks_FieldErrors(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"wrong type", `{"page":"two"}`, "'page'"},
+		{"unknown field", `{"tickr":"AAPL"}`, "'tickr'"},
+		{"negative page size", `{"page_size":-1}`, "'page_size'"},
+		{"bad sort order", `{"sort_order":"sideways"}`, "'sort_order'"},
+		{"bad action category", `{"action_category":"bullish"}`, "'action_category'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/stocks/query", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
+
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if !strings.Contains(resp.Message, tt.field) {
+				t.Errorf("expected error on field %s, got %q", tt.field, resp.Message)
+			}
+		})
+	}
+}
+
+func TestStockListing_TransportsAgree(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	tests := []struct {
+		name   string
+		query  string
+		body   string
+		status int
+	}{
+		{"defaults", "", `{}`, http.StatusOK},
+		{"pagination", "page=2&page_size=1", `{"page":2,"page_size":1}`, http.StatusOK},
+		{"negative page", "page=-1", `{"page":-1}`, http.StatusBadRequest},
+		{"page size above max", "page_size=500", `{"page_size":500}`, http.StatusBadRequest},
+		{"unknown sort field", "sort_by=password", `{"sort_by":"password"}`, http.StatusBadRequest},
+		{"unknown sort order", "sort_by=company&sort_order=up", `{"sort_by":"company","sort_order":"up"}`, http.StatusBadRequest},
+		{"bad action category", "action_category=bullish", `{"action_category":"bullish"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			get := performRequest(router, http.MethodGet, "/api/v1/stocks?"+tt.query)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/stocks/query", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			post := httptest.NewRecorder()
+			router.ServeHTTP(post, req)
+
+			if get.Code != tt.status || post.Code != tt.status {
+				t.Fatalf("expected status %d from both, got GET %d and POST %d", tt.status, get.Code, post.Code)
+			}
+			if get.Body.String() != post.Body.String() {
+				t.Errorf("expected identical responses, got GET %s and POST %s", get.Body.String(), post.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetBrokerageAnalytics(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stats/brokerages")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp struct {
+		Data []stockviewer.BrokerageStats `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Data) != 3 {
+		t.Fatalf("expected 3 brokerages, got %d", len(resp.Data))
+	}
+	for i := 1; i < len(resp.Data); i++ {
+		if resp.Data[i].AvgScore > resp.Data[i-1].AvgScore {
+			t.Errorf("expected descending average score, got %.2f after %.2f", resp.Data[i].AvgScore, resp.Data[i-1].AvgScore)
+		}
+	}
+
+	rec = performRequest(router, http.MethodGet, "/api/v1/stats/brokerages?sort_by=name")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unknown sort, got %d", rec.Code)
+	}
+}
+
+func TestGetDataQualityReport(t *testing.T) {
+	repo := mocks.NewMockStocksRepository()
+	repo.Stocks = append(repo.Stocks, stockviewer.Stock{
+		ID:         "quarantined-1",
+		Ticker:     "OBJ",
+		TargetFrom: stockviewer.Price(10),
+		DataIssues: []stockviewer.DataIssue{{Field: "target_to", Shape: "object", Raw: `{"amount":"12.5"}`}},
+	})
+	router := newTestRouter(Config{StocksService: stocks.NewService(repo, mocks.NewMockStocksFetcher())})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stats/data-quality")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp struct {
+		Data struct {
+			QuarantinedStocks int                          `json:"quarantined_stocks"`
+			Issues            []stockviewer.DataIssueCount `json:"issues"`
+			Stocks            []map[string]any             `json:"stocks"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Data.QuarantinedStocks != 1 || len(resp.Data.Stocks) != 1 {
+		t.Fatalf("expected one quarantined stock, got %+v", resp.Data)
+	}
+	if resp.Data.Stocks[0]["target_to"] != nil || resp.Data.Stocks[0]["data_issues"] == nil {
+		t.Errorf("expected a null target with its data issues, got %v", resp.Data.Stocks[0])
+	}
+	if len(resp.Data.Issues) != 1 || resp.Data.Issues[0] != (stockviewer.DataIssueCount{Field: "target_to", Shape: "object", Count: 1}) {
+		t.Errorf("unexpected issue counts %+v", resp.Data.Issues)
+	}
+}
+
+func TestStrictQuery_Modes(t *testing.T) {
+	paths := []string{
+		"/api/v1/stocks?tickers=AAPL&page=1",
+		"/api/v1/stocks/search?q=AAPL&limt=5",
+		"/api/v1/recommendations?limit=5&include_new=true&include_cold=true",
+	}
+
+	for _, mode := range []StrictQueryMode{"", StrictQueryOff, StrictQueryLog, StrictQueryEnforce} {
+		router := newTestRouter(Config{StrictQuery: mode})
+		logs := captureLogs(t)
+
+		for _, path := range paths {
+			rec := performRequest(router, http.MethodGet, path)
+			if mode == StrictQueryEnforce {
+				if rec.Code != http.StatusBadRequest {
+					t.Errorf("%q %s: expected status 400, got %d", mode, path, rec.Code)
+				}
+				continue
+			}
+			if rec.Code != http.StatusOK {
+				t.Errorf("%q %s: expected status 200, got %d", mode, path, rec.Code)
+			}
+		}
+
+		logged := strings.Contains(logs.String(), "Unknown query parameters on GET /api/v1/stocks: tickers")
+		if logged != (mode == StrictQueryLog) {
+			t.Errorf("%q: expected unknown parameters logged=%v, got logs %q", mode, mode == StrictQueryLog, logs.String())
+		}
+	}
+}
+
+func TestStrictQuery_EnforceListsUnknownKeys(t *testing.T) {
+	router := newTestRouter(Config{StrictQuery: StrictQueryEnforce})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stocks?tickers=AAPL&sortby=ticker&ticker=AAPL")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Message != "unknown query parameters: sortby, tickers" {
+		t.Errorf("expected the unknown keys to be listed, got %q", resp.Message)
+	}
+
+	declared := []string{
+		"/api/v1/stocks?ticker=AAPL&ticker_exact=true&actions=upgraded+by&actions=initiated+by&include_unrated=true&page_size=10&prefetch=true&lang=es&verbosity=full&currency=USD",
+		"/api/v1/stocks/search?q=AAPL&limit=5&fuzzy=true&lang=es",
+		"/api/v1/recommendations?limit=5&include_new=true&verbosity=compact",
+		// Routes without a declared parameter set are not checked.
+		"/api/v1/stocks/filters?anything=1",
+	}
+	for _, path := range declared {
+		if rec := performRequest(router, http.MethodGet, path); rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestGetStocks_PageSizeConfiguredMax(t *testing.T) {
+	repo := mocks.NewMockStocksRepository()
+	limits := stockviewer.PageLimits{DefaultPageSize: 10, MaxPageSize: 50}
+	router := newTestRouter(Config{
+		StocksService:   stocks.NewService(repo, mocks.NewMockStocksFetcher(), stocks.WithPageLimits(limits)),
+		DefaultPageSize: limits.DefaultPageSize,
+		MaxPageSize:     limits.MaxPageSize,
+	})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stocks?page_size=500")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	var errResp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.Contains(errResp.Message, "must be between 1 and 50") {
+		t.Errorf("expected the allowed range in the message, got %q", errResp.Message)
+	}
+	if rec := performRequest(router, http.MethodGet, "/api/v1/stocks?page_size=0"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for page_size=0, got %d", rec.Code)
+	}
+
+	rec = performRequest(router, http.MethodGet, "/api/v1/stocks?page_size=50")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var resp PaginatedSuccessResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.PageSize != 50 {
+		t.Errorf("expected page_size 50, got %d", resp.PageSize)
+	}
+	if repo.LastFilter.PageSize != 50 {
+		t.Errorf("expected repository to receive page size 50, got %d", repo.LastFilter.PageSize)
+	}
+}
+
+func newViewsRouter() *gin.Engine {
+	stocksService := stocks.NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher())
+	return newTestRouter(Config{
+		StocksService:     stocksService,
+		ViewsService:      views.NewService(mocks.NewMockViewRepository(), stocksService),
+		BasicAuthUser:     "admin",
+		BasicAuthPassword: "secret",
+	})
+}
+
+func TestGetView(t *testing.T) {
+	router := newViewsRouter()
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/views/top-upgrades?page=1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp ViewResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.View.Slug != "top-upgrades" || resp.View.Title == "" {
+		t.Errorf("expected view metadata, got %+v", resp.View)
+	}
+	if resp.Page != 1 || resp.TotalItems == 0 {
+		t.Errorf("expected first page of stocks, got page %d with %d items", resp.Page, resp.TotalItems)
+	}
+
+	rec = performRequest(router, http.MethodGet, "/api/v1/views/does-not-exist")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown view, got %d", rec.Code)
+	}
+}
+
+func TestCreateView(t *testing.T) {
+	router := newViewsRouter()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/views", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(`{"slug":"jpm-buys","title":"JPMorgan buys","filter":{"sort_by":"target_to"}}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for invalid filter, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = post(`{"slug":"jpm-buys","title":"JPMorgan buys","filter":{"brokerage":"JPMorgan","rating":"Buy"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = performRequest(router, http.MethodGet, "/api/v1/views/jpm-buys")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = performAuthRequest(router, http.MethodDelete, "/api/v1/views/jpm-buys")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = performRequest(router, http.MethodGet, "/api/v1/views/jpm-buys")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after delete, got %d", rec.Code)
+	}
+}
+
+func TestGetStockChanges(t *testing.T) {
+	repo := mocks.NewMockStocksRepository()
+	updated := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	repo.Stocks[0].UpdatedAt = updated
+	router := newTestRouter(Config{StocksService: stocks.NewService(repo, mocks.NewMockStocksFetcher())})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stocks/changes?since=2024-05-01T00:00:00Z")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp ChangesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Count != 1 || resp.Data[0].ID != repo.Stocks[0].ID {
+		t.Errorf("expected only %s to have changed, got %+v", repo.Stocks[0].ID, resp.Data)
+	}
+	if resp.NextSince != "2024-05-02T12:00:00Z" || resp.NextAfterID != repo.Stocks[0].ID {
+		t.Errorf("expected the cursor at 2024-05-02T12:00:00Z and %s, got %s and %q", repo.Stocks[0].ID, resp.NextSince, resp.NextAfterID)
+	}
+
+	rec = performRequest(router, http.MethodGet, "/api/v1/stocks/changes?since="+resp.NextSince+"&after_id="+resp.NextAfterID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Count != 0 {
+		t.Errorf("expected nothing after the cursor, got %+v", resp.Data)
+	}
+}
+
+func TestGetStockChanges_InvalidSince(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	for path, field := range map[string]string{
+		"/api/v1/stocks/changes":                                      "since",
+		"/api/v1/stocks/changes?since=yesterday":                      "since",
+		"/api/v1/stocks/changes?since=2024-05-01T00:00:00Z&limit=ten": "limit",
+	} {
+		rec := performRequest(router, http.MethodGet, path)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, rec.Code)
+		}
+		var resp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: failed to parse response: %v", path, err)
+		}
+		if resp.Error != "Invalid parameters" || !strings.Contains(resp.Message, "field '"+field+"'") {
+			t.Errorf("%s: expected a validation error on %s, got %+v", path, field, resp)
+		}
+	}
+}
+
+func TestGetTargetConsensuses(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stocks/summaries?tickers=msft,AAPL")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Data []stockviewer.TargetConsensus `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Data) != 2 || resp.Data[0].Ticker != "MSFT" || resp.Data[1].Ticker != "AAPL" {
+		t.Errorf("expected MSFT and AAPL summaries, got %+v", resp.Data)
+	}
+
+	tickers := make([]string, stockviewer.MaxConsensusTickers+1)
+	for i := range tickers {
+		tickers[i] = fmt.Sprintf("T%d", i)
+	}
+	tooMany := strings.Join(tickers, ",")
+	for _, path := range []string{
+		"/api/v1/stocks/summaries",
+		"/api/v1/stocks/summaries?tickers=" + tooMany,
+		"/api/v1/stocks/summaries?tickers=AAPL&days=ninety",
+	} {
+		if rec := performRequest(router, http.MethodGet, path); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestSearchStocks_EmptyQuery(t *testing.T) {
+	rec := performRequest(newTestRouter(Config{}), http.MethodGet, "/api/v1/stocks/search?q=")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 by default, got %d", rec.Code)
+	}
+
+	router := newTestRouter(Config{EmptySearch: EmptySearchRecent})
+	rec = performRequest(router, http.MethodGet, "/api/v1/stocks/search?limit=5")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Data []stockviewer.Stock `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Data) == 0 {
+		t.Error("expected the newest entries")
+	}
+}
+
+func TestGetStocks_InvalidSortField(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stocks?sort_by=target_to")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	for _, field := range query.SortFieldNames() {
+		if !strings.Contains(resp.Message, field) {
+			t.Errorf("expected message to list allowed field %q, got %q", field, resp.Message)
+		}
+	}
+}
+
+func TestGetStockHistory(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stocks/AAPL/history?page_size=5")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp PaginatedSuccessResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.PageSize != 5 || len(resp.Data) == 0 {
+		t.Fatalf("expected a page of size 5 with data, got %+v", resp)
+	}
+	for _, stock := range resp.Data {
+		if stock.Ticker != "AAPL" {
+			t.Errorf("expected only AAPL entries, got %s", stock.Ticker)
+		}
+	}
+
+	// The detail route on the same prefix must still resolve.
+	rec = performRequest(router, http.MethodGet, "/api/v1/stocks/"+mocks.AAPLStockID)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected detail route to still work, got %d", rec.Code)
+	}
+
+	rec = performRequest(router, http.MethodGet, "/api/v1/stocks/AAPL/history?page=abc")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid page, got %d", rec.Code)
+	}
+}
+
+func TestPaginatedSuccessResponse_LargeTotalsRoundTrip(t *testing.T) {
+	const total = int64(1<<53 + 1)
+	resp := PaginatedSuccessResponse{
+		Data:       []stockviewer.Stock{},
+		Page:       1,
+		PageSize:   20,
+		TotalItems: total,
+		TotalPages: stockviewer.TotalPages(total, 20),
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	if !strings.Contains(string(body), `"total_items":9007199254740993`) {
+		t.Errorf("expected total_items as a bare JSON number, got %s", body)
+	}
+
+	var decoded PaginatedSuccessResponse
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if decoded.TotalItems != resp.TotalItems || decoded.TotalPages != resp.TotalPages {
+		t.Errorf("expected totals %d/%d, got %d/%d", resp.TotalItems, resp.TotalPages, decoded.TotalItems, decoded.TotalPages)
+	}
+}
+
+func TestGetStocks_MultiFieldSort(t *testing.T) {
+	repo := mocks.NewMockStocksRepository()
+	router := newTestRouter(Config{StocksService: stocks.NewService(repo, mocks.NewMockStocksFetcher())})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stocks?sort_by=recommend_score,ticker&sort_order=desc,asc")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if repo.LastFilter.SortBy != "recommend_score,ticker" || repo.LastFilter.SortOrder != "DESC,ASC" {
+		t.Errorf("expected parallel sort lists to reach the repository, got %q %q", repo.LastFilter.SortBy, repo.LastFilter.SortOrder)
+	}
+}
+
+func TestReadinessCheck(t *testing.T) {
+	rec := performRequest(newTestRouter(Config{}), http.MethodGet, "/ready")
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 without a check, got %d", rec.Code)
+	}
+
+	router := newTestRouter(Config{
+		ReadinessCheck: func(ctx context.Context) error { return stockviewer.ErrSchemaTooNew },
+	})
+	rec = performRequest(router, http.MethodGet, "/ready")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 for incompatible schema, got %d", rec.Code)
+	}
+}
+
+func TestReadinessCheck_DatabaseUnreachable(t *testing.T) {
+	repo := mocks.NewMockStocksRepository()
+	repo.PingError = errors.New("connection refused")
+	checked := false
+	router := newTestRouter(Config{
+		StocksService: stocks.NewService(repo, mocks.NewMockStocksFetcher()),
+		ReadinessCheck: func(ctx context.Context) error {
+			checked = true
+			return nil
+		},
+	})
+
+	rec := performRequest(router, http.MethodGet, "/ready")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 when the database is down, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "connection refused") {
+		t.Errorf("expected the ping error in the body, got %s", rec.Body.String())
+	}
+	if checked {
+		t.Error("expected the schema check to be skipped when the database is down")
+	}
+
+	rec = performRequest(router, http.MethodGet, "/health")
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected /health to stay a liveness check, got %d", rec.Code)
+	}
+}
+
+func TestUpdateStock(t *testing.T) {
+	repo := mocks.NewMockStocksRepository()
+	router := newTestRouter(Config{
+		StocksService:     stocks.NewService(repo, mocks.NewMockStocksFetcher()),
+		BasicAuthUser:     "admin",
+		BasicAuthPassword: "secret",
+	})
+
+	patch := func(path, body string, auth bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if auth {
+			req.SetBasicAuth("admin", "secret")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := patch("/api/v1/stocks/"+mocks.AAPLStockID, `{"recommend_score":90}`, false); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without credentials, got %d", rec.Code)
+	}
+
+	rec := patch("/api/v1/stocks/"+mocks.AAPLStockID, `{"recommend_score":90,"notes":"Top pick"}`, true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if repo.Stocks[0].RecommendScore != 90 || repo.Stocks[0].Notes != "Top pick" {
+		t.Errorf("expected override to be stored, got %+v", repo.Stocks[0])
+	}
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"score out of range", "/api/v1/stocks/" + mocks.AAPLStockID, `{"recommend_score":101}`, http.StatusBadRequest},
+		{"non-overridable field", "/api/v1/stocks/" + mocks.AAPLStockID, `{"ticker":"MSFT"}`, http.StatusBadRequest},
+		{"malformed id", "/api/v1/stocks/missing", `{"notes":"x"}`, http.StatusBadRequest},
+		{"unknown stock", "/api/v1/stocks/" + strings.Repeat("0", 32), `{"notes":"x"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := patch(tt.path, tt.body, true); rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCORSMiddleware_PreflightAllowsPatch(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/stocks/"+mocks.AAPLStockID, nil)
+	req.Header.Set("Origin", "https://viewer.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rec.Code)
+	}
+	allowed := strings.Split(rec.Header().Get("Access-Control-Allow-Methods"), ", ")
+	if !slices.Contains(allowed, http.MethodPatch) {
+		t.Errorf("expected PATCH among the allowed methods, got %v", allowed)
+	}
+}
+
+func TestGetFilterValues(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stocks/filters/brokerages?query=gold&limit=1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data stockviewer.FilterValues `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Data.Values) != 1 || resp.Data.Values[0].Value != "Goldman Sachs" || resp.Data.Values[0].Count != 1 {
+		t.Errorf("expected Goldman Sachs with count 1, got %+v", resp.Data.Values)
+	}
+	if resp.Data.HasMore {
+		t.Error("expected has_more=false for a single match")
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/stocks/filters/tickers":              http.StatusNotFound,
+		"/api/v1/stocks/filters/ratings?limit=ten":    http.StatusBadRequest,
+		"/api/v1/stocks/filters/ratings?offset=-1":    http.StatusBadRequest,
+		"/api/v1/stocks/filters/ratings_from?limit=1": http.StatusOK,
+	} {
+		if rec := performRequest(router, http.MethodGet, path); rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestGetFilters_Counts(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/stocks/filters?counts=true&rating=Buy")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data stockviewer.FiltersResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Data.Counts == nil {
+		t.Fatal("expected counts with counts=true")
+	}
+	for _, brokerage := range resp.Data.Counts.Brokerages {
+		if brokerage.Value == "JP Morgan" {
+			t.Errorf("expected brokerage counts narrowed to Buy ratings, got %+v", resp.Data.Counts.Brokerages)
+		}
+	}
+
+	rec = performRequest(router, http.MethodGet, "/api/v1/stocks/filters")
+	if strings.Contains(rec.Body.String(), `"counts"`) {
+		t.Errorf("expected no counts by default, got %s", rec.Body.String())
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/stocks/filters?counts=maybe":                    http.StatusBadRequest,
+		"/api/v1/stocks/filters?counts=true&action_category=up":  http.StatusBadRequest,
+		"/api/v1/stocks/filters?counts=false&action_category=up": http.StatusOK,
+	} {
+		if rec := performRequest(router, http.MethodGet, path); rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestGetStocks_LinkHeaders(t *testing.T) {
+	router := newTestRouter(Config{})
+	base := "/api/v1/stocks?brokerage=A%26B+Capital&page_size=1"
+	link := func(page int, rel string) string {
+		return fmt.Sprintf(`</api/v1/stocks?brokerage=A%%26B+Capital&page=%d&page_size=1>; rel="%s"`, page, rel)
+	}
+
+	tests := []struct {
+		name string
+		page int
+		want string
+	}{
+		{"first page", 1, strings.Join([]string{link(1, "first"), link(2, "next"), link(3, "last")}, ", ")},
+		{"middle page", 2, strings.Join([]string{link(1, "first"), link(1, "prev"), link(3, "next"), link(3, "last")}, ", ")},
+		{"last page", 3, strings.Join([]string{link(1, "first"), link(2, "prev"), link(3, "last")}, ", ")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := performRequest(router, http.MethodGet, fmt.Sprintf("%s&page=%d", base, tt.page))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("Link"); got != tt.want {
+				t.Errorf("Link header:\n got  %s\n want %s", got, tt.want)
+			}
+			for name, want := range map[string]string{
+				"X-Total-Count": "3",
+				"X-Page":        strconv.Itoa(tt.page),
+				"X-Page-Size":   "1",
+				"X-Total-Pages": "3",
+			} {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("expected %s %s, got %q", name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryStocks_NoLinkHeaders(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/stocks/query", strings.NewReader(`{"page_size": 1}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, name := range []string{"Link", "X-Total-Count", "X-Page", "X-Page-Size", "X-Total-Pages"} {
+		if got := rec.Header().Get(name); got != "" {
+			t.Errorf("expected no %s header for a body-selected page, got %s", name, got)
+		}
+	}
+}
+
+func TestGetNewTickers_Params(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	for path, want := range map[string]int{
+		"/api/v1/stocks/new":                                                  http.StatusOK,
+		"/api/v1/stocks/new?since_last_sync=maybe":                            http.StatusBadRequest,
+		"/api/v1/stocks/new?since_last_sync=false":                            http.StatusBadRequest,
+		"/api/v1/stocks/new?since_last_sync=false&since=2024-05-01T00:00:00Z": http.StatusOK,
+		"/api/v1/stocks/new?since_last_sync=false&since=yesterday":            http.StatusBadRequest,
+	} {
+		if rec := performRequest(router, http.MethodGet, path); rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestRequestContextMiddleware_Precedence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	api := New(Config{BasicAuthUser: "admin", BasicAuthPassword: "secret"})
+	router := gin.New()
+	router.Use(api.RequestContextMiddleware())
+	router.GET("/prefs", func(c *gin.Context) {
+		c.JSON(http.StatusOK, stockviewer.RequestContextFrom(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		auth    bool
+		want    stockviewer.RequestContext
+	}{
+		{
+			name: "defaults",
+			path: "/prefs",
+			want: stockviewer.DefaultRequestContext(),
+		},
+		{
+			name:    "headers over defaults",
+			path:    "/prefs",
+			headers: map[string]string{"Accept-Language": "fr-FR, es-AR;q=0.8, en;q=0.5", "X-Verbosity": "FULL", "X-Currency": "usd"},
+			want:    stockviewer.RequestContext{Locale: "es", Currency: "USD", Verbosity: "full", Tier: "anonymous"},
+		},
+		{
+			name:    "query over headers",
+			path:    "/prefs?lang=en&verbosity=compact",
+			headers: map[string]string{"Accept-Language": "es", "X-Verbosity": "full"},
+			want:    stockviewer.RequestContext{Locale: "en", Currency: "USD", Verbosity: "compact", Tier: "anonymous"},
+		},
+		{
+			name:    "unsupported Accept-Language falls back",
+			path:    "/prefs",
+			headers: map[string]string{"Accept-Language": "fr, de;q=0.9"},
+			want:    stockviewer.DefaultRequestContext(),
+		},
+		{
+			name: "tier from credentials only",
+			path: "/prefs?tier=anonymous",
+			auth: true,
+			want: stockviewer.RequestContext{Locale: "en", Currency: "USD", Verbosity: "normal", Tier: "authenticated", Role: "admin"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if tt.auth {
+				req.SetBasicAuth("admin", "secret")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			var got stockviewer.RequestContext
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+			if rec.Header().Get("Content-Language") != tt.want.Locale {
+				t.Errorf("expected Content-Language %q, got %q", tt.want.Locale, rec.Header().Get("Content-Language"))
+			}
+		})
+	}
+
+	for _, path := range []string{"/prefs?lang=fr", "/prefs?currency=EUR", "/prefs?verbosity=loud"} {
+		if rec := performRequest(router, http.MethodGet, path); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestGetRecommendations_Compact(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/recommendations?verbosity=compact&lang=es")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var body struct {
+		Data []map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(body.Data) == 0 {
+		t.Fatal("expected recommendations")
+	}
+	for _, item := range body.Data {
+		if _, ok := item["breakdown"]; ok {
+			t.Errorf("expected no breakdown in compact responses, got %v", item["breakdown"])
+		}
+		if reason, _ := item["reason"].(string); strings.Contains(reason, ". ") || strings.Contains(reason, "analyst") {
+			t.Errorf("expected one Spanish sentence, got %q", reason)
+		}
+	}
+}
+
+func TestGetRecommendations_ByBrokerage(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	rec := performRequest(router, http.MethodGet, "/api/v1/recommendations?brokerage=Goldman+Sachs")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body struct {
+		Data []stockviewer.StockRecommendation `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(body.Data) == 0 {
+		t.Fatal("expected recommendations")
+	}
+	for _, item := range body.Data {
+		if item.Stock.Brokerage != "Goldman Sachs" {
+			t.Errorf("expected only Goldman Sachs picks, got %s", item.Stock.Brokerage)
+		}
+	}
+
+	for _, path := range []string{
+		"/api/v1/recommendations?brokerage=",
+		"/api/v1/recommendations?brokerage=Goldman+Sachs&include_new=true",
+	} {
+		if rec := performRequest(router, http.MethodGet, path); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestGetRecommendations_Pagination(t *testing.T) {
+	repo := mocks.NewMockStocksRepository()
+	repo.Stocks = nil
+	for i := 0; i < 12; i++ {
+		repo.Stocks = append(repo.Stocks, stockviewer.Stock{
+			ID: fmt.Sprintf("s-%d", i), Ticker: fmt.Sprintf("T%02d", i), RatingTo: "Buy", RecommendScore: float64(100 - i),
+		})
+	}
+	router := newTestRouter(Config{RecommendationService: recommendation.NewService(repo)})
+
+	page := func(path string) PaginatedRecommendationResponse {
+		t.Helper()
+		rec := performRequest(router, http.MethodGet, path)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+		var body PaginatedRecommendationResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return body
+	}
+
+	second := page("/api/v1/recommendations?page=2&page_size=5")
+	if second.Page != 2 || second.PageSize != 5 || len(second.Data) != 5 || second.TotalItems != 12 || second.TotalPages != 3 {
+		t.Errorf("expected page 2 of 3 with 5 of 12 items, got %+v", second)
+	}
+	if second.NextPage == nil || *second.NextPage != 3 || second.PrevPage == nil || *second.PrevPage != 1 {
+		t.Errorf("expected links to pages 1 and 3, got %v and %v", second.PrevPage, second.NextPage)
+	}
+	if second.Data[0].Rank != 6 {
+		t.Errorf("expected page 2 to start at rank 6, got %d", second.Data[0].Rank)
+	}
+
+	if legacy := page("/api/v1/recommendations?limit=4"); legacy.PageSize != 4 || len(legacy.Data) != 4 {
+		t.Errorf("expected limit to size the page without page_size, got %d items of %d", len(legacy.Data), legacy.PageSize)
+	}
+	if both := page("/api/v1/recommendations?limit=4&page_size=3"); both.PageSize != 3 {
+		t.Errorf("expected page_size to win over limit, got %d", both.PageSize)
+	}
+
+	tier := second.Data[0].Tier
+	if tier == "" {
+		t.Fatal("expected recommendations to carry a tier")
+	}
+	for _, rec := range page("/api/v1/recommendations?page_size=100&tier=" + strings.ToLower(tier)).Data {
+		if rec.Tier != tier {
+			t.Errorf("expected only tier %s, got %s", tier, rec.Tier)
+		}
+	}
+
+	// limit=0 is treated as a missing limit, not as a request for nothing.
+	if zero := page("/api/v1/recommendations?limit=0"); zero.PageSize != stockviewer.DefaultRecommendationLimit || len(zero.Data) != stockviewer.DefaultRecommendationLimit {
+		t.Errorf("expected limit=0 to use the default page size, got %d items of %d", len(zero.Data), zero.PageSize)
+	}
+
+	for _, path := range []string{
+		"/api/v1/recommendations?page=two",
+		"/api/v1/recommendations?page=-1",
+		"/api/v1/recommendations?page_size=0",
+		"/api/v1/recommendations?page_size=101",
+		"/api/v1/recommendations?tier=E",
+	} {
+		if rec := performRequest(router, http.MethodGet, path); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, rec.Code)
+		}
+	}
+
+	var resp ErrorResponse
+	rec := performRequest(router, http.MethodGet, "/api/v1/recommendations?page_size=0")
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if want := fmt.Sprintf("validation error on field 'page_size': must be between 1 and %d", stockviewer.MaxRecommendations); resp.Message != want {
+		t.Errorf("expected %q for page_size=0, got %q", want, resp.Message)
+	}
+}
+
+func TestGetStocks_AuthenticatedPageSize(t *testing.T) {
+	repo := mocks.NewMockStocksRepository()
+	for i := 0; i < 30; i++ {
+		repo.Stocks = append(repo.Stocks, stockviewer.Stock{ID: fmt.Sprintf("extra-%d", i), Ticker: fmt.Sprintf("X%d", i), RatingTo: "Buy"})
+	}
+	limits := stockviewer.PageLimits{MaxPageSize: 10, AuthenticatedMaxPageSize: 25}
+	router := newTestRouter(Config{
+		StocksService:            stocks.NewService(repo, mocks.NewMockStocksFetcher(), stocks.WithPageLimits(limits)),
+		BasicAuthUser:            "admin",
+		BasicAuthPassword:        "secret",
+		MaxPageSize:              limits.MaxPageSize,
+		AuthenticatedMaxPageSize: limits.AuthenticatedMaxPageSize,
+	})
+
+	for _, tt := range []struct {
+		rec  *httptest.ResponseRecorder
+		want int
+	}{
+		{performRequest(router, http.MethodGet, "/api/v1/stocks?page_size=10"), 10},
+		{performAuthRequest(router, http.MethodGet, "/api/v1/stocks?page_size=25"), 25},
+	} {
+		var page PaginatedSuccessResponse
+		if err := json.Unmarshal(tt.rec.Body.Bytes(), &page); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if page.PageSize != tt.want || len(page.Data) != tt.want {
+			t.Errorf("expected pages of %d, got page_size %d with %d items", tt.want, page.PageSize, len(page.Data))
+		}
+	}
+
+	if rec := performRequest(router, http.MethodGet, "/api/v1/stocks?page_size=25"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected anonymous callers to be limited to 10, got status %d", rec.Code)
+	}
+}
+
+func TestGetStockByID_MalformedAndMissing(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	tests := []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{"garbage", "not-a-stock-id", http.StatusBadRequest},
+		{"too short", mocks.AAPLStockID[:31], http.StatusBadRequest},
+		{"non-hex", strings.Repeat("z", 32), http.StatusBadRequest},
+		{"well-formed but missing", strings.Repeat("0", 32), http.StatusNotFound},
+		{"uppercase", strings.ToUpper(mocks.AAPLStockID), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := performRequest(router, http.MethodGet, "/api/v1/stocks/"+tt.id)
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestBasicAuth_UniformUnauthorized(t *testing.T) {
+	router := newTestRouter(Config{BasicAuthUser: "admin", BasicAuthPassword: "secret", BasicAuthRealm: "Stock Viewer"})
+
+	var bodies []string
+	for name, setAuth := range map[string]func(*http.Request){
+		"missing":        func(*http.Request) {},
+		"wrong user":     func(r *http.Request) { r.SetBasicAuth("root", "secret") },
+		"wrong password": func(r *http.Request) { r.SetBasicAuth("admin", "guess") },
+		"both wrong":     func(r *http.Request) { r.SetBasicAuth("root", "guess") },
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/sync/status", nil)
+		setAuth(req)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status 401, got %d", name, rec.Code)
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); got != `Basic realm="Stock Viewer"` {
+			t.Errorf("%s: expected the configured realm, got %q", name, got)
+		}
+		bodies = append(bodies, rec.Body.String())
+	}
+
+	for _, body := range bodies[1:] {
+		if body != bodies[0] {
+			t.Errorf("expected identical 401 bodies, got %s and %s", bodies[0], body)
+		}
+	}
+}
+
+func TestBasicAuth_DefaultRealm(t *testing.T) {
+	router := newTestRouter(Config{BasicAuthUser: "admin", BasicAuthPassword: "secret"})
+
+	rec := performRequest(router, http.MethodPost, "/api/v1/sync")
+	if got := rec.Header().Get("WWW-Authenticate"); got != `Basic realm="`+DefaultBasicAuthRealm+`"` {
+		t.Errorf("expected the default realm, got %q", got)
+	}
+}
+
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+func newJWTRouter(tokens *authtoken.Signer) *gin.Engine {
+	return newTestRouter(Config{
+		BasicAuthUser:     "admin",
+		BasicAuthPassword: "secret",
+		AuthMode:          AuthModeJWT,
+		Tokens:            tokens,
+	})
+}
+
+func performBearerRequest(router *gin.Engine, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	tokens := authtoken.NewSigner(testJWTSecret, time.Hour)
+	router := newJWTRouter(tokens)
+
+	valid, err := tokens.Issue("admin", "admin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A negative TTL issues tokens that are already past their exp.
+	expired, _ := authtoken.NewSigner(testJWTSecret, -time.Minute).Issue("admin", "admin")
+	parts := strings.Split(valid.Value, ".")
+	tampered := parts[0] + "." + parts[1] + "x." + parts[2]
+	foreign, _ := authtoken.NewSigner("fedcba9876543210fedcba9876543210", time.Hour).Issue("admin", "admin")
+
+	if rec := performBearerRequest(router, http.MethodGet, "/api/v1/sync/status", valid.Value); rec.Code != http.StatusOK {
+		t.Errorf("valid token: expected status 200, got %d", rec.Code)
+	}
+
+	tests := []struct {
+		name, token, message string
+	}{
+		{name: "expired", token: expired.Value, message: "Token expired"},
+		{name: "tampered", token: tampered, message: "Invalid token"},
+		{name: "other secret", token: foreign.Value, message: "Invalid token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := performBearerRequest(router, http.MethodGet, "/api/v1/sync/status", tt.token)
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("expected status 401, got %d", rec.Code)
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Message != tt.message {
+				t.Errorf("expected message %q, got %q", tt.message, resp.Message)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); !strings.Contains(got, `error="invalid_token"`) {
+				t.Errorf("expected an invalid_token challenge, got %q", got)
+			}
+		})
+	}
+}
+
+func TestJWTMiddleware_RejectsBasicCredentials(t *testing.T) {
+	router := newJWTRouter(authtoken.NewSigner(testJWTSecret, time.Hour))
+
+	rec := performAuthRequest(router, http.MethodGet, "/api/v1/sync/status")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); got != `Bearer realm="`+DefaultBasicAuthRealm+`"` {
+		t.Errorf("expected a bearer challenge, got %q", got)
+	}
+}
+
+func TestIssueToken(t *testing.T) {
+	router := newJWTRouter(authtoken.NewSigner(testJWTSecret, time.Hour))
+
+	if rec := performRequest(router, http.MethodPost, "/api/v1/auth/token"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without credentials, got %d", rec.Code)
+	}
+
+	rec := performAuthRequest(router, http.MethodPost, "/api/v1/auth/token")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var resp TokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.TokenType != "Bearer" || resp.ExpiresIn < 3590 || resp.ExpiresIn > 3600 {
+		t.Errorf("unexpected token response %+v", resp)
+	}
+
+	if rec := performBearerRequest(router, http.MethodGet, "/api/v1/sync/status", resp.AccessToken); rec.Code != http.StatusOK {
+		t.Errorf("expected the issued token to be accepted, got %d", rec.Code)
+	}
+}
+
+func TestIssueToken_NotRegisteredInBasicMode(t *testing.T) {
+	router := newTestRouter(Config{BasicAuthUser: "admin", BasicAuthPassword: "secret"})
+
+	if rec := performAuthRequest(router, http.MethodPost, "/api/v1/auth/token"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+// newRolesRouter configures a second credential set, which turns admin/secret
+// into the admin and viewer/view-secret into a read-only viewer.
+func newRolesRouter(cfg Config) *gin.Engine {
+	cfg.BasicAuthUser, cfg.BasicAuthPassword = "viewer", "view-secret"
+	cfg.AdminUser, cfg.AdminPassword = "admin", "secret"
+	return newTestRouter(cfg)
+}
+
+func performViewerRequest(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.SetBasicAuth("viewer", "view-secret")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequireRole_ViewerIsReadOnly(t *testing.T) {
+	router := newRolesRouter(Config{ViewsService: views.NewService(mocks.NewMockViewRepository(), nil)})
+
+	tests := []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/api/v1/stocks", http.StatusOK},
+		{http.MethodGet, "/api/v1/sync/status", http.StatusOK},
+		{http.MethodPost, "/api/v1/sync", http.StatusForbidden},
+		{http.MethodPatch, "/api/v1/stocks/mock-1", http.StatusForbidden},
+		{http.MethodDelete, "/api/v1/views/top-buys", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rec := performViewerRequest(router, tt.method, tt.path)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, rec.Code)
+		}
+	}
+
+	rec := performViewerRequest(router, http.MethodPost, "/api/v1/sync")
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Message != "Requires the admin role" {
+		t.Errorf("unexpected message %q", resp.Message)
+	}
+
+	if rec := performRequest(router, http.MethodPost, "/api/v1/sync"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without credentials, got %d", rec.Code)
+	}
+}
+
+func TestRequireRole_AdminCanSync(t *testing.T) {
+	router := newRolesRouter(Config{})
+
+	if rec := performAuthRequest(router, http.MethodPost, "/api/v1/sync"); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rec.Code)
+	}
+	waitForSync(t, router)
+}
+
+func TestRequireRole_ViewerToken(t *testing.T) {
+	tokens := authtoken.NewSigner(testJWTSecret, time.Hour)
+	router := newRolesRouter(Config{AuthMode: AuthModeJWT, Tokens: tokens})
+
+	rec := performViewerRequest(router, http.MethodPost, "/api/v1/auth/token")
+	var resp TokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Role != string(stockviewer.RoleViewer) {
+		t.Fatalf("expected a viewer token, got %+v", resp)
+	}
+
+	if rec := performBearerRequest(router, http.MethodPost, "/api/v1/sync", resp.AccessToken); rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a viewer token, got %d", rec.Code)
+	}
+	if rec := performBearerRequest(router, http.MethodGet, "/api/v1/sync/status", resp.AccessToken); rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 for a viewer token, got %d", rec.Code)
+	}
+
+	// A token without a known role claim is only trusted to read.
+	unknown, _ := tokens.Issue("admin", "")
+	if rec := performBearerRequest(router, http.MethodPost, "/api/v1/sync", unknown.Value); rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a token without a role, got %d", rec.Code)
+	}
+}
+
+const (
+	testAdminKey  = "admin-key-0123456789"
+	testViewerKey = "viewer-key-0123456789"
+)
+
+func newAPIKeyRouter() *gin.Engine {
+	return newTestRouter(Config{
+		BasicAuthUser:     "admin",
+		BasicAuthPassword: "secret",
+		APIKeys: []APIKey{
+			{Key: testAdminKey, Role: stockviewer.RoleAdmin},
+			{Key: testViewerKey, Role: stockviewer.RoleViewer},
+		},
+	})
+}
+
+func performAPIKeyRequest(router *gin.Engine, method, path, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set(APIKeyHeader, key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	router := newAPIKeyRouter()
+
+	if rec := performAPIKeyRequest(router, http.MethodGet, "/api/v1/sync/status", testViewerKey); rec.Code != http.StatusOK {
+		t.Errorf("valid key: expected status 200, got %d", rec.Code)
+	}
+
+	tests := []struct {
+		name, key, message string
+	}{
+		{name: "missing header", key: "", message: "Invalid credentials"},
+		{name: "unknown key", key: "unknown-key-0123456789", message: "Invalid API key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := performAPIKeyRequest(router, http.MethodGet, "/api/v1/sync/status", tt.key)
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("expected status 401, got %d", rec.Code)
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Message != tt.message {
+				t.Errorf("expected message %q, got %q", tt.message, resp.Message)
+			}
+		})
+	}
+}
+
+func TestAPIKeyMiddleware_Roles(t *testing.T) {
+	router := newAPIKeyRouter()
+
+	if rec := performAPIKeyRequest(router, http.MethodPost, "/api/v1/sync", testViewerKey); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer key: expected status 403, got %d", rec.Code)
+	}
+	if rec := performAPIKeyRequest(router, http.MethodPost, "/api/v1/sync", testAdminKey); rec.Code != http.StatusAccepted {
+		t.Fatalf("admin key: expected status 202, got %d", rec.Code)
+	}
+	waitForSync(t, router)
+}
+
+func TestWithAPIKey_AcceptsEither(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	api := New(Config{
+		BasicAuthUser:     "admin",
+		BasicAuthPassword: "secret",
+		APIKeys:           []APIKey{{Key: testViewerKey, Role: stockviewer.RoleViewer}},
+	})
+	router := gin.New()
+	router.Use(api.RequestContextMiddleware())
+	router.GET("/either", api.WithAPIKey(api.BasicAuthMiddleware()), func(c *gin.Context) {
+		c.String(http.StatusOK, string(stockviewer.RoleFrom(c.Request.Context())))
+	})
+	router.GET("/key-only", api.APIKeyMiddleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	if rec := performAuthRequest(router, http.MethodGet, "/either"); rec.Code != http.StatusOK || rec.Body.String() != "admin" {
+		t.Errorf("basic auth: expected 200 as admin, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := performAPIKeyRequest(router, http.MethodGet, "/either", testViewerKey); rec.Code != http.StatusOK || rec.Body.String() != "viewer" {
+		t.Errorf("API key: expected 200 as viewer, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := performRequest(router, http.MethodGet, "/either"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no credentials: expected status 401, got %d", rec.Code)
+	}
+	if rec := performAuthRequest(router, http.MethodGet, "/key-only"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("basic auth on an API key route: expected status 401, got %d", rec.Code)
+	}
+}
+
+func TestGetRecommendations_NewCoverage(t *testing.T) {
+	repo := mocks.NewMockStocksRepository()
+	repo.Stocks = append(repo.Stocks, stockviewer.Stock{
+		ID: "new-1", Ticker: "NEWC", Action: "initiated by", RatingTo: "Buy", RecommendScore: 99, CreatedAt: time.Now(),
+	})
+	for i := range repo.Stocks[:3] {
+		repo.Stocks[i].CreatedAt = time.Now().Add(-90 * 24 * time.Hour)
+	}
+	router := newTestRouter(Config{RecommendationService: recommendation.NewService(repo, recommendation.WithColdStart(0, 30))})
+
+	tickers := func(path string) []string {
+		t.Helper()
+		rec := performRequest(router, http.MethodGet, path)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", path, rec.Code)
+		}
+		var body struct {
+			Data []stockviewer.StockRecommendation `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		var tickers []string
+		for _, r := range body.Data {
+			tickers = append(tickers, r.Stock.Ticker)
+		}
+		return tickers
+	}
+
+	if got := tickers("/api/v1/recommendations/new-coverage"); len(got) != 1 || got[0] != "NEWC" {
+		t.Errorf("expected only NEWC in new coverage, got %v", got)
+	}
+	if got := tickers("/api/v1/recommendations/new-coverage?limit=0"); len(got) != 1 {
+		t.Errorf("expected limit=0 to use the default limit, got %v", got)
+	}
+	for _, ticker := range tickers("/api/v1/recommendations") {
+		if ticker == "NEWC" {
+			t.Error("expected NEWC to be left out of the main ranking")
+		}
+	}
+	if got := tickers("/api/v1/recommendations?include_new=true"); len(got) != 4 || !strings.Contains(strings.Join(got, ","), "NEWC") {
+		t.Errorf("expected NEWC ranked with include_new, got %v", got)
+	}
+	if rec := performRequest(router, http.MethodGet, "/api/v1/recommendations?include_new=maybe"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a bad include_new, got %d", rec.Code)
+	}
+}
@@ -1,35 +1,222 @@
 package httpapi
 
-import "github.com/user/go-stock-viewer-back/src/stockviewer"
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
 
 type SuccessResponse struct {
 	Data    any    `json:"data"`
 	Message string `json:"message,omitempty"`
 }
 
+// PaginatedSuccessResponse mirrors stockviewer.PaginatedResponse. Both totals
+// are int64 and encoded as JSON numbers; clients that parse numbers as
+// doubles are exact up to 2^53.
 type PaginatedSuccessResponse struct {
 	Data       []stockviewer.Stock `json:"data"`
-	Page       int                  `json:"page"`
-	PageSize   int                  `json:"page_size"`
-	TotalItems int64                `json:"total_items"`
-	TotalPages int                  `json:"total_pages"`
+	Page       int                 `json:"page" example:"1"`
+	PageSize   int                 `json:"page_size" example:"20"`
+	TotalItems int64               `json:"total_items" format:"int64" example:"1250"`
+	TotalPages int64               `json:"total_pages" format:"int64" example:"63"`
+	HasNext    bool                `json:"has_next" example:"true"`
+	HasPrev    bool                `json:"has_prev" example:"false"`
+	NextPage   *int                `json:"next_page" example:"2"`
+	PrevPage   *int                `json:"prev_page"`
+}
+
+func newPaginatedSuccessResponse(result *stockviewer.PaginatedResponse) PaginatedSuccessResponse {
+	return PaginatedSuccessResponse{
+		Data:       result.Data,
+		Page:       result.Page,
+		PageSize:   result.PageSize,
+		TotalItems: result.TotalItems,
+		TotalPages: result.TotalPages,
+		HasNext:    result.HasNext,
+		HasPrev:    result.HasPrev,
+		NextPage:   result.NextPage,
+		PrevPage:   result.PrevPage,
+	}
+}
+
+// PaginatedRecommendationResponse mirrors
+// stockviewer.PaginatedRecommendations; the navigation fields are those of
+// PaginatedSuccessResponse.
+type PaginatedRecommendationResponse struct {
+	Data       []stockviewer.StockRecommendation `json:"data"`
+	Page       int                               `json:"page" example:"1"`
+	PageSize   int                               `json:"page_size" example:"10"`
+	TotalItems int64                             `json:"total_items" format:"int64" example:"100"`
+	TotalPages int64                             `json:"total_pages" format:"int64" example:"10"`
+	HasNext    bool                              `json:"has_next" example:"true"`
+	HasPrev    bool                              `json:"has_prev" example:"false"`
+	NextPage   *int                              `json:"next_page" example:"2"`
+	PrevPage   *int                              `json:"prev_page"`
+}
+
+func newPaginatedRecommendationResponse(result *stockviewer.PaginatedRecommendations) PaginatedRecommendationResponse {
+	return PaginatedRecommendationResponse{
+		Data:       result.Data,
+		Page:       result.Page,
+		PageSize:   result.PageSize,
+		TotalItems: result.TotalItems,
+		TotalPages: result.TotalPages,
+		HasNext:    result.HasNext,
+		HasPrev:    result.HasPrev,
+		NextPage:   result.NextPage,
+		PrevPage:   result.PrevPage,
+	}
 }
 
+// ViewResponse is a page of a saved view: the standard paginated payload plus
+// the view's metadata.
+type ViewResponse struct {
+	View stockviewer.SavedView `json:"view"`
+	PaginatedSuccessResponse
+}
+
+// ChangesResponse lists stocks updated since the requested timestamp.
+// NextSince and NextAfterID are the values to pass as since and after_id on
+// the next poll.
+type ChangesResponse struct {
+	Data        []stockviewer.Stock `json:"data"`
+	Count       int                 `json:"count"`
+	NextSince   string              `json:"next_since"`
+	NextAfterID string              `json:"next_after_id,omitempty"`
+}
+
+// NewTickersResponse lists tickers first covered within [From, To]. From
+// and To are omitted when no sync has completed yet.
+type NewTickersResponse struct {
+	Data  []stockviewer.NewTicker `json:"data"`
+	Count int                     `json:"count"`
+	From  string                  `json:"from,omitempty" example:"2024-05-01T12:00:00Z"`
+	To    string                  `json:"to,omitempty" example:"2024-05-01T12:03:10Z"`
+}
+
+// TokenResponse is returned by POST /auth/token.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type" example:"Bearer"`
+	// ExpiresIn is the token's remaining lifetime in seconds.
+	ExpiresIn int    `json:"expires_in" example:"3600"`
+	ExpiresAt string `json:"expires_at" example:"2024-05-01T13:00:00Z"`
+	Role      string `json:"role" example:"admin"`
+}
+
+// internalErrorMessage is the Message of every 500; the cause is logged.
+const internalErrorMessage = "An unexpected error occurred"
+
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message,omitempty"`
 }
 
+// respondError maps err to the status and ErrorResponse every handler uses for
+// it, so the same failure has the same shape on every route:
+//
+//   - stockviewer.ValidationError, validationErrors and ErrInvalidFilter: 400
+//   - stockviewer.ErrStockNotFound, ErrViewNotFound and
+//     ErrUnknownFilterField: 404
+//   - stockviewer.ErrSyncInProgress: 409
+//   - *http.MaxBytesError from a body over MaxBodyBytes: 413
+//   - stockviewer.ExternalAPIError and ErrExternalAPIFailure: 502
+//   - stockviewer.StorageError and anything else: 500, with a fixed message
+func respondError(c *gin.Context, err error) {
+	status, resp := errorResponse(err)
+	c.JSON(status, resp)
+}
+
+func errorResponse(err error) (int, ErrorResponse) {
+	var (
+		validationErr  stockviewer.ValidationError
+		validationErrs validationErrors
+		tooLarge       *http.MaxBytesError
+		externalErr    stockviewer.ExternalAPIError
+	)
+	switch {
+	case errors.As(err, &validationErr):
+		return http.StatusBadRequest, ErrorResponse{Error: "Invalid parameters", Message: validationErr.Error()}
+	case errors.As(err, &validationErrs):
+		return http.StatusBadRequest, ErrorResponse{Error: "Invalid parameters", Message: validationErrs.Error()}
+	case errors.Is(err, stockviewer.ErrInvalidFilter):
+		return http.StatusBadRequest, ErrorResponse{Error: "Invalid parameters", Message: err.Error()}
+	case errors.Is(err, stockviewer.ErrStockNotFound):
+		return http.StatusNotFound, ErrorResponse{Error: "Not found", Message: "Stock not found"}
+	case errors.Is(err, stockviewer.ErrViewNotFound):
+		return http.StatusNotFound, ErrorResponse{Error: "Not found", Message: "View not found"}
+	case errors.Is(err, stockviewer.ErrUnknownFilterField):
+		return http.StatusNotFound, ErrorResponse{Error: "Not found", Message: err.Error()}
+	case errors.Is(err, stockviewer.ErrSyncInProgress):
+		return http.StatusConflict, ErrorResponse{Error: "Conflict", Message: "Sync already in progress"}
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge, ErrorResponse{
+			Error:   "Request too large",
+			Message: fmt.Sprintf("Request body must not exceed %d bytes", tooLarge.Limit),
+		}
+	case errors.As(err, &externalErr), errors.Is(err, stockviewer.ErrExternalAPIFailure):
+		return http.StatusBadGateway, ErrorResponse{Error: "Bad gateway", Message: err.Error()}
+	}
+	// The cause can carry SQL or connection details, so it is only logged.
+	log.Printf("Internal server error: %v", err)
+	return http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: internalErrorMessage}
+}
+
+// validationErrors reports every rejected parameter of a request at once.
+type validationErrors []stockviewer.ValidationError
+
+func (errs validationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
 type SyncResponse struct {
-	Status         string `json:"status"`
-	TotalRecords   int    `json:"total_records"`
-	NewRecords     int    `json:"new_records"`
-	UpdatedRecords int    `json:"updated_records"`
-	LastSync       string `json:"last_sync"`
+	Status           string `json:"status"`
+	TotalRecords     int    `json:"total_records"`
+	NewRecords       int    `json:"new_records"`
+	UpdatedRecords   int    `json:"updated_records"`
+	DuplicateRecords int    `json:"duplicate_records"`
+	LastSync         string `json:"last_sync,omitempty"`
+	Error            string `json:"error,omitempty"`
+	// UpstreamService and UpstreamStatus name the external API that failed
+	// the sync and the HTTP status it answered with, so upstream outages are
+	// told apart from ours.
+	UpstreamService string `json:"upstream_service,omitempty" example:"karenai"`
+	UpstreamStatus  int    `json:"upstream_status,omitempty" example:"503"`
+	// SchemaWarnings counts entries quarantined for unusable field shapes,
+	// keyed by "field:shape".
+	SchemaWarnings map[string]int `json:"schema_warnings,omitempty"`
+}
+
+// SyncProgressEvent is sent on GET /sync/stream after each batch a sync
+// saves.
+type SyncProgressEvent struct {
+	Event     string `json:"event" example:"progress"`
+	Processed int    `json:"processed" example:"50"`
+	Total     int    `json:"total" example:"200"`
+}
+
+// SyncCompletedEvent is the last event sent on GET /sync/stream.
+type SyncCompletedEvent struct {
+	Event  string       `json:"event" example:"completed"`
+	Status SyncResponse `json:"status"`
 }
 
 type FiltersResponse struct {
-	Brokerages []string `json:"brokerages"`
-	Ratings    []string `json:"ratings"`
-	Actions    []string `json:"actions"`
+	Brokerages  []string `json:"brokerages"`
+	Ratings     []string `json:"ratings"`
+	RatingsFrom []string `json:"ratings_from"`
+	Actions     []string `json:"actions"`
+	// HasMore flags the lists cut short; page through them with
+	// /stocks/filters/{field}.
+	HasMore stockviewer.FilterHasMore `json:"has_more"`
 }
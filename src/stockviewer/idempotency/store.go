@@ -0,0 +1,92 @@
+package idempotency
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is how many keys NewStore keeps when given no capacity.
+const DefaultCapacity = 1000
+
+// Response is a stored reply, replayed byte for byte to a retried request.
+type Response struct {
+	Status int
+	Body   []byte
+}
+
+// Store remembers the response to each idempotency key for a TTL. It is held
+// in memory, so keys are per process and lost on restart. Once full, the
+// least recently used key is evicted first.
+type Store struct {
+	ttl      time.Duration
+	capacity int
+	now      func() time.Time
+	mu       sync.Mutex
+	// order lists the keys from most to least recently used.
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key       string
+	response  Response
+	expiresAt time.Time
+}
+
+func NewStore(ttl time.Duration, capacity int) *Store {
+	if capacity < 1 {
+		capacity = DefaultCapacity
+	}
+	return &Store{
+		ttl:      ttl,
+		capacity: capacity,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Do returns the unexpired response stored for key with replayed set.
+// Otherwise it runs fn and stores its response if the status is 2xx, so a
+// failed attempt can be retried with the same key. Calls are serialized, so a
+// retry that races the first request waits for it and gets its response
+// instead of running fn again; fn should therefore return quickly.
+func (s *Store) Do(key string, fn func() Response) (response Response, replayed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if elem, ok := s.entries[key]; ok {
+		e := elem.Value.(*entry)
+		if now.Before(e.expiresAt) {
+			s.order.MoveToFront(elem)
+			return e.response, true
+		}
+		s.remove(elem)
+	}
+
+	response = fn()
+	if response.Status < 200 || response.Status > 299 {
+		return response, false
+	}
+
+	s.entries[key] = s.order.PushFront(&entry{key: key, response: response, expiresAt: now.Add(s.ttl)})
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+	return response, false
+}
+
+// Len returns how many keys are held, expired ones included until they are
+// looked up or evicted.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *Store) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*entry).key)
+}
@@ -0,0 +1,90 @@
+package idempotency
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestStore(now *time.Time, capacity int) *Store {
+	store := NewStore(time.Minute, capacity)
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+func respond(calls *int, status int, body string) func() Response {
+	return func() Response {
+		*calls++
+		return Response{Status: status, Body: []byte(body)}
+	}
+}
+
+func TestDo_ReplaysWithinTTL(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := newTestStore(&now, 10)
+	calls := 0
+
+	first, replayed := store.Do("key", respond(&calls, http.StatusAccepted, "first"))
+	if replayed || string(first.Body) != "first" {
+		t.Fatalf("expected a fresh response, got %q (replayed %v)", first.Body, replayed)
+	}
+
+	now = now.Add(30 * time.Second)
+	second, replayed := store.Do("key", respond(&calls, http.StatusAccepted, "second"))
+	if !replayed || string(second.Body) != "first" || second.Status != http.StatusAccepted {
+		t.Errorf("expected the stored response to be replayed, got %d %q (replayed %v)", second.Status, second.Body, replayed)
+	}
+	if calls != 1 {
+		t.Errorf("expected fn to run once, ran %d times", calls)
+	}
+}
+
+func TestDo_ExpiredKeyRunsAgain(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := newTestStore(&now, 10)
+	calls := 0
+
+	store.Do("key", respond(&calls, http.StatusAccepted, "first"))
+	now = now.Add(2 * time.Minute)
+
+	response, replayed := store.Do("key", respond(&calls, http.StatusAccepted, "second"))
+	if replayed || string(response.Body) != "second" {
+		t.Errorf("expected an expired key to run fn again, got %q (replayed %v)", response.Body, replayed)
+	}
+	if calls != 2 {
+		t.Errorf("expected fn to run twice, ran %d times", calls)
+	}
+}
+
+func TestDo_FailuresAreNotStored(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := newTestStore(&now, 10)
+	calls := 0
+
+	store.Do("key", respond(&calls, http.StatusConflict, "busy"))
+	response, replayed := store.Do("key", respond(&calls, http.StatusAccepted, "started"))
+	if replayed || string(response.Body) != "started" {
+		t.Errorf("expected a retry after a failure to run fn, got %q (replayed %v)", response.Body, replayed)
+	}
+}
+
+func TestDo_EvictsLeastRecentlyUsed(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := newTestStore(&now, 2)
+	calls := 0
+
+	store.Do("a", respond(&calls, http.StatusAccepted, "a"))
+	store.Do("b", respond(&calls, http.StatusAccepted, "b"))
+	store.Do("a", respond(&calls, http.StatusAccepted, "a again"))
+	store.Do("c", respond(&calls, http.StatusAccepted, "c"))
+
+	if store.Len() != 2 {
+		t.Fatalf("expected 2 keys, got %d", store.Len())
+	}
+	if _, replayed := store.Do("a", respond(&calls, http.StatusAccepted, "")); !replayed {
+		t.Error("expected the recently used key to be kept")
+	}
+	if _, replayed := store.Do("b", respond(&calls, http.StatusAccepted, "")); replayed {
+		t.Error("expected the least recently used key to be evicted")
+	}
+}
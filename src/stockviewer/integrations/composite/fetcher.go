@@ -0,0 +1,49 @@
+package composite
+
+import (
+	"context"
+	"sync"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+// Fetcher merges several StocksFetcher sources into a single stream. Sources
+// are read concurrently and a failure in one does not stop the others.
+type Fetcher struct {
+	fetchers []stockviewer.StocksFetcher
+}
+
+func NewFetcher(fetchers ...stockviewer.StocksFetcher) *Fetcher {
+	return &Fetcher{
+		fetchers: fetchers,
+	}
+}
+
+func (f *Fetcher) FetchStocks(ctx context.Context) (<-chan stockviewer.StockOrError, error) {
+	stocksChan := make(chan stockviewer.StockOrError, 100)
+
+	var wg sync.WaitGroup
+	for _, fetcher := range f.fetchers {
+		wg.Add(1)
+		go func(fetcher stockviewer.StocksFetcher) {
+			defer wg.Done()
+
+			source, err := fetcher.FetchStocks(ctx)
+			if err != nil {
+				stocksChan <- stockviewer.StockOrError{Error: err}
+				return
+			}
+
+			for stockOrErr := range source {
+				stocksChan <- stockOrErr
+			}
+		}(fetcher)
+	}
+
+	go func() {
+		wg.Wait()
+		close(stocksChan)
+	}()
+
+	return stocksChan, nil
+}
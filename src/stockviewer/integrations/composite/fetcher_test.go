@@ -0,0 +1,68 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/mocks"
+)
+
+func TestFetchStocks_MergesSources(t *testing.T) {
+	first := mocks.NewMockStocksFetcher()
+	second := &mocks.MockStocksFetcher{
+		Stocks: []stockviewer.Stock{
+			{ID: "other-1", Ticker: "AAPL"},
+		},
+	}
+
+	fetcher := NewFetcher(first, second)
+	stocksChan, err := fetcher.FetchStocks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for stockOrErr := range stocksChan {
+		if stockOrErr.Error != nil {
+			t.Fatalf("unexpected error: %v", stockOrErr.Error)
+		}
+		seen[stockOrErr.Stock.ID] = true
+	}
+
+	expected := len(first.Stocks) + len(second.Stocks)
+	if len(seen) != expected {
+		t.Errorf("expected %d stocks, got %d", expected, len(seen))
+	}
+	if !seen["other-1"] || !seen["mock-1"] {
+		t.Errorf("expected stocks from both sources, got %v", seen)
+	}
+}
+
+func TestFetchStocks_SourceErrorDoesNotStopOthers(t *testing.T) {
+	failing := &mocks.MockStocksFetcher{Error: errors.New("source down")}
+	healthy := mocks.NewMockStocksFetcher()
+
+	fetcher := NewFetcher(failing, healthy)
+	stocksChan, err := fetcher.FetchStocks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var stocks, errs int
+	for stockOrErr := range stocksChan {
+		if stockOrErr.Error != nil {
+			errs++
+			continue
+		}
+		stocks++
+	}
+
+	if errs != 1 {
+		t.Errorf("expected 1 error, got %d", errs)
+	}
+	if stocks != len(healthy.Stocks) {
+		t.Errorf("expected %d stocks from the healthy source, got %d", len(healthy.Stocks), stocks)
+	}
+}
@@ -5,20 +5,77 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracerName names the tracer of this package. Tracers are looked up on each
+// use so spans follow the current global provider.
+const tracerName = "github.com/user/go-stock-viewer-back/src/stockviewer/integrations/karenai"
+
+// DefaultMaxPages is how many pages FetchStocks reads when WithMaxPages is
+// not given.
+const DefaultMaxPages = 100
+
 type Client struct {
-	baseURL    string
-	token      string
-	httpClient *http.Client
+	baseURL        string
+	fallbackURL    string
+	token          string
+	httpClient     *http.Client
+	errorBodyLimit int
+	maxPages       int
+	debug          bool
+}
+
+// Option customizes optional Client settings.
+type Option func(*Client)
+
+// WithErrorBodyLimit caps how many bytes of an error response body are kept
+// in the returned ExternalAPIError.
+func WithErrorBodyLimit(limit int) Option {
+	return func(c *Client) {
+		if limit > 0 {
+			c.errorBodyLimit = limit
+		}
+	}
+}
+
+// WithFallbackBaseURL sets a secondary base URL that fetchPage switches to
+// when the primary is unreachable or answers with a server error.
+func WithFallbackBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.fallbackURL = baseURL
+	}
+}
+
+// WithMaxPages stops FetchStocks after the given number of pages, e.g. for a
+// quick sync of the first few pages during development.
+func WithMaxPages(pages int) Option {
+	return func(c *Client) {
+		if pages > 0 {
+			c.maxPages = pages
+		}
+	}
+}
+
+// WithDebug logs the full (redacted) body of error responses.
+func WithDebug(enabled bool) Option {
+	return func(c *Client) {
+		c.debug = enabled
+	}
 }
 
 type APIResponse struct {
@@ -37,10 +94,22 @@ type StockItem struct {
 	TargetTo   any    `json:"target_to"`
 }
 
-func parseFloat(v any) float64 {
+// maxRawIssueLength caps how much of an unparseable value is kept in a
+// stock's data issues.
+const maxRawIssueLength = 100
+
+// parseTarget reads a price target sent as a number or as a currency string
+// such as "$1,234.50"; an empty string is a missing target and reads as
+// zero. For anything else ok is false and raw holds the value as received,
+// e.g. `{"amount":"12.5"}`, for the stock's data issues.
+func parseTarget(v any) (value float64, ok bool, raw string) {
 	switch val := v.(type) {
 	case float64:
-		return val
+		return val, true, ""
+	case int:
+		return float64(val), true, ""
+	case int64:
+		return float64(val), true, ""
 	case string:
 		// Clean currency format: remove $, commas, and whitespace
 		cleaned := strings.TrimSpace(val)
@@ -48,25 +117,57 @@ func parseFloat(v any) float64 {
 		cleaned = strings.ReplaceAll(cleaned, ",", "")
 		cleaned = strings.TrimSpace(cleaned)
 
+		if cleaned == "" {
+			return 0, true, ""
+		}
 		if f, err := strconv.ParseFloat(cleaned, 64); err == nil {
-			return f
+			return f, true, ""
 		}
-	case int:
-		return float64(val)
-	case int64:
-		return float64(val)
 	}
-	return 0
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		encoded = []byte(fmt.Sprintf("%v", v))
+	}
+	if len(encoded) > maxRawIssueLength {
+		encoded = append(encoded[:maxRawIssueLength], "..."...)
+	}
+	return 0, false, string(encoded)
+}
+
+// jsonShape names the JSON type of a decoded value.
+func jsonShape(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64, int, int64:
+		return "number"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
 }
 
-func NewClient(baseURL, token string) *Client {
-	return &Client{
+func NewClient(baseURL, token string, opts ...Option) *Client {
+	c := &Client{
 		baseURL: baseURL,
 		token:   token,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		errorBodyLimit: stockviewer.DefaultErrorBodyLimit,
+		maxPages:       DefaultMaxPages,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *Client) FetchStocks(ctx context.Context) (<-chan stockviewer.StockOrError, error) {
@@ -77,9 +178,8 @@ func (c *Client) FetchStocks(ctx context.Context) (<-chan stockviewer.StockOrErr
 
 		nextPage := ""
 		pageCount := 0
-		maxPages := 100
 
-		for pageCount < maxPages {
+		for pageCount < c.maxPages {
 			select {
 			case <-ctx.Done():
 				stocksChan <- stockviewer.StockOrError{Error: ctx.Err()}
@@ -94,8 +194,11 @@ func (c *Client) FetchStocks(ctx context.Context) (<-chan stockviewer.StockOrErr
 			}
 
 			for _, item := range response.Items {
-				stock := convertToStock(item)
-				stocksChan <- stockviewer.StockOrError{Stock: stock}
+				if err := validateItem(item); err != nil {
+					stocksChan <- stockviewer.StockOrError{Error: err}
+					continue
+				}
+				stocksChan <- stockviewer.StockOrError{Stock: convertToStock(item)}
 			}
 
 			if response.NextPage == "" {
@@ -110,8 +213,54 @@ func (c *Client) FetchStocks(ctx context.Context) (<-chan stockviewer.StockOrErr
 	return stocksChan, nil
 }
 
-func (c *Client) fetchPage(ctx context.Context, nextPage string) (*APIResponse, error) {
-	url := fmt.Sprintf("%s/swechallenge/list", c.baseURL)
+// fetchPage requests a page from the primary base URL and fails over to the
+// fallback, if configured, when the primary cannot serve it.
+func (c *Client) fetchPage(ctx context.Context, nextPage string) (response *APIResponse, err error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "karenai.fetchPage")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(attribute.Int("karenai.items", len(response.Items)))
+		}
+		span.End()
+	}()
+
+	response, err = c.fetchPageFrom(ctx, c.baseURL, nextPage)
+	if err == nil {
+		log.Printf("karenai page served by %s", c.baseURL)
+		return response, nil
+	}
+	if c.fallbackURL == "" || !shouldFailover(err) {
+		return nil, err
+	}
+
+	log.Printf("karenai primary %s failed, trying fallback %s: %v", c.baseURL, c.fallbackURL, err)
+	span.AddEvent("failover", trace.WithAttributes(attribute.String("karenai.primary_error", err.Error())))
+	response, err = c.fetchPageFrom(ctx, c.fallbackURL, nextPage)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("karenai page served by %s", c.fallbackURL)
+	return response, nil
+}
+
+// shouldFailover reports whether err means the endpoint itself is unhealthy:
+// the request never got a response, or the response was a 5xx.
+func shouldFailover(err error) bool {
+	var apiErr stockviewer.ExternalAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode == 0 {
+		return apiErr.Err != nil && !errors.Is(apiErr.Err, context.Canceled)
+	}
+	return apiErr.StatusCode >= http.StatusInternalServerError
+}
+
+func (c *Client) fetchPageFrom(ctx context.Context, baseURL, nextPage string) (*APIResponse, error) {
+	url := fmt.Sprintf("%s/swechallenge/list", baseURL)
 	if nextPage != "" {
 		url = fmt.Sprintf("%s?next_page=%s", url, nextPage)
 	}
@@ -140,10 +289,14 @@ func (c *Client) fetchPage(ctx context.Context, nextPage string) (*APIResponse,
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
+		if c.debug {
+			log.Printf("karenai error response (status %d, %d bytes): %s",
+				resp.StatusCode, len(body), stockviewer.RedactSecrets(string(body), c.token))
+		}
 		return nil, stockviewer.ExternalAPIError{
 			Service:    "karenai",
 			StatusCode: resp.StatusCode,
-			Message:    fmt.Sprintf("unexpected status code: %s", string(body)),
+			Message:    fmt.Sprintf("unexpected status code: %s", stockviewer.SanitizeErrorBody(body, c.errorBodyLimit, c.token)),
 		}
 	}
 
@@ -168,22 +321,79 @@ func (c *Client) fetchPage(ctx context.Context, nextPage string) (*APIResponse,
 	return &response, nil
 }
 
-func convertToStock(item StockItem) stockviewer.Stock {
-	targetFrom := parseFloat(item.TargetFrom)
-	targetTo := parseFloat(item.TargetTo)
-	id := generateStockID(item, targetFrom, targetTo)
+// validateItem rejects items no stock can be built from: one without a
+// ticker, or with a target such as "NaN" or "Inf" that parses but cannot be
+// scored or stored. Unlike a malformed target, which only quarantines the
+// stock, a rejected item is not saved; the returned ConversionError carries
+// the item for the dead-letter store.
+func validateItem(item StockItem) error {
+	var reason string
+	switch {
+	case strings.TrimSpace(item.Ticker) == "":
+		reason = "missing ticker"
+	case !finiteTarget(item.TargetFrom):
+		reason = "target_from is not a finite number"
+	case !finiteTarget(item.TargetTo):
+		reason = "target_to is not a finite number"
+	default:
+		return nil
+	}
+
+	payload, err := json.Marshal(item)
+	if err != nil {
+		payload = []byte(fmt.Sprintf("%+v", item))
+	}
+	return stockviewer.ConversionError{
+		Source:  "karenai",
+		Reason:  reason,
+		Payload: string(payload),
+	}
+}
 
-	return stockviewer.Stock{
-		ID:         id,
+// finiteTarget is false for a target that parses to NaN or an infinity.
+// Targets that do not parse at all are left to convertToStock.
+func finiteTarget(v any) bool {
+	value, ok, _ := parseTarget(v)
+	return !ok || (!math.IsNaN(value) && !math.IsInf(value, 0))
+}
+
+// convertToStock maps a feed item to a stock. A target that cannot be parsed
+// quarantines the stock: the target is left nil and a data issue records
+// what was received. The ID reads such a target as zero, as it always has.
+func convertToStock(item StockItem) stockviewer.Stock {
+	stock := stockviewer.Stock{
 		Ticker:     item.Ticker,
 		Company:    item.Company,
 		Brokerage:  item.Brokerage,
 		Action:     item.Action,
 		RatingFrom: item.RatingFrom,
 		RatingTo:   item.RatingTo,
-		TargetFrom: targetFrom,
-		TargetTo:   targetTo,
 	}
+
+	targets := []struct {
+		field string
+		value any
+		dest  **float64
+	}{
+		{"target_from", item.TargetFrom, &stock.TargetFrom},
+		{"target_to", item.TargetTo, &stock.TargetTo},
+	}
+	for _, target := range targets {
+		value, ok, raw := parseTarget(target.value)
+		if !ok {
+			stock.DataIssues = append(stock.DataIssues, stockviewer.DataIssue{
+				Field: target.field,
+				Shape: jsonShape(target.value),
+				Raw:   raw,
+			})
+			continue
+		}
+		*target.dest = stockviewer.Price(value)
+	}
+
+	targetFrom, targetTo := stock.Targets()
+	stock.ID = generateStockID(item, targetFrom, targetTo)
+	return stock
 }
 
 func generateStockID(item StockItem, targetFrom, targetTo float64) string {
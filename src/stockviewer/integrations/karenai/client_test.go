@@ -0,0 +1,332 @@
+package karenai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	original := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(original) })
+	return &buf
+}
+
+func newErrorServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchPage_TruncatesAndRedactsErrorBody(t *testing.T) {
+	const token = "super-secret-token"
+	body := "<html>echo: Authorization: Bearer " + token + "</html>" + strings.Repeat("x", 2<<20)
+	server := newErrorServer(t, body)
+	logs := captureLogs(t)
+
+	client := NewClient(server.URL, token, WithErrorBodyLimit(64))
+	_, err := client.fetchPage(context.Background(), "")
+
+	var apiErr stockviewer.ExternalAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected ExternalAPIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", apiErr.StatusCode)
+	}
+
+	msg := err.Error()
+	if strings.Contains(msg, token) {
+		t.Errorf("expected token to be redacted, got %q", msg)
+	}
+	if !strings.Contains(msg, "[REDACTED]") || !strings.Contains(msg, "truncated") {
+		t.Errorf("expected redacted and truncated message, got %q", msg)
+	}
+	if len(msg) > 200 {
+		t.Errorf("expected a bounded error message, got %d bytes", len(msg))
+	}
+	if logs.Len() != 0 {
+		t.Errorf("expected no body logging outside debug mode, got %q", logs.String())
+	}
+}
+
+func TestFetchPage_DebugLogsFullRedactedBody(t *testing.T) {
+	const token = "super-secret-token"
+	body := "token=" + token + " " + strings.Repeat("y", 4096)
+	server := newErrorServer(t, body)
+	logs := captureLogs(t)
+
+	client := NewClient(server.URL, token, WithErrorBodyLimit(32), WithDebug(true))
+	_, err := client.fetchPage(context.Background(), "")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	logged := logs.String()
+	if strings.Contains(logged, token) {
+		t.Errorf("expected token to be redacted in logs, got %q", logged[:100])
+	}
+	if !strings.Contains(logged, strings.Repeat("y", 4096)) {
+		t.Error("expected the full body in debug logs")
+	}
+	if strings.Contains(err.Error(), strings.Repeat("y", 100)) {
+		t.Error("expected the error to stay truncated in debug mode")
+	}
+}
+
+func newPageServer(t *testing.T, calls *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[{"ticker":"AAPL","company":"Apple Inc.","target_from":"$150.00","target_to":"$180.00"}],"next_page":""}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchPage_FailsOverToFallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		primary func(t *testing.T) string
+	}{
+		{"primary returns 5xx", func(t *testing.T) string {
+			return newErrorServer(t, "upstream down").URL
+		}},
+		{"primary unreachable", func(t *testing.T) string {
+			server := httptest.NewServer(http.NotFoundHandler())
+			server.Close()
+			return server.URL
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			secondary := newPageServer(t, &calls)
+			logs := captureLogs(t)
+
+			client := NewClient(tt.primary(t), "token", WithFallbackBaseURL(secondary.URL))
+			response, err := client.fetchPage(context.Background(), "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if calls != 1 {
+				t.Errorf("expected the fallback to be called once, got %d", calls)
+			}
+			if len(response.Items) != 1 || response.Items[0].Ticker != "AAPL" {
+				t.Errorf("expected the fallback page, got %+v", response.Items)
+			}
+			if !strings.Contains(logs.String(), "page served by "+secondary.URL) {
+				t.Errorf("expected the serving endpoint to be logged, got %q", logs.String())
+			}
+		})
+	}
+}
+
+func TestFetchPage_NoFailoverOnClientError(t *testing.T) {
+	calls := 0
+	secondary := newPageServer(t, &calls)
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer primary.Close()
+	captureLogs(t)
+
+	client := NewClient(primary.URL, "token", WithFallbackBaseURL(secondary.URL))
+	if _, err := client.fetchPage(context.Background(), ""); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if calls != 0 {
+		t.Errorf("expected no failover on a 4xx, got %d fallback calls", calls)
+	}
+}
+
+func TestFetchStocks_StopsAtMaxPages(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"items":[{"ticker":"T%d","target_from":"$1.00","target_to":"$2.00"}],"next_page":"p%d"}`, requests, requests)
+	}))
+	t.Cleanup(server.Close)
+	captureLogs(t)
+
+	client := NewClient(server.URL, "token", WithMaxPages(3))
+	stocks, err := client.FetchStocks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var received int
+	for result := range stocks {
+		if result.Error != nil {
+			t.Fatalf("unexpected error: %v", result.Error)
+		}
+		received++
+	}
+	if requests != 3 || received != 3 {
+		t.Errorf("expected 3 pages and 3 stocks, got %d requests and %d stocks", requests, received)
+	}
+}
+
+// TestFetchStocks_QuarantinesMalformedTargets replays a page with every
+// target shape the upstream has been seen to send.
+func TestFetchStocks_QuarantinesMalformedTargets(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/malformed_targets.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fixture)
+	}))
+	t.Cleanup(server.Close)
+	captureLogs(t)
+
+	price := stockviewer.Price
+	tests := map[string]struct {
+		targetFrom, targetTo *float64
+		issues               []stockviewer.DataIssue
+	}{
+		"CLEAN": {targetFrom: price(1234.5), targetTo: price(1400)},
+		"NUMB":  {targetFrom: price(10), targetTo: price(12.5)},
+		"EMPTY": {targetFrom: price(0), targetTo: price(8)},
+		"NULL": {targetFrom: price(20), issues: []stockviewer.DataIssue{
+			{Field: "target_to", Shape: "null", Raw: "null"},
+		}},
+		"OBJ": {targetFrom: price(10), issues: []stockviewer.DataIssue{
+			{Field: "target_to", Shape: "object", Raw: `{"amount":"12.5"}`},
+		}},
+		"BOOL": {issues: []stockviewer.DataIssue{
+			{Field: "target_from", Shape: "boolean", Raw: "false"},
+			{Field: "target_to", Shape: "boolean", Raw: "false"},
+		}},
+		"TEXT": {targetFrom: price(5), issues: []stockviewer.DataIssue{
+			{Field: "target_to", Shape: "string", Raw: `"n/a"`},
+		}},
+		"LIST": {targetFrom: price(5), issues: []stockviewer.DataIssue{
+			{Field: "target_to", Shape: "array", Raw: `["$6.00"]`},
+		}},
+		"MISSING": {targetFrom: price(7), issues: []stockviewer.DataIssue{
+			{Field: "target_to", Shape: "null", Raw: "null"},
+		}},
+	}
+
+	stocks, err := NewClient(server.URL, "token").FetchStocks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	seen := make(map[string]bool)
+	for result := range stocks {
+		if result.Error != nil {
+			t.Fatalf("unexpected error: %v", result.Error)
+		}
+		stock := result.Stock
+		want, ok := tests[stock.Ticker]
+		if !ok {
+			t.Fatalf("unexpected ticker %s", stock.Ticker)
+		}
+		seen[stock.Ticker] = true
+
+		if !reflect.DeepEqual(stock.TargetFrom, want.targetFrom) || !reflect.DeepEqual(stock.TargetTo, want.targetTo) {
+			t.Errorf("%s: expected targets %v/%v, got %v/%v", stock.Ticker,
+				formatTarget(want.targetFrom), formatTarget(want.targetTo), formatTarget(stock.TargetFrom), formatTarget(stock.TargetTo))
+		}
+		if !reflect.DeepEqual(stock.DataIssues, want.issues) {
+			t.Errorf("%s: expected issues %+v, got %+v", stock.Ticker, want.issues, stock.DataIssues)
+		}
+	}
+	if len(seen) != len(tests) {
+		t.Errorf("expected %d stocks, got %d", len(tests), len(seen))
+	}
+}
+
+func formatTarget(target *float64) string {
+	if target == nil {
+		return "nil"
+	}
+	return fmt.Sprint(*target)
+}
+
+func TestConvertToStock_IDReadsUnparseableTargetAsZero(t *testing.T) {
+	base := StockItem{Ticker: "NULL", Company: "Null Target Co", TargetFrom: "$20.00", TargetTo: 0.0}
+	for _, malformed := range []any{nil, false, map[string]any{"amount": "12.5"}, "n/a"} {
+		item := base
+		item.TargetTo = malformed
+		if got, want := convertToStock(item).ID, convertToStock(base).ID; got != want {
+			t.Errorf("target_to %v: expected the ID of a zero target %s, got %s", malformed, want, got)
+		}
+	}
+}
+
+func TestFetchStocks_RejectsUnconvertibleItems(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[
+			{"ticker":"GOOD","target_from":"$1.00","target_to":"$2.00"},
+			{"ticker":" ","company":"Blank Co","target_from":"$1.00","target_to":"$2.00"},
+			{"ticker":"NAN","target_from":"$1.00","target_to":"NaN"},
+			{"ticker":"INF","target_from":"Inf","target_to":"$2.00"}
+		]}`))
+	}))
+	t.Cleanup(server.Close)
+	captureLogs(t)
+
+	stocks, err := NewClient(server.URL, "token").FetchStocks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var tickers, reasons []string
+	for result := range stocks {
+		if result.Error == nil {
+			tickers = append(tickers, result.Stock.Ticker)
+			continue
+		}
+		var convErr stockviewer.ConversionError
+		if !errors.As(result.Error, &convErr) {
+			t.Fatalf("expected a conversion error, got %v", result.Error)
+		}
+		if convErr.Source != "karenai" || !strings.HasPrefix(convErr.Payload, `{"ticker":`) {
+			t.Errorf("expected the item as received, got %+v", convErr)
+		}
+		reasons = append(reasons, convErr.Reason)
+	}
+
+	if want := []string{"GOOD"}; !reflect.DeepEqual(tickers, want) {
+		t.Errorf("expected stocks %v, got %v", want, tickers)
+	}
+	want := []string{"missing ticker", "target_to is not a finite number", "target_from is not a finite number"}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("expected rejections %v, got %v", want, reasons)
+	}
+}
+
+func TestParseTarget_TruncatesRaw(t *testing.T) {
+	long := map[string]any{"amount": strings.Repeat("9", 2*maxRawIssueLength)}
+	_, ok, raw := parseTarget(long)
+	if ok {
+		t.Fatal("expected an object target to be rejected")
+	}
+	if len(raw) != maxRawIssueLength+len("...") || !strings.HasSuffix(raw, "...") {
+		t.Errorf("expected raw truncated to %d bytes, got %d: %s", maxRawIssueLength, len(raw), raw)
+	}
+}
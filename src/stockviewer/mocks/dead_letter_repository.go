@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+type MockDeadLetterRepository struct {
+	mu      sync.Mutex
+	Letters []stockviewer.DeadLetter
+	Error   error
+}
+
+func NewMockDeadLetterRepository() *MockDeadLetterRepository {
+	return &MockDeadLetterRepository{}
+}
+
+func (m *MockDeadLetterRepository) Record(ctx context.Context, letter stockviewer.DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return m.Error
+	}
+	letter.ID = uint(len(m.Letters) + 1)
+	if letter.CreatedAt.IsZero() {
+		letter.CreatedAt = time.Now().UTC()
+	}
+	m.Letters = append(m.Letters, letter)
+	return nil
+}
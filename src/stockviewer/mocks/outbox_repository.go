@@ -0,0 +1,69 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+type MockOutboxRepository struct {
+	mu     sync.Mutex
+	Events []stockviewer.OutboxEvent
+	Error  error
+}
+
+func NewMockOutboxRepository(events ...stockviewer.OutboxEvent) *MockOutboxRepository {
+	return &MockOutboxRepository{
+		Events: events,
+	}
+}
+
+func (m *MockOutboxRepository) GetPending(ctx context.Context, maxAttempts int, limit int) ([]stockviewer.OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	var pending []stockviewer.OutboxEvent
+	for _, event := range m.Events {
+		if event.ProcessedAt == nil && event.Attempts < maxAttempts {
+			pending = append(pending, event)
+		}
+		if len(pending) == limit {
+			break
+		}
+	}
+	return pending, nil
+}
+
+func (m *MockOutboxRepository) MarkProcessed(ctx context.Context, id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.Events {
+		if m.Events[i].ID == id {
+			now := time.Now().UTC()
+			m.Events[i].Attempts++
+			m.Events[i].ProcessedAt = &now
+			return nil
+		}
+	}
+	return stockviewer.ErrStockNotFound
+}
+
+func (m *MockOutboxRepository) MarkFailed(ctx context.Context, id uint, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.Events {
+		if m.Events[i].ID == id {
+			m.Events[i].Attempts++
+			m.Events[i].LastError = reason
+			return nil
+		}
+	}
+	return stockviewer.ErrStockNotFound
+}
@@ -8,35 +8,38 @@ import (
 
 type MockStocksFetcher struct {
 	Stocks []stockviewer.Stock
-	Error  error
+	// ItemErrors are sent after Stocks, as a fetcher reports items it
+	// could not convert.
+	ItemErrors []error
+	Error      error
 }
 
 func NewMockStocksFetcher() *MockStocksFetcher {
 	return &MockStocksFetcher{
 		Stocks: []stockviewer.Stock{
 			{
-				ID:         "mock-1",
-				Ticker:     "RMTI",
-				Company:    "Rockwell Medical",
-				Brokerage:  "Analyst Firm",
-				Action:     "target lowered by",
-				RatingTo:   "Buy",
+				ID:        "mock-1",
+				Ticker:    "RMTI",
+				Company:   "Rockwell Medical",
+				Brokerage: "Analyst Firm",
+				Action:    "target lowered by",
+				RatingTo:  "Buy",
 			},
 			{
-				ID:         "mock-2",
-				Ticker:     "AKBA",
-				Company:    "Akebia Therapeutics",
-				Brokerage:  "Analyst Firm",
-				Action:     "target lowered by",
-				RatingTo:   "Buy",
+				ID:        "mock-2",
+				Ticker:    "AKBA",
+				Company:   "Akebia Therapeutics",
+				Brokerage: "Analyst Firm",
+				Action:    "target lowered by",
+				RatingTo:  "Buy",
 			},
 			{
-				ID:         "mock-3",
-				Ticker:     "CECO",
-				Company:    "CECO Environmental",
-				Brokerage:  "Analyst Firm",
-				Action:     "target raised by",
-				RatingTo:   "Buy",
+				ID:        "mock-3",
+				Ticker:    "CECO",
+				Company:   "CECO Environmental",
+				Brokerage: "Analyst Firm",
+				Action:    "target raised by",
+				RatingTo:  "Buy",
 			},
 		},
 	}
@@ -47,7 +50,7 @@ func (m *MockStocksFetcher) FetchStocks(ctx context.Context) (<-chan stockviewer
 		return nil, m.Error
 	}
 
-	ch := make(chan stockviewer.StockOrError, len(m.Stocks))
+	ch := make(chan stockviewer.StockOrError, len(m.Stocks)+len(m.ItemErrors))
 
 	go func() {
 		defer close(ch)
@@ -59,6 +62,9 @@ func (m *MockStocksFetcher) FetchStocks(ctx context.Context) (<-chan stockviewer
 			case ch <- stockviewer.StockOrError{Stock: stock}:
 			}
 		}
+		for _, err := range m.ItemErrors {
+			ch <- stockviewer.StockOrError{Error: err}
+		}
 	}()
 
 	return ch, nil
@@ -2,59 +2,82 @@ package mocks
 
 import (
 	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/user/go-stock-viewer-back/src/stockviewer"
 )
 
 type MockStocksRepository struct {
-	Stocks     []stockviewer.Stock
-	Error      error
-	SaveError  error
+	Stocks    []stockviewer.Stock
+	Events    []stockviewer.OutboxEvent
+	Error     error
+	SaveError error
+	// PingError is returned by Ping, which ignores Error so a test can
+	// fail readiness while queries still work.
+	PingError  error
+	LastFilter stockviewer.StockFilter
+	// LastSearchFuzzy records the fuzzy flag of the latest Search call.
+	LastSearchFuzzy bool
 }
 
+// IDs of the stocks NewMockStocksRepository starts with. They are MD5 hex
+// strings like the IDs the sync generates.
+const (
+	AAPLStockID  = "23db3d7a683b172b003695da3ae5c542"
+	GOOGLStockID = "df4382cf73c705ab965ab690f63e0acf"
+	MSFTStockID  = "03655f29231084f796329ccf2bd9152c"
+)
+
 func NewMockStocksRepository() *MockStocksRepository {
 	return &MockStocksRepository{
 		Stocks: []stockviewer.Stock{
 			{
-				ID:             "test-id-1",
+				ID:             AAPLStockID,
 				Ticker:         "AAPL",
 				Company:        "Apple Inc.",
 				Brokerage:      "Goldman Sachs",
 				Action:         "target raised by",
 				RatingFrom:     "Hold",
 				RatingTo:       "Buy",
-				TargetFrom:     150.0,
-				TargetTo:       180.0,
+				TargetFrom:     stockviewer.Price(150.0),
+				TargetTo:       stockviewer.Price(180.0),
 				RecommendScore: 85.5,
 			},
 			{
-				ID:             "test-id-2",
+				ID:             GOOGLStockID,
 				Ticker:         "GOOGL",
 				Company:        "Alphabet Inc.",
 				Brokerage:      "Morgan Stanley",
 				Action:         "upgraded by",
 				RatingFrom:     "Neutral",
 				RatingTo:       "Buy",
-				TargetFrom:     2800.0,
-				TargetTo:       3200.0,
+				TargetFrom:     stockviewer.Price(2800.0),
+				TargetTo:       stockviewer.Price(3200.0),
 				RecommendScore: 90.0,
 			},
 			{
-				ID:             "test-id-3",
+				ID:             MSFTStockID,
 				Ticker:         "MSFT",
 				Company:        "Microsoft Corporation",
 				Brokerage:      "JP Morgan",
 				Action:         "target lowered by",
 				RatingFrom:     "Buy",
 				RatingTo:       "Neutral",
-				TargetFrom:     350.0,
-				TargetTo:       320.0,
+				TargetFrom:     stockviewer.Price(350.0),
+				TargetTo:       stockviewer.Price(320.0),
 				RecommendScore: 45.0,
 			},
 		},
 	}
 }
 
+func (m *MockStocksRepository) Ping(ctx context.Context) error {
+	return m.PingError
+}
+
 func (m *MockStocksRepository) Save(ctx context.Context, stock stockviewer.Stock) error {
 	if m.SaveError != nil {
 		return m.SaveError
@@ -71,6 +94,16 @@ func (m *MockStocksRepository) SaveBatch(ctx context.Context, stocks []stockview
 	return nil
 }
 
+func (m *MockStocksRepository) SaveBatchWithEvent(ctx context.Context, stocks []stockviewer.Stock, event stockviewer.OutboxEvent) error {
+	if m.SaveError != nil {
+		return m.SaveError
+	}
+	m.Stocks = append(m.Stocks, stocks...)
+	event.ID = uint(len(m.Events) + 1)
+	m.Events = append(m.Events, event)
+	return nil
+}
+
 func (m *MockStocksRepository) GetByID(ctx context.Context, id string) (*stockviewer.Stock, error) {
 	if m.Error != nil {
 		return nil, m.Error
@@ -83,9 +116,33 @@ func (m *MockStocksRepository) GetByID(ctx context.Context, id string) (*stockvi
 	return nil, stockviewer.ErrStockNotFound
 }
 
-func (m *MockStocksRepository) GetByTicker(ctx context.Context, ticker string) ([]stockviewer.Stock, error) {
+func (m *MockStocksRepository) Update(ctx context.Context, id string, updates map[string]interface{}) error {
+	if m.SaveError != nil {
+		return m.SaveError
+	}
+	for i := range m.Stocks {
+		if m.Stocks[i].ID != id {
+			continue
+		}
+		if score, ok := updates["recommend_score"].(float64); ok {
+			m.Stocks[i].RecommendScore = score
+		}
+		if overridden, ok := updates["score_overridden"].(bool); ok {
+			m.Stocks[i].ScoreOverridden = overridden
+		}
+		if notes, ok := updates["notes"].(string); ok {
+			m.Stocks[i].Notes = notes
+		}
+		m.Stocks[i].UpdatedAt = time.Now()
+		return nil
+	}
+	return stockviewer.ErrStockNotFound
+}
+
+func (m *MockStocksRepository) GetTickerHistory(ctx context.Context, ticker string, filter stockviewer.StockFilter) ([]stockviewer.Stock, int64, error) {
+	m.LastFilter = filter
 	if m.Error != nil {
-		return nil, m.Error
+		return nil, 0, m.Error
 	}
 	var result []stockviewer.Stock
 	for _, stock := range m.Stocks {
@@ -93,14 +150,78 @@ func (m *MockStocksRepository) GetByTicker(ctx context.Context, ticker string) (
 			result = append(result, stock)
 		}
 	}
-	return result, nil
+
+	byUpdated := strings.HasPrefix(filter.SortBy, "updated_at")
+	sort.SliceStable(result, func(i, j int) bool {
+		if byUpdated {
+			return result[i].UpdatedAt.After(result[j].UpdatedAt)
+		}
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return paginate(result, filter), int64(len(result)), nil
+}
+
+// paginate returns the page of stocks selected by filter; a page past the
+// end is empty.
+func paginate(stocks []stockviewer.Stock, filter stockviewer.StockFilter) []stockviewer.Stock {
+	if filter.PageSize < 1 {
+		return stocks
+	}
+	start := min((max(filter.Page, 1)-1)*filter.PageSize, len(stocks))
+	return stocks[start:min(start+filter.PageSize, len(stocks))]
 }
 
 func (m *MockStocksRepository) GetAll(ctx context.Context, filter stockviewer.StockFilter) ([]stockviewer.Stock, int64, error) {
+	m.LastFilter = filter
 	if m.Error != nil {
 		return nil, 0, m.Error
 	}
-	return m.Stocks, int64(len(m.Stocks)), nil
+	var stocks []stockviewer.Stock
+	for _, stock := range m.Stocks {
+		if matchesNotes(stock.Notes, filter.Notes) &&
+			(filter.Sector == "" || stock.Sector == filter.Sector) &&
+			(filter.Industry == "" || stock.Industry == filter.Industry) {
+			stocks = append(stocks, stock)
+		}
+	}
+	if filter.GroupBy == stockviewer.GroupByTicker {
+		stocks = latestPerTicker(stocks)
+	}
+	return paginate(stocks, filter), int64(len(stocks)), nil
+}
+
+// matchesNotes mirrors the storage notes filter.
+func matchesNotes(notes, filter string) bool {
+	switch filter {
+	case "":
+		return true
+	case stockviewer.NotesNone:
+		return notes == ""
+	case stockviewer.NotesAny:
+		return notes != ""
+	default:
+		return strings.Contains(strings.ToLower(notes), strings.ToLower(filter))
+	}
+}
+
+// latestPerTicker keeps the most recently updated stock of each ticker, in
+// order of first appearance.
+func latestPerTicker(stocks []stockviewer.Stock) []stockviewer.Stock {
+	index := make(map[string]int)
+	var latest []stockviewer.Stock
+	for _, stock := range stocks {
+		i, ok := index[stock.Ticker]
+		if !ok {
+			index[stock.Ticker] = len(latest)
+			latest = append(latest, stock)
+			continue
+		}
+		if stock.UpdatedAt.After(latest[i].UpdatedAt) {
+			latest[i] = stock
+		}
+	}
+	return latest
 }
 
 func (m *MockStocksRepository) GetTopRecommended(ctx context.Context, limit int) ([]stockviewer.Stock, error) {
@@ -113,7 +234,21 @@ func (m *MockStocksRepository) GetTopRecommended(ctx context.Context, limit int)
 	return m.Stocks[:limit], nil
 }
 
-func (m *MockStocksRepository) Search(ctx context.Context, query string, limit int) ([]stockviewer.Stock, error) {
+func (m *MockStocksRepository) GetTopRecommendedByBrokerage(ctx context.Context, brokerage string, limit int) ([]stockviewer.Stock, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	var result []stockviewer.Stock
+	for _, stock := range m.Stocks {
+		if stock.Brokerage == brokerage && len(result) < limit {
+			result = append(result, stock)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockStocksRepository) Search(ctx context.Context, query string, limit int, fuzzy bool) ([]stockviewer.Stock, error) {
+	m.LastSearchFuzzy = fuzzy
 	if m.Error != nil {
 		return nil, m.Error
 	}
@@ -133,36 +268,425 @@ func (m *MockStocksRepository) Delete(ctx context.Context, id string) error {
 	return stockviewer.ErrStockNotFound
 }
 
-func (m *MockStocksRepository) GetDistinctBrokerages(ctx context.Context) ([]string, error) {
+func (m *MockStocksRepository) GetDistinctBrokerages(ctx context.Context, query stockviewer.DistinctQuery) ([]stockviewer.ValueCount, error) {
+	return m.distinctValues(func(s stockviewer.Stock) string { return s.Brokerage }, query)
+}
+
+func (m *MockStocksRepository) GetDistinctRatings(ctx context.Context, query stockviewer.DistinctQuery) ([]stockviewer.ValueCount, error) {
+	return m.distinctValues(func(s stockviewer.Stock) string { return s.RatingTo }, query)
+}
+
+func (m *MockStocksRepository) GetDistinctRatingsFrom(ctx context.Context, query stockviewer.DistinctQuery) ([]stockviewer.ValueCount, error) {
+	return m.distinctValues(func(s stockviewer.Stock) string { return s.RatingFrom }, query)
+}
+
+func (m *MockStocksRepository) distinctValues(field func(stockviewer.Stock) string, query stockviewer.DistinctQuery) ([]stockviewer.ValueCount, error) {
 	if m.Error != nil {
 		return nil, m.Error
 	}
+	prefix := strings.ToLower(query.Prefix)
+	result := []stockviewer.ValueCount{}
+	for _, c := range countBy(m.Stocks, field) {
+		if strings.HasPrefix(strings.ToLower(c.value), prefix) {
+			result = append(result, stockviewer.ValueCount{Value: c.value, Count: c.count})
+		}
+	}
+	if query.Offset >= len(result) {
+		return []stockviewer.ValueCount{}, nil
+	}
+	result = result[query.Offset:]
+	if query.Limit > 0 && len(result) > query.Limit {
+		result = result[:query.Limit]
+	}
+	return result, nil
+}
+
+func (m *MockStocksRepository) CountByBrokerage(ctx context.Context, filter stockviewer.StockFilter) ([]stockviewer.ValueCount, error) {
+	return m.countMatching(filter, func(s stockviewer.Stock) string { return s.Brokerage })
+}
+
+func (m *MockStocksRepository) CountByRating(ctx context.Context, filter stockviewer.StockFilter) ([]stockviewer.ValueCount, error) {
+	return m.countMatching(filter, func(s stockviewer.Stock) string { return s.RatingTo })
+}
+
+func (m *MockStocksRepository) CountByAction(ctx context.Context, filter stockviewer.StockFilter) ([]stockviewer.ValueCount, error) {
+	return m.countMatching(filter, func(s stockviewer.Stock) string { return s.Action })
+}
+
+func (m *MockStocksRepository) countMatching(filter stockviewer.StockFilter, field func(stockviewer.Stock) string) ([]stockviewer.ValueCount, error) {
+	m.LastFilter = filter
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	var stocks []stockviewer.Stock
+	for _, stock := range m.Stocks {
+		if matchesFilter(stock, filter) {
+			stocks = append(stocks, stock)
+		}
+	}
+	if filter.GroupBy == stockviewer.GroupByTicker {
+		stocks = latestPerTicker(stocks)
+	}
+	result := []stockviewer.ValueCount{}
+	for _, c := range countBy(stocks, field) {
+		result = append(result, stockviewer.ValueCount{Value: c.value, Count: c.count})
+	}
+	return result, nil
+}
+
+// matchesFilter mirrors the storage equality filters used by the facet
+// counts.
+func matchesFilter(stock stockviewer.Stock, filter stockviewer.StockFilter) bool {
+	return matchesNotes(stock.Notes, filter.Notes) &&
+		(filter.Sector == "" || stock.Sector == filter.Sector) &&
+		(filter.Industry == "" || stock.Industry == filter.Industry) &&
+		(filter.Brokerage == "" || stock.Brokerage == filter.Brokerage) &&
+		(filter.Rating == "" || stock.RatingTo == filter.Rating) &&
+		(filter.RatingFrom == "" || stock.RatingFrom == filter.RatingFrom) &&
+		(filter.Action == "" || stock.Action == filter.Action) &&
+		(len(filter.Actions) == 0 || contains(filter.Actions, stock.Action)) &&
+		(!filter.RatedOnly || contains(stockviewer.RecognizedRatings(), stock.RatingTo))
+}
+
+func (m *MockStocksRepository) GetBrokerageStats(ctx context.Context) ([]stockviewer.BrokerageStats, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+
+	byBrokerage := make(map[string]*stockviewer.BrokerageStats)
+	var order []string
+	for _, stock := range m.Stocks {
+		if stock.Brokerage == "" {
+			continue
+		}
+		stats, ok := byBrokerage[stock.Brokerage]
+		if !ok {
+			stats = &stockviewer.BrokerageStats{Brokerage: stock.Brokerage}
+			byBrokerage[stock.Brokerage] = stats
+			order = append(order, stock.Brokerage)
+		}
+		stats.AvgScore += stock.RecommendScore
+		stats.TotalRecommendations++
+		switch {
+		case contains(stockviewer.BuyRatings, stock.RatingTo):
+			stats.BuyCount++
+		case contains(stockviewer.SellRatings, stock.RatingTo):
+			stats.SellCount++
+		case contains(stockviewer.HoldRatings, stock.RatingTo):
+			stats.HoldCount++
+		}
+	}
+
+	result := make([]stockviewer.BrokerageStats, 0, len(order))
+	for _, brokerage := range order {
+		stats := byBrokerage[brokerage]
+		stats.AvgScore /= float64(stats.TotalRecommendations)
+		result = append(result, *stats)
+	}
+	return result, nil
+}
+
+func (m *MockStocksRepository) GetRatingDistribution(ctx context.Context) ([]stockviewer.RatingDistribution, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	counts := countBy(m.Stocks, func(s stockviewer.Stock) string { return s.RatingTo })
+	result := make([]stockviewer.RatingDistribution, 0, len(counts))
+	for _, c := range counts {
+		result = append(result, stockviewer.RatingDistribution{Rating: c.value, Count: c.count})
+	}
+	return result, nil
+}
+
+func (m *MockStocksRepository) GetActionDistribution(ctx context.Context) ([]stockviewer.ActionDistribution, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	counts := countBy(m.Stocks, func(s stockviewer.Stock) string { return s.Action })
+	result := make([]stockviewer.ActionDistribution, 0, len(counts))
+	for _, c := range counts {
+		result = append(result, stockviewer.ActionDistribution{Action: c.value, Count: c.count})
+	}
+	return result, nil
+}
+
+func (m *MockStocksRepository) GetStockStats(ctx context.Context) (*stockviewer.StockStats, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+
+	stats := &stockviewer.StockStats{TotalStocks: int64(len(m.Stocks))}
+	tickers := make(map[string]bool)
 	brokerages := make(map[string]bool)
+	var totalScore float64
 	for _, stock := range m.Stocks {
+		tickers[stock.Ticker] = true
 		if stock.Brokerage != "" {
 			brokerages[stock.Brokerage] = true
 		}
+		totalScore += stock.RecommendScore
+		if !stock.UpdatedAt.IsZero() && (stats.LastUpdated == nil || stock.UpdatedAt.After(*stats.LastUpdated)) {
+			updated := stock.UpdatedAt
+			stats.LastUpdated = &updated
+		}
 	}
-	result := make([]string, 0, len(brokerages))
-	for b := range brokerages {
-		result = append(result, b)
+	stats.DistinctTickers = int64(len(tickers))
+	stats.DistinctBrokerages = int64(len(brokerages))
+	if len(m.Stocks) > 0 {
+		stats.AvgRecommendScore = math.Round(totalScore/float64(len(m.Stocks))*100) / 100
+	}
+	return stats, nil
+}
+
+func (m *MockStocksRepository) GetScoreDistribution(ctx context.Context) ([]stockviewer.ScoreBucket, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+
+	counts := make(map[int]int64)
+	for _, stock := range m.Stocks {
+		i := int(math.Floor(stock.RecommendScore / stockviewer.ScoreBucketWidth))
+		i = max(0, min(i, stockviewer.ScoreBucketCount-1))
+		counts[i]++
+	}
+
+	var result []stockviewer.ScoreBucket
+	for i := 0; i < stockviewer.ScoreBucketCount; i++ {
+		if counts[i] == 0 {
+			continue
+		}
+		bucket := stockviewer.NewScoreBucket(i)
+		bucket.Count = counts[i]
+		result = append(result, bucket)
 	}
 	return result, nil
 }
 
-func (m *MockStocksRepository) GetDistinctRatings(ctx context.Context) ([]string, error) {
+func (m *MockStocksRepository) GetTargetConsensus(ctx context.Context, ticker string, since time.Time) (*stockviewer.TargetConsensus, error) {
 	if m.Error != nil {
 		return nil, m.Error
 	}
-	ratings := make(map[string]bool)
+
+	var targets []float64
+	for _, stock := range m.Stocks {
+		if strings.EqualFold(stock.Ticker, ticker) && !stock.CreatedAt.Before(since) && stock.TargetTo != nil {
+			targets = append(targets, *stock.TargetTo)
+		}
+	}
+
+	consensus := stockviewer.ConsensusFromTargets(targets)
+	consensus.Ticker = strings.ToUpper(ticker)
+	return &consensus, nil
+}
+
+func (m *MockStocksRepository) GetTargetHistory(ctx context.Context, ticker string, since time.Time) ([]stockviewer.Stock, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+
+	var result []stockviewer.Stock
+	for _, stock := range m.Stocks {
+		if strings.EqualFold(stock.Ticker, ticker) && !stock.CreatedAt.Before(since) && stock.TargetTo != nil && *stock.TargetTo > 0 {
+			result = append(result, stock)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (m *MockStocksRepository) GetTargetConsensuses(ctx context.Context, tickers []string, since time.Time) ([]stockviewer.TargetConsensus, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+
+	var result []stockviewer.TargetConsensus
+	for _, ticker := range tickers {
+		consensus, _ := m.GetTargetConsensus(ctx, ticker, since)
+		if consensus.Count > 0 {
+			result = append(result, *consensus)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockStocksRepository) GetTargetHistories(ctx context.Context, tickers []string, since time.Time) ([]stockviewer.Stock, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+
+	var result []stockviewer.Stock
+	for _, ticker := range tickers {
+		history, _ := m.GetTargetHistory(ctx, ticker, since)
+		result = append(result, history...)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (m *MockStocksRepository) GetUpdatedSince(ctx context.Context, since time.Time, afterID string, limit int) ([]stockviewer.Stock, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+
+	var result []stockviewer.Stock
 	for _, stock := range m.Stocks {
-		if stock.RatingTo != "" {
-			ratings[stock.RatingTo] = true
+		if stock.UpdatedAt.After(since) || (afterID != "" && stock.UpdatedAt.Equal(since) && stock.ID > afterID) {
+			result = append(result, stock)
 		}
 	}
-	result := make([]string, 0, len(ratings))
-	for r := range ratings {
-		result = append(result, r)
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].UpdatedAt.Equal(result[j].UpdatedAt) {
+			return result[i].UpdatedAt.Before(result[j].UpdatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
 	}
 	return result, nil
 }
+
+type valueCount struct {
+	value string
+	count int64
+}
+
+// countBy groups stocks by a non-empty field and orders the groups by count
+// descending, then by value, mirroring the storage GROUP BY queries.
+func countBy(stocks []stockviewer.Stock, field func(stockviewer.Stock) string) []valueCount {
+	index := make(map[string]int)
+	var counts []valueCount
+	for _, stock := range stocks {
+		value := field(stock)
+		if value == "" {
+			continue
+		}
+		i, ok := index[value]
+		if !ok {
+			i = len(counts)
+			index[value] = i
+			counts = append(counts, valueCount{value: value})
+		}
+		counts[i].count++
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].value < counts[j].value
+	})
+	return counts
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MockStocksRepository) GetFirstSeenBetween(ctx context.Context, from, to time.Time) ([]stockviewer.NewTicker, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	first := make(map[string]stockviewer.NewTicker)
+	for _, stock := range m.Stocks {
+		seen, ok := first[stock.Ticker]
+		if !ok || stock.CreatedAt.Before(seen.FirstSeen) {
+			first[stock.Ticker] = stockviewer.NewTicker{Ticker: stock.Ticker, Company: stock.Company, FirstSeen: stock.CreatedAt}
+		}
+	}
+
+	var tickers []stockviewer.NewTicker
+	for _, ticker := range first {
+		if !ticker.FirstSeen.Before(from) && !ticker.FirstSeen.After(to) {
+			tickers = append(tickers, ticker)
+		}
+	}
+	sort.Slice(tickers, func(i, j int) bool {
+		if !tickers[i].FirstSeen.Equal(tickers[j].FirstSeen) {
+			return tickers[i].FirstSeen.Before(tickers[j].FirstSeen)
+		}
+		return tickers[i].Ticker < tickers[j].Ticker
+	})
+	return tickers, nil
+}
+
+func (m *MockStocksRepository) GetTickerCoverage(ctx context.Context, tickers []string) ([]stockviewer.TickerCoverage, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	all := m.coverage()
+	var coverage []stockviewer.TickerCoverage
+	for _, ticker := range tickers {
+		if c, ok := all[ticker]; ok {
+			coverage = append(coverage, c)
+		}
+	}
+	return coverage, nil
+}
+
+func (m *MockStocksRepository) GetColdStartStocks(ctx context.Context, minRecords int, since time.Time) ([]stockviewer.Stock, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	all := m.coverage()
+	var stocks []stockviewer.Stock
+	for _, stock := range m.Stocks {
+		c := all[stock.Ticker]
+		if (minRecords > 0 && c.Records < int64(minRecords)) || (!since.IsZero() && !c.FirstSeen.Before(since)) {
+			stocks = append(stocks, stock)
+		}
+	}
+	sort.SliceStable(stocks, func(i, j int) bool {
+		if stocks[i].Ticker != stocks[j].Ticker {
+			return stocks[i].Ticker < stocks[j].Ticker
+		}
+		return stocks[i].CreatedAt.Before(stocks[j].CreatedAt)
+	})
+	return stocks, nil
+}
+
+func (m *MockStocksRepository) GetQuarantinedStocks(ctx context.Context) ([]stockviewer.Stock, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	var stocks []stockviewer.Stock
+	for _, stock := range m.Stocks {
+		if len(stock.DataIssues) > 0 {
+			stocks = append(stocks, stock)
+		}
+	}
+	sort.SliceStable(stocks, func(i, j int) bool {
+		return stocks[i].UpdatedAt.After(stocks[j].UpdatedAt)
+	})
+	return stocks, nil
+}
+
+func (m *MockStocksRepository) coverage() map[string]stockviewer.TickerCoverage {
+	coverage := make(map[string]stockviewer.TickerCoverage)
+	brokerages := make(map[string]map[string]bool)
+	for _, stock := range m.Stocks {
+		if stock.Brokerage != "" {
+			if brokerages[stock.Ticker] == nil {
+				brokerages[stock.Ticker] = make(map[string]bool)
+			}
+			brokerages[stock.Ticker][stock.Brokerage] = true
+		}
+		c, ok := coverage[stock.Ticker]
+		if !ok || stock.CreatedAt.Before(c.FirstSeen) {
+			c.FirstSeen = stock.CreatedAt
+		}
+		c.Ticker = stock.Ticker
+		c.Records++
+		c.Brokerages = int64(len(brokerages[stock.Ticker]))
+		coverage[stock.Ticker] = c
+	}
+	return coverage
+}
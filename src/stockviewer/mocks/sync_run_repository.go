@@ -0,0 +1,116 @@
+package mocks
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+type MockSyncRunRepository struct {
+	mu         sync.Mutex
+	Runs       []stockviewer.SyncRun
+	Heartbeats int
+	Error      error
+}
+
+func NewMockSyncRunRepository(runs ...stockviewer.SyncRun) *MockSyncRunRepository {
+	return &MockSyncRunRepository{Runs: runs}
+}
+
+func (m *MockSyncRunRepository) Create(ctx context.Context, run *stockviewer.SyncRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return m.Error
+	}
+	run.ID = uint(len(m.Runs) + 1)
+	m.Runs = append(m.Runs, *run)
+	return nil
+}
+
+func (m *MockSyncRunRepository) Heartbeat(ctx context.Context, id uint, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return m.Error
+	}
+	for i := range m.Runs {
+		if m.Runs[i].ID == id {
+			m.Runs[i].HeartbeatAt = at
+			m.Heartbeats++
+		}
+	}
+	return nil
+}
+
+func (m *MockSyncRunRepository) Finish(ctx context.Context, run *stockviewer.SyncRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return m.Error
+	}
+	for i := range m.Runs {
+		if m.Runs[i].ID == run.ID {
+			m.Runs[i] = *run
+		}
+	}
+	return nil
+}
+
+func (m *MockSyncRunRepository) AbortStale(ctx context.Context, instanceID string, staleBefore time.Time, note string) ([]stockviewer.SyncRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	now := time.Now().UTC()
+	var aborted []stockviewer.SyncRun
+	for i := range m.Runs {
+		run := &m.Runs[i]
+		if run.Status == "in_progress" && (run.InstanceID == instanceID || run.HeartbeatAt.Before(staleBefore)) {
+			run.Status = stockviewer.SyncRunAborted
+			run.FinishedAt = &now
+			run.Note = note
+			aborted = append(aborted, *run)
+		}
+	}
+	return aborted, nil
+}
+
+func (m *MockSyncRunRepository) List(ctx context.Context, limit int) ([]stockviewer.SyncRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	runs := append([]stockviewer.SyncRun(nil), m.Runs...)
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func (m *MockSyncRunRepository) CountByStatus(ctx context.Context, since time.Time) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	counts := make(map[string]int)
+	for _, run := range m.Runs {
+		if run.Status != "in_progress" && !run.StartedAt.Before(since) {
+			counts[run.Status]++
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,77 @@
+package mocks
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+type MockViewRepository struct {
+	mu    sync.Mutex
+	Views map[string]stockviewer.SavedView
+	Error error
+}
+
+func NewMockViewRepository(views ...stockviewer.SavedView) *MockViewRepository {
+	m := &MockViewRepository{Views: make(map[string]stockviewer.SavedView)}
+	for _, view := range views {
+		m.Views[view.Slug] = view
+	}
+	return m
+}
+
+func (m *MockViewRepository) List(ctx context.Context) ([]stockviewer.SavedView, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	views := make([]stockviewer.SavedView, 0, len(m.Views))
+	for _, view := range m.Views {
+		views = append(views, view)
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].Slug < views[j].Slug })
+	return views, nil
+}
+
+func (m *MockViewRepository) Get(ctx context.Context, slug string) (*stockviewer.SavedView, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	view, ok := m.Views[slug]
+	if !ok {
+		return nil, stockviewer.ErrViewNotFound
+	}
+	return &view, nil
+}
+
+func (m *MockViewRepository) Save(ctx context.Context, view stockviewer.SavedView) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return m.Error
+	}
+	m.Views[view.Slug] = view
+	return nil
+}
+
+func (m *MockViewRepository) Delete(ctx context.Context, slug string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return m.Error
+	}
+	if _, ok := m.Views[slug]; !ok {
+		return stockviewer.ErrViewNotFound
+	}
+	delete(m.Views, slug)
+	return nil
+}
@@ -0,0 +1,62 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"gorm.io/gorm"
+)
+
+type Storage struct {
+	db *gorm.DB
+}
+
+// NewStorage expects the schema to be in place; see package schema.
+func NewStorage(db *gorm.DB) *Storage {
+	return &Storage{db: db}
+}
+
+func (s *Storage) GetPending(ctx context.Context, maxAttempts int, limit int) ([]stockviewer.OutboxEvent, error) {
+	var events []stockviewer.OutboxEvent
+	result := s.db.WithContext(ctx).
+		Where("processed_at IS NULL AND attempts < ?", maxAttempts).
+		Order("id ASC").
+		Limit(limit).
+		Find(&events)
+
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_pending_events", Err: result.Error}
+	}
+	return events, nil
+}
+
+func (s *Storage) MarkProcessed(ctx context.Context, id uint) error {
+	result := s.db.WithContext(ctx).
+		Model(&stockviewer.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"processed_at": time.Now().UTC(),
+			"attempts":     gorm.Expr("attempts + 1"),
+		})
+
+	if result.Error != nil {
+		return stockviewer.StorageError{Operation: "mark_event_processed", Err: result.Error}
+	}
+	return nil
+}
+
+func (s *Storage) MarkFailed(ctx context.Context, id uint, reason string) error {
+	result := s.db.WithContext(ctx).
+		Model(&stockviewer.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"last_error": reason,
+			"attempts":   gorm.Expr("attempts + 1"),
+		})
+
+	if result.Error != nil {
+		return stockviewer.StorageError{Operation: "mark_event_failed", Err: result.Error}
+	}
+	return nil
+}
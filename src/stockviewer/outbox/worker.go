@@ -0,0 +1,91 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+type Handler func(ctx context.Context, event stockviewer.OutboxEvent) error
+
+// Worker polls the outbox and dispatches pending events to the handler
+// registered for their type. Failed events are retried on later polls until
+// they reach maxAttempts.
+type Worker struct {
+	repo        stockviewer.OutboxRepository
+	handlers    map[string]Handler
+	interval    time.Duration
+	maxAttempts int
+	batchSize   int
+}
+
+func NewWorker(repo stockviewer.OutboxRepository, interval time.Duration, maxAttempts int) *Worker {
+	return &Worker{
+		repo:        repo,
+		handlers:    make(map[string]Handler),
+		interval:    interval,
+		maxAttempts: maxAttempts,
+		batchSize:   50,
+	}
+}
+
+func (w *Worker) Handle(eventType string, handler Handler) {
+	w.handlers[eventType] = handler
+}
+
+// Run processes pending events on every tick until ctx is done. Events left
+// over from a previous process are picked up on the first tick.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.ProcessPending(ctx); err != nil {
+			log.Printf("Outbox processing failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessPending dispatches one batch of pending events and returns how many
+// were handled successfully.
+func (w *Worker) ProcessPending(ctx context.Context) (int, error) {
+	events, err := w.repo.GetPending(ctx, w.maxAttempts, w.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, event := range events {
+		handler, ok := w.handlers[event.Type]
+		if !ok {
+			log.Printf("Outbox event %d has no handler for type %s, marking processed", event.ID, event.Type)
+			if err := w.repo.MarkProcessed(ctx, event.ID); err != nil {
+				return processed, err
+			}
+			continue
+		}
+
+		if err := handler(ctx, event); err != nil {
+			log.Printf("Outbox event %d (%s) failed on attempt %d: %v", event.ID, event.Type, event.Attempts+1, err)
+			if err := w.repo.MarkFailed(ctx, event.ID, err.Error()); err != nil {
+				return processed, err
+			}
+			continue
+		}
+
+		if err := w.repo.MarkProcessed(ctx, event.ID); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+
+	return processed, nil
+}
@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/mocks"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/stocks"
+)
+
+func TestSyncCompletedEvent_DispatchedAfterRestart(t *testing.T) {
+	stocksRepo := mocks.NewMockStocksRepository()
+	service := stocks.NewService(stocksRepo, mocks.NewMockStocksFetcher())
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Simulate the process dying right after the sync committed: no worker
+	// ran, so the only trace of the side effect is the stored event.
+	if len(stocksRepo.Events) != 1 {
+		t.Fatalf("expected 1 committed outbox event, got %d", len(stocksRepo.Events))
+	}
+
+	// On restart a fresh worker reads the same outbox.
+	outboxRepo := mocks.NewMockOutboxRepository(stocksRepo.Events...)
+	worker := NewWorker(outboxRepo, time.Second, 3)
+
+	var dispatched []stockviewer.SyncStatus
+	worker.Handle(stockviewer.EventSyncCompleted, func(ctx context.Context, event stockviewer.OutboxEvent) error {
+		var status stockviewer.SyncStatus
+		if err := json.Unmarshal([]byte(event.Payload), &status); err != nil {
+			return err
+		}
+		dispatched = append(dispatched, status)
+		return nil
+	})
+
+	processed, err := worker.ProcessPending(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if processed != 1 || len(dispatched) != 1 {
+		t.Fatalf("expected the pending event to be dispatched once, got %d", len(dispatched))
+	}
+	if dispatched[0].Status != "completed" || dispatched[0].TotalRecords != 3 {
+		t.Errorf("unexpected payload: %+v", dispatched[0])
+	}
+	if outboxRepo.Events[0].ProcessedAt == nil {
+		t.Error("expected event to be marked processed")
+	}
+
+	processed, err = worker.ProcessPending(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if processed != 0 {
+		t.Errorf("expected processed event not to be dispatched again, got %d", processed)
+	}
+}
+
+func TestProcessPending_RetriesFailedEvents(t *testing.T) {
+	outboxRepo := mocks.NewMockOutboxRepository(stockviewer.OutboxEvent{ID: 1, Type: stockviewer.EventSyncCompleted})
+	worker := NewWorker(outboxRepo, time.Second, 3)
+
+	calls := 0
+	worker.Handle(stockviewer.EventSyncCompleted, func(ctx context.Context, event stockviewer.OutboxEvent) error {
+		calls++
+		if calls == 1 {
+			return errors.New("webhook unavailable")
+		}
+		return nil
+	})
+
+	if processed, _ := worker.ProcessPending(context.Background()); processed != 0 {
+		t.Fatalf("expected first attempt to fail, got %d processed", processed)
+	}
+	if outboxRepo.Events[0].LastError != "webhook unavailable" {
+		t.Errorf("expected failure to be recorded, got %q", outboxRepo.Events[0].LastError)
+	}
+
+	if processed, _ := worker.ProcessPending(context.Background()); processed != 1 {
+		t.Fatalf("expected retry to succeed, got %d processed", processed)
+	}
+	if outboxRepo.Events[0].Attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", outboxRepo.Events[0].Attempts)
+	}
+}
+
+func TestProcessPending_StopsAfterMaxAttempts(t *testing.T) {
+	outboxRepo := mocks.NewMockOutboxRepository(stockviewer.OutboxEvent{ID: 1, Type: stockviewer.EventSyncCompleted})
+	worker := NewWorker(outboxRepo, time.Second, 2)
+
+	calls := 0
+	worker.Handle(stockviewer.EventSyncCompleted, func(ctx context.Context, event stockviewer.OutboxEvent) error {
+		calls++
+		return errors.New("still failing")
+	})
+
+	for i := 0; i < 4; i++ {
+		worker.ProcessPending(context.Background())
+	}
+
+	if calls != 2 {
+		t.Errorf("expected handler to be called 2 times, got %d", calls)
+	}
+}
@@ -0,0 +1,137 @@
+package prefetch
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+// Store keeps short-lived snapshots of list responses and issues signed
+// tokens that let the detail endpoint serve a row straight from the snapshot
+// the list was rendered from.
+type Store struct {
+	secret    []byte
+	ttl       time.Duration
+	now       func() time.Time
+	mu        sync.RWMutex
+	snapshots map[string]snapshot
+}
+
+type snapshot struct {
+	stocks    map[string]stockviewer.Stock
+	expiresAt time.Time
+}
+
+func NewStore(secret string, ttl time.Duration) *Store {
+	return &Store{
+		secret:    []byte(secret),
+		ttl:       ttl,
+		now:       time.Now,
+		snapshots: make(map[string]snapshot),
+	}
+}
+
+// Snapshot caches the given stocks and returns the ETag identifying them.
+func (s *Store) Snapshot(stocks []stockviewer.Stock) string {
+	etag := ETag(stocks)
+	now := s.now()
+
+	byID := make(map[string]stockviewer.Stock, len(stocks))
+	for _, stock := range stocks {
+		byID[stock.ID] = stock
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, snap := range s.snapshots {
+		if !now.Before(snap.expiresAt) {
+			delete(s.snapshots, key)
+		}
+	}
+	s.snapshots[etag] = snapshot{stocks: byID, expiresAt: now.Add(s.ttl)}
+
+	return etag
+}
+
+// Token returns a signed prefetch token for a stock in the snapshot etag.
+func (s *Store) Token(stockID, etag string) string {
+	expiresAt := s.now().Add(s.ttl).Unix()
+	payload := fmt.Sprintf("%s|%s|%d", stockID, etag, expiresAt)
+	return encode([]byte(payload)) + "." + encode(s.sign(payload))
+}
+
+// Lookup returns the cached stock for a valid, unexpired token issued for
+// stockID whose snapshot is still held. Any other case reports false so the
+// caller falls back to the regular lookup.
+func (s *Store) Lookup(token, stockID string) (*stockviewer.Stock, bool) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+
+	payload, err := decode(parts[0])
+	if err != nil {
+		return nil, false
+	}
+	signature, err := decode(parts[1])
+	if err != nil || !hmac.Equal(signature, s.sign(string(payload))) {
+		return nil, false
+	}
+
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 3 || fields[0] != stockID {
+		return nil, false
+	}
+
+	expiresAt, err := strconv.ParseInt(fields[2], 10, 64)
+	now := s.now()
+	if err != nil || now.Unix() > expiresAt {
+		return nil, false
+	}
+
+	s.mu.RLock()
+	snap, ok := s.snapshots[fields[1]]
+	s.mu.RUnlock()
+	if !ok || !now.Before(snap.expiresAt) {
+		return nil, false
+	}
+
+	stock, ok := snap.stocks[stockID]
+	if !ok {
+		return nil, false
+	}
+	return &stock, true
+}
+
+// ETag derives a stable identifier for a list of stocks from their IDs and
+// last update times.
+func ETag(stocks []stockviewer.Stock) string {
+	h := sha256.New()
+	for _, stock := range stocks {
+		fmt.Fprintf(h, "%s|%d|%.2f;", stock.ID, stock.UpdatedAt.UnixNano(), stock.RecommendScore)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func (s *Store) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
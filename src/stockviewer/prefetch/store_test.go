@@ -0,0 +1,106 @@
+package prefetch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+func newTestStore(now *time.Time) *Store {
+	store := NewStore("test-secret", time.Minute)
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+var testStocks = []stockviewer.Stock{
+	{ID: "id-1", Ticker: "AAPL"},
+	{ID: "id-2", Ticker: "MSFT"},
+}
+
+func TestLookup_ValidToken(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := newTestStore(&now)
+
+	etag := store.Snapshot(testStocks)
+	token := store.Token("id-2", etag)
+
+	stock, ok := store.Lookup(token, "id-2")
+	if !ok {
+		t.Fatal("expected token to resolve from snapshot")
+	}
+	if stock.Ticker != "MSFT" {
+		t.Errorf("expected MSFT, got %s", stock.Ticker)
+	}
+}
+
+func TestLookup_ExpiredToken(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := newTestStore(&now)
+
+	etag := store.Snapshot(testStocks)
+	token := store.Token("id-1", etag)
+
+	now = now.Add(2 * time.Minute)
+
+	if _, ok := store.Lookup(token, "id-1"); ok {
+		t.Error("expected expired token to fall back")
+	}
+}
+
+func TestLookup_MismatchedETag(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := newTestStore(&now)
+
+	store.Snapshot(testStocks)
+	token := store.Token("id-1", "stale-etag")
+
+	if _, ok := store.Lookup(token, "id-1"); ok {
+		t.Error("expected token for unknown snapshot to fall back")
+	}
+}
+
+func TestLookup_MismatchedStockID(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := newTestStore(&now)
+
+	etag := store.Snapshot(testStocks)
+	token := store.Token("id-1", etag)
+
+	if _, ok := store.Lookup(token, "id-2"); ok {
+		t.Error("expected token issued for another stock to fall back")
+	}
+}
+
+func TestLookup_TamperedToken(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := newTestStore(&now)
+
+	etag := store.Snapshot(testStocks)
+	token := store.Token("id-1", etag)
+
+	other := NewStore("other-secret", time.Minute)
+	other.now = store.now
+	forged := other.Token("id-1", etag)
+
+	if _, ok := store.Lookup(forged, "id-1"); ok {
+		t.Error("expected token signed with another secret to be rejected")
+	}
+	if _, ok := store.Lookup(token+"x", "id-1"); ok {
+		t.Error("expected malformed token to be rejected")
+	}
+}
+
+func TestETag_ChangesWithData(t *testing.T) {
+	changed := []stockviewer.Stock{
+		{ID: "id-1", Ticker: "AAPL", UpdatedAt: time.Now()},
+		{ID: "id-2", Ticker: "MSFT"},
+	}
+
+	if ETag(testStocks) == ETag(changed) {
+		t.Error("expected ETag to change when a row is updated")
+	}
+	if ETag(testStocks) != ETag(testStocks) {
+		t.Error("expected ETag to be deterministic")
+	}
+}
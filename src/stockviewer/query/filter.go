@@ -0,0 +1,227 @@
+// Package query parses and validates stock list parameters independently of
+// the transport they arrive on, so every API surface filters, defaults and
+// rejects input the same way.
+package query
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+const (
+	DefaultPage      = 1
+	DefaultSortBy    = "recommend_score"
+	DefaultSortOrder = "DESC"
+)
+
+// SortFields lists the columns stocks may be sorted by.
+var SortFields = map[string]bool{
+	"ticker":          true,
+	"company":         true,
+	"brokerage":       true,
+	"recommend_score": true,
+	"created_at":      true,
+	"updated_at":      true,
+}
+
+// SortFieldNames returns the allowed sort fields in alphabetical order.
+func SortFieldNames() []string {
+	names := make([]string, 0, len(SortFields))
+	for name := range SortFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NormalizeSort splits comma-separated sort fields and directions, checks
+// them against SortFields and pads missing directions with DefaultSortOrder.
+// An empty sortBy means DefaultSortBy.
+func NormalizeSort(sortBy, sortOrder string) (fields, orders []string, errs []stockviewer.ValidationError) {
+	fields = splitList(strings.ToLower(sortBy))
+	if len(fields) == 0 {
+		fields = []string{DefaultSortBy}
+	}
+	for _, field := range fields {
+		if !SortFields[field] {
+			errs = append(errs, stockviewer.ValidationError{
+				Field:   "sort_by",
+				Message: "must be a comma-separated list of " + strings.Join(SortFieldNames(), ", "),
+			})
+			break
+		}
+	}
+
+	orders = splitList(strings.ToUpper(sortOrder))
+	if len(orders) > len(fields) {
+		errs = append(errs, stockviewer.ValidationError{Field: "sort_order", Message: "must not list more directions than sort_by fields"})
+	}
+	for _, order := range orders {
+		if order != "ASC" && order != "DESC" {
+			errs = append(errs, stockviewer.ValidationError{Field: "sort_order", Message: "must be one of ASC, DESC"})
+			break
+		}
+	}
+	// Fields without an explicit direction use the default one.
+	for len(orders) < len(fields) {
+		orders = append(orders, DefaultSortOrder)
+	}
+
+	return fields, orders, errs
+}
+
+// ParseStockFilter builds a StockFilter from raw key/value parameters such as
+// URL query values. Unknown keys are ignored; callers that want to reject
+// them check UnknownKeys first. The returned filter is normalized against
+// limits; every invalid parameter is reported, not just the first one.
+func ParseStockFilter(values map[string][]string, limits stockviewer.PageLimits) (stockviewer.StockFilter, []stockviewer.ValidationError) {
+	var errs []stockviewer.ValidationError
+	first := func(key string) string {
+		if v := values[key]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	parseInt := func(key string) int {
+		raw := strings.TrimSpace(first(key))
+		if raw == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			errs = append(errs, stockviewer.ValidationError{Field: key, Message: "must be an integer"})
+		}
+		return n
+	}
+
+	filter := stockviewer.StockFilter{
+		Ticker:         first("ticker"),
+		Company:        first("company"),
+		Notes:          first("notes"),
+		Sector:         first("sector"),
+		Industry:       first("industry"),
+		Brokerage:      first("brokerage"),
+		Rating:         first("rating"),
+		RatingFrom:     first("rating_from"),
+		Action:         first("action"),
+		ActionCategory: first("action_category"),
+		Actions:        values["actions"],
+		GroupBy:        first("group_by"),
+		SortBy:         first("sort_by"),
+		SortOrder:      first("sort_order"),
+		Page:           parseInt("page"),
+		PageSize:       parseInt("page_size"),
+	}
+
+	parseBool := func(key string) bool {
+		raw := strings.TrimSpace(first(key))
+		if raw == "" {
+			return false
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			errs = append(errs, stockviewer.ValidationError{Field: key, Message: "must be a boolean"})
+		}
+		return b
+	}
+	filter.TickerExact = parseBool("ticker_exact")
+	filter.IncludeUnrated = parseBool("include_unrated")
+
+	// From here on a zero page size means "not given"; an explicit
+	// page_size=0 is out of range like any other value below 1.
+	if raw := strings.TrimSpace(first("page_size")); raw != "" && filter.PageSize == 0 {
+		if _, err := strconv.Atoi(raw); err == nil {
+			filter.PageSize = -1
+		}
+	}
+
+	filter, normalizeErrs := NormalizeStockFilter(filter, limits)
+	return filter, append(errs, normalizeErrs...)
+}
+
+// splitList splits a comma-separated value into trimmed entries. Empty
+// entries are kept so they fail validation instead of being ignored.
+func splitList(value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	parts := strings.Split(value, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+// NormalizeStockFilter trims free-text fields, fills in defaults and checks
+// enumerated fields and the page size against limits. It is applied to
+// filters from every transport, including those decoded from a request body.
+func NormalizeStockFilter(filter stockviewer.StockFilter, limits stockviewer.PageLimits) (stockviewer.StockFilter, []stockviewer.ValidationError) {
+	limits = limits.WithDefaults()
+
+	var errs []stockviewer.ValidationError
+
+	filter.Ticker = strings.TrimSpace(filter.Ticker)
+	filter.Company = strings.TrimSpace(filter.Company)
+	filter.Notes = strings.TrimSpace(filter.Notes)
+	filter.Sector = strings.TrimSpace(filter.Sector)
+	filter.Industry = strings.TrimSpace(filter.Industry)
+	filter.Brokerage = strings.TrimSpace(filter.Brokerage)
+	filter.Rating = strings.TrimSpace(filter.Rating)
+	filter.RatingFrom = strings.TrimSpace(filter.RatingFrom)
+	filter.Action = strings.TrimSpace(filter.Action)
+	filter.ActionCategory = strings.ToLower(strings.TrimSpace(filter.ActionCategory))
+
+	if len(filter.Actions) > 0 {
+		actions := make([]string, 0, len(filter.Actions))
+		for _, action := range filter.Actions {
+			action = strings.TrimSpace(action)
+			if action == "" {
+				errs = append(errs, stockviewer.ValidationError{Field: "actions", Message: "must not contain empty values"})
+				break
+			}
+			actions = append(actions, action)
+		}
+		filter.Actions = actions
+	}
+
+	if filter.ActionCategory != "" {
+		if _, ok := stockviewer.ActionCategories[stockviewer.ActionCategory(filter.ActionCategory)]; !ok {
+			errs = append(errs, stockviewer.ValidationError{
+				Field:   "action_category",
+				Message: "must be one of positive, negative, neutral",
+			})
+		}
+	}
+
+	filter.GroupBy = strings.ToLower(strings.TrimSpace(filter.GroupBy))
+	if filter.GroupBy != "" && filter.GroupBy != stockviewer.GroupByTicker {
+		errs = append(errs, stockviewer.ValidationError{Field: "group_by", Message: "must be ticker"})
+	}
+
+	fields, orders, sortErrs := NormalizeSort(filter.SortBy, filter.SortOrder)
+	errs = append(errs, sortErrs...)
+
+	filter.SortBy = strings.Join(fields, ",")
+	filter.SortOrder = strings.Join(orders, ",")
+
+	switch {
+	case filter.Page == 0:
+		filter.Page = DefaultPage
+	case filter.Page < 0:
+		errs = append(errs, stockviewer.ValidationError{Field: "page", Message: "must not be negative"})
+	}
+
+	var pageSizeErr stockviewer.ValidationError
+	if err := limits.CheckPageSize(filter.PageSize); errors.As(err, &pageSizeErr) {
+		errs = append(errs, pageSizeErr)
+	} else {
+		filter.PageSize = limits.PageSize(filter.PageSize)
+	}
+
+	return filter, errs
+}
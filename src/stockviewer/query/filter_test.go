@@ -0,0 +1,255 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+// transportCases describe the same request as URL parameters and as an
+// already-decoded filter (the shape a JSON body or proto message arrives in);
+// both must produce the same filter and reject the same fields.
+var transportCases = []struct {
+	name    string
+	values  map[string][]string
+	decoded stockviewer.StockFilter
+	want    stockviewer.StockFilter
+	fields  []string
+}{
+	{
+		name:    "defaults",
+		values:  map[string][]string{},
+		decoded: stockviewer.StockFilter{},
+		want:    stockviewer.StockFilter{SortBy: DefaultSortBy, SortOrder: DefaultSortOrder, Page: DefaultPage, PageSize: 20},
+	},
+	{
+		name: "normalizes text and enums",
+		values: map[string][]string{
+			"ticker":          {" aapl "},
+			"action_category": {"Positive"},
+			"sort_by":         {"Ticker"},
+			"sort_order":      {"asc"},
+			"actions":         {" upgraded by", "target raised by "},
+		},
+		decoded: stockviewer.StockFilter{
+			Ticker:         " aapl ",
+			ActionCategory: "Positive",
+			SortBy:         "Ticker",
+			SortOrder:      "asc",
+			Actions:        []string{" upgraded by", "target raised by "},
+		},
+		want: stockviewer.StockFilter{
+			Ticker:         "aapl",
+			ActionCategory: "positive",
+			Actions:        []string{"upgraded by", "target raised by"},
+			SortBy:         "ticker",
+			SortOrder:      "ASC",
+			Page:           DefaultPage,
+			PageSize:       20,
+		},
+	},
+	{
+		name:    "trims notes search",
+		values:  map[string][]string{"notes": {" earnings "}},
+		decoded: stockviewer.StockFilter{Notes: " earnings "},
+		want:    stockviewer.StockFilter{Notes: "earnings", SortBy: DefaultSortBy, SortOrder: DefaultSortOrder, Page: DefaultPage, PageSize: 20},
+	},
+	{
+		name:    "keeps explicit pagination",
+		values:  map[string][]string{"page": {"3"}, "page_size": {"100"}},
+		decoded: stockviewer.StockFilter{Page: 3, PageSize: 100},
+		want:    stockviewer.StockFilter{SortBy: DefaultSortBy, SortOrder: DefaultSortOrder, Page: 3, PageSize: 100},
+	},
+	{
+		name:    "rejects negative page",
+		values:  map[string][]string{"page": {"-1"}},
+		decoded: stockviewer.StockFilter{Page: -1},
+		fields:  []string{"page"},
+	},
+	{
+		name:    "rejects page size above max",
+		values:  map[string][]string{"page_size": {"500"}},
+		decoded: stockviewer.StockFilter{PageSize: 500},
+		fields:  []string{"page_size"},
+	},
+	{
+		name:    "rejects negative page size",
+		values:  map[string][]string{"page_size": {"-5"}},
+		decoded: stockviewer.StockFilter{PageSize: -5},
+		fields:  []string{"page_size"},
+	},
+	{
+		name:    "rejects unknown sort field",
+		values:  map[string][]string{"sort_by": {"password"}},
+		decoded: stockviewer.StockFilter{SortBy: "password"},
+		fields:  []string{"sort_by"},
+	},
+	{
+		name:    "multiple sort fields",
+		values:  map[string][]string{"sort_by": {"Ticker, recommend_score"}, "sort_order": {"asc"}},
+		decoded: stockviewer.StockFilter{SortBy: "Ticker, recommend_score", SortOrder: "asc"},
+		want:    stockviewer.StockFilter{SortBy: "ticker,recommend_score", SortOrder: "ASC,DESC", Page: DefaultPage, PageSize: 20},
+	},
+	{
+		name:    "rejects unknown field in sort list",
+		values:  map[string][]string{"sort_by": {"ticker,password"}},
+		decoded: stockviewer.StockFilter{SortBy: "ticker,password"},
+		fields:  []string{"sort_by"},
+	},
+	{
+		name:    "rejects more directions than fields",
+		values:  map[string][]string{"sort_by": {"ticker"}, "sort_order": {"ASC,DESC"}},
+		decoded: stockviewer.StockFilter{SortBy: "ticker", SortOrder: "ASC,DESC"},
+		fields:  []string{"sort_order"},
+	},
+	{
+		name:    "rejects unknown sort order",
+		values:  map[string][]string{"sort_order": {"sideways"}},
+		decoded: stockviewer.StockFilter{SortOrder: "sideways"},
+		fields:  []string{"sort_order"},
+	},
+	{
+		name:    "rejects unknown action category",
+		values:  map[string][]string{"action_category": {"bullish"}},
+		decoded: stockviewer.StockFilter{ActionCategory: "bullish"},
+		fields:  []string{"action_category"},
+	},
+	{
+		name:    "accepts ticker grouping",
+		values:  map[string][]string{"group_by": {"Ticker"}},
+		decoded: stockviewer.StockFilter{GroupBy: "Ticker"},
+		want:    stockviewer.StockFilter{GroupBy: stockviewer.GroupByTicker, SortBy: DefaultSortBy, SortOrder: DefaultSortOrder, Page: DefaultPage, PageSize: 20},
+	},
+	{
+		name:    "rejects unknown grouping",
+		values:  map[string][]string{"group_by": {"brokerage"}},
+		decoded: stockviewer.StockFilter{GroupBy: "brokerage"},
+		fields:  []string{"group_by"},
+	},
+	{
+		name:    "rejects empty action",
+		values:  map[string][]string{"actions": {"upgraded by", " "}},
+		decoded: stockviewer.StockFilter{Actions: []string{"upgraded by", " "}},
+		fields:  []string{"actions"},
+	},
+	{
+		name:    "reports every invalid field",
+		values:  map[string][]string{"page": {"-2"}, "sort_order": {"up"}, "page_size": {"-1"}},
+		decoded: stockviewer.StockFilter{Page: -2, SortOrder: "up", PageSize: -1},
+		fields:  []string{"sort_order", "page", "page_size"},
+	},
+}
+
+func errorFields(errs []stockviewer.ValidationError) []string {
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	return fields
+}
+
+func TestParseStockFilter(t *testing.T) {
+	for _, tt := range transportCases {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, errs := ParseStockFilter(tt.values, stockviewer.DefaultPageLimits())
+
+			if got := errorFields(errs); !reflect.DeepEqual(got, tt.fields) {
+				t.Fatalf("expected errors on %v, got %v", tt.fields, got)
+			}
+			if len(tt.fields) == 0 && !reflect.DeepEqual(filter, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, filter)
+			}
+		})
+	}
+}
+
+func TestNormalizeStockFilter(t *testing.T) {
+	for _, tt := range transportCases {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, errs := NormalizeStockFilter(tt.decoded, stockviewer.DefaultPageLimits())
+
+			if got := errorFields(errs); !reflect.DeepEqual(got, tt.fields) {
+				t.Fatalf("expected errors on %v, got %v", tt.fields, got)
+			}
+			if len(tt.fields) == 0 && !reflect.DeepEqual(filter, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, filter)
+			}
+		})
+	}
+}
+
+func TestParseStockFilter_TypeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string][]string
+		field  string
+	}{
+		{"non-integer page", map[string][]string{"page": {"two"}}, "page"},
+		{"non-integer page size", map[string][]string{"page_size": {"1.5"}}, "page_size"},
+		{"non-boolean ticker_exact", map[string][]string{"ticker_exact": {"maybe"}}, "ticker_exact"},
+		{"non-boolean include_unrated", map[string][]string{"include_unrated": {"all"}}, "include_unrated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := ParseStockFilter(tt.values, stockviewer.DefaultPageLimits())
+			if got := errorFields(errs); !reflect.DeepEqual(got, []string{tt.field}) {
+				t.Errorf("expected error on %s, got %v", tt.field, got)
+			}
+		})
+	}
+}
+
+func TestParseStockFilter_Booleans(t *testing.T) {
+	filter, errs := ParseStockFilter(map[string][]string{
+		"ticker":          {"AAPL"},
+		"ticker_exact":    {"true"},
+		"include_unrated": {"1"},
+	}, stockviewer.DefaultPageLimits())
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if !filter.TickerExact {
+		t.Error("expected ticker_exact to be parsed")
+	}
+	if !filter.IncludeUnrated {
+		t.Error("expected include_unrated to be parsed")
+	}
+}
+
+func TestNormalizeStockFilter_ConfiguredLimits(t *testing.T) {
+	limits := stockviewer.PageLimits{DefaultPageSize: 10, MaxPageSize: 50}
+
+	filter, errs := NormalizeStockFilter(stockviewer.StockFilter{PageSize: 50}, limits)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if filter.PageSize != 50 {
+		t.Errorf("expected page size 50, got %d", filter.PageSize)
+	}
+
+	_, errs = NormalizeStockFilter(stockviewer.StockFilter{PageSize: 51}, limits)
+	if len(errs) != 1 || errs[0].Message != "must be between 1 and 50" {
+		t.Errorf("expected the allowed range in the error, got %v", errs)
+	}
+
+	filter, _ = NormalizeStockFilter(stockviewer.StockFilter{}, limits)
+	if filter.PageSize != 10 {
+		t.Errorf("expected default page size 10, got %d", filter.PageSize)
+	}
+}
+
+func TestParseStockFilter_ExplicitZeroPageSize(t *testing.T) {
+	limits := stockviewer.PageLimits{DefaultPageSize: 10, MaxPageSize: 50}
+
+	_, errs := ParseStockFilter(map[string][]string{"page_size": {"0"}}, limits)
+	if len(errs) != 1 || errs[0].Field != "page_size" {
+		t.Errorf("expected page_size=0 to be rejected, got %v", errs)
+	}
+
+	_, errs = ParseStockFilter(map[string][]string{"page_size": {"ten"}}, limits)
+	if len(errs) != 1 || errs[0].Message != "must be an integer" {
+		t.Errorf("expected a single parse error, got %v", errs)
+	}
+}
@@ -0,0 +1,43 @@
+package query
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FormKeys returns the parameter names declared by the form tags of the
+// struct params, or of the struct it points to. Untagged fields and fields
+// tagged "-" declare nothing.
+func FormKeys(params any) []string {
+	t := reflect.TypeOf(params)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("form"), ",")
+		if name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// UnknownKeys returns, sorted, the keys of values that are not in allowed.
+func UnknownKeys(values map[string][]string, allowed []string) []string {
+	known := make(map[string]bool, len(allowed))
+	for _, key := range allowed {
+		known[key] = true
+	}
+
+	var unknown []string
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,53 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+func TestFormKeys(t *testing.T) {
+	type params struct {
+		Q        string `form:"q"`
+		Limit    int    `form:"limit,default=10"`
+		Internal bool   `form:"-"`
+		Untagged string
+	}
+
+	for _, p := range []any{params{}, &params{}} {
+		if got, want := FormKeys(p), []string{"q", "limit"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("FormKeys(%T): expected %v, got %v", p, want, got)
+		}
+	}
+
+	keys := FormKeys(stockviewer.StockFilter{})
+	for _, key := range []string{"ticker", "actions", "page_size"} {
+		if !contains(keys, key) {
+			t.Errorf("expected StockFilter to declare %s, got %v", key, keys)
+		}
+	}
+	if contains(keys, "RatedOnly") || contains(keys, "rated_only") {
+		t.Errorf("expected internal filter fields to be left out, got %v", keys)
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	values := map[string][]string{"tickers": {"AAPL"}, "ticker": {"MSFT"}, "Page": {"2"}}
+
+	if got, want := UnknownKeys(values, []string{"ticker", "page"}), []string{"Page", "tickers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := UnknownKeys(map[string][]string{"ticker": nil}, []string{"ticker"}); got != nil {
+		t.Errorf("expected no unknown keys, got %v", got)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package stockviewer
+
+import "time"
+
+// MinRecencyFactor is the floor applied to scores of old entries.
+const MinRecencyFactor = 0.5
+
+// RecencyFactor weights a score by how long ago the entry was first seen:
+// 1 - age/decayDays, clamped to [MinRecencyFactor, 1]. Callers pass
+// created_at, since every sync rewrites updated_at. Entries without a
+// creation time are treated as fresh.
+func RecencyFactor(firstSeen, now time.Time, decayDays float64) float64 {
+	if firstSeen.IsZero() || decayDays <= 0 {
+		return 1
+	}
+
+	days := now.Sub(firstSeen).Hours() / 24
+	factor := 1 - days/decayDays
+	if factor > 1 {
+		return 1
+	}
+	if factor < MinRecencyFactor {
+		return MinRecencyFactor
+	}
+	return factor
+}
@@ -0,0 +1,138 @@
+package recommendation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+// PriceTargetBaseline is what the price-target sub-score compares an entry's
+// target_to against.
+type PriceTargetBaseline string
+
+const (
+	// BaselineTargetFrom compares against the entry's own target_from, the
+	// target the brokerage moved away from.
+	BaselineTargetFrom PriceTargetBaseline = "target_from"
+	// BaselinePriorTarget compares against the target_to of the ticker's
+	// previous entry, whichever brokerage published it, so a revision is
+	// scored against the consensus it actually moved. Entries without an
+	// earlier target fall back to target_from.
+	BaselinePriorTarget PriceTargetBaseline = "prior_target"
+)
+
+// ParsePriceTargetBaseline validates a baseline name. An empty name is
+// BaselineTargetFrom.
+func ParsePriceTargetBaseline(value string) (PriceTargetBaseline, error) {
+	switch baseline := PriceTargetBaseline(value); baseline {
+	case "":
+		return BaselineTargetFrom, nil
+	case BaselineTargetFrom, BaselinePriorTarget:
+		return baseline, nil
+	}
+	return "", stockviewer.ValidationError{
+		Field:   "price_target_baseline",
+		Message: fmt.Sprintf("must be %s or %s, got %q", BaselineTargetFrom, BaselinePriorTarget, value),
+	}
+}
+
+// priorTargetLookback is how many of a ticker's newest entries are read to
+// find prior targets. Older candidates fall back to target_from.
+const priorTargetLookback = 50
+
+// WithPriceTargetBaseline sets what the price-target sub-score compares
+// target_to against. BaselinePriorTarget reads each candidate ticker's
+// recent history on every ranking.
+func WithPriceTargetBaseline(baseline PriceTargetBaseline) Option {
+	return func(s *Service) {
+		if baseline != "" {
+			s.baseline = baseline
+		}
+	}
+}
+
+// priorTargets returns the baseline target of every stock that has one
+// under BaselinePriorTarget, keyed by stock ID. It is empty under
+// BaselineTargetFrom.
+func (s *Service) priorTargets(ctx context.Context, stocks []stockviewer.Stock) (map[string]float64, error) {
+	if s.baseline != BaselinePriorTarget || len(stocks) == 0 {
+		return nil, nil
+	}
+
+	priors := make(map[string]float64)
+	seen := make(map[string]bool)
+	for _, stock := range stocks {
+		if seen[stock.Ticker] {
+			continue
+		}
+		seen[stock.Ticker] = true
+
+		history, _, err := s.stocksRepo.GetTickerHistory(ctx, stock.Ticker, stockviewer.StockFilter{
+			SortBy:    "created_at",
+			SortOrder: "DESC",
+			Page:      1,
+			PageSize:  priorTargetLookback,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for id, prior := range historyPriorTargets(append(history, stock)) {
+			priors[id] = prior
+		}
+	}
+	return priors, nil
+}
+
+// historyPriorTargets maps each entry to the target_to of the latest entry
+// of the same ticker created before it that has one. Entries are keyed by
+// ID, so stocks may repeat an entry and be in any order.
+func historyPriorTargets(stocks []stockviewer.Stock) map[string]float64 {
+	entries := make([]stockviewer.Stock, len(stocks))
+	copy(entries, stocks)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Ticker != entries[j].Ticker {
+			return entries[i].Ticker < entries[j].Ticker
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+
+	priors := make(map[string]float64)
+	var prior float64
+	for start := 0; start < len(entries); {
+		if start == 0 || entries[start].Ticker != entries[start-1].Ticker {
+			prior = 0
+		}
+		// Entries of a ticker created at the same instant are not ordered,
+		// so none of them is another's prior.
+		end := start + 1
+		for end < len(entries) && entries[end].Ticker == entries[start].Ticker &&
+			entries[end].CreatedAt.Equal(entries[start].CreatedAt) {
+			end++
+		}
+		group := entries[start:end]
+		for _, entry := range group {
+			if prior > 0 {
+				priors[entry.ID] = prior
+			}
+		}
+		for _, entry := range group {
+			if _, to := entry.Targets(); to > 0 {
+				prior = to
+			}
+		}
+		start = end
+	}
+	return priors
+}
+
+// baselineTarget is what stock's target_to is compared against: its prior
+// target when priors has one, otherwise its own target_from.
+func baselineTarget(stock stockviewer.Stock, priors map[string]float64) float64 {
+	if prior, ok := priors[stock.ID]; ok {
+		return prior
+	}
+	from, _ := stock.Targets()
+	return from
+}
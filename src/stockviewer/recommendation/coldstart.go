@@ -0,0 +1,149 @@
+package recommendation
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+// WithColdStart treats tickers with fewer than minRecords entries, or whose
+// first entry is less than minDays old, as cold start. Zero disables either
+// threshold; with both disabled no ticker is cold start.
+func WithColdStart(minRecords int, minDays float64) Option {
+	return func(s *Service) {
+		if minRecords >= 0 {
+			s.coldStartMinRecords = minRecords
+		}
+		if minDays >= 0 {
+			s.coldStartMinDays = minDays
+		}
+	}
+}
+
+func (s *Service) coldStartEnabled() bool {
+	return s.coldStartMinRecords > 0 || s.coldStartMinDays > 0
+}
+
+// coldStartSince is the first-seen time from which a ticker is still cold
+// start by age, or zero when age is not a threshold.
+func (s *Service) coldStartSince(now time.Time) time.Time {
+	if s.coldStartMinDays <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-time.Duration(s.coldStartMinDays * float64(24*time.Hour)))
+}
+
+func (s *Service) isColdStart(coverage stockviewer.TickerCoverage, since time.Time) bool {
+	if s.coldStartMinRecords > 0 && coverage.Records < int64(s.coldStartMinRecords) {
+		return true
+	}
+	return !since.IsZero() && !coverage.FirstSeen.Before(since)
+}
+
+// coldStartTickers returns which tickers in coverage are cold start.
+func (s *Service) coldStartTickers(coverage map[string]stockviewer.TickerCoverage) map[string]bool {
+	if !s.coldStartEnabled() {
+		return nil
+	}
+
+	since := s.coldStartSince(time.Now())
+	cold := make(map[string]bool)
+	for ticker, c := range coverage {
+		if s.isColdStart(c, since) {
+			cold[ticker] = true
+		}
+	}
+	return cold
+}
+
+// GetNewCoverage lists cold-start tickers, one recommendation each for their
+// latest entry. They are ranked by the strength of the rating the coverage
+// was initiated with, then by the latest target upside, then by ticker. The
+// list is empty when no cold-start threshold is configured. A limit of zero
+// or above MaxRecommendations lists DefaultRecommendationLimit tickers.
+func (s *Service) GetNewCoverage(ctx context.Context, limit int) ([]stockviewer.StockRecommendation, error) {
+	if limit < 1 || limit > stockviewer.MaxRecommendations {
+		limit = stockviewer.DefaultRecommendationLimit
+	}
+	if !s.coldStartEnabled() {
+		return []stockviewer.StockRecommendation{}, nil
+	}
+
+	stocks, err := s.stocksRepo.GetColdStartStocks(ctx, s.coldStartMinRecords, s.coldStartSince(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		initiation stockviewer.Stock
+		latest     stockviewer.Stock
+	}
+	byTicker := make(map[string]*candidate)
+	var order []string
+	for _, stock := range stocks {
+		c, ok := byTicker[stock.Ticker]
+		if !ok {
+			byTicker[stock.Ticker] = &candidate{initiation: stock, latest: stock}
+			order = append(order, stock.Ticker)
+			continue
+		}
+		if stock.CreatedAt.Before(c.initiation.CreatedAt) {
+			c.initiation = stock
+		}
+		if !stock.CreatedAt.Before(c.latest.CreatedAt) {
+			c.latest = stock
+		}
+	}
+
+	candidates := make([]*candidate, 0, len(order))
+	for _, ticker := range order {
+		candidates = append(candidates, byTicker[ticker])
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if ra, rb := calculateRatingScore(a.initiation.RatingTo), calculateRatingScore(b.initiation.RatingTo); ra != rb {
+			return ra > rb
+		}
+		ua, _ := a.latest.Upside()
+		ub, _ := b.latest.Upside()
+		if ua != ub {
+			return ua > ub
+		}
+		return a.latest.Ticker < b.latest.Ticker
+	})
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	var priors map[string]float64
+	if s.baseline == BaselinePriorTarget {
+		priors = historyPriorTargets(stocks)
+	}
+
+	latest := make([]stockviewer.Stock, 0, len(candidates))
+	for _, c := range candidates {
+		latest = append(latest, c.latest)
+	}
+	coverage, err := s.tickerCoverage(ctx, latest)
+	if err != nil {
+		return nil, err
+	}
+	consensuses, err := s.targetConsensuses(ctx, latest)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := stockviewer.RequestContextFrom(ctx)
+	recommendations := make([]stockviewer.StockRecommendation, 0, len(candidates))
+	for i, c := range candidates {
+		rec := s.recommend(c.latest, baselineTarget(c.latest, priors), coverage[c.latest.Ticker], consensuses[strings.ToUpper(c.latest.Ticker)], prefs)
+		rec.ColdStart = true
+		rec.Rank = i + 1
+		recommendations = append(recommendations, rec)
+	}
+	return recommendations, nil
+}
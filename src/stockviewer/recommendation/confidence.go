@@ -0,0 +1,119 @@
+package recommendation
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+// Confidence weights; they add up to 1.
+const (
+	// confidenceRatingWeight is earned when the rating is one
+	// calculateRatingScore grades rather than scoring it as the default.
+	confidenceRatingWeight = 0.35
+	// confidenceTargetsWeight is earned when the price-target component
+	// compares two targets rather than falling back to neutral.
+	confidenceTargetsWeight = 0.25
+	// confidenceAnalystsWeight is earned in proportion to the distinct
+	// brokerages covering the ticker, up to confidenceFullCoverage.
+	confidenceAnalystsWeight = 0.25
+	// confidenceAgreementWeight is earned as the analysts' price targets
+	// agree: in full when they are equal, none once the spread reaches the
+	// mean target.
+	confidenceAgreementWeight = 0.15
+)
+
+// confidenceFullCoverage is how many distinct brokerages covering a ticker
+// earn the whole analysts weight.
+const confidenceFullCoverage = 5
+
+// confidenceSpreadWindow is how far back the target consensus behind the
+// agreement weight reaches, the default window of the consensus endpoint.
+const confidenceSpreadWindow = 90 * 24 * time.Hour
+
+// confidence grades from 0 to 1 how much data backs a score, independently
+// of the score itself:
+//
+//	0.35 if the rating is recognized
+//	+ 0.25 if baseline and target_to are both set
+//	+ 0.25 × min(brokerages, 5) / 5
+//	+ 0.15 × (1 − min(spread / mean, 1))
+//
+// where brokerages is how many distinct brokerages cover the ticker, and
+// spread and mean are those of its price targets first seen in the last 90
+// days. The last term is 0 with fewer than two targets, as there is no
+// agreement to measure. A lone entry with an unknown rating and no targets
+// is 0.05 however high it scores, and a recognized rating with both targets,
+// five or more brokerages and equal targets is 1.
+func confidence(stock stockviewer.Stock, baseline float64, coverage stockviewer.TickerCoverage, consensus stockviewer.TargetConsensus, rounding stockviewer.Rounding) float64 {
+	var value float64
+	if _, ok := ratingScores[stockviewer.Rating(stock.RatingTo)]; ok {
+		value += confidenceRatingWeight
+	}
+	if _, to := stock.Targets(); baseline > 0 && to > 0 {
+		value += confidenceTargetsWeight
+	}
+	value += confidenceAnalystsWeight * float64(min(max(coverage.Brokerages, 0), confidenceFullCoverage)) / confidenceFullCoverage
+	if consensus.Count >= 2 && consensus.Mean > 0 {
+		value += confidenceAgreementWeight * (1 - min(consensus.Spread/consensus.Mean, 1))
+	}
+	return rounding.Round(value)
+}
+
+// tickerCoverage returns the coverage of every ticker among stocks, keyed by
+// ticker.
+func (s *Service) tickerCoverage(ctx context.Context, stocks []stockviewer.Stock) (map[string]stockviewer.TickerCoverage, error) {
+	if len(stocks) == 0 {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	var tickers []string
+	for _, stock := range stocks {
+		if !seen[stock.Ticker] {
+			seen[stock.Ticker] = true
+			tickers = append(tickers, stock.Ticker)
+		}
+	}
+
+	coverage, err := s.stocksRepo.GetTickerCoverage(ctx, tickers)
+	if err != nil {
+		return nil, err
+	}
+	byTicker := make(map[string]stockviewer.TickerCoverage, len(coverage))
+	for _, c := range coverage {
+		byTicker[c.Ticker] = c
+	}
+	return byTicker, nil
+}
+
+// targetConsensuses returns the target consensus of every ticker among
+// stocks over confidenceSpreadWindow, keyed by upper-case ticker. Tickers
+// without targets are left out.
+func (s *Service) targetConsensuses(ctx context.Context, stocks []stockviewer.Stock) (map[string]stockviewer.TargetConsensus, error) {
+	if len(stocks) == 0 {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	var tickers []string
+	for _, stock := range stocks {
+		ticker := strings.ToUpper(stock.Ticker)
+		if !seen[ticker] {
+			seen[ticker] = true
+			tickers = append(tickers, ticker)
+		}
+	}
+
+	consensuses, err := s.stocksRepo.GetTargetConsensuses(ctx, tickers, s.now().Add(-confidenceSpreadWindow))
+	if err != nil {
+		return nil, err
+	}
+	byTicker := make(map[string]stockviewer.TargetConsensus, len(consensuses))
+	for _, c := range consensuses {
+		byTicker[c.Ticker] = c
+	}
+	return byTicker, nil
+}
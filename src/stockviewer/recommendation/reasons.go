@@ -0,0 +1,157 @@
+package recommendation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+type reasonKey int
+
+const (
+	reasonStrongBuy reasonKey = iota
+	reasonOutperform
+	reasonStable
+	reasonUnderperform
+	reasonTargetRaised
+	reasonUpgraded
+	reasonTargetLowered
+	reasonDowngraded
+	reasonUpside
+	reasonDownside
+	reasonFallback
+	reasonTargetMove
+)
+
+// reasonCatalog holds the reason sentences per locale. Every locale accepted
+// by stockviewer.MatchLocale must have every key.
+var reasonCatalog = map[string]map[reasonKey]string{
+	stockviewer.LocaleEnglish: {
+		reasonStrongBuy:     "Strong buy recommendation from analyst",
+		reasonOutperform:    "Expected to outperform the market",
+		reasonStable:        "Stable performance expected",
+		reasonUnderperform:  "Caution advised - underperformance expected",
+		reasonTargetRaised:  "Price target recently increased",
+		reasonUpgraded:      "Recently upgraded by analyst",
+		reasonTargetLowered: "Price target recently decreased",
+		reasonDowngraded:    "Recently downgraded by analyst",
+		reasonUpside:        "Significant upside potential in price target",
+		reasonDownside:      "Notable downside risk in price target",
+		reasonFallback:      "Based on current market analysis",
+		reasonTargetMove:    "Price target moved from %s to %s",
+	},
+	stockviewer.LocaleSpanish: {
+		reasonStrongBuy:     "Fuerte recomendación de compra del analista",
+		reasonOutperform:    "Se espera un rendimiento superior al del mercado",
+		reasonStable:        "Se espera un rendimiento estable",
+		reasonUnderperform:  "Se aconseja precaución - se espera un rendimiento inferior",
+		reasonTargetRaised:  "Precio objetivo aumentado recientemente",
+		reasonUpgraded:      "Mejorado recientemente por el analista",
+		reasonTargetLowered: "Precio objetivo reducido recientemente",
+		reasonDowngraded:    "Rebajado recientemente por el analista",
+		reasonUpside:        "Potencial de suba significativo en el precio objetivo",
+		reasonDownside:      "Riesgo de baja notable en el precio objetivo",
+		reasonFallback:      "Basado en el análisis actual del mercado",
+		reasonTargetMove:    "El precio objetivo pasó de %s a %s",
+	},
+}
+
+// maxReasons is how many sentences a reason carries at each verbosity; zero
+// means no limit.
+var maxReasons = map[stockviewer.Verbosity]int{
+	stockviewer.VerbosityCompact: 1,
+	stockviewer.VerbosityNormal:  3,
+	stockviewer.VerbosityFull:    0,
+}
+
+// generateReason renders why a stock is recommended in the request's locale.
+// Full verbosity keeps every sentence and adds the price target move in the
+// request's currency.
+func generateReason(stock stockviewer.Stock, prefs stockviewer.RequestContext) string {
+	messages, ok := reasonCatalog[prefs.Locale]
+	if !ok {
+		messages = reasonCatalog[stockviewer.LocaleEnglish]
+	}
+
+	var reasons []string
+
+	switch stockviewer.Rating(stock.RatingTo) {
+	case stockviewer.RatingBuy, stockviewer.RatingStrongBuy:
+		reasons = append(reasons, messages[reasonStrongBuy])
+	case stockviewer.RatingOutperform, stockviewer.RatingOverweight:
+		reasons = append(reasons, messages[reasonOutperform])
+	case stockviewer.RatingHold, stockviewer.RatingNeutral:
+		reasons = append(reasons, messages[reasonStable])
+	case stockviewer.RatingSell, stockviewer.RatingUnderperform:
+		reasons = append(reasons, messages[reasonUnderperform])
+	}
+
+	switch stockviewer.Action(stock.Action) {
+	case stockviewer.ActionTargetRaised:
+		reasons = append(reasons, messages[reasonTargetRaised])
+	case stockviewer.ActionUpgraded:
+		reasons = append(reasons, messages[reasonUpgraded])
+	case stockviewer.ActionTargetLowered:
+		reasons = append(reasons, messages[reasonTargetLowered])
+	case stockviewer.ActionDowngraded:
+		reasons = append(reasons, messages[reasonDowngraded])
+	}
+
+	if change, ok := stock.Upside(); ok {
+		if change > 10 {
+			reasons = append(reasons, messages[reasonUpside])
+		} else if change < -10 {
+			reasons = append(reasons, messages[reasonDownside])
+		}
+		if prefs.Verbosity == stockviewer.VerbosityFull {
+			from, to := stock.Targets()
+			reasons = append(reasons, fmt.Sprintf(messages[reasonTargetMove],
+				formatAmount(from, prefs), formatAmount(to, prefs)))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return messages[reasonFallback]
+	}
+
+	if limit, ok := maxReasons[prefs.Verbosity]; ok && limit > 0 && len(reasons) > limit {
+		reasons = reasons[:limit]
+	}
+	return strings.Join(reasons, ". ")
+}
+
+// formatAmount formats a price with two decimals, the locale's separators and
+// the currency's symbol, e.g. "$1,234.50" or "US$ 1.234,50". Amounts are
+// never converted.
+func formatAmount(amount float64, prefs stockviewer.RequestContext) string {
+	cents := int64(math.Round(math.Abs(amount) * 100))
+	whole := fmt.Sprintf("%d", cents/100)
+
+	thousands, decimal := ",", "."
+	if prefs.Locale == stockviewer.LocaleSpanish {
+		thousands, decimal = ".", ","
+	}
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(thousands)
+		}
+		grouped.WriteRune(digit)
+	}
+	number := fmt.Sprintf("%s%s%02d", grouped.String(), decimal, cents%100)
+	if amount < 0 {
+		number = "-" + number
+	}
+
+	switch {
+	case prefs.Currency != stockviewer.CurrencyUSD:
+		return prefs.Currency + " " + number
+	case prefs.Locale == stockviewer.LocaleSpanish:
+		return "US$ " + number
+	default:
+		return "$" + number
+	}
+}
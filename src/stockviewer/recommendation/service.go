@@ -2,39 +2,277 @@ package recommendation
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/user/go-stock-viewer-back/src/stockviewer"
 )
 
+// ScoreWeights controls how much each component contributes to the
+// recommendation score. The weights must add up to 1.
+type ScoreWeights struct {
+	Rating      float64
+	Action      float64
+	PriceTarget float64
+}
+
+// DefaultScoreWeights returns the weights used when none are configured.
+func DefaultScoreWeights() ScoreWeights {
+	return ScoreWeights{
+		Rating:      0.40,
+		Action:      0.35,
+		PriceTarget: 0.25,
+	}
+}
+
+func (w ScoreWeights) Validate() error {
+	if w.Rating < 0 || w.Action < 0 || w.PriceTarget < 0 {
+		return stockviewer.ValidationError{Field: "score_weights", Message: "weights must not be negative"}
+	}
+	sum := w.Rating + w.Action + w.PriceTarget
+	if math.Abs(sum-1.0) > 1e-6 {
+		return stockviewer.ValidationError{
+			Field:   "score_weights",
+			Message: fmt.Sprintf("weights must sum to 1.0, got %.4f", sum),
+		}
+	}
+	return nil
+}
+
+// DefaultRecencyDecayDays is the age at which a score reaches the minimum
+// recency factor.
+const DefaultRecencyDecayDays = 365.0
+
+// defaultCacheTTL is how long top-scored candidates are served from memory
+// unless WithCacheTTL says otherwise.
+const defaultCacheTTL = 2 * time.Minute
+
 type Service struct {
-	stocksRepo stockviewer.StocksRepository
+	stocksRepo       stockviewer.StocksRepository
+	weights          ScoreWeights
+	latestPerTicker  bool
+	recencyDecayDays float64
+	tieEpsilon       float64
+	baseline         PriceTargetBaseline
+	rounding         stockviewer.Rounding
+	// coldStartMinRecords and coldStartMinDays are the thresholds below
+	// which a ticker is cold start; zero disables each one.
+	coldStartMinRecords int
+	coldStartMinDays    float64
+
+	cacheTTL time.Duration
+	now      func() time.Time
+	cacheMu  sync.RWMutex
+	// cache holds the top-scored candidates read from storage, keyed by
+	// brokerage and how many were requested. cacheGeneration is bumped by
+	// InvalidateCache so a read that raced an invalidation is not stored.
+	cache           map[candidatesKey]cachedCandidates
+	cacheGeneration uint64
+}
+
+// candidatesKey identifies a candidate list; an empty brokerage means every
+// brokerage.
+type candidatesKey struct {
+	brokerage string
+	limit     int
 }
 
-func NewService(stocksRepo stockviewer.StocksRepository) *Service {
-	return &Service{
-		stocksRepo: stocksRepo,
+type cachedCandidates struct {
+	stocks    []stockviewer.Stock
+	expiresAt time.Time
+}
+
+// Option customizes optional Service settings.
+type Option func(*Service)
+
+// WithLatestPerTicker collapses candidates to the most recent entry of each
+// ticker before ranking, so every recommendation is a distinct ticker.
+func WithLatestPerTicker(enabled bool) Option {
+	return func(s *Service) {
+		s.latestPerTicker = enabled
 	}
 }
 
-func (s *Service) GetTopRecommendations(ctx context.Context, limit int) ([]stockviewer.StockRecommendation, error) {
-	if limit < 1 || limit > 100 {
-		limit = 10
+// WithScoreWeights overrides the default score weights.
+func WithScoreWeights(weights ScoreWeights) Option {
+	return func(s *Service) {
+		s.weights = weights
 	}
+}
+
+// WithRecencyDecayDays sets how many days it takes for a score to decay to
+// the minimum recency factor.
+func WithRecencyDecayDays(days float64) Option {
+	return func(s *Service) {
+		if days > 0 {
+			s.recencyDecayDays = days
+		}
+	}
+}
+
+// WithTieEpsilon gives recommendations whose score is within epsilon of the
+// first recommendation of a tie group the same rank, using competition
+// ranking (1, 2, 2, 4). Zero keeps every rank distinct.
+func WithTieEpsilon(epsilon float64) Option {
+	return func(s *Service) {
+		if epsilon >= 0 {
+			s.tieEpsilon = epsilon
+		}
+	}
+}
+
+// WithRounding sets how scores and their breakdown are rounded to two
+// decimals.
+func WithRounding(rounding stockviewer.Rounding) Option {
+	return func(s *Service) {
+		if rounding != "" {
+			s.rounding = rounding
+		}
+	}
+}
+
+// WithCacheTTL sets how long the top-scored candidates are served from
+// memory. A zero TTL disables caching.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		if ttl >= 0 {
+			s.cacheTTL = ttl
+		}
+	}
+}
+
+func NewService(stocksRepo stockviewer.StocksRepository, opts ...Option) *Service {
+	s := &Service{
+		stocksRepo:       stocksRepo,
+		weights:          DefaultScoreWeights(),
+		recencyDecayDays: DefaultRecencyDecayDays,
+		baseline:         BaselineTargetFrom,
+		cacheTTL:         defaultCacheTTL,
+		now:              time.Now,
+		cache:            make(map[candidatesKey]cachedCandidates),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
 
-	stocks, err := s.stocksRepo.GetTopRecommended(ctx, limit*2)
+// GetTopRecommendations ranks up to stockviewer.MaxRecommendations of the best scored stocks and
+// returns the page filter asks for. When cold-start thresholds are
+// configured, cold-start tickers are flagged and, unless filter.IncludeNew is
+// set, left out so a single fresh rating cannot outrank established
+// coverage.
+//
+// With filter.Brokerage only that brokerage's stocks are ranked, matched
+// exactly, and cold-start tickers are always left out. With
+// WithLatestPerTicker each ticker is then represented by the brokerage's own
+// most recent entry rather than the newest entry of any brokerage.
+func (s *Service) GetTopRecommendations(ctx context.Context, filter stockviewer.RecommendationFilter) (*stockviewer.PaginatedRecommendations, error) {
+	filter, err := normalizeFilter(filter)
 	if err != nil {
 		return nil, err
 	}
 
+	ranked, err := s.top(ctx, filter, stockviewer.MaxRecommendations)
+	if err != nil {
+		return nil, err
+	}
+
+	start := min((filter.Page-1)*filter.PageSize, len(ranked))
+	end := min(start+filter.PageSize, len(ranked))
+	return stockviewer.NewPaginatedRecommendations(ranked[start:end], filter.Page, filter.PageSize, int64(len(ranked))), nil
+}
+
+// normalizeFilter trims the brokerage, upper-cases the tier, fills in the
+// first page and the default page size, and rejects values the ranking
+// cannot serve.
+func normalizeFilter(filter stockviewer.RecommendationFilter) (stockviewer.RecommendationFilter, error) {
+	filter.Brokerage = strings.TrimSpace(filter.Brokerage)
+	if filter.Brokerage != "" && filter.IncludeNew {
+		return filter, stockviewer.ValidationError{Field: "include_new", Message: "cannot be combined with brokerage"}
+	}
+
+	filter.Tier = strings.ToUpper(strings.TrimSpace(filter.Tier))
+	if filter.Tier != "" && !validTier(filter.Tier) {
+		return filter, stockviewer.ValidationError{Field: "tier", Message: "must be one of A, B, C, D, F"}
+	}
+
+	switch {
+	case filter.Page == 0:
+		filter.Page = 1
+	case filter.Page < 0:
+		return filter, stockviewer.ValidationError{Field: "page", Message: "must not be negative"}
+	}
+
+	switch {
+	case filter.PageSize == 0:
+		filter.PageSize = stockviewer.DefaultRecommendationLimit
+	case filter.PageSize < 0 || filter.PageSize > stockviewer.MaxRecommendations:
+		return filter, stockviewer.ValidationError{Field: "page_size", Message: fmt.Sprintf("must be between 1 and %d", stockviewer.MaxRecommendations)}
+	}
+	return filter, nil
+}
+
+// top ranks the best scored candidates of filter's brokerage, or of every
+// brokerage when it is empty. A tier filters the candidates before they are
+// sorted and ranked, so ranks count within the tier.
+func (s *Service) top(ctx context.Context, filter stockviewer.RecommendationFilter, limit int) ([]stockviewer.StockRecommendation, error) {
+	brokerage := filter.Brokerage
+	if limit < 1 || limit > stockviewer.MaxRecommendations {
+		limit = stockviewer.DefaultRecommendationLimit
+	}
+
+	candidates := limit * 2
+	if s.latestPerTicker {
+		candidates = limit * 5
+	}
+
+	stocks, err := s.topRecommended(ctx, candidatesKey{brokerage: brokerage, limit: candidates})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.latestPerTicker {
+		if brokerage == "" {
+			stocks, err = s.latestEntries(ctx, stocks, limit)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			stocks = latestCandidates(stocks, limit)
+		}
+	}
+
+	coverage, err := s.tickerCoverage(ctx, stocks)
+	if err != nil {
+		return nil, err
+	}
+	cold := s.coldStartTickers(coverage)
+	consensuses, err := s.targetConsensuses(ctx, stocks)
+	if err != nil {
+		return nil, err
+	}
+
+	priors, err := s.priorTargets(ctx, stocks)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := stockviewer.RequestContextFrom(ctx)
 	var recommendations []stockviewer.StockRecommendation
 	for _, stock := range stocks {
-		rec := stockviewer.StockRecommendation{
-			Stock:  stock,
-			Score:  s.CalculateScore(stock),
-			Reason: generateReason(stock),
+		if cold[stock.Ticker] && !filter.IncludeNew {
+			continue
+		}
+		rec := s.recommend(stock, baselineTarget(stock, priors), coverage[stock.Ticker], consensuses[strings.ToUpper(stock.Ticker)], prefs)
+		if filter.Tier != "" && rec.Tier != filter.Tier {
+			continue
 		}
+		rec.ColdStart = cold[stock.Ticker]
 		recommendations = append(recommendations, rec)
 	}
 
@@ -46,79 +284,273 @@ func (s *Service) GetTopRecommendations(ctx context.Context, limit int) ([]stock
 		recommendations = recommendations[:limit]
 	}
 
+	assignRanks(recommendations, s.tieEpsilon)
+
+	return recommendations, nil
+}
+
+// topRecommended reads the candidate list key identifies, from the cache
+// while the entry is fresh. Scores only change on syncs and manual overrides,
+// which call InvalidateCache.
+func (s *Service) topRecommended(ctx context.Context, key candidatesKey) ([]stockviewer.Stock, error) {
+	if s.cacheTTL == 0 {
+		return s.readCandidates(ctx, key)
+	}
+
+	s.cacheMu.RLock()
+	entry, ok := s.cache[key]
+	generation := s.cacheGeneration
+	s.cacheMu.RUnlock()
+	if ok && s.now().Before(entry.expiresAt) {
+		return entry.stocks, nil
+	}
+
+	stocks, err := s.readCandidates(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	if s.cacheGeneration == generation {
+		s.cache[key] = cachedCandidates{stocks: stocks, expiresAt: s.now().Add(s.cacheTTL)}
+	}
+	return stocks, nil
+}
+
+func (s *Service) readCandidates(ctx context.Context, key candidatesKey) ([]stockviewer.Stock, error) {
+	if key.brokerage == "" {
+		return s.stocksRepo.GetTopRecommended(ctx, key.limit)
+	}
+	return s.stocksRepo.GetTopRecommendedByBrokerage(ctx, key.brokerage, key.limit)
+}
+
+// InvalidateCache drops every cached candidate list so the next request
+// reads the current scores.
+func (s *Service) InvalidateCache() {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+
+	s.cache = make(map[candidatesKey]cachedCandidates)
+	s.cacheGeneration++
+}
+
+// recommend scores stock, comparing its target_to against baseline. The
+// coverage and target consensus of its ticker feed the confidence.
+func (s *Service) recommend(stock stockviewer.Stock, baseline float64, coverage stockviewer.TickerCoverage, consensus stockviewer.TargetConsensus, prefs stockviewer.RequestContext) stockviewer.StockRecommendation {
+	breakdown := s.scoreBreakdown(stock, baseline)
+	rounded := breakdown.rounded(s.rounding)
+	score := breakdown.total(s.rounding)
+	return stockviewer.StockRecommendation{
+		Stock:      stock,
+		Score:      score,
+		Confidence: confidence(stock, baseline, coverage, consensus, s.rounding),
+		Breakdown:  &rounded,
+		Reason:     generateReason(stock, prefs),
+		Tier:       scoreTier(score),
+	}
+}
+
+// Score tiers, best first.
+const (
+	TierA = "A"
+	TierB = "B"
+	TierC = "C"
+	TierD = "D"
+	TierF = "F"
+)
+
+// tierFloors holds the lowest score of each tier above TierF.
+var tierFloors = []struct {
+	tier  string
+	floor float64
+}{
+	{TierA, 80},
+	{TierB, 65},
+	{TierC, 50},
+	{TierD, 35},
+}
+
+// scoreTier grades a 0-100 score: 80 and up is A, 65 to under 80 is B, 50 to
+// under 65 is C, 35 to under 50 is D and anything lower is F. Fractional
+// scores fall in the tier of their floor, so 79.99 is a B.
+func scoreTier(score float64) string {
+	for _, t := range tierFloors {
+		if score >= t.floor {
+			return t.tier
+		}
+	}
+	return TierF
+}
+
+func validTier(tier string) bool {
+	switch tier {
+	case TierA, TierB, TierC, TierD, TierF:
+		return true
+	}
+	return false
+}
+
+// assignRanks numbers recommendations sorted by descending score. A score
+// less than epsilon below the first score of the current tie group shares its
+// rank; comparing against the group's first score rather than the previous
+// one keeps a long run of small gaps from collapsing into a single rank.
+func assignRanks(recommendations []stockviewer.StockRecommendation, epsilon float64) {
+	groupRank, groupScore := 0, 0.0
 	for i := range recommendations {
-		recommendations[i].Rank = i + 1
+		score := recommendations[i].Score
+		if i == 0 || groupScore-score >= epsilon {
+			groupRank, groupScore = i+1, score
+		}
+		recommendations[i].Rank = groupRank
 	}
+}
 
-	return recommendations, nil
+// latestCandidates keeps the most recently updated candidate of each
+// distinct ticker, keeping at most limit tickers in the order each ticker
+// first appears.
+func latestCandidates(candidates []stockviewer.Stock, limit int) []stockviewer.Stock {
+	index := make(map[string]int)
+	var latest []stockviewer.Stock
+	for _, candidate := range candidates {
+		if i, ok := index[candidate.Ticker]; ok {
+			if candidate.UpdatedAt.After(latest[i].UpdatedAt) {
+				latest[i] = candidate
+			}
+			continue
+		}
+		if len(latest) == limit {
+			continue
+		}
+		index[candidate.Ticker] = len(latest)
+		latest = append(latest, candidate)
+	}
+	return latest
 }
 
+// latestEntries replaces the candidates with the most recently updated entry
+// of each distinct ticker, keeping at most limit tickers in candidate order.
+func (s *Service) latestEntries(ctx context.Context, candidates []stockviewer.Stock, limit int) ([]stockviewer.Stock, error) {
+	seen := make(map[string]bool)
+	var latest []stockviewer.Stock
+
+	for _, candidate := range candidates {
+		if seen[candidate.Ticker] {
+			continue
+		}
+		seen[candidate.Ticker] = true
+
+		entries, _, err := s.stocksRepo.GetTickerHistory(ctx, candidate.Ticker, stockviewer.StockFilter{
+			SortBy:    "updated_at",
+			SortOrder: "DESC",
+			Page:      1,
+			PageSize:  1,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		newest := candidate
+		for _, entry := range entries {
+			if entry.UpdatedAt.After(newest.UpdatedAt) {
+				newest = entry
+			}
+		}
+		latest = append(latest, newest)
+
+		if len(latest) == limit {
+			break
+		}
+	}
+
+	return latest, nil
+}
+
+// CalculateScore scores stock on its own, so the price-target sub-score
+// always compares against its target_from.
 func (s *Service) CalculateScore(stock stockviewer.Stock) float64 {
-	score := 0.0
+	from, _ := stock.Targets()
+	return s.scoreBreakdown(stock, from).total(s.rounding)
+}
 
-	ratingWeight := 0.40
-	actionWeight := 0.35
-	priceTargetWeight := 0.25
+// scoreBreakdown returns the unrounded weighted components of the score,
+// each scaled by the recency factor. The price-target component compares
+// target_to against baseline.
+func (s *Service) scoreBreakdown(stock stockviewer.Stock, baseline float64) breakdown {
+	_, to := stock.Targets()
+	recency := stockviewer.RecencyFactor(stock.CreatedAt, time.Now(), s.recencyDecayDays)
+	return breakdown{
+		RatingComponent:      calculateRatingScore(stock.RatingTo) * s.weights.Rating * recency,
+		ActionComponent:      calculateActionScore(stock.Action) * s.weights.Action * recency,
+		PriceTargetComponent: calculatePriceTargetScore(baseline, to) * s.weights.PriceTarget * recency,
+		RecencyFactor:        recency,
+	}
+}
 
-	ratingScore := calculateRatingScore(stock.RatingTo)
-	score += ratingScore * ratingWeight
+type breakdown stockviewer.ScoreBreakdown
 
-	actionScore := calculateActionScore(stock.Action)
-	score += actionScore * actionWeight
+func (b breakdown) total(rounding stockviewer.Rounding) float64 {
+	return rounding.Round(b.RatingComponent + b.ActionComponent + b.PriceTargetComponent)
+}
 
-	priceTargetScore := calculatePriceTargetScore(stock.TargetFrom, stock.TargetTo)
-	score += priceTargetScore * priceTargetWeight
+func (b breakdown) rounded(rounding stockviewer.Rounding) stockviewer.ScoreBreakdown {
+	return stockviewer.ScoreBreakdown{
+		RatingComponent:      rounding.Round(b.RatingComponent),
+		ActionComponent:      rounding.Round(b.ActionComponent),
+		PriceTargetComponent: rounding.Round(b.PriceTargetComponent),
+		RecencyFactor:        rounding.Round(b.RecencyFactor),
+	}
+}
 
-	normalizedScore := (score + 100) / 2
-	return math.Round(normalizedScore*100) / 100
+// ratingScores grades every recognized rating; other ratings score 40.
+var ratingScores = map[stockviewer.Rating]float64{
+	stockviewer.RatingBuy:           100.0,
+	stockviewer.RatingStrongBuy:     100.0,
+	stockviewer.RatingOutperform:    80.0,
+	stockviewer.RatingOverweight:    70.0,
+	stockviewer.RatingAccumulate:    60.0,
+	stockviewer.RatingHold:          40.0,
+	stockviewer.RatingNeutral:       35.0,
+	stockviewer.RatingMarketPerform: 30.0,
+	stockviewer.RatingEqualWeight:   30.0,
+	stockviewer.RatingUnderperform:  15.0,
+	stockviewer.RatingUnderweight:   15.0,
+	stockviewer.RatingReduce:        10.0,
+	stockviewer.RatingSell:          0.0,
+	stockviewer.RatingSpeculative:   50.0,
 }
 
 func calculateRatingScore(rating string) float64 {
-	scores := map[string]float64{
-		"Buy":            100.0,
-		"Strong Buy":     100.0,
-		"Outperform":     80.0,
-		"Overweight":     70.0,
-		"Accumulate":     60.0,
-		"Hold":           40.0,
-		"Neutral":        35.0,
-		"Market Perform": 30.0,
-		"Equal Weight":   30.0,
-		"Underperform":   15.0,
-		"Underweight":    15.0,
-		"Reduce":         10.0,
-		"Sell":           0.0,
-		"Speculative":    50.0,
-	}
-
-	if score, ok := scores[rating]; ok {
+	if score, ok := ratingScores[stockviewer.Rating(rating)]; ok {
 		return score
 	}
 	return 40.0
 }
 
-func calculateActionScore(action string) float64 {
-	scores := map[string]float64{
-		"target raised by":  100.0,
-		"upgraded by":       100.0,
-		"initiated by":      60.0,
-		"reiterated by":     50.0,
-		"target lowered by": 0.0,
-		"downgraded by":     0.0,
-	}
+// actionScores grades every stockviewer.Action; unknown actions score 50.
+var actionScores = map[stockviewer.Action]float64{
+	stockviewer.ActionTargetRaised:  100.0,
+	stockviewer.ActionUpgraded:      100.0,
+	stockviewer.ActionInitiated:     60.0,
+	stockviewer.ActionReiterated:    50.0,
+	stockviewer.ActionTargetLowered: 0.0,
+	stockviewer.ActionDowngraded:    0.0,
+}
 
-	if score, ok := scores[action]; ok {
+func calculateActionScore(action string) float64 {
+	if score, ok := actionScores[stockviewer.Action(action)]; ok {
 		return score
 	}
 	return 50.0
 }
 
-func calculatePriceTargetScore(from, to float64) float64 {
-	if from <= 0 || to <= 0 {
+// calculatePriceTargetScore grades the percentage change from baseline to
+// target. Without both targets it is neutral.
+func calculatePriceTargetScore(baseline, target float64) float64 {
+	if baseline <= 0 || target <= 0 {
 		return 50.0
 	}
-
-	percentChange := ((to - from) / from) * 100
+	percentChange := (target - baseline) / baseline * 100
 
 	if percentChange > 50 {
 		return 100.0
@@ -140,48 +572,3 @@ func calculatePriceTargetScore(from, to float64) float64 {
 	}
 	return 0.0
 }
-
-func generateReason(stock stockviewer.Stock) string {
-	var reasons []string
-
-	switch stock.RatingTo {
-	case "Buy", "Strong Buy":
-		reasons = append(reasons, "Strong buy recommendation from analyst")
-	case "Outperform", "Overweight":
-		reasons = append(reasons, "Expected to outperform the market")
-	case "Hold", "Neutral":
-		reasons = append(reasons, "Stable performance expected")
-	case "Sell", "Underperform":
-		reasons = append(reasons, "Caution advised - underperformance expected")
-	}
-
-	switch stock.Action {
-	case "target raised by":
-		reasons = append(reasons, "Price target recently increased")
-	case "upgraded by":
-		reasons = append(reasons, "Recently upgraded by analyst")
-	case "target lowered by":
-		reasons = append(reasons, "Price target recently decreased")
-	case "downgraded by":
-		reasons = append(reasons, "Recently downgraded by analyst")
-	}
-
-	if stock.TargetFrom > 0 && stock.TargetTo > 0 {
-		change := ((stock.TargetTo - stock.TargetFrom) / stock.TargetFrom) * 100
-		if change > 10 {
-			reasons = append(reasons, "Significant upside potential in price target")
-		} else if change < -10 {
-			reasons = append(reasons, "Notable downside risk in price target")
-		}
-	}
-
-	if len(reasons) == 0 {
-		return "Based on current market analysis"
-	}
-
-	result := reasons[0]
-	for i := 1; i < len(reasons) && i < 3; i++ {
-		result += ". " + reasons[i]
-	}
-	return result
-}
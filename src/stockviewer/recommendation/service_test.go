@@ -2,7 +2,12 @@ package recommendation
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/user/go-stock-viewer-back/src/stockviewer"
 	"github.com/user/go-stock-viewer-back/src/stockviewer/mocks"
@@ -12,7 +17,7 @@ func TestGetTopRecommendations_Success(t *testing.T) {
 	mockRepo := mocks.NewMockStocksRepository()
 	service := NewService(mockRepo)
 
-	recommendations, err := service.GetTopRecommendations(context.Background(), 5)
+	recommendations, err := topRecommendations(context.Background(), service, stockviewer.RecommendationFilter{PageSize: 5})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -33,7 +38,7 @@ func TestGetTopRecommendations_WithRanks(t *testing.T) {
 	mockRepo := mocks.NewMockStocksRepository()
 	service := NewService(mockRepo)
 
-	recommendations, err := service.GetTopRecommendations(context.Background(), 10)
+	recommendations, err := topRecommendations(context.Background(), service, stockviewer.RecommendationFilter{PageSize: 10})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -46,18 +51,155 @@ func TestGetTopRecommendations_WithRanks(t *testing.T) {
 	}
 }
 
-func TestGetTopRecommendations_LimitExceeds(t *testing.T) {
+// topRecommendations returns the page of the ranking filter selects.
+func topRecommendations(ctx context.Context, service *Service, filter stockviewer.RecommendationFilter) ([]stockviewer.StockRecommendation, error) {
+	page, err := service.GetTopRecommendations(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return page.Data, nil
+}
+
+func TestScoreTier(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  string
+	}{
+		{100, "A"},
+		{80, "A"},
+		{79.99, "B"},
+		{65, "B"},
+		{64.99, "C"},
+		{50, "C"},
+		{49.99, "D"},
+		{35, "D"},
+		{34.99, "F"},
+		{0, "F"},
+	}
+	for _, tt := range tests {
+		if got := scoreTier(tt.score); got != tt.want {
+			t.Errorf("scoreTier(%v): expected %s, got %s", tt.score, tt.want, got)
+		}
+	}
+}
+
+func TestGetTopRecommendations_Tier(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository())
+	ctx := context.Background()
+
+	all, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	counts := make(map[string]int)
+	for _, rec := range all {
+		if rec.Tier != scoreTier(rec.Score) {
+			t.Errorf("%s: expected tier %s for score %v, got %s", rec.Stock.ID, scoreTier(rec.Score), rec.Score, rec.Tier)
+		}
+		counts[rec.Tier]++
+	}
+	if len(counts) < 2 {
+		t.Fatalf("expected the fixture to span several tiers, got %v", counts)
+	}
+
+	for tier, count := range counts {
+		filtered, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{Tier: strings.ToLower(tier), PageSize: 100})
+		if err != nil {
+			t.Fatalf("tier %s: unexpected error: %v", tier, err)
+		}
+		if len(filtered) != count {
+			t.Errorf("tier %s: expected %d recommendations, got %d", tier, count, len(filtered))
+		}
+		for i, rec := range filtered {
+			if rec.Tier != tier || rec.Rank != i+1 {
+				t.Errorf("tier %s: expected rank %d within the tier, got %s ranked %d", tier, i+1, rec.Tier, rec.Rank)
+			}
+		}
+	}
+}
+
+func TestGetTopRecommendations_InvalidFilter(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository())
+
+	tests := map[string]struct {
+		filter stockviewer.RecommendationFilter
+		field  string
+	}{
+		"page size too large": {stockviewer.RecommendationFilter{PageSize: stockviewer.MaxRecommendations + 1}, "page_size"},
+		"negative page size":  {stockviewer.RecommendationFilter{PageSize: -1}, "page_size"},
+		"negative page":       {stockviewer.RecommendationFilter{Page: -1}, "page"},
+		"unknown tier":        {stockviewer.RecommendationFilter{Tier: "E"}, "tier"},
+		"include new by brokerage": {
+			stockviewer.RecommendationFilter{Brokerage: "Goldman Sachs", IncludeNew: true}, "include_new",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.GetTopRecommendations(context.Background(), tt.filter)
+			var validationErr stockviewer.ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.field {
+				t.Errorf("expected validation error on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestGetTopRecommendations_Pages(t *testing.T) {
 	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = nil
+	for i := 0; i < 25; i++ {
+		mockRepo.Stocks = append(mockRepo.Stocks, stockviewer.Stock{
+			ID: fmt.Sprintf("s-%d", i), Ticker: fmt.Sprintf("T%02d", i), RatingTo: "Buy", RecommendScore: float64(100 - i),
+		})
+	}
 	service := NewService(mockRepo)
+	ctx := context.Background()
+
+	first, err := service.GetTopRecommendations(ctx, stockviewer.RecommendationFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Page != 1 || first.PageSize != stockviewer.DefaultRecommendationLimit || len(first.Data) != stockviewer.DefaultRecommendationLimit {
+		t.Errorf("expected a first page of %d, got page %d of size %d with %d items", stockviewer.DefaultRecommendationLimit, first.Page, first.PageSize, len(first.Data))
+	}
+	if first.TotalItems != 25 || first.TotalPages != 3 || !first.HasNext || first.HasPrev {
+		t.Errorf("expected 25 items over 3 pages, got %+v", first)
+	}
 
-	recommendations, err := service.GetTopRecommendations(context.Background(), 1000)
+	last, err := service.GetTopRecommendations(ctx, stockviewer.RecommendationFilter{Page: 3})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if len(last.Data) != 5 || last.HasNext || !last.HasPrev {
+		t.Fatalf("expected a last page of 5, got %d items (next %v, prev %v)", len(last.Data), last.HasNext, last.HasPrev)
+	}
+	if last.Data[0].Rank != 21 || last.Data[0].Stock.Ticker != "T20" {
+		t.Errorf("expected the last page to continue the ranking at 21, got %s ranked %d", last.Data[0].Stock.Ticker, last.Data[0].Rank)
+	}
 
-	if len(recommendations) > len(mockRepo.Stocks) {
-		t.Errorf("expected at most %d recommendations, got %d",
-			len(mockRepo.Stocks), len(recommendations))
+	past, err := service.GetTopRecommendations(ctx, stockviewer.RecommendationFilter{Page: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if past.Data == nil || len(past.Data) != 0 || past.HasNext {
+		t.Errorf("expected an empty page past the end, got %+v", past)
+	}
+}
+
+func TestGetTopRecommendations_RankingCapped(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = nil
+	for i := 0; i < stockviewer.MaxRecommendations+20; i++ {
+		mockRepo.Stocks = append(mockRepo.Stocks, stockviewer.Stock{ID: fmt.Sprintf("s-%d", i), Ticker: fmt.Sprintf("T%03d", i)})
+	}
+	service := NewService(mockRepo)
+
+	page, err := service.GetTopRecommendations(context.Background(), stockviewer.RecommendationFilter{PageSize: stockviewer.MaxRecommendations})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.TotalItems != stockviewer.MaxRecommendations || len(page.Data) != stockviewer.MaxRecommendations || page.HasNext {
+		t.Errorf("expected the ranking to stop at %d, got %d of %d", stockviewer.MaxRecommendations, len(page.Data), page.TotalItems)
 	}
 }
 
@@ -76,8 +218,8 @@ func TestCalculateScore(t *testing.T) {
 			stock: stockviewer.Stock{
 				RatingTo:   "Buy",
 				Action:     "target raised by",
-				TargetFrom: 100,
-				TargetTo:   150,
+				TargetFrom: stockviewer.Price(100),
+				TargetTo:   stockviewer.Price(150),
 			},
 			minScore: 70,
 			maxScore: 100,
@@ -87,8 +229,8 @@ func TestCalculateScore(t *testing.T) {
 			stock: stockviewer.Stock{
 				RatingTo:   "Sell",
 				Action:     "downgraded by",
-				TargetFrom: 100,
-				TargetTo:   50,
+				TargetFrom: stockviewer.Price(100),
+				TargetTo:   stockviewer.Price(50),
 			},
 			minScore: 0,
 			maxScore: 30,
@@ -145,10 +287,782 @@ func TestGenerateReason(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			reason := generateReason(tt.stock)
+			reason := generateReason(tt.stock, stockviewer.DefaultRequestContext())
 			if reason == "" {
 				t.Error("expected non-empty reason")
 			}
 		})
 	}
 }
+
+func TestGetTopRecommendations_LatestPerTicker(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	now := time.Now()
+	mockRepo.Stocks = append(mockRepo.Stocks,
+		stockviewer.Stock{
+			ID:             "test-id-4",
+			Ticker:         "AAPL",
+			Company:        "Apple Inc.",
+			Brokerage:      "JP Morgan",
+			Action:         "downgraded by",
+			RatingTo:       "Neutral",
+			RecommendScore: 40.0,
+			UpdatedAt:      now,
+		},
+		stockviewer.Stock{
+			ID:             "test-id-5",
+			Ticker:         "GOOGL",
+			Company:        "Alphabet Inc.",
+			Brokerage:      "JP Morgan",
+			Action:         "target raised by",
+			RatingTo:       "Buy",
+			RecommendScore: 88.0,
+			UpdatedAt:      now.Add(-time.Hour),
+		},
+	)
+	service := NewService(mockRepo, WithLatestPerTicker(true))
+
+	recommendations, err := topRecommendations(context.Background(), service, stockviewer.RecommendationFilter{PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, rec := range recommendations {
+		if seen[rec.Stock.Ticker] {
+			t.Errorf("duplicate ticker %s in collapsed recommendations", rec.Stock.Ticker)
+		}
+		seen[rec.Stock.Ticker] = true
+
+		if rec.Stock.Ticker == "AAPL" && rec.Stock.ID != "test-id-4" {
+			t.Errorf("expected latest AAPL entry test-id-4, got %s", rec.Stock.ID)
+		}
+	}
+
+	if len(recommendations) != 3 {
+		t.Errorf("expected 3 distinct tickers, got %d", len(recommendations))
+	}
+}
+
+func TestActionScores_CoverEveryAction(t *testing.T) {
+	actions := stockviewer.Actions()
+	for _, action := range actions {
+		if _, ok := actionScores[action]; !ok {
+			t.Errorf("action %q has no score", action)
+		}
+	}
+	if len(actionScores) != len(actions) {
+		t.Errorf("expected %d action scores, got %d", len(actions), len(actionScores))
+	}
+}
+
+func TestScoreWeights_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights ScoreWeights
+		wantErr bool
+	}{
+		{"defaults", DefaultScoreWeights(), false},
+		{"custom valid", ScoreWeights{Rating: 0.5, Action: 0.3, PriceTarget: 0.2}, false},
+		{"sum too low", ScoreWeights{Rating: 0.4, Action: 0.3, PriceTarget: 0.2}, true},
+		{"negative weight", ScoreWeights{Rating: 1.2, Action: -0.2, PriceTarget: 0}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.weights.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCalculateScore_CustomWeights(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	stock := stockviewer.Stock{
+		RatingTo:   "Buy",
+		Action:     "downgraded by",
+		TargetFrom: stockviewer.Price(100),
+		TargetTo:   stockviewer.Price(100),
+	}
+
+	defaultScore := NewService(mockRepo).CalculateScore(stock)
+	ratingHeavy := NewService(mockRepo, WithScoreWeights(ScoreWeights{
+		Rating:      0.8,
+		Action:      0.1,
+		PriceTarget: 0.1,
+	})).CalculateScore(stock)
+
+	if ratingHeavy <= defaultScore {
+		t.Errorf("expected rating-heavy weights to raise the score of a Buy rating, got %.2f <= %.2f",
+			ratingHeavy, defaultScore)
+	}
+}
+
+func TestCalculateScore_Rounding(t *testing.T) {
+	// Buy (100*0.5), target raised by (100*0.4375) and +20% target
+	// (70*0.0625) sum to exactly 98.125.
+	weights := ScoreWeights{Rating: 0.5, Action: 0.4375, PriceTarget: 0.0625}
+	stock := stockviewer.Stock{
+		RatingTo:   "Buy",
+		Action:     "target raised by",
+		TargetFrom: stockviewer.Price(100),
+		TargetTo:   stockviewer.Price(120),
+	}
+
+	for rounding, want := range map[stockviewer.Rounding]float64{
+		stockviewer.RoundHalfUp:   98.13,
+		stockviewer.RoundHalfEven: 98.12,
+		stockviewer.RoundFloor:    98.12,
+	} {
+		service := NewService(mocks.NewMockStocksRepository(), WithScoreWeights(weights), WithRounding(rounding))
+		if got := service.CalculateScore(stock); got != want {
+			t.Errorf("%s: expected %.2f, got %v", rounding, want, got)
+		}
+	}
+}
+
+func TestGetTopRecommendations_Breakdown(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo)
+
+	recommendations, err := topRecommendations(context.Background(), service, stockviewer.RecommendationFilter{PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, rec := range recommendations {
+		b := rec.Breakdown
+		sum := b.RatingComponent + b.ActionComponent + b.PriceTargetComponent
+		if math.Abs(sum-rec.Score) > 0.02 {
+			t.Errorf("%s: components sum to %.2f, score is %.2f", rec.Stock.Ticker, sum, rec.Score)
+		}
+	}
+
+	// AAPL: Buy (100*0.40), target raised by (100*0.35), +20% target (70*0.25).
+	aapl := recommendations[0]
+	for _, rec := range recommendations {
+		if rec.Stock.Ticker == "AAPL" {
+			aapl = rec
+		}
+	}
+	want := stockviewer.ScoreBreakdown{RatingComponent: 40, ActionComponent: 35, PriceTargetComponent: 17.5, RecencyFactor: 1}
+	if *aapl.Breakdown != want {
+		t.Errorf("expected breakdown %+v, got %+v", want, aapl.Breakdown)
+	}
+}
+
+func TestCalculateScore_RecencyDecay(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository())
+	base := stockviewer.Stock{RatingTo: "Buy", Action: "upgraded by", TargetFrom: stockviewer.Price(100), TargetTo: stockviewer.Price(130)}
+	fresh := service.CalculateScore(base)
+
+	tests := []struct {
+		name   string
+		age    time.Duration
+		factor float64
+	}{
+		{"first seen now", 0, 1.0},
+		{"one day old", 24 * time.Hour, 1 - 1.0/365},
+		{"six months old", 182 * 24 * time.Hour, 1 - 182.0/365},
+		{"one year old", 365 * 24 * time.Hour, 0.5},
+		{"three years old", 3 * 365 * 24 * time.Hour, 0.5},
+	}
+
+	previous := math.Inf(1)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stock := base
+			stock.CreatedAt = time.Now().Add(-tt.age)
+			// Syncs rewrite updated_at; only the first-seen time ages a score.
+			stock.UpdatedAt = time.Now()
+
+			score := service.CalculateScore(stock)
+			if math.Abs(score-fresh*tt.factor) > 0.02 {
+				t.Errorf("expected score %.2f, got %.2f", fresh*tt.factor, score)
+			}
+			if score > previous {
+				t.Errorf("expected score to not increase with age, got %.2f after %.2f", score, previous)
+			}
+			previous = score
+		})
+	}
+}
+
+func TestCalculateScore_RecencyDecayDays(t *testing.T) {
+	stock := stockviewer.Stock{RatingTo: "Buy", Action: "upgraded by", CreatedAt: time.Now().Add(-30 * 24 * time.Hour)}
+
+	standard := NewService(mocks.NewMockStocksRepository()).CalculateScore(stock)
+	fast := NewService(mocks.NewMockStocksRepository(), WithRecencyDecayDays(60)).CalculateScore(stock)
+
+	if fast >= standard {
+		t.Errorf("expected a shorter decay to lower the score, got %.2f >= %.2f", fast, standard)
+	}
+}
+
+func TestAssignRanks(t *testing.T) {
+	tests := []struct {
+		name    string
+		scores  []float64
+		epsilon float64
+		want    []int
+	}{
+		{"distinct without epsilon", []float64{90, 90, 80}, 0, []int{1, 2, 3}},
+		{"near-equal scores tie", []float64{90, 89.95, 80, 79.99}, 0.1, []int{1, 1, 3, 3}},
+		{"competition ranking", []float64{95, 90, 89.99, 70}, 0.1, []int{1, 2, 2, 4}},
+		{"gap at epsilon is not a tie", []float64{90, 89.5}, 0.5, []int{1, 2}},
+		{"ties measured from group leader", []float64{90, 89.96, 89.88, 89.84}, 0.1, []int{1, 1, 3, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recommendations := make([]stockviewer.StockRecommendation, len(tt.scores))
+			for i, score := range tt.scores {
+				recommendations[i].Score = score
+			}
+
+			assignRanks(recommendations, tt.epsilon)
+
+			for i, rec := range recommendations {
+				if rec.Rank != tt.want[i] {
+					t.Errorf("position %d: expected rank %d, got %d", i, tt.want[i], rec.Rank)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTopRecommendations_TieEpsilon(t *testing.T) {
+	// a and b differ only by a day of recency decay.
+	now := time.Now()
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = []stockviewer.Stock{
+		{ID: "a", Ticker: "AAA", Action: "upgraded by", RatingTo: "Buy", TargetFrom: stockviewer.Price(100), TargetTo: stockviewer.Price(120), UpdatedAt: now},
+		{ID: "b", Ticker: "BBB", Action: "upgraded by", RatingTo: "Buy", TargetFrom: stockviewer.Price(100), TargetTo: stockviewer.Price(120), UpdatedAt: now.Add(-24 * time.Hour)},
+		{ID: "c", Ticker: "CCC", Action: "downgraded by", RatingTo: "Sell", TargetFrom: stockviewer.Price(100), TargetTo: stockviewer.Price(80), UpdatedAt: now},
+	}
+
+	tests := []struct {
+		name    string
+		epsilon float64
+		want    []int
+	}{
+		{"disabled", 0, []int{1, 2, 3}},
+		{"near-equal scores share a rank", 0.5, []int{1, 1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewService(mockRepo, WithTieEpsilon(tt.epsilon))
+
+			recommendations, err := topRecommendations(context.Background(), service, stockviewer.RecommendationFilter{PageSize: 3})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(recommendations) != 3 {
+				t.Fatalf("expected 3 recommendations, got %d", len(recommendations))
+			}
+			for i, rec := range recommendations {
+				if rec.Rank != tt.want[i] {
+					t.Errorf("position %d (%s, score %.4f): expected rank %d, got %d", i, rec.Stock.ID, rec.Score, tt.want[i], rec.Rank)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateReason_Preferences(t *testing.T) {
+	stock := stockviewer.Stock{RatingTo: "Buy", Action: "target raised by", TargetFrom: stockviewer.Price(1000), TargetTo: stockviewer.Price(1234.5)}
+	prefs := stockviewer.DefaultRequestContext()
+
+	if got := generateReason(stock, prefs); got != "Strong buy recommendation from analyst. Price target recently increased. Significant upside potential in price target" {
+		t.Errorf("unexpected normal reason: %q", got)
+	}
+
+	prefs.Verbosity = stockviewer.VerbosityCompact
+	if got := generateReason(stock, prefs); got != "Strong buy recommendation from analyst" {
+		t.Errorf("expected a single sentence when compact, got %q", got)
+	}
+
+	prefs.Locale = stockviewer.LocaleSpanish
+	prefs.Verbosity = stockviewer.VerbosityFull
+	want := "Fuerte recomendación de compra del analista. Precio objetivo aumentado recientemente. " +
+		"Potencial de suba significativo en el precio objetivo. El precio objetivo pasó de US$ 1.000,00 a US$ 1.234,50"
+	if got := generateReason(stock, prefs); got != want {
+		t.Errorf("unexpected full Spanish reason:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestGetTopRecommendations_Locale(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository())
+	ctx := stockviewer.WithRequestContext(context.Background(), stockviewer.RequestContext{Locale: stockviewer.LocaleSpanish})
+
+	recommendations, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, rec := range recommendations {
+		for _, sentence := range strings.Split(rec.Reason, ". ") {
+			if !containsValue(reasonCatalog[stockviewer.LocaleSpanish], sentence) {
+				t.Errorf("%s: expected Spanish reasons, got %q", rec.Stock.Ticker, rec.Reason)
+			}
+		}
+	}
+}
+
+func containsValue(messages map[reasonKey]string, sentence string) bool {
+	for _, message := range messages {
+		if message == sentence {
+			return true
+		}
+	}
+	return false
+}
+
+func coldStartRepo() *mocks.MockStocksRepository {
+	now := time.Now()
+	old := now.Add(-90 * 24 * time.Hour)
+	return &mocks.MockStocksRepository{Stocks: []stockviewer.Stock{
+		{ID: "aapl-1", Ticker: "AAPL", Action: "upgraded by", RatingTo: "Buy", RecommendScore: 80, CreatedAt: old, UpdatedAt: now},
+		{ID: "aapl-2", Ticker: "AAPL", Action: "target raised by", RatingTo: "Buy", RecommendScore: 85, CreatedAt: old.Add(time.Hour), UpdatedAt: now},
+		{ID: "newb-1", Ticker: "NEWB", Action: "initiated by", RatingTo: "Hold", TargetFrom: stockviewer.Price(10), TargetTo: stockviewer.Price(20), RecommendScore: 95, CreatedAt: now, UpdatedAt: now},
+		{ID: "newc-1", Ticker: "NEWC", Action: "initiated by", RatingTo: "Buy", TargetFrom: stockviewer.Price(10), TargetTo: stockviewer.Price(11), RecommendScore: 90, CreatedAt: now, UpdatedAt: now},
+		// Enough records, but all of them recent.
+		{ID: "newd-1", Ticker: "NEWD", Action: "initiated by", RatingTo: "Buy", TargetFrom: stockviewer.Price(10), TargetTo: stockviewer.Price(15), RecommendScore: 70, CreatedAt: now.Add(-48 * time.Hour), UpdatedAt: now},
+		{ID: "newd-2", Ticker: "NEWD", Action: "target raised by", RatingTo: "Buy", TargetFrom: stockviewer.Price(15), TargetTo: stockviewer.Price(18), RecommendScore: 75, CreatedAt: now, UpdatedAt: now},
+	}}
+}
+
+func TestConfidence(t *testing.T) {
+	rated := stockviewer.Stock{RatingTo: "Buy", TargetFrom: stockviewer.Price(100), TargetTo: stockviewer.Price(130)}
+	unrated := stockviewer.Stock{RatingTo: "Top Pick"}
+	agreed := stockviewer.TargetConsensus{Count: 6, Mean: 130, Spread: 0}
+	tests := []struct {
+		name       string
+		stock      stockviewer.Stock
+		baseline   float64
+		brokerages int64
+		consensus  stockviewer.TargetConsensus
+		want       float64
+	}{
+		{"data rich", rated, 100, 8, agreed, 1},
+		{"full coverage", rated, 100, confidenceFullCoverage, agreed, 1},
+		{"data poor", unrated, 0, 1, stockviewer.TargetConsensus{}, 0.05},
+		{"no coverage read", unrated, 0, 0, stockviewer.TargetConsensus{}, 0},
+		{"rating only", stockviewer.Stock{RatingTo: "Hold"}, 0, 0, stockviewer.TargetConsensus{}, 0.35},
+		{"targets without a baseline", rated, 0, 2, stockviewer.TargetConsensus{}, 0.45},
+		{"targets only", stockviewer.Stock{RatingTo: "Top Pick", TargetTo: stockviewer.Price(50)}, 40, 3, stockviewer.TargetConsensus{}, 0.4},
+		{"spread of half the mean", rated, 100, 5, stockviewer.TargetConsensus{Count: 4, Mean: 120, Spread: 60}, 0.93},
+		{"spread beyond the mean", rated, 100, 5, stockviewer.TargetConsensus{Count: 4, Mean: 100, Spread: 150}, 0.85},
+		{"a single target", rated, 100, 5, stockviewer.TargetConsensus{Count: 1, Mean: 130}, 0.85},
+	}
+	for _, tt := range tests {
+		coverage := stockviewer.TickerCoverage{Brokerages: tt.brokerages}
+		if got := confidence(tt.stock, tt.baseline, coverage, tt.consensus, stockviewer.RoundHalfUp); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestGetTopRecommendations_Confidence(t *testing.T) {
+	now := time.Now()
+	repo := &mocks.MockStocksRepository{}
+	for i := 0; i < 5; i++ {
+		repo.Stocks = append(repo.Stocks, stockviewer.Stock{
+			ID: fmt.Sprintf("rich-%d", i), Ticker: "RICH", Brokerage: fmt.Sprintf("Brokerage %d", i),
+			Action: "reiterated by", RatingTo: "Hold",
+			TargetFrom: stockviewer.Price(100), TargetTo: stockviewer.Price(102),
+			RecommendScore: 50, CreatedAt: now.Add(-time.Duration(i) * time.Hour), UpdatedAt: now,
+		})
+	}
+	// As many entries as RICH, but all from one brokerage whose targets
+	// swing from 40 to 160.
+	for i := 0; i < 5; i++ {
+		repo.Stocks = append(repo.Stocks, stockviewer.Stock{
+			ID: fmt.Sprintf("split-%d", i), Ticker: "SPLIT", Brokerage: "Lone Brokerage",
+			Action: "reiterated by", RatingTo: "Hold",
+			TargetFrom: stockviewer.Price(100), TargetTo: stockviewer.Price(float64(40 + 30*i)),
+			RecommendScore: 40, CreatedAt: now.Add(-time.Duration(i) * time.Hour), UpdatedAt: now,
+		})
+	}
+	// One upgrade with an unknown rating and no targets outscores every
+	// other entry on little data.
+	repo.Stocks = append(repo.Stocks, stockviewer.Stock{
+		ID: "poor-1", Ticker: "POOR", Brokerage: "Lone Brokerage", Action: "upgraded by", RatingTo: "Top Pick",
+		RecommendScore: 90, CreatedAt: now, UpdatedAt: now,
+	})
+
+	recommendations, err := topRecommendations(context.Background(), NewService(repo), stockviewer.RecommendationFilter{PageSize: 20})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recommendations) != 11 || recommendations[0].Stock.ID != "poor-1" {
+		t.Fatalf("expected poor-1 ranked first of 11, got %+v", recommendations)
+	}
+	// RICH targets agree within 0; SPLIT's spread of 120 exceeds its mean
+	// of 100, and a single brokerage earns a fifth of the analysts weight.
+	want := map[string]float64{"RICH": 1, "SPLIT": 0.65, "POOR": 0.05}
+	for _, rec := range recommendations {
+		if rec.Confidence != want[rec.Stock.Ticker] {
+			t.Errorf("%s: expected confidence %v, got %v", rec.Stock.ID, want[rec.Stock.Ticker], rec.Confidence)
+		}
+	}
+}
+
+func TestGetNewCoverage_ZeroLimitUsesDefault(t *testing.T) {
+	repo := &mocks.MockStocksRepository{}
+	now := time.Now()
+	for i := 0; i < stockviewer.DefaultRecommendationLimit+5; i++ {
+		repo.Stocks = append(repo.Stocks, stockviewer.Stock{
+			ID: fmt.Sprintf("new-%d", i), Ticker: fmt.Sprintf("N%02d", i), Action: "initiated by", RatingTo: "Buy", CreatedAt: now, UpdatedAt: now,
+		})
+	}
+	service := NewService(repo, WithColdStart(2, 30))
+
+	for _, limit := range []int{0, -1, stockviewer.MaxRecommendations + 1} {
+		recommendations, err := service.GetNewCoverage(context.Background(), limit)
+		if err != nil {
+			t.Fatalf("limit %d: unexpected error: %v", limit, err)
+		}
+		if len(recommendations) != stockviewer.DefaultRecommendationLimit {
+			t.Errorf("limit %d: expected the default of %d, got %d", limit, stockviewer.DefaultRecommendationLimit, len(recommendations))
+		}
+	}
+}
+
+func TestGetTopRecommendations_ColdStart(t *testing.T) {
+	service := NewService(coldStartRepo(), WithColdStart(2, 30))
+	ctx := context.Background()
+
+	main, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, rec := range main {
+		if rec.Stock.Ticker != "AAPL" || rec.ColdStart {
+			t.Errorf("expected only established AAPL entries in the main ranking, got %s (cold_start=%v)", rec.Stock.Ticker, rec.ColdStart)
+		}
+	}
+	if len(main) != 2 {
+		t.Errorf("expected 2 AAPL entries, got %d", len(main))
+	}
+
+	all, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 10, IncludeNew: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cold := 0
+	for _, rec := range all {
+		if rec.ColdStart != (rec.Stock.Ticker != "AAPL") {
+			t.Errorf("%s: unexpected cold_start=%v", rec.Stock.Ticker, rec.ColdStart)
+		}
+		if rec.ColdStart {
+			cold++
+		}
+	}
+	if cold != 4 {
+		t.Errorf("expected the 4 cold-start entries with include_new, got %d", cold)
+	}
+}
+
+func TestGetNewCoverage(t *testing.T) {
+	ctx := context.Background()
+
+	recommendations, err := NewService(coldStartRepo(), WithColdStart(2, 30)).GetNewCoverage(ctx, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Buy initiations first, the larger latest upside breaking the tie, then
+	// the Hold initiation despite its higher score.
+	var got []string
+	for i, rec := range recommendations {
+		got = append(got, rec.Stock.ID)
+		if !rec.ColdStart || rec.Rank != i+1 {
+			t.Errorf("%s: expected cold_start with rank %d, got %v and %d", rec.Stock.ID, i+1, rec.ColdStart, rec.Rank)
+		}
+	}
+	if want := []string{"newd-2", "newc-1", "newb-1"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	disabled, err := NewService(coldStartRepo()).GetNewCoverage(ctx, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(disabled) != 0 {
+		t.Errorf("expected no new coverage without thresholds, got %d", len(disabled))
+	}
+}
+
+func TestGetTopRecommendations_CacheHit(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo)
+	ctx := context.Background()
+
+	first, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mockRepo.Stocks = nil
+
+	cached, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cached) != len(first) {
+		t.Errorf("expected %d cached recommendations, got %d", len(first), len(cached))
+	}
+}
+
+func TestGetTopRecommendations_CacheSharedAcrossPages(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo)
+	ctx := context.Background()
+
+	if _, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mockRepo.Stocks = nil
+
+	other, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{Page: 2, PageSize: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(other) == 0 {
+		t.Error("expected another page of the same ranking to be served from the cache")
+	}
+}
+
+func TestGetTopRecommendations_CacheExpires(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, WithCacheTTL(time.Minute))
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mockRepo.Stocks = nil
+
+	now = now.Add(59 * time.Second)
+	cached, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cached) == 0 {
+		t.Error("expected the entry to be served before the TTL")
+	}
+
+	now = now.Add(time.Second)
+	expired, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(expired) != 0 {
+		t.Errorf("expected the entry to expire after the TTL, got %d recommendations", len(expired))
+	}
+}
+
+func TestGetTopRecommendations_CacheInvalidated(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo)
+	ctx := context.Background()
+
+	if _, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mockRepo.Stocks = nil
+	service.InvalidateCache()
+
+	recommendations, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recommendations) != 0 {
+		t.Errorf("expected invalidation to force a fresh read, got %d recommendations", len(recommendations))
+	}
+}
+
+func TestGetTopRecommendations_CacheDisabled(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, WithCacheTTL(0))
+	ctx := context.Background()
+
+	if _, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mockRepo.Stocks = nil
+
+	recommendations, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recommendations) != 0 {
+		t.Errorf("expected a zero TTL to disable caching, got %d recommendations", len(recommendations))
+	}
+}
+
+func TestGetTopRecommendations_ByBrokerage(t *testing.T) {
+	now := time.Now()
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = []stockviewer.Stock{
+		{ID: "gs-1", Ticker: "AAPL", Brokerage: "Goldman Sachs", RatingTo: "Buy", RecommendScore: 90, UpdatedAt: now},
+		{ID: "ms-1", Ticker: "MSFT", Brokerage: "Morgan Stanley", RatingTo: "Strong Buy", RecommendScore: 95, UpdatedAt: now},
+		{ID: "gs-2", Ticker: "NVDA", Brokerage: "Goldman Sachs", RatingTo: "Hold", RecommendScore: 60, UpdatedAt: now},
+	}
+	service := NewService(mockRepo)
+
+	recommendations, err := topRecommendations(context.Background(), service, stockviewer.RecommendationFilter{Brokerage: " Goldman Sachs ", PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recommendations) != 2 {
+		t.Fatalf("expected 2 recommendations, got %d", len(recommendations))
+	}
+	for i, rec := range recommendations {
+		if rec.Stock.Brokerage != "Goldman Sachs" || rec.Rank != i+1 {
+			t.Errorf("expected Goldman Sachs pick ranked %d, got %s ranked %d", i+1, rec.Stock.Brokerage, rec.Rank)
+		}
+	}
+}
+
+func TestGetTopRecommendations_ByBrokerageLatestPerTicker(t *testing.T) {
+	now := time.Now()
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = []stockviewer.Stock{
+		{ID: "old", Ticker: "AAPL", Brokerage: "Goldman Sachs", RatingTo: "Buy", UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "new", Ticker: "AAPL", Brokerage: "Goldman Sachs", RatingTo: "Hold", UpdatedAt: now.Add(-time.Hour)},
+		{ID: "other", Ticker: "AAPL", Brokerage: "Morgan Stanley", RatingTo: "Sell", UpdatedAt: now},
+	}
+	service := NewService(mockRepo, WithLatestPerTicker(true))
+
+	recommendations, err := topRecommendations(context.Background(), service, stockviewer.RecommendationFilter{Brokerage: "Goldman Sachs", PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recommendations) != 1 || recommendations[0].Stock.ID != "new" {
+		t.Errorf("expected the brokerage's latest AAPL entry, got %+v", recommendations)
+	}
+}
+
+func TestGetTopRecommendations_ByBrokerageCachedSeparately(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo)
+	ctx := context.Background()
+
+	if _, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{PageSize: 5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recommendations, err := topRecommendations(ctx, service, stockviewer.RecommendationFilter{Brokerage: "JP Morgan", PageSize: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, rec := range recommendations {
+		if rec.Stock.Brokerage != "JP Morgan" {
+			t.Errorf("expected the brokerage's own candidates, got %s", rec.Stock.Brokerage)
+		}
+	}
+}
+
+func TestGetTopRecommendations_PriorTargetBaseline(t *testing.T) {
+	now := time.Now()
+	entry := func(id string, age time.Duration, from, to float64) stockviewer.Stock {
+		return stockviewer.Stock{
+			ID: id, Ticker: "REV", Action: "target raised by", RatingTo: "Buy",
+			TargetFrom: stockviewer.Price(from), TargetTo: stockviewer.Price(to),
+			CreatedAt: now.Add(-age), UpdatedAt: now,
+		}
+	}
+	// Each brokerage reports its own previous target, which lags the
+	// consensus: rev-2 is +33% on its own target_from but +20% on the
+	// prior 100, and rev-3 is +19% on its own but -21% on the prior 120.
+	repo := mocks.NewMockStocksRepository()
+	repo.Stocks = []stockviewer.Stock{
+		entry("rev-3", 0, 80, 95),
+		entry("rev-2", 24*time.Hour, 90, 120),
+		entry("rev-1", 48*time.Hour, 80, 100),
+	}
+
+	components := func(service *Service) map[string]float64 {
+		t.Helper()
+		recommendations, err := topRecommendations(context.Background(), service, stockviewer.RecommendationFilter{PageSize: 10})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := make(map[string]float64)
+		for _, rec := range recommendations {
+			got[rec.Stock.ID] = rec.Breakdown.PriceTargetComponent
+		}
+		return got
+	}
+
+	tests := []struct {
+		baseline PriceTargetBaseline
+		want     map[string]float64
+	}{
+		// 80, 80 and 70 points of the 0.25 weight.
+		{BaselineTargetFrom, map[string]float64{"rev-1": 20, "rev-2": 20, "rev-3": 17.5}},
+		// rev-1 has no earlier entry and keeps its target_from; rev-2 scores
+		// 70 and rev-3 scores 0.
+		{BaselinePriorTarget, map[string]float64{"rev-1": 20, "rev-2": 17.5, "rev-3": 0}},
+	}
+	for _, tt := range tests {
+		// A decay far longer than the entries' ages keeps recency out of
+		// the components.
+		got := components(NewService(repo, WithPriceTargetBaseline(tt.baseline), WithCacheTTL(0), WithRecencyDecayDays(1e9)))
+		for id, want := range tt.want {
+			if got[id] != want {
+				t.Errorf("%s %s: expected price target component %.2f, got %.2f", tt.baseline, id, want, got[id])
+			}
+		}
+	}
+}
+
+func TestHistoryPriorTargets(t *testing.T) {
+	now := time.Now()
+	stocks := []stockviewer.Stock{
+		{ID: "a-3", Ticker: "A", TargetTo: stockviewer.Price(30), CreatedAt: now},
+		{ID: "b-1", Ticker: "B", TargetTo: stockviewer.Price(500), CreatedAt: now.Add(-time.Hour)},
+		{ID: "a-2", Ticker: "A", TargetTo: stockviewer.Price(0), CreatedAt: now.Add(-time.Hour)},
+		{ID: "a-2b", Ticker: "A", TargetTo: stockviewer.Price(25), CreatedAt: now.Add(-time.Hour)},
+		{ID: "a-1", Ticker: "A", TargetTo: stockviewer.Price(10), CreatedAt: now.Add(-2 * time.Hour)},
+	}
+
+	got := historyPriorTargets(stocks)
+	// a-2 and a-2b share an instant, so both look back to a-1; a-2 has no
+	// target, so a-3 looks back to a-2b. b-1 is another ticker's first entry.
+	want := map[string]float64{"a-2": 10, "a-2b": 10, "a-3": 25}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for id, target := range want {
+		if got[id] != target {
+			t.Errorf("%s: expected prior %.0f, got %.0f", id, target, got[id])
+		}
+	}
+}
+
+func TestParsePriceTargetBaseline(t *testing.T) {
+	for value, want := range map[string]PriceTargetBaseline{
+		"":             BaselineTargetFrom,
+		"target_from":  BaselineTargetFrom,
+		"prior_target": BaselinePriorTarget,
+	} {
+		if got, err := ParsePriceTargetBaseline(value); err != nil || got != want {
+			t.Errorf("%q: expected %s, got %s (%v)", value, want, got, err)
+		}
+	}
+
+	var validationErr stockviewer.ValidationError
+	if _, err := ParsePriceTargetBaseline("previous"); !errors.As(err, &validationErr) {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
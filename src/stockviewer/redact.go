@@ -0,0 +1,65 @@
+package stockviewer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultErrorBodyLimit is how many bytes of an upstream error body are kept
+// in an ExternalAPIError when no other limit is configured.
+const DefaultErrorBodyLimit = 512
+
+const redacted = "[REDACTED]"
+
+// secretPatterns match credentials that upstream error pages tend to echo
+// back, such as our own Authorization header or API keys in query strings.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(authorization["']?\s*[:=]\s*["']?)(bearer|basic)?\s*[^\s"',;<&]+`),
+	regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`),
+	regexp.MustCompile(`(?i)((?:api[_-]?key|access[_-]?token|token|secret|password)["']?\s*[:=]\s*["']?)[^\s"',;<&]+`),
+}
+
+// RedactSecrets replaces known secret patterns and the given literal secrets
+// in s with a placeholder.
+func RedactSecrets(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			groups := pattern.FindStringSubmatch(match)
+			if len(groups) > 1 && !strings.EqualFold(groups[1], "bearer") && !strings.EqualFold(groups[1], "basic") {
+				return groups[1] + redacted
+			}
+			return redacted
+		})
+	}
+	return s
+}
+
+// SanitizeErrorBody prepares an upstream response body for inclusion in an
+// error: secrets are redacted first, then the result is cut to limit bytes.
+// A limit of zero or less uses DefaultErrorBodyLimit.
+func SanitizeErrorBody(body []byte, limit int, secrets ...string) string {
+	if limit <= 0 {
+		limit = DefaultErrorBodyLimit
+	}
+
+	clean := RedactSecrets(string(body), secrets...)
+	if len(clean) <= limit {
+		return clean
+	}
+
+	cut := limit
+	for cut > 0 && !isRuneStart(clean[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... (truncated %d bytes)", clean[:cut], len(clean)-cut)
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
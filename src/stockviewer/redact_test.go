@@ -0,0 +1,59 @@
+package stockviewer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		secret string
+		leak   string
+	}{
+		{"authorization header", "Authorization: Bearer abc.def.ghi", "", "abc.def.ghi"},
+		{"bare bearer token", "received token bearer eyJhbGciOi.payload", "", "eyJhbGciOi"},
+		{"json api key", `{"api_key":"sk-12345"}`, "", "sk-12345"},
+		{"query string token", "GET /list?token=s3cr3t&page=2", "", "s3cr3t"},
+		{"literal secret", "upstream echoed my-configured-token back", "my-configured-token", "my-configured-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RedactSecrets(tt.input, tt.secret)
+			if strings.Contains(got, tt.leak) {
+				t.Errorf("expected %q to be redacted, got %q", tt.leak, got)
+			}
+			if !strings.Contains(got, "[REDACTED]") {
+				t.Errorf("expected placeholder in %q", got)
+			}
+		})
+	}
+}
+
+func TestSanitizeErrorBody_Truncates(t *testing.T) {
+	body := []byte(strings.Repeat("<div>error</div>", 1000))
+
+	got := SanitizeErrorBody(body, 100)
+
+	if !strings.HasPrefix(got, string(body[:100])) {
+		t.Errorf("expected the first 100 bytes to be kept, got %q", got)
+	}
+	if !strings.HasSuffix(got, "... (truncated 15900 bytes)") {
+		t.Errorf("expected truncation marker, got %q", got[len(got)-40:])
+	}
+}
+
+func TestSanitizeErrorBody_ShortBodyUnchanged(t *testing.T) {
+	if got := SanitizeErrorBody([]byte("Service Unavailable"), 0); got != "Service Unavailable" {
+		t.Errorf("expected short body unchanged, got %q", got)
+	}
+}
+
+func TestSanitizeErrorBody_DoesNotSplitRunes(t *testing.T) {
+	got := SanitizeErrorBody([]byte("ñññ"), 3)
+	if !strings.HasPrefix(got, "ñ...") {
+		t.Errorf("expected cut on a rune boundary, got %q", got)
+	}
+}
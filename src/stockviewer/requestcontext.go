@@ -0,0 +1,167 @@
+package stockviewer
+
+import (
+	"context"
+	"strings"
+)
+
+// Locales the reason renderer has translations for.
+const (
+	LocaleEnglish = "en"
+	LocaleSpanish = "es"
+)
+
+// CurrencyUSD is the currency upstream price targets are quoted in. It is the
+// only supported display currency until exchange rates are available.
+const CurrencyUSD = "USD"
+
+// Verbosity controls how much detail responses and rendered reasons carry.
+type Verbosity string
+
+const (
+	VerbosityCompact Verbosity = "compact"
+	VerbosityNormal  Verbosity = "normal"
+	VerbosityFull    Verbosity = "full"
+)
+
+// AuthTier is the access level of the caller. It is derived from the
+// request's credentials and can never be chosen through a header or query
+// parameter.
+type AuthTier string
+
+const (
+	TierAnonymous     AuthTier = "anonymous"
+	TierAuthenticated AuthTier = "authenticated"
+)
+
+// Role is what an authenticated caller may do. Anonymous callers have none.
+type Role string
+
+const (
+	// RoleAdmin may also change data: trigger syncs, edit stocks and manage
+	// saved views.
+	RoleAdmin Role = "admin"
+	// RoleViewer may read everything, protected routes included, but change
+	// nothing.
+	RoleViewer Role = "viewer"
+)
+
+// ParseRole validates a role name.
+func ParseRole(value string) (Role, bool) {
+	switch role := Role(value); role {
+	case RoleAdmin, RoleViewer:
+		return role, true
+	}
+	return "", false
+}
+
+// RequestContext holds the per-request preferences the HTTP layer parses
+// once and attaches to the context.Context. Services read it through
+// RequestContextFrom and the typed accessors instead of looking at request
+// parameters themselves.
+type RequestContext struct {
+	Locale    string
+	Currency  string
+	Verbosity Verbosity
+	Tier      AuthTier
+	// Role is set only for TierAuthenticated callers.
+	Role Role
+}
+
+// DefaultRequestContext returns the preferences of a request that states
+// none: English, USD, normal verbosity and anonymous access.
+func DefaultRequestContext() RequestContext {
+	return RequestContext{
+		Locale:    LocaleEnglish,
+		Currency:  CurrencyUSD,
+		Verbosity: VerbosityNormal,
+		Tier:      TierAnonymous,
+	}
+}
+
+// WithDefaults fills in any unset field from DefaultRequestContext.
+func (rc RequestContext) WithDefaults() RequestContext {
+	defaults := DefaultRequestContext()
+	if rc.Locale == "" {
+		rc.Locale = defaults.Locale
+	}
+	if rc.Currency == "" {
+		rc.Currency = defaults.Currency
+	}
+	if rc.Verbosity == "" {
+		rc.Verbosity = defaults.Verbosity
+	}
+	if rc.Tier == "" {
+		rc.Tier = defaults.Tier
+	}
+	return rc
+}
+
+type requestContextKey struct{}
+
+// WithRequestContext returns a copy of ctx carrying rc.
+func WithRequestContext(ctx context.Context, rc RequestContext) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, rc.WithDefaults())
+}
+
+// RequestContextFrom returns the preferences attached to ctx, or
+// DefaultRequestContext when there are none, as in background jobs.
+func RequestContextFrom(ctx context.Context) RequestContext {
+	if rc, ok := ctx.Value(requestContextKey{}).(RequestContext); ok {
+		return rc
+	}
+	return DefaultRequestContext()
+}
+
+// LocaleFrom returns the request's locale.
+func LocaleFrom(ctx context.Context) string {
+	return RequestContextFrom(ctx).Locale
+}
+
+// CurrencyFrom returns the request's display currency.
+func CurrencyFrom(ctx context.Context) string {
+	return RequestContextFrom(ctx).Currency
+}
+
+// VerbosityFrom returns the request's verbosity.
+func VerbosityFrom(ctx context.Context) Verbosity {
+	return RequestContextFrom(ctx).Verbosity
+}
+
+// TierFrom returns the caller's access level.
+func TierFrom(ctx context.Context) AuthTier {
+	return RequestContextFrom(ctx).Tier
+}
+
+// RoleFrom returns the caller's role, empty for anonymous callers.
+func RoleFrom(ctx context.Context) Role {
+	return RequestContextFrom(ctx).Role
+}
+
+// MatchLocale maps a language tag such as "es-AR" to a supported locale.
+func MatchLocale(tag string) (string, bool) {
+	lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	switch lang {
+	case LocaleEnglish, LocaleSpanish:
+		return lang, true
+	}
+	return "", false
+}
+
+// ParseVerbosity validates a verbosity name, ignoring case.
+func ParseVerbosity(value string) (Verbosity, bool) {
+	switch v := Verbosity(strings.ToLower(strings.TrimSpace(value))); v {
+	case VerbosityCompact, VerbosityNormal, VerbosityFull:
+		return v, true
+	}
+	return "", false
+}
+
+// ParseCurrency validates an ISO 4217 currency code, ignoring case. Only
+// CurrencyUSD is accepted since amounts are never converted.
+func ParseCurrency(value string) (string, bool) {
+	if code := strings.ToUpper(strings.TrimSpace(value)); code == CurrencyUSD {
+		return code, true
+	}
+	return "", false
+}
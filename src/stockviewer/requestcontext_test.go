@@ -0,0 +1,51 @@
+package stockviewer
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRequestContextFrom_Defaults(t *testing.T) {
+	if got := RequestContextFrom(context.Background()); got != DefaultRequestContext() {
+		t.Errorf("expected defaults without a request context, got %+v", got)
+	}
+
+	ctx := WithRequestContext(context.Background(), RequestContext{Locale: LocaleSpanish})
+	want := DefaultRequestContext()
+	want.Locale = LocaleSpanish
+	if got := RequestContextFrom(ctx); got != want {
+		t.Errorf("expected unset fields to take defaults, got %+v", got)
+	}
+	if LocaleFrom(ctx) != LocaleSpanish || CurrencyFrom(ctx) != CurrencyUSD ||
+		VerbosityFrom(ctx) != VerbosityNormal || TierFrom(ctx) != TierAnonymous {
+		t.Errorf("accessors disagree with %+v", RequestContextFrom(ctx))
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	tests := map[string]string{"es-AR": LocaleSpanish, " EN ": LocaleEnglish, "es": LocaleSpanish, "fr-FR": "", "": ""}
+	for tag, want := range tests {
+		got, ok := MatchLocale(tag)
+		if got != want || ok != (want != "") {
+			t.Errorf("MatchLocale(%q) = %q, %v; want %q", tag, got, ok, want)
+		}
+	}
+}
+
+func TestPageLimitsFor(t *testing.T) {
+	limits := PageLimits{DefaultPageSize: 20, MaxPageSize: 100, AuthenticatedMaxPageSize: 500}
+	anonymous := context.Background()
+	authenticated := WithRequestContext(anonymous, RequestContext{Tier: TierAuthenticated})
+
+	if got := limits.For(anonymous).PageSize(1000); got != 100 {
+		t.Errorf("expected anonymous callers capped at 100, got %d", got)
+	}
+	if got := limits.For(authenticated).PageSize(1000); got != 500 {
+		t.Errorf("expected authenticated callers capped at 500, got %d", got)
+	}
+
+	limits.AuthenticatedMaxPageSize = 50
+	if got := limits.For(authenticated).PageSize(1000); got != 100 {
+		t.Errorf("expected a smaller authenticated cap not to lower the limit, got %d", got)
+	}
+}
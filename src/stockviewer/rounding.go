@@ -0,0 +1,51 @@
+package stockviewer
+
+import "math"
+
+// Rounding is how scores are rounded to two decimals.
+type Rounding string
+
+const (
+	// RoundHalfUp rounds halves away from zero: 2.125 becomes 2.13.
+	RoundHalfUp Rounding = "half_up"
+	// RoundHalfEven rounds halves to the even neighbor, as banks do: 2.125
+	// becomes 2.12 and 2.135 becomes 2.14.
+	RoundHalfEven Rounding = "half_even"
+	// RoundFloor drops everything past the second decimal: 2.129 becomes
+	// 2.12.
+	RoundFloor Rounding = "floor"
+)
+
+// roundingTolerance absorbs the binary error of scaling by 100: a scaled
+// value this close to a whole number or a half is taken as exactly that, so
+// 0.29 is not floored to 0.28 because 0.29*100 is 28.999999999999996, and
+// 2.135 is a half rather than 213.49999999999997.
+const roundingTolerance = 1e-9
+
+// ParseRounding validates a rounding name. An empty name is RoundHalfUp.
+func ParseRounding(value string) (Rounding, bool) {
+	switch r := Rounding(value); r {
+	case "":
+		return RoundHalfUp, true
+	case RoundHalfUp, RoundHalfEven, RoundFloor:
+		return r, true
+	}
+	return "", false
+}
+
+// Round rounds value to two decimals. The zero Rounding is RoundHalfUp.
+func (r Rounding) Round(value float64) float64 {
+	scaled := value * 100
+	if nearest := math.Round(scaled*2) / 2; math.Abs(scaled-nearest) < roundingTolerance {
+		scaled = nearest
+	}
+	switch r {
+	case RoundHalfEven:
+		scaled = math.RoundToEven(scaled)
+	case RoundFloor:
+		scaled = math.Floor(scaled)
+	default:
+		scaled = math.Round(scaled)
+	}
+	return scaled / 100
+}
@@ -0,0 +1,48 @@
+package stockviewer
+
+import "testing"
+
+func TestRounding_Round(t *testing.T) {
+	tests := []struct {
+		rounding Rounding
+		value    float64
+		want     float64
+	}{
+		// 2.125 and 0.375 are exact in binary, so these are true halves.
+		{RoundHalfUp, 2.125, 2.13},
+		{RoundHalfEven, 2.125, 2.12},
+		{RoundFloor, 2.125, 2.12},
+		{RoundHalfUp, 0.375, 0.38},
+		{RoundHalfEven, 0.375, 0.38},
+		{RoundFloor, 0.375, 0.37},
+		{"", 2.125, 2.13},
+		// 2.135 and 1.005 are stored just below the half; they still round
+		// as the decimal halves they are written as.
+		{RoundHalfUp, 2.135, 2.14},
+		{RoundHalfEven, 2.135, 2.14},
+		{RoundFloor, 2.135, 2.13},
+		{RoundHalfUp, 1.005, 1.01},
+		{RoundHalfEven, 1.005, 1},
+		{RoundHalfEven, 1.015, 1.02},
+		{RoundHalfUp, -1.005, -1.01},
+		{RoundFloor, 0.29, 0.29},
+		{RoundFloor, 99.999, 99.99},
+		{RoundHalfEven, 87.456, 87.46},
+	}
+	for _, tt := range tests {
+		if got := tt.rounding.Round(tt.value); got != tt.want {
+			t.Errorf("%q.Round(%v): expected %v, got %v", tt.rounding, tt.value, tt.want, got)
+		}
+	}
+}
+
+func TestParseRounding(t *testing.T) {
+	for value, want := range map[string]Rounding{"": RoundHalfUp, "half_up": RoundHalfUp, "half_even": RoundHalfEven, "floor": RoundFloor} {
+		if got, ok := ParseRounding(value); !ok || got != want {
+			t.Errorf("ParseRounding(%q): expected %q, got %q (ok=%v)", value, want, got, ok)
+		}
+	}
+	if _, ok := ParseRounding("ceil"); ok {
+		t.Error("expected ceil to be rejected")
+	}
+}
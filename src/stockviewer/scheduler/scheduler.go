@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+type Syncer interface {
+	SyncStocks(ctx context.Context) (*stockviewer.SyncStatus, error)
+}
+
+// Scheduler triggers a stock sync on a fixed interval until its context is
+// cancelled.
+type Scheduler struct {
+	syncer   Syncer
+	interval time.Duration
+}
+
+func New(syncer Syncer, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		syncer:   syncer,
+		interval: interval,
+	}
+}
+
+// Run blocks, syncing on every tick, and returns once ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	log.Printf("Scheduled sync enabled every %s", s.interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Scheduled sync stopped")
+			return
+		case <-ticker.C:
+			s.runSync(ctx)
+		}
+	}
+}
+
+// runSync performs a single scheduled sync and reports whether it was
+// skipped because another sync was already running.
+func (s *Scheduler) runSync(ctx context.Context) bool {
+	status, err := s.syncer.SyncStocks(ctx)
+	if errors.Is(err, stockviewer.ErrSyncInProgress) {
+		log.Println("Scheduled sync skipped: sync already in progress")
+		return true
+	}
+	if err != nil {
+		log.Printf("Scheduled sync failed: %v", err)
+		return false
+	}
+
+	log.Printf("Scheduled sync completed: %d records (%d new, %d updated)",
+		status.TotalRecords, status.NewRecords, status.UpdatedRecords)
+	return false
+}
@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+type fakeSyncer struct {
+	calls int32
+	err   error
+}
+
+func (f *fakeSyncer) SyncStocks(ctx context.Context) (*stockviewer.SyncStatus, error) {
+	atomic.AddInt32(&f.calls, 1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &stockviewer.SyncStatus{Status: "completed"}, nil
+}
+
+func TestRunSync_SkipsWhenInProgress(t *testing.T) {
+	syncer := &fakeSyncer{err: stockviewer.ErrSyncInProgress}
+	scheduler := New(syncer, time.Minute)
+
+	if skipped := scheduler.runSync(context.Background()); !skipped {
+		t.Error("expected scheduled sync to be skipped while another sync is in progress")
+	}
+}
+
+func TestRunSync_Completes(t *testing.T) {
+	syncer := &fakeSyncer{}
+	scheduler := New(syncer, time.Minute)
+
+	if skipped := scheduler.runSync(context.Background()); skipped {
+		t.Error("expected scheduled sync to run")
+	}
+	if atomic.LoadInt32(&syncer.calls) != 1 {
+		t.Errorf("expected 1 sync call, got %d", syncer.calls)
+	}
+}
+
+func TestRun_StopsOnCancel(t *testing.T) {
+	syncer := &fakeSyncer{}
+	scheduler := New(syncer, 5*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop after cancel")
+	}
+
+	if atomic.LoadInt32(&syncer.calls) == 0 {
+		t.Error("expected at least one scheduled sync")
+	}
+}
@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"log"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"gorm.io/gorm"
+)
+
+// Supported is the schema range this binary runs against. Min is the oldest
+// schema the code works with; bump it when code starts depending on a newer
+// migration. Max is the newest migration shipped in this binary.
+var Supported = Range{Min: 10, Max: 10}
+
+// Migrations returns the schema migrations in version order. Versions are
+// never reused or reordered once released. Adding a NOT NULL column without
+// a default, dropping or renaming anything is a contract migration and must
+// ship in a release after the code that stops needing the old shape.
+func Migrations() []Migration {
+	return []Migration{
+		{
+			Version: 1,
+			Name:    "create stocks and outbox events",
+			Kind:    stockviewer.MigrationExpand,
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&stockviewer.Stock{}, &stockviewer.OutboxEvent{})
+			},
+		},
+		{
+			Version: 2,
+			Name:    "create saved views",
+			Kind:    stockviewer.MigrationExpand,
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&stockviewer.SavedView{})
+			},
+		},
+		{
+			Version: 3,
+			Name:    "add stock notes and score override flag",
+			Kind:    stockviewer.MigrationExpand,
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&stockviewer.Stock{})
+			},
+		},
+		{
+			Version: 4,
+			Name:    "create sync runs",
+			Kind:    stockviewer.MigrationExpand,
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&stockviewer.SyncRun{})
+			},
+		},
+		{
+			Version: 5,
+			Name:    "add stock sector and industry",
+			Kind:    stockviewer.MigrationExpand,
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&stockviewer.Stock{})
+			},
+		},
+		{
+			Version: 6,
+			Name:    "add stock data issues and sync run schema warnings",
+			Kind:    stockviewer.MigrationExpand,
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&stockviewer.Stock{}, &stockviewer.SyncRun{})
+			},
+		},
+		{
+			Version: 7,
+			Name:    "create dead letters",
+			Kind:    stockviewer.MigrationExpand,
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&stockviewer.DeadLetter{})
+			},
+		},
+		{
+			Version: 8,
+			Name:    "add stock search vector",
+			Kind:    stockviewer.MigrationExpand,
+			// The column is generated by the database and never read or
+			// written through the Stock model, which leaves it out so that
+			// AutoMigrate in earlier migrations does not create it as a plain
+			// column.
+			Up: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE stocks ADD COLUMN IF NOT EXISTS search_vector tsvector " +
+					"GENERATED ALWAYS AS (to_tsvector('english', ticker || ' ' || company)) STORED").Error; err != nil {
+					return err
+				}
+				return tx.Exec("CREATE INDEX IF NOT EXISTS idx_stocks_search_vector ON stocks USING GIN (search_vector)").Error
+			},
+		},
+		{
+			Version: 9,
+			Name:    "add stock ticker and company trigram indexes",
+			Kind:    stockviewer.MigrationExpand,
+			// The indexes serve the ILIKE substring matches of search and the
+			// ticker and company filters. Where pg_trgm cannot be enabled the
+			// nested transaction rolls back to its savepoint and the
+			// migration is recorded without them: ILIKE still works, only
+			// without an index.
+			Up: func(tx *gorm.DB) error {
+				err := tx.Transaction(func(tx *gorm.DB) error {
+					if err := tx.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+						return err
+					}
+					if err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_stocks_ticker_trigram ON stocks USING GIN (ticker gin_trgm_ops)").Error; err != nil {
+						return err
+					}
+					return tx.Exec("CREATE INDEX IF NOT EXISTS idx_stocks_company_trigram ON stocks USING GIN (company gin_trgm_ops)").Error
+				})
+				if err != nil {
+					log.Printf("pg_trgm is not available, substring search runs without trigram indexes: %v", err)
+				}
+				return nil
+			},
+		},
+		{
+			Version: 10,
+			Name:    "add sync run upstream failure",
+			Kind:    stockviewer.MigrationExpand,
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&stockviewer.SyncRun{})
+			},
+		},
+	}
+}
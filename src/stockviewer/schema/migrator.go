@@ -0,0 +1,121 @@
+// Package schema applies versioned database migrations and guards rolling
+// deploys against schemas the running binary cannot work with.
+package schema
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"gorm.io/gorm"
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Kind    stockviewer.MigrationKind
+	Up      func(tx *gorm.DB) error
+}
+
+// Range is the span of schema versions a binary supports.
+type Range struct {
+	Min int
+	Max int
+}
+
+// Store persists which migrations have been applied.
+type Store interface {
+	Applied(ctx context.Context) ([]stockviewer.AppliedMigration, error)
+	Apply(ctx context.Context, migration Migration) error
+}
+
+type Migrator struct {
+	store         Store
+	migrations    []Migration
+	supported     Range
+	allowContract bool
+}
+
+// Option customizes optional Migrator settings.
+type Option func(*Migrator)
+
+// WithAllowContract lets Migrate apply contract migrations. Only enable it
+// once every running pod is on a binary that supports them.
+func WithAllowContract(allow bool) Option {
+	return func(m *Migrator) {
+		m.allowContract = allow
+	}
+}
+
+func NewMigrator(store Store, migrations []Migration, supported Range, opts ...Option) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	m := &Migrator{
+		store:      store,
+		migrations: sorted,
+		supported:  supported,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Migrate applies pending migrations in version order and then checks the
+// resulting schema is compatible. A contract migration stops the run unless
+// contract migrations are allowed, since later migrations may depend on it.
+func (m *Migrator) Migrate(ctx context.Context) error {
+	applied, err := m.store.Applied(ctx)
+	if err != nil {
+		return err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, migration := range applied {
+		done[migration.Version] = true
+	}
+
+	for _, migration := range m.migrations {
+		if done[migration.Version] {
+			continue
+		}
+		if migration.Kind == stockviewer.MigrationContract && !m.allowContract {
+			log.Printf("Schema migration %d (%s) is a contract migration; set ALLOW_CONTRACT_MIGRATIONS=true once all pods run this version", migration.Version, migration.Name)
+			break
+		}
+		if err := m.store.Apply(ctx, migration); err != nil {
+			return fmt.Errorf("schema migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("Applied %s schema migration %d (%s)", migration.Kind, migration.Version, migration.Name)
+	}
+
+	return m.Check(ctx)
+}
+
+// Check reports whether the live schema is within the supported range. A
+// schema ahead of Max is fine as long as the extra migrations are all
+// expand migrations.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.store.Applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	live := 0
+	for _, migration := range applied {
+		if migration.Version > live {
+			live = migration.Version
+		}
+		if migration.Version > m.supported.Max && migration.Kind == stockviewer.MigrationContract {
+			return fmt.Errorf("%w: migration %d (%s), binary supports up to %d",
+				stockviewer.ErrSchemaTooNew, migration.Version, migration.Name, m.supported.Max)
+		}
+	}
+	if live < m.supported.Min {
+		return fmt.Errorf("%w: live version %d, binary needs at least %d",
+			stockviewer.ErrSchemaTooOld, live, m.supported.Min)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"gorm.io/gorm"
+)
+
+// memoryStore records applied migrations without running them.
+type memoryStore struct {
+	applied []stockviewer.AppliedMigration
+}
+
+func (s *memoryStore) Applied(ctx context.Context) ([]stockviewer.AppliedMigration, error) {
+	return append([]stockviewer.AppliedMigration(nil), s.applied...), nil
+}
+
+func (s *memoryStore) Apply(ctx context.Context, migration Migration) error {
+	s.applied = append(s.applied, stockviewer.AppliedMigration{
+		Version: migration.Version,
+		Name:    migration.Name,
+		Kind:    migration.Kind,
+	})
+	return nil
+}
+
+func (s *memoryStore) has(version int) bool {
+	for _, migration := range s.applied {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func noop(tx *gorm.DB) error { return nil }
+
+// Release 1 ships migrations 1-2. Release 2 adds an expand migration (3).
+// Release 3 drops what release 1 needed (contract, 4) and depends on it.
+var (
+	release1 = []Migration{
+		{Version: 1, Name: "create stocks", Kind: stockviewer.MigrationExpand, Up: noop},
+		{Version: 2, Name: "create views", Kind: stockviewer.MigrationExpand, Up: noop},
+	}
+	release2 = append(release1[:2:2],
+		Migration{Version: 3, Name: "add nullable column", Kind: stockviewer.MigrationExpand, Up: noop},
+	)
+	release3 = append(release2[:3:3],
+		Migration{Version: 4, Name: "drop legacy column", Kind: stockviewer.MigrationContract, Up: noop},
+	)
+)
+
+func storeAt(migrations []Migration) *memoryStore {
+	store := &memoryStore{}
+	for _, migration := range migrations {
+		store.Apply(context.Background(), migration)
+	}
+	return store
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name          string
+		schema        []Migration
+		binary        []Migration
+		supported     Range
+		allowContract bool
+		wantErr       error
+		wantApplied   []int
+		wantPending   []int
+	}{
+		{
+			name:        "fresh database",
+			binary:      release1,
+			supported:   Range{Min: 2, Max: 2},
+			wantApplied: []int{1, 2},
+		},
+		{
+			name:        "new binary applies expand migration to old schema",
+			schema:      release1,
+			binary:      release2,
+			supported:   Range{Min: 2, Max: 3},
+			wantApplied: []int{3},
+		},
+		{
+			name:      "old binary starts against expanded schema",
+			schema:    release2,
+			binary:    release1,
+			supported: Range{Min: 2, Max: 2},
+		},
+		{
+			name:      "old binary refuses contracted schema",
+			schema:    release3,
+			binary:    release2,
+			supported: Range{Min: 2, Max: 3},
+			wantErr:   stockviewer.ErrSchemaTooNew,
+		},
+		{
+			name:        "contract migration held back while code still supports old schema",
+			schema:      release2,
+			binary:      release3,
+			supported:   Range{Min: 3, Max: 4},
+			wantPending: []int{4},
+		},
+		{
+			name:        "contract migration held back when code requires it",
+			schema:      release2,
+			binary:      release3,
+			supported:   Range{Min: 4, Max: 4},
+			wantErr:     stockviewer.ErrSchemaTooOld,
+			wantPending: []int{4},
+		},
+		{
+			name:          "contract migration applied when allowed",
+			schema:        release2,
+			binary:        release3,
+			supported:     Range{Min: 4, Max: 4},
+			allowContract: true,
+			wantApplied:   []int{4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storeAt(tt.schema)
+			migrator := NewMigrator(store, tt.binary, tt.supported, WithAllowContract(tt.allowContract))
+
+			err := migrator.Migrate(context.Background())
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			for _, version := range tt.wantApplied {
+				if !store.has(version) {
+					t.Errorf("expected migration %d to be applied", version)
+				}
+			}
+			for _, version := range tt.wantPending {
+				if store.has(version) {
+					t.Errorf("expected migration %d to stay pending", version)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrate_StopsAtContractMigration(t *testing.T) {
+	binary := append(release3[:4:4],
+		Migration{Version: 5, Name: "add index", Kind: stockviewer.MigrationExpand, Up: noop},
+	)
+	store := storeAt(release2)
+
+	err := NewMigrator(store, binary, Range{Min: 3, Max: 5}).Migrate(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if store.has(4) || store.has(5) {
+		t.Errorf("expected migrations after the held contract migration to wait, got %+v", store.applied)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	store := storeAt(release2)
+
+	if err := NewMigrator(store, release2, Range{Min: 2, Max: 3}).Check(context.Background()); err != nil {
+		t.Errorf("expected compatible schema, got %v", err)
+	}
+
+	store.applied = append(store.applied, stockviewer.AppliedMigration{Version: 4, Kind: stockviewer.MigrationContract})
+	err := NewMigrator(store, release2, Range{Min: 2, Max: 3}).Check(context.Background())
+	if !errors.Is(err, stockviewer.ErrSchemaTooNew) {
+		t.Errorf("expected ErrSchemaTooNew after a newer contract migration, got %v", err)
+	}
+}
+
+func TestMigrations_Supported(t *testing.T) {
+	migrations := Migrations()
+	latest := migrations[len(migrations)-1].Version
+
+	if Supported.Max != latest {
+		t.Errorf("expected Supported.Max to be the latest migration %d, got %d", latest, Supported.Max)
+	}
+	if Supported.Min > Supported.Max {
+		t.Errorf("expected Supported.Min <= Max, got %+v", Supported)
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("expected contiguous versions, got %d at position %d", migration.Version, i)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"gorm.io/gorm"
+)
+
+// Storage keeps the migration history in the schema_migrations table.
+type Storage struct {
+	db *gorm.DB
+}
+
+// NewStorage creates the schema_migrations table if needed. That table is the
+// only one created outside the versioned migrations.
+func NewStorage(db *gorm.DB) (*Storage, error) {
+	if err := db.AutoMigrate(&stockviewer.AppliedMigration{}); err != nil {
+		return nil, stockviewer.StorageError{Operation: "migrate_schema_migrations", Err: err}
+	}
+	return &Storage{db: db}, nil
+}
+
+func (s *Storage) Applied(ctx context.Context) ([]stockviewer.AppliedMigration, error) {
+	var applied []stockviewer.AppliedMigration
+	result := s.db.WithContext(ctx).Order("version ASC").Find(&applied)
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_applied_migrations", Err: result.Error}
+	}
+	return applied, nil
+}
+
+// Apply runs the migration and records it in the same transaction.
+func (s *Storage) Apply(ctx context.Context, migration Migration) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&stockviewer.AppliedMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Kind:      migration.Kind,
+			AppliedAt: time.Now().UTC(),
+		}).Error
+	})
+	if err != nil {
+		return stockviewer.StorageError{Operation: "apply_migration", Err: err}
+	}
+	return nil
+}
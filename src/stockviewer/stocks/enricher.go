@@ -0,0 +1,50 @@
+package stocks
+
+import (
+	"context"
+	"log"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+// NoopEnricher is the default stockviewer.StockEnricher; it knows no sectors
+// or industries, so enabling enrichment with it leaves stocks unchanged.
+type NoopEnricher struct{}
+
+func (NoopEnricher) EnrichStock(ctx context.Context, ticker string) (string, string, error) {
+	return "", "", nil
+}
+
+type enrichment struct {
+	sector   string
+	industry string
+}
+
+// enrich fills in the stock's sector and industry, asking the enricher at
+// most once per ticker in a run. A failed or empty lookup keeps the values
+// the stock already had.
+func (s *Service) enrich(ctx context.Context, stock *stockviewer.Stock, seen map[string]*enrichment) {
+	if s.enricher == nil {
+		return
+	}
+
+	found, ok := seen[stock.Ticker]
+	if !ok {
+		sector, industry, err := s.enricher.EnrichStock(ctx, stock.Ticker)
+		if err != nil {
+			log.Printf("Error enriching %s: %v", stock.Ticker, err)
+		} else {
+			found = &enrichment{sector: sector, industry: industry}
+		}
+		seen[stock.Ticker] = found
+	}
+	if found == nil {
+		return
+	}
+	if found.sector != "" {
+		stock.Sector = found.sector
+	}
+	if found.industry != "" {
+		stock.Industry = found.industry
+	}
+}
@@ -0,0 +1,159 @@
+package stocks
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"gorm.io/gorm"
+)
+
+// editDistanceCandidates bounds how many entries editDistanceSearch reads.
+// The best scored entries whose ticker or company starts like the query are
+// read, since typos rarely hit the first letter.
+const editDistanceCandidates = 500
+
+// editDistanceSearch tops up matched, the non-fuzzy results of a fuzzy
+// search made without pg_trgm, with entries whose ticker, company or a word
+// of the company is within maxEditDistance of query. The additions rank by
+// distance, then recommend_score, after matched, and are MatchFuzzy.
+func (s *Storage) editDistanceSearch(ctx context.Context, query string, limit int, matched []stockviewer.Stock) ([]stockviewer.Stock, error) {
+	term := strings.ToLower(strings.TrimSpace(query))
+	if len(matched) >= limit || maxEditDistance(term) == 0 {
+		return matched, nil
+	}
+
+	var candidates []stockviewer.Stock
+	if err := editDistanceCandidatesQuery(s.db.WithContext(ctx), term, editDistanceCandidates).Find(&candidates).Error; err != nil {
+		return nil, stockviewer.StorageError{Operation: "fuzzy_candidates", Err: err}
+	}
+	return appendFuzzy(matched, rankByEditDistance(candidates, term), limit), nil
+}
+
+// appendFuzzy appends the fuzzy entries not already in matched, as
+// MatchFuzzy, until there are limit results.
+func appendFuzzy(matched, fuzzy []stockviewer.Stock, limit int) []stockviewer.Stock {
+	seen := make(map[string]bool, len(matched))
+	for _, stock := range matched {
+		seen[stock.ID] = true
+	}
+	for _, stock := range fuzzy {
+		if len(matched) >= limit {
+			break
+		}
+		if seen[stock.ID] {
+			continue
+		}
+		seen[stock.ID] = true
+		stock.MatchType = stockviewer.MatchFuzzy
+		matched = append(matched, stock)
+	}
+	return matched
+}
+
+// editDistanceCandidatesQuery reads the best scored entries whose ticker or
+// company starts with the first letter of term.
+func editDistanceCandidatesQuery(db *gorm.DB, term string, limit int) *gorm.DB {
+	first, _ := utf8.DecodeRuneInString(term)
+	prefix := string(first) + "%"
+	return db.Model(&stockviewer.Stock{}).
+		Where("ticker ILIKE ? OR company ILIKE ?", prefix, prefix).
+		Order("recommend_score DESC").
+		Limit(limit)
+}
+
+// rankByEditDistance keeps the candidates within maxEditDistance of term,
+// closest first, then by recommend_score. term must be lower case.
+func rankByEditDistance(candidates []stockviewer.Stock, term string) []stockviewer.Stock {
+	maxDistance := maxEditDistance(term)
+	distances := make(map[string]int, len(candidates))
+	var ranked []stockviewer.Stock
+	for _, stock := range candidates {
+		if d := editDistanceTo(stock, term); d <= maxDistance {
+			distances[stock.ID] = d
+			ranked = append(ranked, stock)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if di, dj := distances[ranked[i].ID], distances[ranked[j].ID]; di != dj {
+			return di < dj
+		}
+		return ranked[i].RecommendScore > ranked[j].RecommendScore
+	})
+	return ranked
+}
+
+// maxEditDistance is how many typos a query of term's length may have: none
+// for one or two characters, where any ticker would match, and one more per
+// four characters after that, up to three.
+func maxEditDistance(term string) int {
+	n := utf8.RuneCountInString(term)
+	switch {
+	case n <= 2:
+		return 0
+	case n <= 4:
+		return 1
+	case n <= 8:
+		return 2
+	}
+	return 3
+}
+
+// editDistanceTo is the smallest distance from term to the stock's ticker,
+// its company or any word of its company.
+func editDistanceTo(stock stockviewer.Stock, term string) int {
+	company := strings.ToLower(stock.Company)
+	best := min(levenshtein(term, strings.ToLower(stock.Ticker)), levenshtein(term, company))
+	words := strings.FieldsFunc(company, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		best = min(best, levenshtein(term, word))
+	}
+	return best
+}
+
+// levenshtein counts the single-rune insertions, deletions and substitutions
+// that turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// labelMatches sets each result's MatchType: MatchExact for the ticker equal
+// to query, MatchText when the ticker or company contains it, and otherwise
+// other, the kind of match the query that found it makes.
+func labelMatches(stocks []stockviewer.Stock, query string, other stockviewer.MatchType) []stockviewer.Stock {
+	term := strings.ToLower(strings.TrimSpace(query))
+	for i := range stocks {
+		switch {
+		case strings.ToLower(stocks[i].Ticker) == term:
+			stocks[i].MatchType = stockviewer.MatchExact
+		case strings.Contains(strings.ToLower(stocks[i].Ticker), term),
+			strings.Contains(strings.ToLower(stocks[i].Company), term):
+			stocks[i].MatchType = stockviewer.MatchText
+		default:
+			stocks[i].MatchType = other
+		}
+	}
+	return stocks
+}
@@ -2,114 +2,783 @@ package stocks
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"log"
 	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/query"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const (
+	defaultMaxFilterValues   = 100
+	recencyDecayDays         = 365.0
+	defaultConsensusWindow   = 90
+	maxConsensusWindow       = 3650
+	defaultChangesLimit      = 100
+	defaultFilterValuesLimit = 50
+	maxFilterValuesLimit     = 500
+	maxChangesLimit          = 1000
+	defaultSaveRetries       = 3
+	defaultSaveBackoff       = 100 * time.Millisecond
+	defaultHeartbeat         = 15 * time.Second
+	defaultSyncRunsLimit     = 20
+	maxSyncRunsLimit         = 100
+	defaultSuccessRateWindow = 24 * time.Hour
+	// staleHeartbeats is how many heartbeat intervals a run may miss before
+	// another instance treats it as abandoned.
+	staleHeartbeats = 3
+)
+
+// abortedSyncRuns counts runs found abandoned by a dead instance and aborted
+// during startup recovery.
+var abortedSyncRuns = expvar.NewInt("sync_runs_aborted_total")
+
+// deadLetters counts feed items syncs dropped because they could not be
+// converted or scored.
+var deadLetters = expvar.NewInt("sync_dead_letters_total")
+
+// syncSuccess mirrors the last computed SyncSuccessRate as completed,
+// partial, failed and rate; rate is absent while no run finished within the
+// window.
+var syncSuccess = expvar.NewMap("sync_success_window")
+
+// tracerName names the tracer of this package. Tracers are looked up on each
+// use so spans follow the current global provider.
+const tracerName = "github.com/user/go-stock-viewer-back/src/stockviewer/stocks"
+
 type Service struct {
-	storage     stockviewer.StocksRepository
-	fetcher     stockviewer.StocksFetcher
-	syncMutex   sync.Mutex
-	syncInProg  bool
-	lastSync    time.Time
+	storage         stockviewer.StocksRepository
+	fetcher         stockviewer.StocksFetcher
+	syncMutex       sync.Mutex
+	syncInProg      bool
+	syncStatus      stockviewer.SyncStatus
+	lastSync        time.Time
+	lastSyncStart   time.Time
+	maxFilterValues int
+	excludeUnrated  bool
+	pageLimits      stockviewer.PageLimits
+	saveRetries     int
+	saveBackoff     time.Duration
+	syncRuns        stockviewer.SyncRunRepository
+	instanceID      string
+	heartbeat       time.Duration
+	enricher        stockviewer.StockEnricher
+	progress        func(processed, estimated int)
+	caches          []stockviewer.CacheInvalidator
+	deadLetters     stockviewer.DeadLetterRepository
+	successWindow   time.Duration
+	successRate     *stockviewer.SyncSuccessRate
+	rounding        stockviewer.Rounding
+}
+
+// Option customizes optional Service settings.
+type Option func(*Service)
+
+// WithRounding sets how stored scores are rounded to two decimals.
+func WithRounding(rounding stockviewer.Rounding) Option {
+	return func(s *Service) {
+		if rounding != "" {
+			s.rounding = rounding
+		}
+	}
+}
+
+// WithMaxFilterValues caps how many distinct values GetFilters returns per
+// category.
+func WithMaxFilterValues(max int) Option {
+	return func(s *Service) {
+		if max > 0 {
+			s.maxFilterValues = max
+		}
+	}
 }
 
-func NewService(storage stockviewer.StocksRepository, fetcher stockviewer.StocksFetcher) *Service {
-	return &Service{
-		storage: storage,
-		fetcher: fetcher,
+// WithExcludeUnrated controls whether GetStocks hides stocks without a
+// recognized rating unless the filter asks for them with IncludeUnrated.
+func WithExcludeUnrated(exclude bool) Option {
+	return func(s *Service) {
+		s.excludeUnrated = exclude
 	}
 }
 
+// WithPageLimits sets the default and maximum page size for GetStocks.
+func WithPageLimits(limits stockviewer.PageLimits) Option {
+	return func(s *Service) {
+		s.pageLimits = limits.WithDefaults()
+	}
+}
+
+// WithSaveRetry sets how many times a sync batch write is retried after a
+// retryable transaction error, and the wait before the first retry, which
+// doubles on each further attempt. Zero retries disables retrying.
+func WithSaveRetry(retries int, backoff time.Duration) Option {
+	return func(s *Service) {
+		if retries >= 0 {
+			s.saveRetries = retries
+		}
+		if backoff > 0 {
+			s.saveBackoff = backoff
+		}
+	}
+}
+
+// WithSyncRuns records every sync as a run owned by instanceID and refreshes
+// the run's heartbeat every interval while it is in progress. instanceID
+// should stay the same across restarts of the same deployment slot (the pod
+// hostname, for instance) so its own leftover runs are recognised.
+func WithSyncRuns(runs stockviewer.SyncRunRepository, instanceID string, interval time.Duration) Option {
+	return func(s *Service) {
+		s.syncRuns = runs
+		s.instanceID = instanceID
+		if interval > 0 {
+			s.heartbeat = interval
+		}
+	}
+}
+
+// WithSuccessRateWindow sets how far back GetSyncSuccessRate looks in the
+// persisted sync runs; it has no effect without WithSyncRuns.
+func WithSuccessRateWindow(window time.Duration) Option {
+	return func(s *Service) {
+		if window > 0 {
+			s.successWindow = window
+		}
+	}
+}
+
+// WithEnricher adds an enrichment step to syncs that sets each stock's
+// sector and industry. Without it syncs keep whatever values are stored.
+func WithEnricher(enricher stockviewer.StockEnricher) Option {
+	return func(s *Service) {
+		s.enricher = enricher
+	}
+}
+
+// WithSyncProgress calls fn after each batch a sync saves with the records
+// saved so far and the number the run expects to save. fn runs on the sync
+// goroutine, so it must not block.
+func WithSyncProgress(fn func(processed, estimated int)) Option {
+	return func(s *Service) {
+		s.progress = fn
+	}
+}
+
+// WithCacheInvalidation invalidates caches after each completed sync and
+// manual override.
+func WithCacheInvalidation(caches ...stockviewer.CacheInvalidator) Option {
+	return func(s *Service) {
+		s.caches = append(s.caches, caches...)
+	}
+}
+
+// WithDeadLetters records every feed item a sync drops, with the item as
+// received and the reason. Without it dropped items are only logged.
+func WithDeadLetters(letters stockviewer.DeadLetterRepository) Option {
+	return func(s *Service) {
+		s.deadLetters = letters
+	}
+}
+
+func NewService(storage stockviewer.StocksRepository, fetcher stockviewer.StocksFetcher, opts ...Option) *Service {
+	s := &Service{
+		storage:         storage,
+		fetcher:         fetcher,
+		syncStatus:      stockviewer.SyncStatus{Status: "idle"},
+		maxFilterValues: defaultMaxFilterValues,
+		excludeUnrated:  true,
+		pageLimits:      stockviewer.DefaultPageLimits(),
+		saveRetries:     defaultSaveRetries,
+		saveBackoff:     defaultSaveBackoff,
+		heartbeat:       defaultHeartbeat,
+		successWindow:   defaultSuccessRateWindow,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Service) Ping(ctx context.Context) error {
+	return s.storage.Ping(ctx)
+}
+
 func (s *Service) SyncStocks(ctx context.Context) (*stockviewer.SyncStatus, error) {
+	if err := s.beginSync(); err != nil {
+		return nil, err
+	}
+	return s.runSync(ctx)
+}
+
+// StartSync starts a sync in the background and returns as soon as it has
+// been claimed, so callers are not tied to the duration of the sync. Progress
+// is reported through GetSyncStatus.
+func (s *Service) StartSync(ctx context.Context) error {
+	if err := s.beginSync(); err != nil {
+		return err
+	}
+
+	go func() {
+		if _, err := s.runSync(context.WithoutCancel(ctx)); err != nil {
+			log.Printf("Background sync failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// GetSyncStatus returns the state of the current or most recent sync. Until
+// this process has synced, that is the latest persisted run.
+func (s *Service) GetSyncStatus(ctx context.Context) (*stockviewer.SyncStatus, error) {
+	s.syncMutex.Lock()
+	status := s.syncStatus
+	s.syncMutex.Unlock()
+
+	if status.Status != "idle" || s.syncRuns == nil {
+		return &status, nil
+	}
+	runs, err := s.syncRuns.List(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) > 0 {
+		status = runs[0].SyncStatus()
+	}
+	return &status, nil
+}
+
+// GetSyncRuns returns the most recent persisted sync runs, newest first.
+// limit falls back to 20 when unset and is capped at 100.
+func (s *Service) GetSyncRuns(ctx context.Context, limit int) ([]stockviewer.SyncRun, error) {
+	if limit < 0 {
+		return nil, stockviewer.ValidationError{Field: "limit", Message: "must not be negative"}
+	}
+	if s.syncRuns == nil {
+		return []stockviewer.SyncRun{}, nil
+	}
+	if limit == 0 {
+		limit = defaultSyncRunsLimit
+	}
+	if limit > maxSyncRunsLimit {
+		limit = maxSyncRunsLimit
+	}
+	return s.syncRuns.List(ctx, limit)
+}
+
+// RecoverAbandonedSyncs aborts runs left in_progress by a process that died
+// mid-sync: earlier runs of this instance, and runs of any instance that
+// has missed several heartbeats. Call it at startup before syncing. The sync
+// itself is claimed in memory only, so no lock needs releasing.
+func (s *Service) RecoverAbandonedSyncs(ctx context.Context) ([]stockviewer.SyncRun, error) {
+	if s.syncRuns == nil {
+		return nil, nil
+	}
+
+	staleBefore := time.Now().UTC().Add(-staleHeartbeats * s.heartbeat)
+	note := fmt.Sprintf("aborted at startup of %s: the instance running it stopped before finishing", s.instanceID)
+	aborted, err := s.syncRuns.AbortStale(ctx, s.instanceID, staleBefore, note)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, run := range aborted {
+		log.Printf("Aborted sync run %d of instance %s, last heartbeat %s", run.ID, run.InstanceID, run.HeartbeatAt.Format(time.RFC3339))
+	}
+	abortedSyncRuns.Add(int64(len(aborted)))
+	s.refreshSuccessRate(ctx)
+	return aborted, nil
+}
+
+// GetSyncSuccessRate returns the success rate as of the last finished sync
+// or startup recovery. It never reads storage, so liveness checks can report
+// it; between syncs the window does not move.
+func (s *Service) GetSyncSuccessRate(ctx context.Context) *stockviewer.SyncSuccessRate {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+	return s.successRate
+}
+
+// refreshSuccessRate recomputes the success rate from the runs started
+// within the window. A failure is logged and keeps the previous rate.
+func (s *Service) refreshSuccessRate(ctx context.Context) {
+	if s.syncRuns == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	since := now.Add(-s.successWindow)
+	counts, err := s.syncRuns.CountByStatus(ctx, since)
+	if err != nil {
+		log.Printf("Error computing sync success rate: %v", err)
+		return
+	}
+	rate := stockviewer.NewSyncSuccessRate(counts, since, now)
+
+	s.syncMutex.Lock()
+	s.successRate = &rate
+	s.syncMutex.Unlock()
+	publishSuccessRate(rate)
+}
+
+func publishSuccessRate(rate stockviewer.SyncSuccessRate) {
+	for key, count := range map[string]int{
+		"completed": rate.Completed,
+		"partial":   rate.Partial,
+		"failed":    rate.Failed,
+	} {
+		value := new(expvar.Int)
+		value.Set(int64(count))
+		syncSuccess.Set(key, value)
+	}
+
+	if rate.Rate == nil {
+		syncSuccess.Delete("rate")
+		return
+	}
+	value := new(expvar.Float)
+	value.Set(*rate.Rate)
+	syncSuccess.Set("rate", value)
+}
+
+func (s *Service) beginSync() error {
 	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
 	if s.syncInProg {
-		s.syncMutex.Unlock()
-		return nil, stockviewer.ErrSyncInProgress
+		return stockviewer.ErrSyncInProgress
 	}
 	s.syncInProg = true
-	s.syncMutex.Unlock()
+	s.syncStatus = stockviewer.SyncStatus{
+		LastSync: s.lastSync,
+		Status:   "in_progress",
+	}
+	return nil
+}
 
+func (s *Service) finishSync(status stockviewer.SyncStatus) {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	s.syncInProg = false
+	s.syncStatus = status
+}
+
+func (s *Service) runSync(ctx context.Context) (result *stockviewer.SyncStatus, err error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "stocks.sync")
 	defer func() {
-		s.syncMutex.Lock()
-		s.syncInProg = false
-		s.syncMutex.Unlock()
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(attribute.Int("stocks.total_records", result.TotalRecords))
+		}
+		span.End()
 	}()
 
+	started := time.Now().UTC()
 	status := &stockviewer.SyncStatus{
-		Status: "in_progress",
+		LastSync: s.lastSync,
+		Status:   "in_progress",
 	}
 
+	run := s.startRun(ctx)
+	defer func() {
+		if err != nil {
+			status.Status = "error"
+			status.SetError(err)
+		}
+		s.finishRun(ctx, run, *status)
+		s.finishSync(*status)
+	}()
+
 	stocksChan, err := s.fetcher.FetchStocks(ctx)
 	if err != nil {
-		status.Status = "error"
 		return status, err
 	}
 
-	var batch []stockviewer.Stock
+	var pending []stockviewer.Stock
+	positions := make(map[string]int)
 	batchSize := 100
-	totalRecords := 0
 	newRecords := 0
+	duplicateRecords := 0
+	var schemaWarnings map[string]int
+	enriched := make(map[string]*enrichment)
+	// received counts the items the feed delivered, dropped ones included,
+	// to tell a feed that failed part-way from one that never answered.
+	received := 0
+	var fetchErr error
+	skipped := 0
+	var lookupErr error
 
 	for stockOrErr := range stocksChan {
 		if stockOrErr.Error != nil {
+			var convErr stockviewer.ConversionError
+			if errors.As(stockOrErr.Error, &convErr) {
+				received++
+				s.recordDeadLetter(ctx, convErr)
+				continue
+			}
 			log.Printf("Error fetching stock: %v", stockOrErr.Error)
+			fetchErr = stockOrErr.Error
 			continue
 		}
+		received++
 
+		now := time.Now().UTC()
 		stock := stockOrErr.Stock
-		stock.RecommendScore = calculateRecommendScore(stock)
-		stock.UpdatedAt = time.Now()
+		for _, issue := range stock.DataIssues {
+			if schemaWarnings == nil {
+				schemaWarnings = make(map[string]int)
+			}
+			schemaWarnings[issue.Field+":"+issue.Shape]++
+		}
+		stock.UpdatedAt = now
 
-		existing, err := s.storage.GetByID(ctx, stock.ID)
-		if err == stockviewer.ErrStockNotFound {
-			stock.CreatedAt = time.Now()
-			newRecords++
-		} else if err == nil {
-			stock.CreatedAt = existing.CreatedAt
+		// The upstream occasionally repeats an entry within a run; keep the
+		// latest occurrence in the original slot instead of saving twice.
+		pos, repeated := positions[stock.ID]
+		var existing *stockviewer.Stock
+		isNew := false
+		if repeated {
+			stock.CreatedAt = pending[pos].CreatedAt
+		} else {
+			var err error
+			existing, err = s.storage.GetByID(ctx, stock.ID)
+			switch {
+			case err == stockviewer.ErrStockNotFound:
+				stock.CreatedAt = now
+				isNew = true
+			case err != nil:
+				// Upserting without the stored copy would wipe its notes
+				// and score override and reset created_at, so the stock
+				// keeps its stored copy and the sync is only partial.
+				log.Printf("Error reading stored stock %s: %v", stock.ID, err)
+				skipped++
+				lookupErr = err
+				continue
+			default:
+				stock.CreatedAt = existing.CreatedAt.UTC()
+			}
 		}
 
-		batch = append(batch, stock)
-		totalRecords++
+		// The score decays from when the entry was first seen, which needs
+		// created_at; updated_at is rewritten by every sync.
+		stock.RecommendScore = calculateRecommendScore(stock, s.rounding)
+		if math.IsNaN(stock.RecommendScore) {
+			s.recordDeadLetter(ctx, unscorable(stock))
+			continue
+		}
 
-		if len(batch) >= batchSize {
-			if err := s.storage.SaveBatch(ctx, batch); err != nil {
-				log.Printf("Error saving batch: %v", err)
-			}
-			batch = batch[:0]
+		if repeated {
+			keepLocalFields(&stock, pending[pos])
+			pending[pos] = stock
+			duplicateRecords++
+			continue
+		}
+		if isNew {
+			newRecords++
+		} else if existing != nil {
+			keepLocalFields(&stock, *existing)
 		}
+
+		s.enrich(ctx, &stock, enriched)
+
+		positions[stock.ID] = len(pending)
+		pending = append(pending, stock)
 	}
 
-	if len(batch) > 0 {
-		if err := s.storage.SaveBatch(ctx, batch); err != nil {
-			log.Printf("Error saving final batch: %v", err)
+	// Without a single item there is nothing newer than the stored data to
+	// save, so the failure fails the sync. After some items it only makes
+	// the sync partial: what arrived is saved and the rest is kept as is.
+	if fetchErr != nil && received == 0 {
+		return status, fetchErr
+	}
+
+	totalRecords := len(pending)
+	lastSync := time.Now().UTC()
+	completed := stockviewer.SyncStatus{
+		LastSync:         lastSync,
+		TotalRecords:     totalRecords,
+		NewRecords:       newRecords,
+		UpdatedRecords:   totalRecords - newRecords,
+		DuplicateRecords: duplicateRecords,
+		Status:           "completed",
+		SchemaWarnings:   schemaWarnings,
+	}
+
+	// The final batch is written together with the sync.completed outbox
+	// event so post-sync side effects are not lost if the process dies
+	// right after the data is committed.
+	finalStart := 0
+	if len(pending) > 0 {
+		finalStart = (len(pending) - 1) / batchSize * batchSize
+	}
+	// A batch that is not saved leaves those stocks as they were, so the
+	// sync is only partial even when the final batch goes through.
+	failedBatches := 0
+	var saveErr error
+	for start := 0; start < finalStart; start += batchSize {
+		batch := pending[start : start+batchSize]
+		err := s.saveBatch(ctx, "stocks.SaveBatch", len(batch), func(ctx context.Context) error {
+			return s.storage.SaveBatch(ctx, batch)
+		})
+		if err != nil {
+			log.Printf("Error saving batch: %v", err)
+			failedBatches++
+			saveErr = err
 		}
+		s.reportProgress(start+len(batch), totalRecords)
+	}
+	if failedBatches > 0 {
+		batches := finalStart/batchSize + 1
+		saveErr = fmt.Errorf("%d of %d batches were not saved: %w", failedBatches, batches, saveErr)
+	}
+	if skipped > 0 {
+		lookupErr = fmt.Errorf("%d of %d stocks were not synced because their stored copy could not be read: %w", skipped, received, lookupErr)
+	}
+	if err := errors.Join(fetchErr, lookupErr, saveErr); err != nil {
+		completed.Status = stockviewer.SyncPartial
+		completed.SetError(err)
 	}
 
-	s.lastSync = time.Now()
-	status.LastSync = s.lastSync
-	status.TotalRecords = totalRecords
-	status.NewRecords = newRecords
-	status.UpdatedRecords = totalRecords - newRecords
-	status.Status = "completed"
+	payload, err := json.Marshal(completed)
+	if err != nil {
+		return nil, err
+	}
+	event := stockviewer.OutboxEvent{
+		Type:    stockviewer.EventSyncCompleted,
+		Payload: string(payload),
+	}
+	err = s.saveBatch(ctx, "stocks.SaveBatchWithEvent", len(pending)-finalStart, func(ctx context.Context) error {
+		return s.storage.SaveBatchWithEvent(ctx, pending[finalStart:], event)
+	})
+	if err != nil {
+		// Neither the final batch nor the sync.completed event was written,
+		// so the run fails and lastSync keeps pointing at the last complete
+		// one. Earlier batches may have been saved, so caches still go.
+		log.Printf("Error saving final batch: %v", err)
+		s.invalidateCaches()
+		return status, err
+	}
+	s.reportProgress(totalRecords, totalRecords)
+	s.invalidateCaches()
+
+	s.syncMutex.Lock()
+	s.lastSync = lastSync
+	s.lastSyncStart = started
+	s.syncMutex.Unlock()
+	*status = completed
 
 	return status, nil
 }
 
+// recordDeadLetter stores an item the sync drops. Failing to store it is
+// logged and does not stop the sync.
+func (s *Service) recordDeadLetter(ctx context.Context, convErr stockviewer.ConversionError) {
+	deadLetters.Add(1)
+	log.Printf("Dropping feed item: %v", convErr)
+	if s.deadLetters == nil {
+		return
+	}
+	letter := stockviewer.DeadLetter{
+		Source:  convErr.Source,
+		Reason:  convErr.Reason,
+		Payload: convErr.Payload,
+	}
+	if err := s.deadLetters.Record(ctx, letter); err != nil {
+		log.Printf("Error recording dead letter: %v", err)
+	}
+}
+
+// unscorable reports a stock whose score came out as NaN. JSON cannot encode
+// the NaN that caused it, so the payload lists the fields as text.
+func unscorable(stock stockviewer.Stock) stockviewer.ConversionError {
+	targetFrom, targetTo := stock.Targets()
+	return stockviewer.ConversionError{
+		Source: "scoring",
+		Reason: "recommend score is not a number",
+		Payload: fmt.Sprintf("id=%s ticker=%s brokerage=%q action=%q rating_from=%q rating_to=%q target_from=%v target_to=%v",
+			stock.ID, stock.Ticker, stock.Brokerage, stock.Action, stock.RatingFrom, stock.RatingTo, targetFrom, targetTo),
+	}
+}
+
+func (s *Service) invalidateCaches() {
+	for _, cache := range s.caches {
+		cache.InvalidateCache()
+	}
+}
+
+func (s *Service) reportProgress(processed, estimated int) {
+	if s.progress != nil {
+		s.progress(processed, estimated)
+	}
+}
+
+// trackedRun is a persisted sync run whose heartbeat is kept fresh by a
+// goroutine until the run finishes.
+type trackedRun struct {
+	run  stockviewer.SyncRun
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startRun records a new in_progress run and starts its heartbeat. A run
+// that cannot be recorded is logged and the sync goes ahead untracked.
+func (s *Service) startRun(ctx context.Context) *trackedRun {
+	if s.syncRuns == nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	t := &trackedRun{
+		run: stockviewer.SyncRun{
+			InstanceID:  s.instanceID,
+			Status:      "in_progress",
+			StartedAt:   now,
+			HeartbeatAt: now,
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if err := s.syncRuns.Create(ctx, &t.run); err != nil {
+		log.Printf("Error recording sync run: %v", err)
+		return nil
+	}
+
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stop:
+				return
+			case at := <-ticker.C:
+				if err := s.syncRuns.Heartbeat(ctx, t.run.ID, at.UTC()); err != nil {
+					log.Printf("Error writing sync run heartbeat: %v", err)
+				}
+			}
+		}
+	}()
+	return t
+}
+
+// finishRun stops the run's heartbeat and stores its outcome.
+func (s *Service) finishRun(ctx context.Context, t *trackedRun, status stockviewer.SyncStatus) {
+	if t == nil {
+		return
+	}
+	close(t.stop)
+	<-t.done
+
+	now := time.Now().UTC()
+	t.run.Status = status.Status
+	t.run.HeartbeatAt = now
+	t.run.FinishedAt = &now
+	t.run.TotalRecords = status.TotalRecords
+	t.run.NewRecords = status.NewRecords
+	t.run.UpdatedRecords = status.UpdatedRecords
+	t.run.DuplicateRecords = status.DuplicateRecords
+	t.run.Error = status.Error
+	t.run.UpstreamService = status.UpstreamService
+	t.run.UpstreamStatus = status.UpstreamStatus
+	t.run.SchemaWarnings = status.SchemaWarnings
+	if err := s.syncRuns.Finish(context.WithoutCancel(ctx), &t.run); err != nil {
+		log.Printf("Error recording sync run result: %v", err)
+		return
+	}
+	s.refreshSuccessRate(context.WithoutCancel(ctx))
+}
+
+// keepLocalFields copies onto a freshly fetched stock the fields the feed
+// does not own: its creation time, analyst notes, enrichment, and the score
+// while it is manually overridden.
+func keepLocalFields(stock *stockviewer.Stock, existing stockviewer.Stock) {
+	stock.CreatedAt = existing.CreatedAt.UTC()
+	stock.Notes = existing.Notes
+	stock.Sector = existing.Sector
+	stock.Industry = existing.Industry
+	if existing.ScoreOverridden {
+		stock.RecommendScore = existing.RecommendScore
+		stock.ScoreOverridden = true
+	}
+}
+
+// saveBatch runs save with saveWithRetry under a span called name, which
+// covers every retry of the batch.
+func (s *Service) saveBatch(ctx context.Context, name string, size int, save func(ctx context.Context) error) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attribute.Int("stocks.batch_size", size)))
+	defer span.End()
+
+	err := s.saveWithRetry(ctx, func() error {
+		return save(ctx)
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// saveWithRetry runs save again while the database aborts it with a
+// retryable transaction error, such as CockroachDB serialization conflicts
+// with concurrent writers. These retries only cover contention on our own
+// writes, never failures fetching from upstream.
+func (s *Service) saveWithRetry(ctx context.Context, save func() error) error {
+	backoff := s.saveBackoff
+	for attempt := 1; ; attempt++ {
+		err := save()
+		if err == nil || attempt > s.saveRetries || !stockviewer.IsRetryableTxError(err) {
+			return err
+		}
+		log.Printf("Retrying save after retryable transaction error (retry %d/%d): %v", attempt, s.saveRetries, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
 func (s *Service) GetStock(ctx context.Context, id string) (*stockviewer.Stock, error) {
 	return s.storage.GetByID(ctx, id)
 }
 
 func (s *Service) GetStocks(ctx context.Context, filter stockviewer.StockFilter) (*stockviewer.PaginatedResponse, error) {
-	if filter.Page < 1 {
+	// Callers other than the HTTP layer may pass an unvalidated filter; reject
+	// values storage would otherwise silently replace or ignore. Zero page and
+	// page size still mean the defaults.
+	if filter.Page < 0 {
+		return nil, stockviewer.ValidationError{Field: "page", Message: "must not be negative"}
+	}
+	if filter.Page == 0 {
 		filter.Page = 1
 	}
-	if filter.PageSize < 1 || filter.PageSize > 100 {
-		filter.PageSize = 20
+	limits := s.pageLimits.For(ctx)
+	if err := limits.CheckPageSize(filter.PageSize); err != nil {
+		return nil, err
+	}
+	filter.PageSize = limits.PageSize(filter.PageSize)
+
+	if _, _, errs := query.NormalizeSort(filter.SortBy, filter.SortOrder); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	filter, err := s.resolveListFilter(filter)
+	if err != nil {
+		return nil, err
 	}
 
 	stocks, total, err := s.storage.GetAll(ctx, filter)
@@ -117,87 +786,618 @@ func (s *Service) GetStocks(ctx context.Context, filter stockviewer.StockFilter)
 		return nil, err
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(filter.PageSize)))
+	return stockviewer.NewPaginatedResponse(stocks, filter.Page, filter.PageSize, total), nil
+}
 
-	return &stockviewer.PaginatedResponse{
-		Data:       stocks,
-		Page:       filter.Page,
-		PageSize:   filter.PageSize,
-		TotalItems: total,
-		TotalPages: totalPages,
-	}, nil
+// resolveListFilter expands the action category into its actions and hides
+// unrated stocks unless the filter asks for them.
+func (s *Service) resolveListFilter(filter stockviewer.StockFilter) (stockviewer.StockFilter, error) {
+	if filter.ActionCategory != "" {
+		actions, ok := stockviewer.ActionCategories[stockviewer.ActionCategory(filter.ActionCategory)]
+		if !ok {
+			return filter, stockviewer.ValidationError{
+				Field:   "action_category",
+				Message: "must be one of positive, negative, neutral",
+			}
+		}
+		filter.Actions = make([]string, len(actions))
+		for i, action := range actions {
+			filter.Actions[i] = string(action)
+		}
+	}
+
+	filter.RatedOnly = s.excludeUnrated && !filter.IncludeUnrated
+	return filter, nil
+}
+
+// UpdateStock applies a manual override. Setting recommend_score marks the
+// score as overridden so later syncs do not recompute it.
+func (s *Service) UpdateStock(ctx context.Context, id string, update stockviewer.StockUpdate) (*stockviewer.Stock, error) {
+	updates := make(map[string]interface{})
+	if update.RecommendScore != nil {
+		score := *update.RecommendScore
+		if math.IsNaN(score) || score < 0 || score > 100 {
+			return nil, stockviewer.ValidationError{Field: "recommend_score", Message: "must be between 0 and 100"}
+		}
+		updates["recommend_score"] = score
+		updates["score_overridden"] = true
+	}
+	if update.Notes != nil {
+		updates["notes"] = strings.TrimSpace(*update.Notes)
+	}
+	if len(updates) == 0 {
+		return nil, stockviewer.ValidationError{Field: "body", Message: "must set recommend_score or notes"}
+	}
+
+	if err := s.storage.Update(ctx, id, updates); err != nil {
+		return nil, err
+	}
+	s.invalidateCaches()
+	return s.storage.GetByID(ctx, id)
+}
+
+// GetStockHistory returns one page of every entry recorded for ticker,
+// newest first.
+func (s *Service) GetStockHistory(ctx context.Context, ticker string, filter stockviewer.StockFilter) (*stockviewer.PaginatedResponse, error) {
+	ticker = strings.TrimSpace(ticker)
+	if ticker == "" {
+		return nil, stockviewer.ValidationError{Field: "ticker", Message: "is required"}
+	}
+	// As in GetStocks, a zero page means the first one.
+	if filter.Page < 0 {
+		return nil, stockviewer.ValidationError{Field: "page", Message: "must not be negative"}
+	}
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	limits := s.pageLimits.For(ctx)
+	if err := limits.CheckPageSize(filter.PageSize); err != nil {
+		return nil, err
+	}
+	filter.PageSize = limits.PageSize(filter.PageSize)
+	filter.SortBy = "created_at"
+	filter.SortOrder = "DESC"
+
+	stocks, total, err := s.storage.GetTickerHistory(ctx, ticker, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return stockviewer.NewPaginatedResponse(stocks, filter.Page, filter.PageSize, total), nil
 }
 
-func (s *Service) SearchStocks(ctx context.Context, query string, limit int) ([]stockviewer.Stock, error) {
+// SearchStocks matches query against ticker and company, by whole words and
+// then by substring; see Storage.Search. fuzzy also accepts
+// near matches by trigram similarity where the database supports it. A
+// blank query lists the newest entries, with unrated ones hidden as on
+// GetStocks.
+func (s *Service) SearchStocks(ctx context.Context, query string, limit int, fuzzy bool) ([]stockviewer.Stock, error) {
 	if limit < 1 || limit > 50 {
 		limit = 10
 	}
-	return s.storage.Search(ctx, query, limit)
+	if strings.TrimSpace(query) == "" {
+		filter, err := s.resolveListFilter(stockviewer.StockFilter{
+			SortBy:    "created_at",
+			SortOrder: "DESC",
+			Page:      1,
+			PageSize:  limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		stocks, _, err := s.storage.GetAll(ctx, filter)
+		return stocks, err
+	}
+	return s.storage.Search(ctx, query, limit, fuzzy)
 }
 
-func (s *Service) GetFilters(ctx context.Context) (*stockviewer.FiltersResponse, error) {
-	brokerages, err := s.storage.GetDistinctBrokerages(ctx)
+func (s *Service) GetFilters(ctx context.Context, query stockviewer.FiltersQuery) (*stockviewer.FiltersResponse, error) {
+	// One extra value tells whether the list was cut short.
+	top := stockviewer.DistinctQuery{Prefix: query.Prefix, Limit: s.maxFilterValues + 1}
+
+	brokerages, err := s.storage.GetDistinctBrokerages(ctx, top)
+	if err != nil {
+		return nil, err
+	}
+
+	ratings, err := s.storage.GetDistinctRatings(ctx, top)
 	if err != nil {
 		return nil, err
 	}
 
-	ratings, err := s.storage.GetDistinctRatings(ctx)
+	ratingsFrom, err := s.storage.GetDistinctRatingsFrom(ctx, top)
 	if err != nil {
 		return nil, err
 	}
 
-	actions := []string{
-		string(stockviewer.ActionTargetRaised),
-		string(stockviewer.ActionTargetLowered),
-		string(stockviewer.ActionUpgraded),
-		string(stockviewer.ActionDowngraded),
-		string(stockviewer.ActionInitiated),
+	var actions []string
+	for _, action := range stockviewer.Actions() {
+		actions = append(actions, string(action))
 	}
 
-	return &stockviewer.FiltersResponse{
-		Brokerages: brokerages,
-		Ratings:    ratings,
-		Actions:    actions,
+	filters := &stockviewer.FiltersResponse{Actions: actions}
+	filters.Brokerages, filters.HasMore.Brokerages = s.topValues(brokerages)
+	filters.Ratings, filters.HasMore.Ratings = s.topValues(ratings)
+	filters.RatingsFrom, filters.HasMore.RatingsFrom = s.topValues(ratingsFrom)
+
+	if query.Counts {
+		filters.Counts, err = s.filterCounts(ctx, query.Prefix, query.Filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filters, nil
+}
+
+// filterCounts counts each facet's values among the stocks matching filter.
+// Each facet leaves its own field out of the filter so the alternatives to
+// the current choice keep their counts.
+func (s *Service) filterCounts(ctx context.Context, prefix string, filter stockviewer.StockFilter) (*stockviewer.FilterCounts, error) {
+	filter, err := s.resolveListFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	byBrokerage := filter
+	byBrokerage.Brokerage = ""
+	brokerages, err := s.storage.CountByBrokerage(ctx, byBrokerage)
+	if err != nil {
+		return nil, err
+	}
+
+	byRating := filter
+	byRating.Rating = ""
+	ratings, err := s.storage.CountByRating(ctx, byRating)
+	if err != nil {
+		return nil, err
+	}
+
+	byAction := filter
+	byAction.Action, byAction.ActionCategory, byAction.Actions = "", "", nil
+	actions, err := s.storage.CountByAction(ctx, byAction)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stockviewer.FilterCounts{
+		Brokerages: s.topCounts(brokerages, prefix),
+		Ratings:    s.topCounts(ratings, prefix),
+		Actions:    s.topCounts(actions, ""),
 	}, nil
 }
 
-func calculateRecommendScore(stock stockviewer.Stock) float64 {
-	score := 50.0
+// topCounts keeps the first maxFilterValues counts whose value starts with
+// prefix, ignoring case.
+func (s *Service) topCounts(counts []stockviewer.ValueCount, prefix string) []stockviewer.ValueCount {
+	prefix = strings.ToLower(prefix)
+	kept := []stockviewer.ValueCount{}
+	for _, c := range counts {
+		if len(kept) == s.maxFilterValues {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(c.Value), prefix) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// topValues keeps the first maxFilterValues values and reports whether any
+// were dropped.
+func (s *Service) topValues(counts []stockviewer.ValueCount) ([]string, bool) {
+	hasMore := len(counts) > s.maxFilterValues
+	if hasMore {
+		counts = counts[:s.maxFilterValues]
+	}
+	values := make([]string, len(counts))
+	for i, c := range counts {
+		values[i] = c.Value
+	}
+	return values, hasMore
+}
 
-	ratingScores := map[string]float64{
-		"Buy":            30.0,
-		"Outperform":     25.0,
-		"Overweight":     20.0,
-		"Hold":           0.0,
-		"Neutral":        -5.0,
-		"Market Perform": -10.0,
-		"Underperform":   -20.0,
-		"Underweight":    -20.0,
-		"Sell":           -30.0,
-		"Speculative":    10.0,
+// GetFilterValues pages through every value of a filter field with its stock
+// count, most common first. limit falls back to 50 when unset and is capped
+// at 500.
+func (s *Service) GetFilterValues(ctx context.Context, field string, query stockviewer.DistinctQuery) (*stockviewer.FilterValues, error) {
+	var lookup func(context.Context, stockviewer.DistinctQuery) ([]stockviewer.ValueCount, error)
+	switch field {
+	case stockviewer.FilterFieldBrokerages:
+		lookup = s.storage.GetDistinctBrokerages
+	case stockviewer.FilterFieldRatings:
+		lookup = s.storage.GetDistinctRatings
+	case stockviewer.FilterFieldRatingsFrom:
+		lookup = s.storage.GetDistinctRatingsFrom
+	default:
+		return nil, stockviewer.ErrUnknownFilterField
 	}
 
-	if ratingScore, ok := ratingScores[stock.RatingTo]; ok {
-		score += ratingScore
+	if query.Limit < 0 {
+		return nil, stockviewer.ValidationError{Field: "limit", Message: "must not be negative"}
+	}
+	if query.Offset < 0 {
+		return nil, stockviewer.ValidationError{Field: "offset", Message: "must not be negative"}
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultFilterValuesLimit
+	}
+	if query.Limit > maxFilterValuesLimit {
+		query.Limit = maxFilterValuesLimit
+	}
+	query.Prefix = strings.TrimSpace(query.Prefix)
+
+	limit := query.Limit
+	query.Limit++
+	values, err := lookup(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &stockviewer.FilterValues{
+		Field:   field,
+		Values:  values,
+		Limit:   limit,
+		Offset:  query.Offset,
+		HasMore: len(values) > limit,
+	}
+	if page.HasMore {
+		page.Values = values[:limit]
+	}
+	return page, nil
+}
+
+// GetBrokerageStats returns per-brokerage aggregates ordered descending by
+// sortBy, which is stockviewer.BrokerageSortTotal (the default) or
+// stockviewer.BrokerageSortAvgScore.
+func (s *Service) GetBrokerageStats(ctx context.Context, sortBy string) ([]stockviewer.BrokerageStats, error) {
+	var less func(a, b stockviewer.BrokerageStats) bool
+	switch sortBy {
+	case "", stockviewer.BrokerageSortTotal:
+		less = func(a, b stockviewer.BrokerageStats) bool {
+			return a.TotalRecommendations > b.TotalRecommendations
+		}
+	case stockviewer.BrokerageSortAvgScore:
+		less = func(a, b stockviewer.BrokerageStats) bool {
+			return a.AvgScore > b.AvgScore
+		}
+	default:
+		return nil, stockviewer.ValidationError{
+			Field:   "sort_by",
+			Message: "must be one of total_recommendations, avg_score",
+		}
 	}
 
-	actionScores := map[string]float64{
-		"target raised by": 15.0,
-		"upgraded by":      20.0,
-		"initiated by":     5.0,
-		"target lowered by": -15.0,
-		"downgraded by":    -20.0,
+	stats, err := s.storage.GetBrokerageStats(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	if actionScore, ok := actionScores[stock.Action]; ok {
+	sort.SliceStable(stats, func(i, j int) bool {
+		return less(stats[i], stats[j])
+	})
+
+	return stats, nil
+}
+
+func (s *Service) GetRatingDistribution(ctx context.Context) ([]stockviewer.RatingDistribution, error) {
+	return s.storage.GetRatingDistribution(ctx)
+}
+
+func (s *Service) GetActionDistribution(ctx context.Context) ([]stockviewer.ActionDistribution, error) {
+	return s.storage.GetActionDistribution(ctx)
+}
+
+// GetStats returns headline numbers for the stored stocks. The last sync is
+// taken from this process when it has synced, and otherwise falls back to the
+// most recent row update so it survives restarts.
+func (s *Service) GetStats(ctx context.Context) (*stockviewer.StatsResponse, error) {
+	stats, err := s.storage.GetStockStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lastSync := stats.LastUpdated
+	s.syncMutex.Lock()
+	if !s.lastSync.IsZero() {
+		synced := s.lastSync
+		lastSync = &synced
+	}
+	s.syncMutex.Unlock()
+
+	return &stockviewer.StatsResponse{
+		TotalStocks:        stats.TotalStocks,
+		DistinctTickers:    stats.DistinctTickers,
+		DistinctBrokerages: stats.DistinctBrokerages,
+		AvgRecommendScore:  stats.AvgRecommendScore,
+		LastSync:           lastSync,
+	}, nil
+}
+
+// GetScoreDistribution returns every score bucket in order, including the
+// empty ones, so charts always get the full 0-100 range.
+func (s *Service) GetScoreDistribution(ctx context.Context) ([]stockviewer.ScoreBucket, error) {
+	counts, err := s.storage.GetScoreDistribution(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]stockviewer.ScoreBucket, stockviewer.ScoreBucketCount)
+	for i := range buckets {
+		buckets[i] = stockviewer.NewScoreBucket(i)
+	}
+	for _, bucket := range counts {
+		i := int(bucket.BucketMin / stockviewer.ScoreBucketWidth)
+		if i >= 0 && i < len(buckets) {
+			buckets[i].Count += bucket.Count
+		}
+	}
+	return buckets, nil
+}
+
+// GetDataQualityReport summarizes the stocks quarantined by syncs, counting
+// issues by field and shape with the most frequent first.
+func (s *Service) GetDataQualityReport(ctx context.Context) (*stockviewer.DataQualityReport, error) {
+	quarantined, err := s.storage.GetQuarantinedStocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status, err := s.GetSyncStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[stockviewer.DataIssueCount]int)
+	for _, stock := range quarantined {
+		for _, issue := range stock.DataIssues {
+			counts[stockviewer.DataIssueCount{Field: issue.Field, Shape: issue.Shape}]++
+		}
+	}
+	issues := make([]stockviewer.DataIssueCount, 0, len(counts))
+	for issue, count := range counts {
+		issue.Count = count
+		issues = append(issues, issue)
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Count != issues[j].Count {
+			return issues[i].Count > issues[j].Count
+		}
+		if issues[i].Field != issues[j].Field {
+			return issues[i].Field < issues[j].Field
+		}
+		return issues[i].Shape < issues[j].Shape
+	})
+
+	warnings := status.SchemaWarnings
+	if warnings == nil {
+		warnings = map[string]int{}
+	}
+	if quarantined == nil {
+		quarantined = []stockviewer.Stock{}
+	}
+	return &stockviewer.DataQualityReport{
+		QuarantinedStocks: len(quarantined),
+		Issues:            issues,
+		LastSyncWarnings:  warnings,
+		Stocks:            quarantined,
+	}, nil
+}
+
+// GetTargetConsensus summarizes the price targets published for ticker in the
+// last windowDays days, including the momentum of the targets over the
+// window. A zero window uses the default of 90 days.
+func (s *Service) GetTargetConsensus(ctx context.Context, ticker string, windowDays int) (*stockviewer.TargetConsensus, error) {
+	if strings.TrimSpace(ticker) == "" {
+		return nil, stockviewer.ValidationError{Field: "ticker", Message: "is required"}
+	}
+	if windowDays == 0 {
+		windowDays = defaultConsensusWindow
+	}
+	if windowDays < 0 || windowDays > maxConsensusWindow {
+		return nil, stockviewer.ValidationError{Field: "days", Message: "must be between 1 and 3650"}
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -windowDays)
+	consensus, err := s.storage.GetTargetConsensus(ctx, strings.TrimSpace(ticker), since)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.storage.GetTargetHistory(ctx, strings.TrimSpace(ticker), since)
+	if err != nil {
+		return nil, err
+	}
+
+	rounded := consensus.Rounded()
+	rounded.WindowDays = windowDays
+	rounded.Momentum = stockviewer.MomentumFromHistory(history)
+	return &rounded, nil
+}
+
+// GetTargetConsensuses is GetTargetConsensus for several tickers, read with
+// two queries however many tickers are listed. Tickers are upper-cased and
+// repeats dropped; a ticker without targets in the window has a zero count.
+func (s *Service) GetTargetConsensuses(ctx context.Context, tickers []string, windowDays int) ([]stockviewer.TargetConsensus, error) {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, ticker := range tickers {
+		ticker = strings.ToUpper(strings.TrimSpace(ticker))
+		if ticker != "" && !seen[ticker] {
+			seen[ticker] = true
+			unique = append(unique, ticker)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, stockviewer.ValidationError{Field: "tickers", Message: "is required"}
+	}
+	if len(unique) > stockviewer.MaxConsensusTickers {
+		return nil, stockviewer.ValidationError{Field: "tickers", Message: fmt.Sprintf("must list at most %d tickers", stockviewer.MaxConsensusTickers)}
+	}
+	if windowDays == 0 {
+		windowDays = defaultConsensusWindow
+	}
+	if windowDays < 0 || windowDays > maxConsensusWindow {
+		return nil, stockviewer.ValidationError{Field: "days", Message: "must be between 1 and 3650"}
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -windowDays)
+	aggregated, err := s.storage.GetTargetConsensuses(ctx, unique, since)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.storage.GetTargetHistories(ctx, unique, since)
+	if err != nil {
+		return nil, err
+	}
+
+	byTicker := make(map[string]stockviewer.TargetConsensus, len(aggregated))
+	for _, consensus := range aggregated {
+		byTicker[strings.ToUpper(consensus.Ticker)] = consensus
+	}
+	histories := make(map[string][]stockviewer.Stock)
+	for _, entry := range entries {
+		ticker := strings.ToUpper(entry.Ticker)
+		histories[ticker] = append(histories[ticker], entry)
+	}
+
+	consensuses := make([]stockviewer.TargetConsensus, 0, len(unique))
+	for _, ticker := range unique {
+		rounded := byTicker[ticker].Rounded()
+		rounded.Ticker = ticker
+		rounded.WindowDays = windowDays
+		rounded.Momentum = stockviewer.MomentumFromHistory(histories[ticker])
+		consensuses = append(consensuses, rounded)
+	}
+	return consensuses, nil
+}
+
+// GetNewTickers lists the tickers covered for the first time since since,
+// or, when since is nil, during the most recent completed sync. Without a
+// completed sync there is nothing to compare against and the list is empty.
+func (s *Service) GetNewTickers(ctx context.Context, since *time.Time) (*stockviewer.NewTickers, error) {
+	var from, to time.Time
+	if since != nil {
+		from, to = since.UTC(), time.Now().UTC()
+	} else {
+		var err error
+		from, to, err = s.lastSyncWindow(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if to.IsZero() {
+			return &stockviewer.NewTickers{Tickers: []stockviewer.NewTicker{}}, nil
+		}
+	}
+
+	tickers, err := s.storage.GetFirstSeenBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if tickers == nil {
+		tickers = []stockviewer.NewTicker{}
+	}
+	return &stockviewer.NewTickers{Tickers: tickers, From: from, To: to}, nil
+}
+
+// lastSyncWindow returns when the most recent completed sync started and
+// finished: this process's own, or else the latest persisted run. Both are
+// zero when no sync has completed.
+func (s *Service) lastSyncWindow(ctx context.Context) (time.Time, time.Time, error) {
+	s.syncMutex.Lock()
+	from, to := s.lastSyncStart, s.lastSync
+	s.syncMutex.Unlock()
+	if !to.IsZero() || s.syncRuns == nil {
+		return from, to, nil
+	}
+
+	runs, err := s.syncRuns.List(ctx, maxSyncRunsLimit)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	for _, run := range runs {
+		if run.SavedData() {
+			return run.StartedAt, *run.FinishedAt, nil
+		}
+	}
+	return time.Time{}, time.Time{}, nil
+}
+
+// GetChanges returns stocks after the (since, afterID) cursor, oldest first.
+// limit falls back to 100 when unset and is capped at 1000. The cursor of
+// the result points at its last stock, so a batch that ends inside a run of
+// stocks sharing updated_at resumes with the rest of the run. When nothing
+// changed, the cursor is echoed so clients can keep polling with it.
+func (s *Service) GetChanges(ctx context.Context, since time.Time, afterID string, limit int) (*stockviewer.StockChanges, error) {
+	if limit < 0 {
+		return nil, stockviewer.ValidationError{Field: "limit", Message: "must not be negative"}
+	}
+	if limit == 0 {
+		limit = defaultChangesLimit
+	}
+	if limit > maxChangesLimit {
+		limit = maxChangesLimit
+	}
+
+	stocks, err := s.storage.GetUpdatedSince(ctx, since, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &stockviewer.StockChanges{Stocks: stocks, NextSince: since.UTC(), NextAfterID: afterID}
+	if len(stocks) > 0 {
+		last := stocks[len(stocks)-1]
+		changes.NextSince = last.UpdatedAt.UTC()
+		changes.NextAfterID = last.ID
+	}
+	return changes, nil
+}
+
+// actionScores adjusts the stored score by the analyst action. It lists
+// every stockviewer.Action, neutral ones included, so a new action is a
+// deliberate scoring decision.
+var actionScores = map[stockviewer.Action]float64{
+	stockviewer.ActionTargetRaised:  15.0,
+	stockviewer.ActionUpgraded:      20.0,
+	stockviewer.ActionInitiated:     5.0,
+	stockviewer.ActionReiterated:    0.0,
+	stockviewer.ActionTargetLowered: -15.0,
+	stockviewer.ActionDowngraded:    -20.0,
+}
+
+// ratingScores adjusts the stored score by the target rating. It lists every
+// recognized rating so synonyms such as Buy and Strong Buy score alike.
+var ratingScores = map[stockviewer.Rating]float64{
+	stockviewer.RatingBuy:           30.0,
+	stockviewer.RatingStrongBuy:     30.0,
+	stockviewer.RatingOutperform:    25.0,
+	stockviewer.RatingOverweight:    20.0,
+	stockviewer.RatingAccumulate:    15.0,
+	stockviewer.RatingHold:          0.0,
+	stockviewer.RatingNeutral:       -5.0,
+	stockviewer.RatingMarketPerform: -10.0,
+	stockviewer.RatingEqualWeight:   -10.0,
+	stockviewer.RatingUnderperform:  -20.0,
+	stockviewer.RatingUnderweight:   -20.0,
+	stockviewer.RatingReduce:        -25.0,
+	stockviewer.RatingSell:          -30.0,
+	stockviewer.RatingSpeculative:   10.0,
+}
+
+func calculateRecommendScore(stock stockviewer.Stock, rounding stockviewer.Rounding) float64 {
+	score := 50.0
+
+	if ratingScore, ok := ratingScores[stockviewer.Rating(stock.RatingTo)]; ok {
+		score += ratingScore
+	}
+
+	if actionScore, ok := actionScores[stockviewer.Action(stock.Action)]; ok {
 		score += actionScore
 	}
 
-	if stock.TargetFrom > 0 && stock.TargetTo > 0 {
-		priceChange := ((stock.TargetTo - stock.TargetFrom) / stock.TargetFrom) * 100
+	if priceChange, ok := stock.Upside(); ok {
 		score += priceChange * 0.5
 	}
 
+	score *= stockviewer.RecencyFactor(stock.CreatedAt, time.Now(), recencyDecayDays)
+
 	if score > 100 {
 		score = 100
 	}
@@ -205,5 +1405,5 @@ func calculateRecommendScore(stock stockviewer.Stock) float64 {
 		score = 0
 	}
 
-	return math.Round(score*100) / 100
+	return rounding.Round(score)
 }
@@ -3,10 +3,20 @@ package stocks
 import (
 	"context"
 	"errors"
+	"expvar"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/user/go-stock-viewer-back/src/stockviewer"
 	"github.com/user/go-stock-viewer-back/src/stockviewer/mocks"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestGetStocks_Success(t *testing.T) {
@@ -57,12 +67,59 @@ func TestGetStocks_WithPagination(t *testing.T) {
 	}
 }
 
+func TestGetStocks_PageBeyondTotal(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	result, err := service.GetStocks(context.Background(), stockviewer.StockFilter{Page: 5, PageSize: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result.Data) != 0 {
+		t.Errorf("expected no stocks past the last page, got %d", len(result.Data))
+	}
+	if result.HasNext || result.NextPage != nil {
+		t.Errorf("expected no next page, got has_next=%v next_page=%v", result.HasNext, result.NextPage)
+	}
+	if !result.HasPrev || result.PrevPage == nil || int64(*result.PrevPage) != result.TotalPages {
+		t.Errorf("expected prev_page to point at last page %d, got %v", result.TotalPages, result.PrevPage)
+	}
+}
+
+func TestGetStocks_NotesFilter(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks[0].Notes = "Watch the earnings call"
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+	ctx := context.Background()
+
+	tests := []struct {
+		notes string
+		want  int
+	}{
+		{"EARNINGS", 1},
+		{"dividend", 0},
+		{stockviewer.NotesAny, 1},
+		{stockviewer.NotesNone, len(mockRepo.Stocks) - 1},
+	}
+
+	for _, tt := range tests {
+		result, err := service.GetStocks(ctx, stockviewer.StockFilter{Notes: tt.notes})
+		if err != nil {
+			t.Fatalf("notes %q: unexpected error: %v", tt.notes, err)
+		}
+		if len(result.Data) != tt.want {
+			t.Errorf("notes %q: expected %d stocks, got %d", tt.notes, tt.want, len(result.Data))
+		}
+	}
+}
+
 func TestGetStock_Success(t *testing.T) {
 	mockRepo := mocks.NewMockStocksRepository()
 	mockFetcher := mocks.NewMockStocksFetcher()
 	service := NewService(mockRepo, mockFetcher)
 
-	stock, err := service.GetStock(context.Background(), "test-id-1")
+	stock, err := service.GetStock(context.Background(), mocks.AAPLStockID)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -96,7 +153,7 @@ func TestSearchStocks_Success(t *testing.T) {
 	mockFetcher := mocks.NewMockStocksFetcher()
 	service := NewService(mockRepo, mockFetcher)
 
-	stocks, err := service.SearchStocks(context.Background(), "AAPL", 10)
+	stocks, err := service.SearchStocks(context.Background(), "AAPL", 10, false)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -106,6 +163,40 @@ func TestSearchStocks_Success(t *testing.T) {
 	}
 }
 
+func TestSearchStocks_PassesFuzzy(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	if _, err := service.SearchStocks(context.Background(), "APPL", 10, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !mockRepo.LastSearchFuzzy {
+		t.Error("expected the fuzzy flag to reach storage")
+	}
+}
+
+func TestSearchStocks_BlankQueryListsNewest(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	stocks, err := service.SearchStocks(context.Background(), "  ", 5, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stocks) == 0 {
+		t.Fatal("expected the newest entries")
+	}
+
+	filter := mockRepo.LastFilter
+	if filter.SortBy != "created_at" || filter.SortOrder != "DESC" || filter.Page != 1 || filter.PageSize != 5 {
+		t.Errorf("expected the first 5 entries by created_at DESC, got %+v", filter)
+	}
+	if !filter.RatedOnly {
+		t.Error("expected unrated entries to be hidden as on GetStocks")
+	}
+}
+
 func TestSyncStocks_Success(t *testing.T) {
 	mockRepo := mocks.NewMockStocksRepository()
 	mockFetcher := mocks.NewMockStocksFetcher()
@@ -125,6 +216,89 @@ func TestSyncStocks_Success(t *testing.T) {
 	}
 }
 
+func TestSyncStocks_FailsWhenFinalBatchIsNotSaved(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	runs := mocks.NewMockSyncRunRepository()
+	service := NewService(mockRepo, fetcherWithStocks(3), WithSyncRuns(runs, "pod-a", time.Minute))
+
+	first, err := service.SyncStocks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lastSync := first.LastSync
+
+	mockRepo.SaveError = stockviewer.StorageError{Operation: "save_batch_with_event", Err: errors.New("connection refused")}
+	status, err := service.SyncStocks(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected the save error, got %v", err)
+	}
+	if status.Status != "error" || status.Error != err.Error() {
+		t.Errorf("expected status error, got %+v", status)
+	}
+	if service.lastSync != lastSync {
+		t.Errorf("expected lastSync to stay at %s, got %s", lastSync, service.lastSync)
+	}
+	if len(runs.Runs) != 2 || runs.Runs[1].Status != "error" {
+		t.Errorf("expected the second run to be recorded as failed, got %+v", runs.Runs)
+	}
+	if len(mockRepo.Events) != 1 {
+		t.Errorf("expected only the first sync's completed event, got %d", len(mockRepo.Events))
+	}
+}
+
+func TestSyncStocks_PartialWhenFeedFailsMidway(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = nil
+	mockFetcher := &mocks.MockStocksFetcher{
+		Stocks: []stockviewer.Stock{
+			{ID: "first", Ticker: "AAPL", RatingTo: "Buy"},
+			{ID: "second", Ticker: "MSFT", RatingTo: "Hold"},
+		},
+		ItemErrors: []error{errors.New("karenai: unexpected status code 503")},
+	}
+	runs := mocks.NewMockSyncRunRepository()
+	service := NewService(mockRepo, mockFetcher, WithSyncRuns(runs, "pod-a", time.Minute))
+
+	status, err := service.SyncStocks(context.Background())
+	if err != nil {
+		t.Fatalf("expected a partial sync to succeed, got %v", err)
+	}
+	if status.Status != stockviewer.SyncPartial || status.TotalRecords != 2 {
+		t.Errorf("expected a partial sync of 2 records, got %s with %d", status.Status, status.TotalRecords)
+	}
+	if !strings.Contains(status.Error, "503") || status.LastSync.IsZero() {
+		t.Errorf("expected the failure and a last sync time, got %+v", status)
+	}
+	for _, id := range []string{"first", "second"} {
+		if _, err := mockRepo.GetByID(context.Background(), id); err != nil {
+			t.Errorf("expected %s to be saved, got %v", id, err)
+		}
+	}
+	if len(runs.Runs) != 1 || runs.Runs[0].Status != stockviewer.SyncPartial || !runs.Runs[0].SavedData() {
+		t.Errorf("expected the run to be recorded as partial, got %+v", runs.Runs)
+	}
+}
+
+func TestSyncStocks_FailsWhenFeedFailsBeforeAnyData(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	stored := len(mockRepo.Stocks)
+	mockFetcher := &mocks.MockStocksFetcher{
+		ItemErrors: []error{errors.New("karenai: connection refused")},
+	}
+	service := NewService(mockRepo, mockFetcher)
+
+	status, err := service.SyncStocks(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected the fetch error, got %v", err)
+	}
+	if status.Status != "error" || status.Error != err.Error() {
+		t.Errorf("expected status error, got %+v", status)
+	}
+	if len(mockRepo.Stocks) != stored {
+		t.Errorf("expected the stored stocks to be kept, got %d of %d", len(mockRepo.Stocks), stored)
+	}
+}
+
 func TestSyncStocks_AlreadyInProgress(t *testing.T) {
 	mockRepo := mocks.NewMockStocksRepository()
 	mockFetcher := &slowMockFetcher{}
@@ -199,10 +373,1902 @@ func TestCalculateRecommendScore(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			score := calculateRecommendScore(tt.stock)
+			score := calculateRecommendScore(tt.stock, stockviewer.RoundHalfUp)
 			if score < tt.minScore || score > tt.maxScore {
 				t.Errorf("expected score between %.2f and %.2f, got %.2f", tt.minScore, tt.maxScore, score)
 			}
 		})
 	}
 }
+
+func TestCalculateRecommendScore_Rounding(t *testing.T) {
+	// A 1/16 upside is exactly 6.25%, which adds 3.125 to the base of 50.
+	stock := stockviewer.Stock{TargetFrom: stockviewer.Price(16), TargetTo: stockviewer.Price(17)}
+
+	for rounding, want := range map[stockviewer.Rounding]float64{
+		stockviewer.RoundHalfUp:   53.13,
+		stockviewer.RoundHalfEven: 53.12,
+		stockviewer.RoundFloor:    53.12,
+	} {
+		if got := calculateRecommendScore(stock, rounding); got != want {
+			t.Errorf("%s: expected %.2f, got %v", rounding, want, got)
+		}
+	}
+}
+
+func TestGetBrokerageStats_SortedByTotal(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = append(mockRepo.Stocks,
+		stockviewer.Stock{ID: "test-id-4", Ticker: "NVDA", Brokerage: "JP Morgan", RatingTo: "Sell", RecommendScore: 20.0},
+		stockviewer.Stock{ID: "test-id-5", Ticker: "AMD", Brokerage: "JP Morgan", RatingTo: "Hold", RecommendScore: 50.0},
+	)
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	stats, err := service.GetBrokerageStats(context.Background(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 brokerages, got %d", len(stats))
+	}
+
+	top := stats[0]
+	if top.Brokerage != "JP Morgan" {
+		t.Fatalf("expected JP Morgan first, got %s", top.Brokerage)
+	}
+	if top.TotalRecommendations != 3 {
+		t.Errorf("expected 3 recommendations, got %d", top.TotalRecommendations)
+	}
+	if top.SellCount != 1 || top.HoldCount != 2 || top.BuyCount != 0 {
+		t.Errorf("unexpected rating breakdown: buy=%d hold=%d sell=%d", top.BuyCount, top.HoldCount, top.SellCount)
+	}
+	if math.Abs(top.AvgScore-38.33) > 0.01 {
+		t.Errorf("expected average score around 38.33, got %.2f", top.AvgScore)
+	}
+}
+
+func TestSyncStocks_StoresUTCTimestamps(t *testing.T) {
+	original := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	defer func() { time.Local = original }()
+
+	mockRepo := mocks.NewMockStocksRepository()
+	mockFetcher := mocks.NewMockStocksFetcher()
+	service := NewService(mockRepo, mockFetcher)
+
+	status, err := service.SyncStocks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status.LastSync.Location() != time.UTC {
+		t.Errorf("expected last sync in UTC, got %s", status.LastSync.Location())
+	}
+
+	for _, stock := range mockRepo.Stocks {
+		if stock.ID != "mock-1" {
+			continue
+		}
+		if stock.CreatedAt.Location() != time.UTC || stock.UpdatedAt.Location() != time.UTC {
+			t.Errorf("expected UTC timestamps, got created_at=%s updated_at=%s",
+				stock.CreatedAt.Location(), stock.UpdatedAt.Location())
+		}
+	}
+}
+
+func TestGetFilters_IncludesRatingsFrom(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	filters, err := service.GetFilters(context.Background(), stockviewer.FiltersQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]bool{"Hold": true, "Neutral": true, "Buy": true}
+	if len(filters.RatingsFrom) != len(expected) {
+		t.Fatalf("expected %d ratings_from values, got %v", len(expected), filters.RatingsFrom)
+	}
+	for _, r := range filters.RatingsFrom {
+		if !expected[r] {
+			t.Errorf("unexpected rating_from value %q", r)
+		}
+	}
+}
+
+func TestGetStocks_ActionCategory(t *testing.T) {
+	tests := []struct {
+		category string
+		expected []string
+	}{
+		{"positive", []string{"target raised by", "upgraded by"}},
+		{"negative", []string{"target lowered by", "downgraded by"}},
+		{"neutral", []string{"initiated by", "reiterated by"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.category, func(t *testing.T) {
+			mockRepo := mocks.NewMockStocksRepository()
+			service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+			_, err := service.GetStocks(context.Background(), stockviewer.StockFilter{ActionCategory: tt.category})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			actions := mockRepo.LastFilter.Actions
+			if len(actions) != len(tt.expected) {
+				t.Fatalf("expected actions %v, got %v", tt.expected, actions)
+			}
+			for i := range actions {
+				if actions[i] != tt.expected[i] {
+					t.Errorf("expected actions %v, got %v", tt.expected, actions)
+				}
+			}
+		})
+	}
+}
+
+func TestGetStocks_InvalidActionCategory(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	_, err := service.GetStocks(context.Background(), stockviewer.StockFilter{ActionCategory: "bullish"})
+
+	var validationErr stockviewer.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	if validationErr.Field != "action_category" {
+		t.Errorf("expected field action_category, got %s", validationErr.Field)
+	}
+}
+
+func TestGetFilters_ListsEveryAction(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher())
+
+	filters, err := service.GetFilters(context.Background(), stockviewer.FiltersQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "[target raised by upgraded by target lowered by downgraded by initiated by reiterated by]"
+	if fmt.Sprint(filters.Actions) != want {
+		t.Errorf("expected every action, got %v", filters.Actions)
+	}
+}
+
+func TestGetFilters_CapsValues(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher(), WithMaxFilterValues(2))
+
+	filters, err := service.GetFilters(context.Background(), stockviewer.FiltersQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(filters.Brokerages) != 2 {
+		t.Errorf("expected 2 brokerages, got %v", filters.Brokerages)
+	}
+	if len(filters.Ratings) != 2 {
+		t.Errorf("expected 2 ratings, got %v", filters.Ratings)
+	}
+}
+
+func TestGetFilters_PrefixFilter(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	filters, err := service.GetFilters(context.Background(), stockviewer.FiltersQuery{Prefix: "go"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(filters.Brokerages) != 1 || filters.Brokerages[0] != "Goldman Sachs" {
+		t.Errorf("expected only Goldman Sachs, got %v", filters.Brokerages)
+	}
+	if len(filters.Ratings) != 0 {
+		t.Errorf("expected no ratings matching prefix, got %v", filters.Ratings)
+	}
+}
+
+func TestGetFilters_Counts(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = []stockviewer.Stock{
+		{ID: "1", Brokerage: "Goldman Sachs", RatingTo: "Buy", Action: "upgraded by"},
+		{ID: "2", Brokerage: "Goldman Sachs", RatingTo: "Buy", Action: "target raised by"},
+		{ID: "3", Brokerage: "Goldman Sachs", RatingTo: "Sell", Action: "downgraded by"},
+		{ID: "4", Brokerage: "JP Morgan", RatingTo: "Buy", Action: "upgraded by"},
+		{ID: "5", Brokerage: "JP Morgan", RatingTo: "Not Rated", Action: "upgraded by"},
+	}
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	plain, err := service.GetFilters(context.Background(), stockviewer.FiltersQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plain.Counts != nil {
+		t.Errorf("expected no counts unless requested, got %+v", plain.Counts)
+	}
+
+	filters, err := service.GetFilters(context.Background(), stockviewer.FiltersQuery{Counts: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &stockviewer.FilterCounts{
+		Brokerages: []stockviewer.ValueCount{{Value: "Goldman Sachs", Count: 3}, {Value: "JP Morgan", Count: 1}},
+		Ratings:    []stockviewer.ValueCount{{Value: "Buy", Count: 3}, {Value: "Sell", Count: 1}},
+		Actions: []stockviewer.ValueCount{
+			{Value: "upgraded by", Count: 2}, {Value: "downgraded by", Count: 1}, {Value: "target raised by", Count: 1},
+		},
+	}
+	if fmt.Sprint(filters.Counts) != fmt.Sprint(want) {
+		t.Errorf("expected counts %+v, got %+v", want, filters.Counts)
+	}
+}
+
+func TestGetFilters_CountsNarrowByFilter(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = []stockviewer.Stock{
+		{ID: "1", Brokerage: "Goldman Sachs", RatingTo: "Buy", Action: "upgraded by"},
+		{ID: "2", Brokerage: "Goldman Sachs", RatingTo: "Sell", Action: "downgraded by"},
+		{ID: "3", Brokerage: "JP Morgan", RatingTo: "Buy", Action: "upgraded by"},
+		{ID: "4", Brokerage: "JP Morgan", RatingTo: "Buy", Action: "target raised by"},
+	}
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	filters, err := service.GetFilters(context.Background(), stockviewer.FiltersQuery{
+		Counts: true,
+		Filter: stockviewer.StockFilter{Brokerage: "Goldman Sachs", ActionCategory: "positive"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &stockviewer.FilterCounts{
+		// Brokerages ignore the brokerage choice but keep the action category.
+		Brokerages: []stockviewer.ValueCount{{Value: "JP Morgan", Count: 2}, {Value: "Goldman Sachs", Count: 1}},
+		Ratings:    []stockviewer.ValueCount{{Value: "Buy", Count: 1}},
+		// Actions ignore the action category but keep the brokerage.
+		Actions: []stockviewer.ValueCount{{Value: "downgraded by", Count: 1}, {Value: "upgraded by", Count: 1}},
+	}
+	if fmt.Sprint(filters.Counts) != fmt.Sprint(want) {
+		t.Errorf("expected counts %+v, got %+v", want, filters.Counts)
+	}
+
+	_, err = service.GetFilters(context.Background(), stockviewer.FiltersQuery{
+		Counts: true,
+		Filter: stockviewer.StockFilter{ActionCategory: "bullish"},
+	})
+	var validationErr stockviewer.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "action_category" {
+		t.Errorf("expected validation error on action_category, got %v", err)
+	}
+}
+
+func TestGetFilters_HasMoreBoundary(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+
+	// The mock holds three brokerages and two ratings.
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher(), WithMaxFilterValues(2))
+	filters, err := service.GetFilters(context.Background(), stockviewer.FiltersQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !filters.HasMore.Brokerages {
+		t.Error("expected has_more for 3 brokerages capped at 2")
+	}
+	if filters.HasMore.Ratings {
+		t.Error("expected no has_more for exactly 2 ratings capped at 2")
+	}
+
+	service = NewService(mockRepo, mocks.NewMockStocksFetcher(), WithMaxFilterValues(3))
+	filters, err = service.GetFilters(context.Background(), stockviewer.FiltersQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filters.HasMore.Brokerages {
+		t.Error("expected no has_more for exactly 3 brokerages capped at 3")
+	}
+}
+
+func TestGetFilters_OrdersByCount(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	filters, err := service.GetFilters(context.Background(), stockviewer.FiltersQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(filters.Ratings) != 2 || filters.Ratings[0] != "Buy" {
+		t.Errorf("expected Buy (2 stocks) before Neutral (1 stock), got %v", filters.Ratings)
+	}
+}
+
+func TestGetFilterValues_OrderAndCounts(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = append(mockRepo.Stocks,
+		stockviewer.Stock{ID: "extra-1", Ticker: "MSFT", Brokerage: "JP Morgan", RatingTo: "Buy"},
+		stockviewer.Stock{ID: "extra-2", Ticker: "NVDA", Brokerage: "JP Morgan", RatingTo: "Buy"},
+	)
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	page, err := service.GetFilterValues(context.Background(), stockviewer.FilterFieldBrokerages, stockviewer.DistinctQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []stockviewer.ValueCount{
+		{Value: "JP Morgan", Count: 3},
+		{Value: "Goldman Sachs", Count: 1},
+		{Value: "Morgan Stanley", Count: 1},
+	}
+	if len(page.Values) != len(want) {
+		t.Fatalf("expected %d values, got %v", len(want), page.Values)
+	}
+	for i := range want {
+		if page.Values[i] != want[i] {
+			t.Errorf("value %d: expected %+v, got %+v", i, want[i], page.Values[i])
+		}
+	}
+	if page.Limit != defaultFilterValuesLimit || page.HasMore {
+		t.Errorf("expected default limit and no more values, got limit=%d has_more=%v", page.Limit, page.HasMore)
+	}
+}
+
+func TestGetFilterValues_PrefixFilter(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	page, err := service.GetFilterValues(context.Background(), stockviewer.FilterFieldBrokerages, stockviewer.DistinctQuery{Prefix: "MOR"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(page.Values) != 1 || page.Values[0].Value != "Morgan Stanley" {
+		t.Errorf("expected only Morgan Stanley, got %v", page.Values)
+	}
+}
+
+func TestGetFilterValues_HasMoreBoundary(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		query       stockviewer.DistinctQuery
+		wantValues  int
+		wantHasMore bool
+	}{
+		{"first page of two", stockviewer.DistinctQuery{Limit: 2}, 2, true},
+		{"last page", stockviewer.DistinctQuery{Limit: 2, Offset: 2}, 1, false},
+		{"limit equals total", stockviewer.DistinctQuery{Limit: 3}, 3, false},
+		{"offset past end", stockviewer.DistinctQuery{Limit: 2, Offset: 5}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := service.GetFilterValues(ctx, stockviewer.FilterFieldBrokerages, tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(page.Values) != tt.wantValues {
+				t.Errorf("expected %d values, got %v", tt.wantValues, page.Values)
+			}
+			if page.HasMore != tt.wantHasMore {
+				t.Errorf("expected has_more=%v, got %v", tt.wantHasMore, page.HasMore)
+			}
+		})
+	}
+}
+
+func TestGetFilterValues_Invalid(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+	ctx := context.Background()
+
+	if _, err := service.GetFilterValues(ctx, "tickers", stockviewer.DistinctQuery{}); !errors.Is(err, stockviewer.ErrUnknownFilterField) {
+		t.Errorf("expected ErrUnknownFilterField, got %v", err)
+	}
+
+	var validationErr stockviewer.ValidationError
+	_, err := service.GetFilterValues(ctx, stockviewer.FilterFieldRatings, stockviewer.DistinctQuery{Offset: -1})
+	if !errors.As(err, &validationErr) || validationErr.Field != "offset" {
+		t.Errorf("expected offset validation error, got %v", err)
+	}
+}
+
+func TestGetRatingDistribution(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	distribution, err := service.GetRatingDistribution(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(distribution) != 2 {
+		t.Fatalf("expected 2 ratings, got %v", distribution)
+	}
+	if distribution[0].Rating != "Buy" || distribution[0].Count != 2 {
+		t.Errorf("expected Buy with 2 stocks first, got %+v", distribution[0])
+	}
+	if distribution[1].Rating != "Neutral" || distribution[1].Count != 1 {
+		t.Errorf("expected Neutral with 1 stock second, got %+v", distribution[1])
+	}
+}
+
+func TestGetActionDistribution(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	distribution, err := service.GetActionDistribution(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var total int64
+	for _, d := range distribution {
+		total += d.Count
+	}
+	if total != int64(len(mockRepo.Stocks)) {
+		t.Errorf("expected counts to sum to %d, got %d", len(mockRepo.Stocks), total)
+	}
+}
+
+func TestSyncStocks_ReportsProgressPerBatch(t *testing.T) {
+	mockFetcher := &mocks.MockStocksFetcher{}
+	for i := 0; i < 250; i++ {
+		mockFetcher.Stocks = append(mockFetcher.Stocks, stockviewer.Stock{ID: fmt.Sprintf("progress-%d", i), Ticker: "RMTI"})
+	}
+
+	var reports [][2]int
+	service := NewService(mocks.NewMockStocksRepository(), mockFetcher, WithSyncProgress(func(processed, estimated int) {
+		reports = append(reports, [2]int{processed, estimated})
+	}))
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := [][2]int{{100, 250}, {200, 250}, {250, 250}}
+	if fmt.Sprint(reports) != fmt.Sprint(want) {
+		t.Errorf("expected progress %v, got %v", want, reports)
+	}
+}
+
+func TestSyncStocks_DeduplicatesWithinRun(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockFetcher := &mocks.MockStocksFetcher{
+		Stocks: []stockviewer.Stock{
+			{ID: "dup-1", Ticker: "RMTI", RatingTo: "Hold"},
+			{ID: "dup-2", Ticker: "AKBA", RatingTo: "Buy"},
+			{ID: "dup-1", Ticker: "RMTI", RatingTo: "Buy"},
+		},
+	}
+	service := NewService(mockRepo, mockFetcher)
+	before := len(mockRepo.Stocks)
+
+	status, err := service.SyncStocks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status.TotalRecords != 2 {
+		t.Errorf("expected 2 total records, got %d", status.TotalRecords)
+	}
+	if status.NewRecords != 2 {
+		t.Errorf("expected 2 new records, got %d", status.NewRecords)
+	}
+	if status.DuplicateRecords != 1 {
+		t.Errorf("expected 1 duplicate record, got %d", status.DuplicateRecords)
+	}
+
+	saved := mockRepo.Stocks[before:]
+	if len(saved) != 2 {
+		t.Fatalf("expected 2 saved stocks, got %d", len(saved))
+	}
+	if saved[0].ID != "dup-1" || saved[0].RatingTo != "Buy" {
+		t.Errorf("expected the latest dup-1 occurrence to be kept, got %+v", saved[0])
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = append(mockRepo.Stocks,
+		stockviewer.Stock{ID: "test-id-4", Ticker: "AAPL", Brokerage: "Goldman Sachs", RecommendScore: 60.0},
+	)
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	stats, err := service.GetStats(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stats.TotalStocks != 4 {
+		t.Errorf("expected 4 stocks, got %d", stats.TotalStocks)
+	}
+	if stats.DistinctTickers != 3 {
+		t.Errorf("expected 3 distinct tickers, got %d", stats.DistinctTickers)
+	}
+	if stats.DistinctBrokerages != 3 {
+		t.Errorf("expected 3 distinct brokerages, got %d", stats.DistinctBrokerages)
+	}
+	if math.Abs(stats.AvgRecommendScore-70.13) > 0.01 {
+		t.Errorf("expected average score around 70.13, got %.2f", stats.AvgRecommendScore)
+	}
+	if stats.LastSync != nil {
+		t.Errorf("expected no last sync before syncing, got %v", stats.LastSync)
+	}
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+
+	stats, err = service.GetStats(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.LastSync == nil || stats.LastSync.Location() != time.UTC {
+		t.Errorf("expected UTC last sync after syncing, got %v", stats.LastSync)
+	}
+}
+
+func waitForSyncStatus(t *testing.T, service *Service, want string) *stockviewer.SyncStatus {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, err := service.GetSyncStatus(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if status.Status == want {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected sync status %s, still %s", want, status.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStartSync_CompletesInBackground(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	status, err := service.GetSyncStatus(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Status != "idle" {
+		t.Errorf("expected idle status before any sync, got %s", status.Status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := service.StartSync(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The request that started the sync going away must not abort it.
+	cancel()
+
+	status = waitForSyncStatus(t, service, "completed")
+	if status.TotalRecords != 3 {
+		t.Errorf("expected 3 records, got %d", status.TotalRecords)
+	}
+	if status.LastSync.IsZero() {
+		t.Error("expected last sync to be set")
+	}
+}
+
+func TestStartSync_AlreadyInProgress(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository(), &slowMockFetcher{})
+
+	if err := service.StartSync(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForSyncStatus(t, service, "in_progress")
+
+	if err := service.StartSync(context.Background()); !errors.Is(err, stockviewer.ErrSyncInProgress) {
+		t.Errorf("expected ErrSyncInProgress, got %v", err)
+	}
+}
+
+func TestStartSync_ReportsFetchError(t *testing.T) {
+	mockFetcher := mocks.NewMockStocksFetcher()
+	mockFetcher.Error = stockviewer.ErrExternalAPIFailure
+	service := NewService(mocks.NewMockStocksRepository(), mockFetcher)
+
+	if err := service.StartSync(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status := waitForSyncStatus(t, service, "error")
+	if status.Error == "" {
+		t.Error("expected the fetch error to be reported")
+	}
+}
+
+func TestActionScores_CoverEveryAction(t *testing.T) {
+	actions := stockviewer.Actions()
+	for _, action := range actions {
+		if _, ok := actionScores[action]; !ok {
+			t.Errorf("action %q has no score", action)
+		}
+	}
+	if len(actionScores) != len(actions) {
+		t.Errorf("expected %d action scores, got %d", len(actions), len(actionScores))
+	}
+}
+
+func TestRatingScores_CoverEveryRecognizedRating(t *testing.T) {
+	ratings := stockviewer.RecognizedRatings()
+	for _, rating := range ratings {
+		if _, ok := ratingScores[stockviewer.Rating(rating)]; !ok {
+			t.Errorf("rating %q has no score", rating)
+		}
+	}
+	if len(ratingScores) != len(ratings) {
+		t.Errorf("expected %d rating scores, got %d", len(ratings), len(ratingScores))
+	}
+
+	buy := calculateRecommendScore(stockviewer.Stock{RatingTo: "Buy"}, stockviewer.RoundHalfUp)
+	strongBuy := calculateRecommendScore(stockviewer.Stock{RatingTo: "Strong Buy"}, stockviewer.RoundHalfUp)
+	if strongBuy != buy {
+		t.Errorf("expected Strong Buy to score like Buy (%v), got %v", buy, strongBuy)
+	}
+}
+
+func TestSyncStocks_ScoreDecaysFromFirstSeen(t *testing.T) {
+	entry := stockviewer.Stock{Ticker: "HOLD", RatingTo: "Hold", Action: "initiated by"}
+	fresh := calculateRecommendScore(entry, stockviewer.RoundHalfUp)
+
+	old := entry
+	old.ID = "seen-last-year"
+	old.CreatedAt = time.Now().Add(-365 * 24 * time.Hour)
+	old.UpdatedAt = time.Now()
+	repo := &mocks.MockStocksRepository{Stocks: []stockviewer.Stock{old}}
+
+	fetched := []stockviewer.Stock{entry, entry}
+	fetched[0].ID = "seen-last-year"
+	fetched[1].ID = "seen-now"
+	service := NewService(repo, &mocks.MockStocksFetcher{Stocks: fetched})
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The mock appends every saved batch; the last copy of each ID is what
+	// the sync stored.
+	saved := make(map[string]stockviewer.Stock)
+	for _, stock := range repo.Stocks {
+		saved[stock.ID] = stock
+	}
+	if score := saved["seen-now"].RecommendScore; score != fresh {
+		t.Errorf("expected a new entry to keep the fresh score %.2f, got %.2f", fresh, score)
+	}
+	// Re-syncing rewrote updated_at, but the entry was first seen a year ago.
+	if score, want := saved["seen-last-year"].RecommendScore, fresh*stockviewer.MinRecencyFactor; math.Abs(score-want) > 0.02 {
+		t.Errorf("expected the year-old entry to decay to %.2f, got %.2f", want, score)
+	}
+}
+
+func TestGetScoreDistribution(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = append(mockRepo.Stocks,
+		stockviewer.Stock{ID: "test-id-4", Ticker: "NVDA", RecommendScore: 100.0},
+		stockviewer.Stock{ID: "test-id-5", Ticker: "AMD", RecommendScore: 0.0},
+	)
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	buckets, err := service.GetScoreDistribution(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(buckets) != stockviewer.ScoreBucketCount {
+		t.Fatalf("expected %d buckets, got %d", stockviewer.ScoreBucketCount, len(buckets))
+	}
+
+	// Scores: 85.5, 90, 45, 100, 0.
+	want := []int64{1, 0, 0, 0, 1, 0, 0, 0, 1, 2}
+	var total int64
+	for i, bucket := range buckets {
+		if bucket.BucketMin != float64(i*10) || bucket.BucketMax != float64(i*10+10) {
+			t.Errorf("bucket %d: expected range %d-%d, got %.0f-%.0f", i, i*10, i*10+10, bucket.BucketMin, bucket.BucketMax)
+		}
+		if bucket.Count != want[i] {
+			t.Errorf("bucket %d: expected count %d, got %d", i, want[i], bucket.Count)
+		}
+		total += bucket.Count
+	}
+	if total != int64(len(mockRepo.Stocks)) {
+		t.Errorf("expected every stock to be counted once, got %d", total)
+	}
+}
+
+func TestGetTargetConsensus(t *testing.T) {
+	now := time.Now().UTC()
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = []stockviewer.Stock{
+		{ID: "1", Ticker: "AAPL", TargetTo: stockviewer.Price(150), CreatedAt: now.AddDate(0, 0, -10), UpdatedAt: now},
+		{ID: "2", Ticker: "AAPL", TargetTo: stockviewer.Price(210), CreatedAt: now.AddDate(0, 0, -20), UpdatedAt: now},
+		{ID: "3", Ticker: "AAPL", TargetTo: stockviewer.Price(185), CreatedAt: now.AddDate(0, 0, -30), UpdatedAt: now},
+		{ID: "4", Ticker: "AAPL", TargetTo: stockviewer.Price(0), CreatedAt: now.AddDate(0, 0, -5), UpdatedAt: now},
+		// Resynced today, but first seen outside the window.
+		{ID: "5", Ticker: "AAPL", TargetTo: stockviewer.Price(400), CreatedAt: now.AddDate(0, 0, -200), UpdatedAt: now},
+		{ID: "6", Ticker: "MSFT", TargetTo: stockviewer.Price(320), CreatedAt: now, UpdatedAt: now},
+	}
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	consensus, err := service.GetTargetConsensus(context.Background(), "aapl", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := stockviewer.TargetConsensus{
+		Ticker: "AAPL", Count: 3, Min: 150, Max: 210, Median: 185, Mean: 181.67, Spread: 60, WindowDays: 90,
+	}
+	if consensus.Momentum == nil || consensus.Momentum.Entries != 3 {
+		t.Errorf("expected momentum over the 3 windowed entries, got %+v", consensus.Momentum)
+	}
+	consensus.Momentum = nil
+	if *consensus != want {
+		t.Errorf("expected %+v, got %+v", want, *consensus)
+	}
+}
+
+func TestGetTargetConsensuses(t *testing.T) {
+	now := time.Now().UTC()
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = []stockviewer.Stock{
+		{ID: "1", Ticker: "AAPL", TargetTo: stockviewer.Price(150), CreatedAt: now.AddDate(0, 0, -30), UpdatedAt: now.AddDate(0, 0, -10)},
+		{ID: "2", Ticker: "AAPL", TargetTo: stockviewer.Price(210), CreatedAt: now.AddDate(0, 0, -20), UpdatedAt: now.AddDate(0, 0, -20)},
+		{ID: "3", Ticker: "AAPL", TargetTo: stockviewer.Price(185), CreatedAt: now.AddDate(0, 0, -10), UpdatedAt: now.AddDate(0, 0, -30)},
+		{ID: "4", Ticker: "AAPL", TargetTo: stockviewer.Price(400), CreatedAt: now.AddDate(0, 0, -200), UpdatedAt: now},
+		{ID: "5", Ticker: "MSFT", TargetTo: stockviewer.Price(320), CreatedAt: now, UpdatedAt: now},
+	}
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	consensuses, err := service.GetTargetConsensuses(context.Background(), []string{" msft", "aapl", "MSFT", "none", ""}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var tickers []string
+	for _, consensus := range consensuses {
+		tickers = append(tickers, consensus.Ticker)
+	}
+	if fmt.Sprint(tickers) != "[MSFT AAPL NONE]" {
+		t.Fatalf("expected summaries in request order without repeats, got %v", tickers)
+	}
+	for _, consensus := range consensuses[:2] {
+		single, err := service.GetTargetConsensus(context.Background(), consensus.Ticker, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(consensus, *single) {
+			t.Errorf("%s: expected the single-ticker summary %+v, got %+v", consensus.Ticker, *single, consensus)
+		}
+	}
+	if consensuses[1].Momentum == nil || consensuses[1].Momentum.Entries != 3 {
+		t.Errorf("expected AAPL momentum over 3 entries, got %+v", consensuses[1].Momentum)
+	}
+	none := stockviewer.TargetConsensus{Ticker: "NONE", WindowDays: 90}
+	if consensuses[2] != none {
+		t.Errorf("expected an empty summary for a ticker without targets, got %+v", consensuses[2])
+	}
+}
+
+func TestGetTargetConsensuses_InvalidTickers(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher())
+
+	capped := make([]string, stockviewer.MaxConsensusTickers)
+	for i := range capped {
+		capped[i] = fmt.Sprintf("T%d", i)
+	}
+	if _, err := service.GetTargetConsensuses(context.Background(), capped, 0); err != nil {
+		t.Errorf("expected %d tickers to be accepted, got %v", len(capped), err)
+	}
+	// Repeats do not count against the cap.
+	if _, err := service.GetTargetConsensuses(context.Background(), append(capped, "t0"), 0); err != nil {
+		t.Errorf("expected a repeated ticker to be accepted, got %v", err)
+	}
+
+	for name, tickers := range map[string][]string{
+		"too many": append(capped, "EXTRA"),
+		"none":     {"", " "},
+	} {
+		_, err := service.GetTargetConsensuses(context.Background(), tickers, 0)
+		var validationErr stockviewer.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != "tickers" {
+			t.Errorf("%s: expected validation error on tickers, got %v", name, err)
+		}
+	}
+}
+
+func TestGetTargetConsensus_InvalidWindow(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher())
+
+	_, err := service.GetTargetConsensus(context.Background(), "AAPL", -1)
+
+	var validationErr stockviewer.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "days" {
+		t.Errorf("expected validation error on days, got %v", err)
+	}
+}
+
+func TestGetBrokerageStats_SortedByAvgScore(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	stats, err := service.GetBrokerageStats(context.Background(), stockviewer.BrokerageSortAvgScore)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"Morgan Stanley", "Goldman Sachs", "JP Morgan"}
+	for i, brokerage := range want {
+		if stats[i].Brokerage != brokerage {
+			t.Errorf("position %d: expected %s, got %s", i, brokerage, stats[i].Brokerage)
+		}
+	}
+}
+
+func TestGetBrokerageStats_InvalidSort(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher())
+
+	_, err := service.GetBrokerageStats(context.Background(), "name")
+
+	var validationErr stockviewer.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+}
+
+func TestGetStocks_ExcludesUnratedByDefault(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           []Option
+		includeUnrated bool
+		wantRatedOnly  bool
+	}{
+		{"default excludes unrated", nil, false, true},
+		{"include_unrated overrides", nil, true, false},
+		{"exclusion disabled", []Option{WithExcludeUnrated(false)}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := mocks.NewMockStocksRepository()
+			service := NewService(mockRepo, mocks.NewMockStocksFetcher(), tt.opts...)
+
+			_, err := service.GetStocks(context.Background(), stockviewer.StockFilter{IncludeUnrated: tt.includeUnrated})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if mockRepo.LastFilter.RatedOnly != tt.wantRatedOnly {
+				t.Errorf("expected RatedOnly %v, got %v", tt.wantRatedOnly, mockRepo.LastFilter.RatedOnly)
+			}
+		})
+	}
+}
+
+func TestGetStocks_PageSizeLimits(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher(),
+		WithPageLimits(stockviewer.PageLimits{DefaultPageSize: 10, MaxPageSize: 50}))
+
+	for _, size := range []int{51, 500, -1} {
+		_, err := service.GetStocks(context.Background(), stockviewer.StockFilter{PageSize: size})
+		var validationErr stockviewer.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Message != "must be between 1 and 50" {
+			t.Errorf("page size %d: expected a range validation error, got %v", size, err)
+		}
+	}
+
+	result, err := service.GetStocks(context.Background(), stockviewer.StockFilter{PageSize: 50})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.PageSize != 50 {
+		t.Errorf("expected page size 50, got %d", result.PageSize)
+	}
+
+	result, err = service.GetStocks(context.Background(), stockviewer.StockFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.PageSize != 10 {
+		t.Errorf("expected default page size 10, got %d", result.PageSize)
+	}
+}
+
+func TestGetStocks_GroupByTicker(t *testing.T) {
+	now := time.Now().UTC()
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = []stockviewer.Stock{
+		{ID: "1", Ticker: "AAPL", Action: "initiated by", UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "2", Ticker: "AAPL", Action: "upgraded by", UpdatedAt: now},
+		{ID: "3", Ticker: "MSFT", Action: "target raised by", UpdatedAt: now.Add(-time.Hour)},
+	}
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	result, err := service.GetStocks(context.Background(), stockviewer.StockFilter{GroupBy: stockviewer.GroupByTicker, PageSize: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.TotalItems != 2 {
+		t.Errorf("expected total over distinct tickers (2), got %d", result.TotalItems)
+	}
+	if result.TotalPages != 2 {
+		t.Errorf("expected 2 pages of one ticker each, got %d", result.TotalPages)
+	}
+	if result.Data[0].ID != "2" {
+		t.Errorf("expected the latest AAPL entry, got %s", result.Data[0].ID)
+	}
+}
+
+func TestGetChanges(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.Stocks[0].UpdatedAt = base.Add(3 * time.Hour)
+	mockRepo.Stocks[1].UpdatedAt = base.Add(-time.Hour)
+	mockRepo.Stocks[2].UpdatedAt = base.Add(time.Hour)
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	changes, err := service.GetChanges(context.Background(), base, "", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(changes.Stocks) != 2 {
+		t.Fatalf("expected 2 changed stocks, got %d", len(changes.Stocks))
+	}
+	if changes.Stocks[0].ID != mockRepo.Stocks[2].ID || changes.Stocks[1].ID != mockRepo.Stocks[0].ID {
+		t.Errorf("expected oldest change first, got %s then %s", changes.Stocks[0].ID, changes.Stocks[1].ID)
+	}
+	if !changes.NextSince.Equal(base.Add(3 * time.Hour)) {
+		t.Errorf("expected next_since at the last change, got %v", changes.NextSince)
+	}
+
+	limited, err := service.GetChanges(context.Background(), base, "", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(limited.Stocks) != 1 || !limited.NextSince.Equal(base.Add(time.Hour)) {
+		t.Errorf("expected one change with next_since at it, got %d and %v", len(limited.Stocks), limited.NextSince)
+	}
+}
+
+func TestGetChanges_ResumesWithinTiedTimestamp(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tied := base.Add(time.Hour)
+	for i := range mockRepo.Stocks {
+		mockRepo.Stocks[i].UpdatedAt = tied
+	}
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	// Every stock shares updated_at and the batch holds fewer of them, so
+	// only the id in the cursor can tell the polls apart.
+	seen := make(map[string]bool)
+	since, afterID := base, ""
+	for poll := 0; poll < len(mockRepo.Stocks)+1; poll++ {
+		changes, err := service.GetChanges(context.Background(), since, afterID, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(changes.Stocks) == 0 {
+			break
+		}
+		for _, stock := range changes.Stocks {
+			if seen[stock.ID] {
+				t.Errorf("expected %s only once, got it again on poll %d", stock.ID, poll)
+			}
+			seen[stock.ID] = true
+		}
+		if !changes.NextSince.Equal(tied) {
+			t.Errorf("expected next_since at the tied timestamp, got %v", changes.NextSince)
+		}
+		since, afterID = changes.NextSince, changes.NextAfterID
+	}
+	if len(seen) != len(mockRepo.Stocks) {
+		t.Errorf("expected all %d tied stocks across the polls, got %d", len(mockRepo.Stocks), len(seen))
+	}
+}
+
+func TestGetChanges_NoChangesKeepsSince(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+	since := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	changes, err := service.GetChanges(context.Background(), since, "", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes.Stocks) != 0 {
+		t.Errorf("expected no changes, got %d", len(changes.Stocks))
+	}
+	if !changes.NextSince.Equal(since) || changes.NextAfterID != "" {
+		t.Errorf("expected the cursor to be echoed, got %v and %q", changes.NextSince, changes.NextAfterID)
+	}
+}
+
+func TestGetChanges_NegativeLimit(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher())
+
+	_, err := service.GetChanges(context.Background(), time.Now(), "", -1)
+
+	var validationErr stockviewer.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("expected ValidationError, got %v", err)
+	}
+}
+
+func TestGetStocks_InvalidFilter(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher(),
+		WithPageLimits(stockviewer.PageLimits{DefaultPageSize: 10, MaxPageSize: 50}))
+
+	tests := []struct {
+		name   string
+		filter stockviewer.StockFilter
+		field  string
+	}{
+		{"unknown field", stockviewer.StockFilter{SortBy: "password"}, "sort_by"},
+		{"unknown order", stockviewer.StockFilter{SortBy: "ticker", SortOrder: "up"}, "sort_order"},
+		{"negative page", stockviewer.StockFilter{Page: -1}, "page"},
+		{"page size above max", stockviewer.StockFilter{PageSize: 51}, "page_size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.GetStocks(context.Background(), tt.filter)
+
+			var validationErr stockviewer.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			if validationErr.Field != tt.field {
+				t.Errorf("expected field %q, got %q", tt.field, validationErr.Field)
+			}
+		})
+	}
+}
+
+func TestGetStockHistory(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.Stocks = []stockviewer.Stock{
+		{ID: "old", Ticker: "AAPL", Brokerage: "Goldman Sachs", CreatedAt: base},
+		{ID: "other", Ticker: "MSFT", Brokerage: "Goldman Sachs", CreatedAt: base.Add(time.Hour)},
+		{ID: "new", Ticker: "AAPL", Brokerage: "Morgan Stanley", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: "mid", Ticker: "AAPL", Brokerage: "JPMorgan", CreatedAt: base.Add(time.Hour)},
+	}
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	result, err := service.GetStockHistory(context.Background(), "AAPL", stockviewer.StockFilter{Page: 1, PageSize: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.TotalItems != 3 || result.TotalPages != 2 {
+		t.Errorf("expected 3 items over 2 pages, got %d over %d", result.TotalItems, result.TotalPages)
+	}
+	if len(result.Data) != 2 || result.Data[0].ID != "new" || result.Data[1].ID != "mid" {
+		t.Errorf("expected newest entries first, got %+v", result.Data)
+	}
+	if mockRepo.LastFilter.SortBy != "created_at" || mockRepo.LastFilter.SortOrder != "DESC" {
+		t.Errorf("expected created_at DESC, got %s %s", mockRepo.LastFilter.SortBy, mockRepo.LastFilter.SortOrder)
+	}
+}
+
+func TestGetStockHistory_RequiresTicker(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher())
+
+	_, err := service.GetStockHistory(context.Background(), " ", stockviewer.StockFilter{})
+
+	var validationErr stockviewer.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("expected ValidationError, got %v", err)
+	}
+}
+
+func TestGetStockHistory_RejectsNegativePage(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher())
+
+	_, err := service.GetStockHistory(context.Background(), "AAPL", stockviewer.StockFilter{Page: -1})
+
+	var validationErr stockviewer.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "page" {
+		t.Errorf("expected a validation error on page, got %v", err)
+	}
+}
+
+func TestUpdateStock(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+	score := 12.5
+	notes := "  Waiting on earnings  "
+
+	stock, err := service.UpdateStock(context.Background(), mocks.AAPLStockID, stockviewer.StockUpdate{
+		RecommendScore: &score,
+		Notes:          &notes,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stock.RecommendScore != 12.5 || !stock.ScoreOverridden {
+		t.Errorf("expected overridden score 12.5, got %v (overridden %v)", stock.RecommendScore, stock.ScoreOverridden)
+	}
+	if stock.Notes != "Waiting on earnings" {
+		t.Errorf("expected trimmed notes, got %q", stock.Notes)
+	}
+}
+
+func TestUpdateStock_Validation(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher())
+	tooHigh := 100.5
+	negative := -1.0
+
+	tests := []struct {
+		name   string
+		update stockviewer.StockUpdate
+		field  string
+	}{
+		{"score above range", stockviewer.StockUpdate{RecommendScore: &tooHigh}, "recommend_score"},
+		{"negative score", stockviewer.StockUpdate{RecommendScore: &negative}, "recommend_score"},
+		{"empty update", stockviewer.StockUpdate{}, "body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.UpdateStock(context.Background(), mocks.AAPLStockID, tt.update)
+
+			var validationErr stockviewer.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			if validationErr.Field != tt.field {
+				t.Errorf("expected field %q, got %q", tt.field, validationErr.Field)
+			}
+		})
+	}
+}
+
+func TestSyncStocks_KeepsManualOverrides(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks[0].RecommendScore = 5
+	mockRepo.Stocks[0].ScoreOverridden = true
+	mockRepo.Stocks[0].Notes = "Manual review"
+	fetcher := mocks.NewMockStocksFetcher()
+	fetcher.Stocks = []stockviewer.Stock{mockRepo.Stocks[0]}
+	service := NewService(mockRepo, fetcher)
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	saved := mockRepo.Stocks[len(mockRepo.Stocks)-1]
+	if saved.RecommendScore != 5 || !saved.ScoreOverridden {
+		t.Errorf("expected overridden score to survive sync, got %v", saved.RecommendScore)
+	}
+	if saved.Notes != "Manual review" {
+		t.Errorf("expected notes to survive sync, got %q", saved.Notes)
+	}
+}
+
+// unreadableStorage fails GetByID for one stock, as a dropped connection
+// would.
+type unreadableStorage struct {
+	*mocks.MockStocksRepository
+	id string
+}
+
+func (u unreadableStorage) GetByID(ctx context.Context, id string) (*stockviewer.Stock, error) {
+	if id == u.id {
+		return nil, stockviewer.StorageError{Operation: "get_by_id", Err: errors.New("connection reset")}
+	}
+	return u.MockStocksRepository.GetByID(ctx, id)
+}
+
+func TestSyncStocks_SkipsStockWhoseLookupFails(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks[0].ScoreOverridden = true
+	mockRepo.Stocks[0].Notes = "Manual review"
+	stored := len(mockRepo.Stocks)
+	fetcher := mocks.NewMockStocksFetcher()
+	fetcher.Stocks = []stockviewer.Stock{mockRepo.Stocks[0], mockRepo.Stocks[1]}
+	service := NewService(unreadableStorage{mockRepo, mockRepo.Stocks[0].ID}, fetcher)
+
+	status, err := service.SyncStocks(context.Background())
+	if err != nil {
+		t.Fatalf("expected the other stocks to be synced, got %v", err)
+	}
+	if status.Status != stockviewer.SyncPartial || !strings.Contains(status.Error, "1 of 2 stocks were not synced") || status.TotalRecords != 1 {
+		t.Errorf("expected a partial sync of 1 record reporting the skipped stock, got %+v", status)
+	}
+	// The mock appends saved stocks, so only the readable one is added and
+	// the unreadable one keeps its notes and override.
+	if len(mockRepo.Stocks) != stored+1 || mockRepo.Stocks[stored].ID != fetcher.Stocks[1].ID {
+		t.Fatalf("expected only %s to be saved, got %d stocks", fetcher.Stocks[1].ID, len(mockRepo.Stocks)-stored)
+	}
+	if mockRepo.Stocks[0].Notes != "Manual review" || !mockRepo.Stocks[0].ScoreOverridden {
+		t.Errorf("expected the stored copy to be untouched, got %+v", mockRepo.Stocks[0])
+	}
+}
+
+// retryableError mimics a driver error carrying a SQLSTATE code.
+type retryableError struct{ code string }
+
+func (e retryableError) Error() string    { return "pq: restart transaction (SQLSTATE " + e.code + ")" }
+func (e retryableError) SQLState() string { return e.code }
+
+// conflictingStorage fails the first SaveBatch calls with err, the way a
+// contended CockroachDB transaction does, then behaves normally.
+type conflictingStorage struct {
+	*mocks.MockStocksRepository
+	failures int
+	err      error
+	calls    int
+}
+
+func (c *conflictingStorage) SaveBatch(ctx context.Context, stocks []stockviewer.Stock) error {
+	c.calls++
+	if c.calls <= c.failures {
+		return stockviewer.StorageError{Operation: "save_batch", Err: c.err}
+	}
+	return c.MockStocksRepository.SaveBatch(ctx, stocks)
+}
+
+// fetcherWithStocks returns a fetcher yielding n distinct stocks, enough to
+// need more than one batch when n > 100.
+func fetcherWithStocks(n int) *mocks.MockStocksFetcher {
+	fetcher := &mocks.MockStocksFetcher{}
+	for i := 0; i < n; i++ {
+		fetcher.Stocks = append(fetcher.Stocks, stockviewer.Stock{
+			ID:       fmt.Sprintf("bulk-%d", i),
+			Ticker:   fmt.Sprintf("T%d", i),
+			RatingTo: "Buy",
+		})
+	}
+	return fetcher
+}
+
+func TestSyncStocks_RetriesRetryableSaveError(t *testing.T) {
+	storage := &conflictingStorage{
+		MockStocksRepository: &mocks.MockStocksRepository{},
+		failures:             1,
+		err:                  retryableError{code: "40001"},
+	}
+	service := NewService(storage, fetcherWithStocks(150), WithSaveRetry(2, time.Millisecond))
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if storage.calls != 2 {
+		t.Errorf("expected the batch to be saved on the second attempt, got %d calls", storage.calls)
+	}
+	if len(storage.Stocks) != 150 {
+		t.Errorf("expected all 150 stocks to be saved, got %d", len(storage.Stocks))
+	}
+}
+
+func TestSyncStocks_DoesNotRetryOtherSaveErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		retries int
+		want    int
+	}{
+		{"non-retryable code", retryableError{code: "23505"}, 2, 1},
+		{"plain error", errors.New("connection refused"), 2, 1},
+		{"retries exhausted", retryableError{code: "40001"}, 2, 3},
+		{"retries disabled", retryableError{code: "40P01"}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &conflictingStorage{
+				MockStocksRepository: &mocks.MockStocksRepository{},
+				failures:             10,
+				err:                  tt.err,
+			}
+			service := NewService(storage, fetcherWithStocks(150), WithSaveRetry(tt.retries, time.Millisecond))
+
+			if _, err := service.SyncStocks(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if storage.calls != tt.want {
+				t.Errorf("expected %d save attempts, got %d", tt.want, storage.calls)
+			}
+		})
+	}
+}
+
+func TestSyncStocks_PartialWhenIntermediateBatchFails(t *testing.T) {
+	storage := &conflictingStorage{
+		MockStocksRepository: &mocks.MockStocksRepository{},
+		failures:             1,
+		err:                  errors.New("connection refused"),
+	}
+	runs := mocks.NewMockSyncRunRepository()
+	service := NewService(storage, fetcherWithStocks(250), WithSyncRuns(runs, "pod-a", time.Minute))
+
+	status, err := service.SyncStocks(context.Background())
+	if err != nil {
+		t.Fatalf("expected the sync to save what it could, got %v", err)
+	}
+	if status.Status != stockviewer.SyncPartial || !strings.Contains(status.Error, "1 of 3 batches were not saved") {
+		t.Errorf("expected a partial sync reporting the failed batch, got %+v", status)
+	}
+	if len(storage.Stocks) != 150 {
+		t.Errorf("expected the other 150 stocks to be saved, got %d", len(storage.Stocks))
+	}
+	if len(runs.Runs) != 1 || runs.Runs[0].Status != stockviewer.SyncPartial {
+		t.Errorf("expected the run to be recorded as partial, got %+v", runs.Runs)
+	}
+}
+
+// slowFetcher yields its stocks only after delay, long enough for a sync's
+// heartbeat to fire.
+type slowFetcher struct {
+	stocks []stockviewer.Stock
+	delay  time.Duration
+}
+
+func (f slowFetcher) FetchStocks(ctx context.Context) (<-chan stockviewer.StockOrError, error) {
+	ch := make(chan stockviewer.StockOrError, len(f.stocks))
+	go func() {
+		defer close(ch)
+		time.Sleep(f.delay)
+		for _, stock := range f.stocks {
+			ch <- stockviewer.StockOrError{Stock: stock}
+		}
+	}()
+	return ch, nil
+}
+
+func TestSyncStocks_RecordsRunWithHeartbeat(t *testing.T) {
+	runs := mocks.NewMockSyncRunRepository()
+	fetcher := slowFetcher{stocks: mocks.NewMockStocksFetcher().Stocks, delay: 30 * time.Millisecond}
+	service := NewService(&mocks.MockStocksRepository{}, fetcher, WithSyncRuns(runs, "pod-a", 5*time.Millisecond))
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(runs.Runs) != 1 {
+		t.Fatalf("expected one persisted run, got %d", len(runs.Runs))
+	}
+	run := runs.Runs[0]
+	if run.Status != "completed" || run.InstanceID != "pod-a" || run.FinishedAt == nil {
+		t.Errorf("expected a finished completed run of pod-a, got %+v", run)
+	}
+	if run.TotalRecords != 3 || run.NewRecords != 3 {
+		t.Errorf("expected 3 new records, got %+v", run)
+	}
+	if runs.Heartbeats == 0 {
+		t.Error("expected the run to heartbeat while the sync was in progress")
+	}
+}
+
+func TestSyncStocks_CountsSchemaWarnings(t *testing.T) {
+	nullTo := stockviewer.DataIssue{Field: "target_to", Shape: "null", Raw: "null"}
+	boolFrom := stockviewer.DataIssue{Field: "target_from", Shape: "boolean", Raw: "false"}
+	mockRepo := mocks.NewMockStocksRepository()
+	mockFetcher := &mocks.MockStocksFetcher{
+		Stocks: []stockviewer.Stock{
+			{ID: "clean", Ticker: "CLEAN", TargetFrom: stockviewer.Price(10), TargetTo: stockviewer.Price(12)},
+			{ID: "null-1", Ticker: "NULL", TargetFrom: stockviewer.Price(10), DataIssues: []stockviewer.DataIssue{nullTo}},
+			{ID: "null-2", Ticker: "NULL", TargetFrom: stockviewer.Price(11), DataIssues: []stockviewer.DataIssue{nullTo}},
+			{ID: "bool", Ticker: "BOOL", DataIssues: []stockviewer.DataIssue{boolFrom, {Field: "target_to", Shape: "boolean", Raw: "false"}}},
+		},
+	}
+	runs := mocks.NewMockSyncRunRepository()
+	service := NewService(mockRepo, mockFetcher, WithSyncRuns(runs, "pod-a", time.Minute))
+
+	status, err := service.SyncStocks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]int{"target_to:null": 2, "target_from:boolean": 1, "target_to:boolean": 1}
+	if fmt.Sprint(status.SchemaWarnings) != fmt.Sprint(want) {
+		t.Errorf("expected schema warnings %v, got %v", want, status.SchemaWarnings)
+	}
+	if len(runs.Runs) != 1 || fmt.Sprint(runs.Runs[0].SchemaWarnings) != fmt.Sprint(want) {
+		t.Errorf("expected the run to record the schema warnings, got %+v", runs.Runs)
+	}
+	if status.TotalRecords != 4 {
+		t.Errorf("expected quarantined stocks to be saved, got %d records", status.TotalRecords)
+	}
+
+	report, err := service.GetDataQualityReport(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.QuarantinedStocks != 3 || len(report.Stocks) != 3 {
+		t.Errorf("expected 3 quarantined stocks, got %d (%d listed)", report.QuarantinedStocks, len(report.Stocks))
+	}
+	wantIssues := []stockviewer.DataIssueCount{
+		{Field: "target_to", Shape: "null", Count: 2},
+		{Field: "target_from", Shape: "boolean", Count: 1},
+		{Field: "target_to", Shape: "boolean", Count: 1},
+	}
+	if fmt.Sprint(report.Issues) != fmt.Sprint(wantIssues) {
+		t.Errorf("expected issues %v, got %v", wantIssues, report.Issues)
+	}
+	if fmt.Sprint(report.LastSyncWarnings) != fmt.Sprint(want) {
+		t.Errorf("expected the last sync's warnings %v, got %v", want, report.LastSyncWarnings)
+	}
+}
+
+func TestSyncStocks_RecordsDeadLetters(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockFetcher := &mocks.MockStocksFetcher{
+		Stocks: []stockviewer.Stock{
+			{ID: "good", Ticker: "GOOD", TargetFrom: stockviewer.Price(10), TargetTo: stockviewer.Price(12)},
+			{ID: "nan", Ticker: "NAN", TargetFrom: stockviewer.Price(10), TargetTo: stockviewer.Price(math.NaN())},
+		},
+		ItemErrors: []error{
+			stockviewer.ConversionError{Source: "karenai", Reason: "missing ticker", Payload: `{"ticker":""}`},
+			errors.New("connection reset"),
+		},
+	}
+	letters := mocks.NewMockDeadLetterRepository()
+	service := NewService(mockRepo, mockFetcher, WithDeadLetters(letters))
+
+	status, err := service.SyncStocks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.TotalRecords != 1 {
+		t.Errorf("expected only the convertible stock to be saved, got %d records", status.TotalRecords)
+	}
+	if _, err := mockRepo.GetByID(context.Background(), "nan"); err != stockviewer.ErrStockNotFound {
+		t.Errorf("expected the unscorable stock not to be saved, got %v", err)
+	}
+
+	if len(letters.Letters) != 2 {
+		t.Fatalf("expected 2 dead letters, got %+v", letters.Letters)
+	}
+	scoring, conversion := letters.Letters[0], letters.Letters[1]
+	if scoring.Source != "scoring" || scoring.Reason != "recommend score is not a number" ||
+		!strings.Contains(scoring.Payload, "ticker=NAN") {
+		t.Errorf("expected the unscorable stock to be recorded, got %+v", scoring)
+	}
+	if conversion.Source != "karenai" || conversion.Reason != "missing ticker" || conversion.Payload != `{"ticker":""}` {
+		t.Errorf("expected the conversion failure to be recorded as received, got %+v", conversion)
+	}
+}
+
+func TestSyncStocks_DeadLetterFailureKeepsSyncing(t *testing.T) {
+	mockFetcher := &mocks.MockStocksFetcher{
+		Stocks: []stockviewer.Stock{{ID: "good", Ticker: "GOOD"}},
+		ItemErrors: []error{
+			stockviewer.ConversionError{Source: "karenai", Reason: "missing ticker"},
+		},
+	}
+	letters := mocks.NewMockDeadLetterRepository()
+	letters.Error = errors.New("database unavailable")
+	service := NewService(mocks.NewMockStocksRepository(), mockFetcher, WithDeadLetters(letters))
+
+	status, err := service.SyncStocks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Status != "completed" || status.TotalRecords != 1 {
+		t.Errorf("expected the sync to complete with 1 record, got %+v", status)
+	}
+}
+
+func TestGetDataQualityReport_Empty(t *testing.T) {
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher())
+
+	report, err := service.GetDataQualityReport(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.QuarantinedStocks != 0 || report.Stocks == nil || report.Issues == nil || report.LastSyncWarnings == nil {
+		t.Errorf("expected an empty report with non-nil lists, got %+v", report)
+	}
+}
+
+func TestRecoverAbandonedSyncs(t *testing.T) {
+	now := time.Now().UTC()
+	runs := mocks.NewMockSyncRunRepository(
+		// Left behind by another instance that stopped heartbeating.
+		stockviewer.SyncRun{ID: 1, InstanceID: "pod-b", Status: "in_progress", StartedAt: now.Add(-5 * time.Minute), HeartbeatAt: now.Add(-4 * time.Minute)},
+		// Still running on a live instance.
+		stockviewer.SyncRun{ID: 2, InstanceID: "pod-c", Status: "in_progress", StartedAt: now.Add(-20 * time.Second), HeartbeatAt: now},
+		// This instance's own run from before a restart, however fresh.
+		stockviewer.SyncRun{ID: 3, InstanceID: "pod-a", Status: "in_progress", StartedAt: now.Add(-10 * time.Second), HeartbeatAt: now},
+	)
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher(), WithSyncRuns(runs, "pod-a", 15*time.Second))
+	before := abortedSyncRuns.Value()
+
+	aborted, err := service.RecoverAbandonedSyncs(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(aborted) != 2 || aborted[0].ID != 1 || aborted[1].ID != 3 {
+		t.Fatalf("expected runs 1 and 3 to be aborted, got %+v", aborted)
+	}
+	for _, run := range runs.Runs {
+		want := stockviewer.SyncRunAborted
+		if run.ID == 2 {
+			want = "in_progress"
+		}
+		if run.Status != want {
+			t.Errorf("run %d: expected status %s, got %s", run.ID, want, run.Status)
+		}
+		if run.Status == stockviewer.SyncRunAborted && (run.Note == "" || run.FinishedAt == nil) {
+			t.Errorf("run %d: expected a note and finish time, got %+v", run.ID, run)
+		}
+	}
+	if got := abortedSyncRuns.Value() - before; got != 2 {
+		t.Errorf("expected the aborted counter to grow by 2, got %d", got)
+	}
+
+	// The newest run is pod-a's aborted one, so the status no longer claims
+	// a sync is running.
+	status, err := service.GetSyncStatus(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Status != stockviewer.SyncRunAborted || status.Error == "" {
+		t.Errorf("expected aborted status with the note, got %+v", status)
+	}
+}
+
+func TestGetSyncRuns(t *testing.T) {
+	now := time.Now().UTC()
+	runs := mocks.NewMockSyncRunRepository(
+		stockviewer.SyncRun{ID: 1, Status: "completed", StartedAt: now.Add(-time.Hour)},
+		stockviewer.SyncRun{ID: 2, Status: "completed", StartedAt: now},
+	)
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher(), WithSyncRuns(runs, "pod-a", 0))
+
+	got, err := service.GetSyncRuns(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("expected only the newest run, got %+v", got)
+	}
+
+	var validationErr stockviewer.ValidationError
+	if _, err := service.GetSyncRuns(context.Background(), -1); !errors.As(err, &validationErr) {
+		t.Errorf("expected validation error for negative limit, got %v", err)
+	}
+}
+
+func TestGetSyncSuccessRate(t *testing.T) {
+	now := time.Now().UTC()
+	finished := now.Add(-time.Minute)
+	runs := mocks.NewMockSyncRunRepository(
+		stockviewer.SyncRun{ID: 1, Status: "completed", StartedAt: now.Add(-time.Hour), FinishedAt: &finished},
+		stockviewer.SyncRun{ID: 2, Status: "completed", StartedAt: now.Add(-2 * time.Hour), FinishedAt: &finished},
+		stockviewer.SyncRun{ID: 3, Status: stockviewer.SyncPartial, StartedAt: now.Add(-3 * time.Hour), FinishedAt: &finished},
+		stockviewer.SyncRun{ID: 4, Status: "error", StartedAt: now.Add(-4 * time.Hour), FinishedAt: &finished},
+		stockviewer.SyncRun{ID: 5, Status: stockviewer.SyncRunAborted, StartedAt: now.Add(-5 * time.Hour), FinishedAt: &finished},
+		// Outside the window.
+		stockviewer.SyncRun{ID: 6, Status: "error", StartedAt: now.Add(-30 * time.Hour), FinishedAt: &finished},
+		// Still running on another instance.
+		stockviewer.SyncRun{ID: 7, InstanceID: "pod-b", Status: "in_progress", StartedAt: now, HeartbeatAt: now},
+	)
+	service := NewService(mocks.NewMockStocksRepository(), mocks.NewMockStocksFetcher(),
+		WithSyncRuns(runs, "pod-a", 15*time.Second), WithSuccessRateWindow(24*time.Hour))
+
+	if rate := service.GetSyncSuccessRate(context.Background()); rate != nil {
+		t.Fatalf("expected no rate before it is computed, got %+v", rate)
+	}
+
+	if _, err := service.RecoverAbandonedSyncs(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rate := service.GetSyncSuccessRate(context.Background())
+	if rate == nil || rate.Rate == nil {
+		t.Fatalf("expected a rate after startup recovery, got %+v", rate)
+	}
+	if rate.Completed != 2 || rate.Partial != 1 || rate.Failed != 2 || *rate.Rate != 0.4 {
+		t.Errorf("expected 2 completed, 1 partial and 2 failed for a 0.4 rate, got %+v (rate %v)", rate, *rate.Rate)
+	}
+	if got := syncSuccess.Get("rate").(*expvar.Float).Value(); got != 0.4 {
+		t.Errorf("expected the published rate to be 0.4, got %v", got)
+	}
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rate = service.GetSyncSuccessRate(context.Background())
+	if rate.Completed != 3 || *rate.Rate != 0.5 {
+		t.Errorf("expected the finished sync to raise the rate to 0.5, got %+v (rate %v)", rate, *rate.Rate)
+	}
+}
+
+// countingEnricher maps tickers to a sector and industry and counts lookups.
+type countingEnricher struct {
+	sectors map[string][2]string
+	err     error
+	calls   map[string]int
+}
+
+func (e *countingEnricher) EnrichStock(ctx context.Context, ticker string) (string, string, error) {
+	if e.calls == nil {
+		e.calls = make(map[string]int)
+	}
+	e.calls[ticker]++
+	if e.err != nil {
+		return "", "", e.err
+	}
+	found := e.sectors[ticker]
+	return found[0], found[1], nil
+}
+
+func TestSyncStocks_EnrichesOncePerTicker(t *testing.T) {
+	mockRepo := &mocks.MockStocksRepository{}
+	fetcher := &mocks.MockStocksFetcher{Stocks: []stockviewer.Stock{
+		{ID: "a-1", Ticker: "AAPL", RatingTo: "Buy"},
+		{ID: "a-2", Ticker: "AAPL", RatingTo: "Hold"},
+		{ID: "x-1", Ticker: "XYZ", RatingTo: "Buy"},
+	}}
+	enricher := &countingEnricher{sectors: map[string][2]string{
+		"AAPL": {"Technology", "Consumer Electronics"},
+	}}
+	service := NewService(mockRepo, fetcher, WithEnricher(enricher))
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if enricher.calls["AAPL"] != 1 || enricher.calls["XYZ"] != 1 {
+		t.Errorf("expected one lookup per ticker, got %v", enricher.calls)
+	}
+	for _, stock := range mockRepo.Stocks {
+		wantSector := ""
+		if stock.Ticker == "AAPL" {
+			wantSector = "Technology"
+		}
+		if stock.Sector != wantSector {
+			t.Errorf("%s: expected sector %q, got %q", stock.ID, wantSector, stock.Sector)
+		}
+	}
+}
+
+func TestSyncStocks_EnrichmentFailureKeepsStoredValues(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks[0].Sector = "Technology"
+	mockRepo.Stocks[0].Industry = "Consumer Electronics"
+	stored := mockRepo.Stocks[0]
+	fetcher := &mocks.MockStocksFetcher{Stocks: []stockviewer.Stock{
+		{ID: stored.ID, Ticker: stored.Ticker, RatingTo: "Buy"},
+	}}
+	service := NewService(mockRepo, fetcher, WithEnricher(&countingEnricher{err: errors.New("lookup unavailable")}))
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	saved := mockRepo.Stocks[len(mockRepo.Stocks)-1]
+	if saved.Sector != "Technology" || saved.Industry != "Consumer Electronics" {
+		t.Errorf("expected stored sector and industry to be kept, got %q / %q", saved.Sector, saved.Industry)
+	}
+}
+
+func TestSyncStocks_DuplicateKeepsLocalFields(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks[0].Notes = "Watch the earnings call"
+	stored := mockRepo.Stocks[0]
+	fetcher := &mocks.MockStocksFetcher{Stocks: []stockviewer.Stock{
+		{ID: stored.ID, Ticker: stored.Ticker, RatingTo: "Hold"},
+		{ID: stored.ID, Ticker: stored.Ticker, RatingTo: "Buy"},
+	}}
+	service := NewService(mockRepo, fetcher)
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	saved := mockRepo.Stocks[len(mockRepo.Stocks)-1]
+	if saved.RatingTo != "Buy" || saved.Notes != stored.Notes {
+		t.Errorf("expected the latest duplicate with the stored notes, got rating %q notes %q", saved.RatingTo, saved.Notes)
+	}
+}
+
+func TestGetStocks_SectorFilter(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks[0].Sector = "Technology"
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	result, err := service.GetStocks(context.Background(), stockviewer.StockFilter{Sector: "Technology"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Data) != 1 || result.Data[0].ID != mockRepo.Stocks[0].ID {
+		t.Errorf("expected only %s, got %+v", mockRepo.Stocks[0].ID, result.Data)
+	}
+}
+
+func TestGetNewTickers_LastSync(t *testing.T) {
+	mockRepo := mocks.NewMockStocksRepository()
+	for i := range mockRepo.Stocks {
+		mockRepo.Stocks[i].CreatedAt = time.Now().UTC().Add(-48 * time.Hour)
+	}
+	existingTicker := mockRepo.Stocks[0].Ticker
+	fetcher := &mocks.MockStocksFetcher{Stocks: []stockviewer.Stock{
+		// A new entry for a ticker covered before the sync is not new coverage.
+		{ID: "again-1", Ticker: existingTicker, Company: "Again", RatingTo: "Buy"},
+		{ID: "new-1", Ticker: "NEWC", Company: "New Co", RatingTo: "Buy"},
+	}}
+	service := NewService(mockRepo, fetcher)
+	ctx := context.Background()
+
+	empty, err := service.GetNewTickers(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(empty.Tickers) != 0 || !empty.To.IsZero() {
+		t.Errorf("expected no tickers and no window before any sync, got %+v", empty)
+	}
+
+	if _, err := service.SyncStocks(ctx); err != nil {
+		t.Fatalf("unexpected sync error: %v", err)
+	}
+
+	result, err := service.GetNewTickers(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Tickers) != 1 || result.Tickers[0].Ticker != "NEWC" {
+		t.Errorf("expected only NEWC to be newly covered, got %+v", result.Tickers)
+	}
+	if result.From.After(result.Tickers[0].FirstSeen) || result.To.Before(result.Tickers[0].FirstSeen) {
+		t.Errorf("expected first_seen within [%s, %s], got %s", result.From, result.To, result.Tickers[0].FirstSeen)
+	}
+}
+
+func TestGetNewTickers_PersistedRunAndSince(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	finish := start.Add(3 * time.Minute)
+	mockRepo := &mocks.MockStocksRepository{Stocks: []stockviewer.Stock{
+		{ID: "old", Ticker: "OLD", CreatedAt: start.Add(-time.Hour)},
+		{ID: "during", Ticker: "DUR", CreatedAt: start.Add(time.Minute)},
+		{ID: "after", Ticker: "AFT", CreatedAt: finish.Add(time.Hour)},
+	}}
+	runs := mocks.NewMockSyncRunRepository(
+		stockviewer.SyncRun{ID: 1, Status: "completed", StartedAt: start, FinishedAt: &finish},
+		stockviewer.SyncRun{ID: 2, Status: "error", StartedAt: finish.Add(time.Hour), FinishedAt: &finish},
+	)
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher(), WithSyncRuns(runs, "pod-a", 0))
+	ctx := context.Background()
+
+	result, err := service.GetNewTickers(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Tickers) != 1 || result.Tickers[0].Ticker != "DUR" {
+		t.Errorf("expected only DUR from the last completed run, got %+v", result.Tickers)
+	}
+
+	since := start
+	result, err = service.GetNewTickers(ctx, &since)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Tickers) != 2 || result.Tickers[0].Ticker != "DUR" || result.Tickers[1].Ticker != "AFT" {
+		t.Errorf("expected DUR then AFT since %s, got %+v", since, result.Tickers)
+	}
+}
+
+func TestSyncStocks_RecordsSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	mockFetcher := &mocks.MockStocksFetcher{}
+	for i := 0; i < 150; i++ {
+		mockFetcher.Stocks = append(mockFetcher.Stocks, stockviewer.Stock{ID: fmt.Sprintf("span-%d", i), Ticker: "RMTI"})
+	}
+	service := NewService(mocks.NewMockStocksRepository(), mockFetcher)
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var syncSpan sdktrace.ReadOnlySpan
+	var batches []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "stocks.sync":
+			syncSpan = span
+		case "stocks.SaveBatch", "stocks.SaveBatchWithEvent":
+			batches = append(batches, span)
+		}
+	}
+	if syncSpan == nil {
+		t.Fatal("expected a stocks.sync span")
+	}
+	if len(batches) != 2 {
+		t.Fatalf("expected 2 batch spans, got %d", len(batches))
+	}
+	for _, span := range batches {
+		if span.Parent().SpanID() != syncSpan.SpanContext().SpanID() {
+			t.Errorf("expected %s to be a child of stocks.sync", span.Name())
+		}
+	}
+}
+
+type countingInvalidator struct {
+	calls int
+}
+
+func (c *countingInvalidator) InvalidateCache() {
+	c.calls++
+}
+
+func TestSyncStocks_InvalidatesCaches(t *testing.T) {
+	cache := &countingInvalidator{}
+	service := NewService(mocks.NewMockStocksRepository(), &mocks.MockStocksFetcher{}, WithCacheInvalidation(cache))
+
+	if _, err := service.SyncStocks(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cache.calls != 1 {
+		t.Errorf("expected one invalidation after the sync, got %d", cache.calls)
+	}
+}
+
+func TestSyncStocks_FailedSyncKeepsCaches(t *testing.T) {
+	cache := &countingInvalidator{}
+	fetcher := &mocks.MockStocksFetcher{Error: errors.New("upstream down")}
+	service := NewService(mocks.NewMockStocksRepository(), fetcher, WithCacheInvalidation(cache))
+
+	if _, err := service.SyncStocks(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if cache.calls != 0 {
+		t.Errorf("expected no invalidation after a failed sync, got %d", cache.calls)
+	}
+}
+
+func TestGetTargetConsensus_Momentum(t *testing.T) {
+	now := time.Now().UTC()
+	mockRepo := mocks.NewMockStocksRepository()
+	mockRepo.Stocks = []stockviewer.Stock{
+		{ID: "r1", Ticker: "RISE", TargetTo: stockviewer.Price(100), CreatedAt: now.AddDate(0, 0, -60), UpdatedAt: now},
+		{ID: "r2", Ticker: "RISE", TargetTo: stockviewer.Price(115), CreatedAt: now.AddDate(0, 0, -30), UpdatedAt: now},
+		{ID: "r3", Ticker: "RISE", TargetTo: stockviewer.Price(130), CreatedAt: now, UpdatedAt: now},
+		{ID: "f1", Ticker: "FALL", TargetTo: stockviewer.Price(90), CreatedAt: now, UpdatedAt: now},
+		{ID: "f2", Ticker: "FALL", TargetTo: stockviewer.Price(120), CreatedAt: now.AddDate(0, 0, -40), UpdatedAt: now},
+		{ID: "f3", Ticker: "FALL", TargetTo: stockviewer.Price(200), CreatedAt: now.AddDate(0, 0, -200), UpdatedAt: now.AddDate(0, 0, -200)},
+	}
+	service := NewService(mockRepo, mocks.NewMockStocksFetcher())
+
+	tests := []struct {
+		ticker    string
+		direction stockviewer.MomentumDirection
+		entries   int
+	}{
+		{ticker: "rise", direction: stockviewer.MomentumRising, entries: 3},
+		{ticker: "FALL", direction: stockviewer.MomentumFalling, entries: 2},
+	}
+	for _, tt := range tests {
+		consensus, err := service.GetTargetConsensus(context.Background(), tt.ticker, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		momentum := consensus.Momentum
+		if momentum == nil || momentum.Direction != tt.direction || momentum.Entries != tt.entries {
+			t.Errorf("%s: expected %s momentum over %d entries, got %+v", tt.ticker, tt.direction, tt.entries, momentum)
+		}
+	}
+}
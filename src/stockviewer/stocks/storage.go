@@ -3,21 +3,77 @@ package stocks
 import (
 	"context"
 	"fmt"
+	"log"
+	"math"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/query"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultTrigramThreshold matches pg_trgm's own similarity threshold.
+	defaultTrigramThreshold = 0.3
+	// sqlStateUndefinedFunction is raised for similarity() when pg_trgm is
+	// not installed.
+	sqlStateUndefinedFunction = "42883"
 )
 
 type Storage struct {
-	db *gorm.DB
+	db               *gorm.DB
+	pageLimits       stockviewer.PageLimits
+	trigramThreshold float64
+	// trigramMissing is set once a fuzzy search finds pg_trgm unavailable;
+	// later fuzzy searches go straight to substring matching.
+	trigramMissing atomic.Bool
+}
+
+// StorageOption customizes optional Storage settings.
+type StorageOption func(*Storage)
+
+// WithStoragePageLimits sets the page size bounds applied to GetAll.
+func WithStoragePageLimits(limits stockviewer.PageLimits) StorageOption {
+	return func(s *Storage) {
+		s.pageLimits = limits.WithDefaults()
+	}
 }
 
-func NewStorage(db *gorm.DB) (*Storage, error) {
-	if err := db.AutoMigrate(&stockviewer.Stock{}); err != nil {
-		return nil, stockviewer.StorageError{Operation: "migrate", Err: err}
+// WithTrigramThreshold sets the minimum pg_trgm similarity, between 0 and 1,
+// for a fuzzy search to match a ticker or company.
+func WithTrigramThreshold(threshold float64) StorageOption {
+	return func(s *Storage) {
+		if threshold > 0 && threshold <= 1 {
+			s.trigramThreshold = threshold
+		}
 	}
-	return &Storage{db: db}, nil
+}
+
+// NewStorage expects the schema to be in place; see package schema.
+func NewStorage(db *gorm.DB, opts ...StorageOption) *Storage {
+	s := &Storage{
+		db:               db,
+		pageLimits:       stockviewer.DefaultPageLimits(),
+		trigramThreshold: defaultTrigramThreshold,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return stockviewer.StorageError{Operation: "ping", Err: err}
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return stockviewer.StorageError{Operation: "ping", Err: err}
+	}
+	return nil
 }
 
 func (s *Storage) Save(ctx context.Context, stock stockviewer.Stock) error {
@@ -40,6 +96,36 @@ func (s *Storage) SaveBatch(ctx context.Context, stocks []stockviewer.Stock) err
 	return nil
 }
 
+// SaveBatchWithEvent saves stocks and records an outbox event atomically, so
+// the event survives a crash that happens after the data is committed.
+func (s *Storage) SaveBatchWithEvent(ctx context.Context, stocks []stockviewer.Stock, event stockviewer.OutboxEvent) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(stocks) > 0 {
+			if err := tx.Save(&stocks).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&event).Error
+	})
+	if err != nil {
+		return stockviewer.StorageError{Operation: "save_batch_with_event", Err: err}
+	}
+	return nil
+}
+
+// Update applies a partial update to one stock; updated_at is bumped by
+// gorm.
+func (s *Storage) Update(ctx context.Context, id string, updates map[string]interface{}) error {
+	result := s.db.WithContext(ctx).Model(&stockviewer.Stock{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return stockviewer.StorageError{Operation: "update", Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return stockviewer.ErrStockNotFound
+	}
+	return nil
+}
+
 func (s *Storage) GetByID(ctx context.Context, id string) (*stockviewer.Stock, error) {
 	var stock stockviewer.Stock
 	result := s.db.WithContext(ctx).Where("id = ?", id).First(&stock)
@@ -52,29 +138,46 @@ func (s *Storage) GetByID(ctx context.Context, id string) (*stockviewer.Stock, e
 	return &stock, nil
 }
 
-func (s *Storage) GetByTicker(ctx context.Context, ticker string) ([]stockviewer.Stock, error) {
+// GetTickerHistory returns every entry of a ticker, honoring the filter's
+// sort and pagination fields. Other filter fields are ignored. Without an
+// explicit sort the newest entries come first.
+func (s *Storage) GetTickerHistory(ctx context.Context, ticker string, filter stockviewer.StockFilter) ([]stockviewer.Stock, int64, error) {
 	var stocks []stockviewer.Stock
-	result := s.db.WithContext(ctx).Where("ticker = ?", ticker).Find(&stocks)
-	if result.Error != nil {
-		return nil, stockviewer.StorageError{Operation: "get_by_ticker", Err: result.Error}
+	var total int64
+
+	query := s.db.WithContext(ctx).Model(&stockviewer.Stock{}).Where("ticker = ?", ticker)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, stockviewer.StorageError{Operation: "count_ticker_history", Err: err}
 	}
-	return stocks, nil
+
+	query = applySorting(query, tickerHistorySort(filter))
+	query = applyPagination(query, filter, s.pageLimits.For(ctx))
+
+	if err := query.Find(&stocks).Error; err != nil {
+		return nil, 0, stockviewer.StorageError{Operation: "get_ticker_history", Err: err}
+	}
+	return stocks, total, nil
+}
+
+func tickerHistorySort(filter stockviewer.StockFilter) stockviewer.StockFilter {
+	if filter.SortBy == "" {
+		filter.SortBy = "created_at"
+		filter.SortOrder = "DESC"
+	}
+	return filter
 }
 
 func (s *Storage) GetAll(ctx context.Context, filter stockviewer.StockFilter) ([]stockviewer.Stock, int64, error) {
 	var stocks []stockviewer.Stock
 	var total int64
 
-	query := s.db.WithContext(ctx).Model(&stockviewer.Stock{})
-
-	query = applyFilters(query, filter)
-
+	query := filteredQuery(s.db.WithContext(ctx), filter)
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, stockviewer.StorageError{Operation: "count", Err: err}
 	}
 
 	query = applySorting(query, filter)
-	query = applyPagination(query, filter)
+	query = applyPagination(query, filter, s.pageLimits.For(ctx))
 
 	if err := query.Find(&stocks).Error; err != nil {
 		return nil, 0, stockviewer.StorageError{Operation: "get_all", Err: err}
@@ -83,6 +186,15 @@ func (s *Storage) GetAll(ctx context.Context, filter stockviewer.StockFilter) ([
 	return stocks, total, nil
 }
 
+// filteredQuery selects the stocks matching filter, collapsed to the latest
+// entry per ticker when the filter groups by ticker.
+func filteredQuery(db *gorm.DB, filter stockviewer.StockFilter) *gorm.DB {
+	if filter.GroupBy == stockviewer.GroupByTicker {
+		return latestPerTickerQuery(db, filter)
+	}
+	return applyFilters(db.Model(&stockviewer.Stock{}), filter)
+}
+
 func (s *Storage) GetTopRecommended(ctx context.Context, limit int) ([]stockviewer.Stock, error) {
 	var stocks []stockviewer.Stock
 	result := s.db.WithContext(ctx).
@@ -96,22 +208,144 @@ func (s *Storage) GetTopRecommended(ctx context.Context, limit int) ([]stockview
 	return stocks, nil
 }
 
-func (s *Storage) Search(ctx context.Context, query string, limit int) ([]stockviewer.Stock, error) {
+func (s *Storage) GetTopRecommendedByBrokerage(ctx context.Context, brokerage string, limit int) ([]stockviewer.Stock, error) {
 	var stocks []stockviewer.Stock
-	searchPattern := fmt.Sprintf("%%%s%%", strings.ToLower(query))
-
 	result := s.db.WithContext(ctx).
-		Where("LOWER(ticker) LIKE ? OR LOWER(company) LIKE ?", searchPattern, searchPattern).
+		Where("brokerage = ?", brokerage).
 		Order("recommend_score DESC").
 		Limit(limit).
 		Find(&stocks)
 
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_top_recommended_by_brokerage", Err: result.Error}
+	}
+	return stocks, nil
+}
+
+// Search matches the words of query against ticker and company with
+// full-text search, along with the tickers query is a prefix of. An exact
+// ticker ranks first, then ticker prefixes, then word matches by ts_rank, so
+// "A" finds Agilent ahead of AAPL whatever their scores. When that finds
+// nothing, as for a company fragment or a query without lexemes, it falls
+// back to matching query as a substring.
+//
+// With fuzzy it instead matches substrings and values similar to query by
+// pg_trgm trigram similarity, so typos still find results. Without pg_trgm
+// the non-fuzzy results are topped up with entries within a few edits of
+// query; see editDistanceSearch. Every result's MatchType says how it
+// matched.
+func (s *Storage) Search(ctx context.Context, query string, limit int, fuzzy bool) ([]stockviewer.Stock, error) {
+	var stocks []stockviewer.Stock
+	if fuzzy && !s.trigramMissing.Load() {
+		result := fuzzySearchQuery(s.db.WithContext(ctx), query, limit, s.trigramThreshold).Find(&stocks)
+		if result.Error == nil {
+			return labelMatches(stocks, query, stockviewer.MatchFuzzy), nil
+		}
+		if stockviewer.SQLState(result.Error) != sqlStateUndefinedFunction {
+			return nil, stockviewer.StorageError{Operation: "fuzzy_search", Err: result.Error}
+		}
+		log.Printf("pg_trgm is not available, fuzzy search falls back to edit distance: %v", result.Error)
+		s.trigramMissing.Store(true)
+	}
+
+	stocks, err := s.textSearch(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	labelMatches(stocks, query, stockviewer.MatchText)
+	if fuzzy {
+		return s.editDistanceSearch(ctx, query, limit, stocks)
+	}
+	return stocks, nil
+}
+
+// textSearch is the non-fuzzy Search: full-text, then substring matching.
+func (s *Storage) textSearch(ctx context.Context, query string, limit int) ([]stockviewer.Stock, error) {
+	var stocks []stockviewer.Stock
+	if err := fullTextSearchQuery(s.db.WithContext(ctx), query, limit).Find(&stocks).Error; err != nil {
+		return nil, stockviewer.StorageError{Operation: "full_text_search", Err: err}
+	}
+	if len(stocks) > 0 {
+		return stocks, nil
+	}
+
+	result := searchQuery(s.db.WithContext(ctx), query, limit).Find(&stocks)
 	if result.Error != nil {
 		return nil, stockviewer.StorageError{Operation: "search", Err: result.Error}
 	}
 	return stocks, nil
 }
 
+// searchTSQuery parses a query with the text search configuration
+// search_vector is built with, so both produce the same lexemes.
+const searchTSQuery = "plainto_tsquery('english', ?)"
+
+// fullTextSearchQuery matches every word of query against search_vector, and
+// query as a ticker prefix. An exact ticker match ranks first, then ticker
+// prefixes, then ts_rank, then recommend_score. A query without lexemes, such
+// as punctuation or only stop words, matches only by ticker prefix.
+func fullTextSearchQuery(db *gorm.DB, query string, limit int) *gorm.DB {
+	term := strings.TrimSpace(query)
+	prefix := strings.ToLower(term) + "%"
+
+	return db.Model(&stockviewer.Stock{}).
+		Where("search_vector @@ "+searchTSQuery+" OR ticker ILIKE ?", term, prefix).
+		Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL: "CASE WHEN LOWER(ticker) = ? THEN 0 WHEN ticker ILIKE ? THEN 1 ELSE 2 END, " +
+				"ts_rank(search_vector, " + searchTSQuery + ") DESC, recommend_score DESC",
+			Vars: []interface{}{strings.ToLower(term), prefix, term},
+		}}).
+		Limit(limit)
+}
+
+// searchQuery matches query against ticker and company and ranks exact ticker
+// matches first, then ticker prefixes, then company prefixes, then any other
+// substring match. recommend_score breaks ties within a rank.
+func searchQuery(db *gorm.DB, query string, limit int) *gorm.DB {
+	term := strings.ToLower(strings.TrimSpace(query))
+	contains := fmt.Sprintf("%%%s%%", term)
+	prefix := term + "%"
+
+	return db.Model(&stockviewer.Stock{}).
+		Where("ticker ILIKE ? OR company ILIKE ?", contains, contains).
+		Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL: "CASE WHEN LOWER(ticker) = ? THEN 0 " +
+				"WHEN ticker ILIKE ? THEN 1 " +
+				"WHEN company ILIKE ? THEN 2 " +
+				"ELSE 3 END, recommend_score DESC",
+			Vars: []interface{}{term, prefix, prefix},
+		}}).
+		Limit(limit)
+}
+
+// fuzzySearchQuery extends searchQuery with tickers and companies whose
+// trigram similarity to query reaches threshold. For companies the best
+// matching word counts too (word_similarity), so "microsft" finds
+// "Microsoft Corporation". Substring matches keep their ranks ahead of
+// similarity-only matches; within a rank the closest match comes first, then
+// recommend_score.
+func fuzzySearchQuery(db *gorm.DB, query string, limit int, threshold float64) *gorm.DB {
+	term := strings.ToLower(strings.TrimSpace(query))
+	contains := fmt.Sprintf("%%%s%%", term)
+	prefix := term + "%"
+
+	return db.Model(&stockviewer.Stock{}).
+		Where("ticker ILIKE ? OR company ILIKE ? OR similarity(LOWER(ticker), ?) >= ? OR similarity(LOWER(company), ?) >= ? "+
+			"OR word_similarity(?, LOWER(company)) >= ?",
+			contains, contains, term, threshold, term, threshold, term, threshold).
+		Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL: "CASE WHEN LOWER(ticker) = ? THEN 0 " +
+				"WHEN ticker ILIKE ? THEN 1 " +
+				"WHEN company ILIKE ? THEN 2 " +
+				"WHEN ticker ILIKE ? OR company ILIKE ? THEN 3 " +
+				"ELSE 4 END, " +
+				"GREATEST(similarity(LOWER(ticker), ?), similarity(LOWER(company), ?), word_similarity(?, LOWER(company))) DESC, " +
+				"recommend_score DESC",
+			Vars: []interface{}{term, prefix, prefix, contains, contains, term, term, term},
+		}}).
+		Limit(limit)
+}
+
 func (s *Storage) Delete(ctx context.Context, id string) error {
 	result := s.db.WithContext(ctx).Delete(&stockviewer.Stock{}, "id = ?", id)
 	if result.Error != nil {
@@ -123,40 +357,459 @@ func (s *Storage) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-func (s *Storage) GetDistinctBrokerages(ctx context.Context) ([]string, error) {
-	var brokerages []string
+func (s *Storage) GetDistinctBrokerages(ctx context.Context, query stockviewer.DistinctQuery) ([]stockviewer.ValueCount, error) {
+	return s.distinctValues(ctx, "brokerage", "get_distinct_brokerages", query)
+}
+
+func (s *Storage) GetDistinctRatings(ctx context.Context, query stockviewer.DistinctQuery) ([]stockviewer.ValueCount, error) {
+	return s.distinctValues(ctx, "rating_to", "get_distinct_ratings", query)
+}
+
+func (s *Storage) GetDistinctRatingsFrom(ctx context.Context, query stockviewer.DistinctQuery) ([]stockviewer.ValueCount, error) {
+	return s.distinctValues(ctx, "rating_from", "get_distinct_ratings_from", query)
+}
+
+func (s *Storage) distinctValues(ctx context.Context, column, operation string, query stockviewer.DistinctQuery) ([]stockviewer.ValueCount, error) {
+	var values []stockviewer.ValueCount
+	result := distinctValuesQuery(s.db.WithContext(ctx), column, query).Scan(&values)
+
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: operation, Err: result.Error}
+	}
+	return values, nil
+}
+
+func (s *Storage) CountByBrokerage(ctx context.Context, filter stockviewer.StockFilter) ([]stockviewer.ValueCount, error) {
+	return s.countBy(ctx, "brokerage", "count_by_brokerage", filter)
+}
+
+func (s *Storage) CountByRating(ctx context.Context, filter stockviewer.StockFilter) ([]stockviewer.ValueCount, error) {
+	return s.countBy(ctx, "rating_to", "count_by_rating", filter)
+}
+
+func (s *Storage) CountByAction(ctx context.Context, filter stockviewer.StockFilter) ([]stockviewer.ValueCount, error) {
+	return s.countBy(ctx, "action", "count_by_action", filter)
+}
+
+func (s *Storage) countBy(ctx context.Context, column, operation string, filter stockviewer.StockFilter) ([]stockviewer.ValueCount, error) {
+	var values []stockviewer.ValueCount
+	result := countByQuery(s.db.WithContext(ctx), column, filter).Scan(&values)
+
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: operation, Err: result.Error}
+	}
+	return values, nil
+}
+
+// countByQuery counts the stocks matching filter per non-empty value of
+// column, most common first with ties in alphabetical order. Sorting and
+// pagination fields of the filter are ignored.
+func countByQuery(db *gorm.DB, column string, filter stockviewer.StockFilter) *gorm.DB {
+	return filteredQuery(db, filter).
+		Select(fmt.Sprintf("%s AS value, COUNT(*) AS count", column)).
+		Where(fmt.Sprintf("%s != ''", column)).
+		Group(column).
+		Order("count DESC").
+		Order(column)
+}
+
+// distinctValuesQuery counts the stocks per non-empty value of column, most
+// common first with ties in alphabetical order.
+func distinctValuesQuery(db *gorm.DB, column string, q stockviewer.DistinctQuery) *gorm.DB {
+	query := db.Model(&stockviewer.Stock{}).
+		Select(fmt.Sprintf("%s AS value, COUNT(*) AS count", column)).
+		Where(fmt.Sprintf("%s != ''", column))
+
+	if q.Prefix != "" {
+		query = query.Where(fmt.Sprintf("LOWER(%s) LIKE ?", column), strings.ToLower(q.Prefix)+"%")
+	}
+
+	query = query.Group(column).Order("count DESC").Order(column)
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+	if q.Offset > 0 {
+		query = query.Offset(q.Offset)
+	}
+	return query
+}
+
+func (s *Storage) GetBrokerageStats(ctx context.Context) ([]stockviewer.BrokerageStats, error) {
+	var stats []stockviewer.BrokerageStats
 	result := s.db.WithContext(ctx).
 		Model(&stockviewer.Stock{}).
-		Distinct("brokerage").
+		Select(`brokerage,
+			COUNT(*) AS total_recommendations,
+			AVG(recommend_score) AS avg_score,
+			SUM(CASE WHEN rating_to IN ? THEN 1 ELSE 0 END) AS buy_count,
+			SUM(CASE WHEN rating_to IN ? THEN 1 ELSE 0 END) AS sell_count,
+			SUM(CASE WHEN rating_to IN ? THEN 1 ELSE 0 END) AS hold_count`,
+			stockviewer.BuyRatings, stockviewer.SellRatings, stockviewer.HoldRatings).
 		Where("brokerage != ''").
-		Pluck("brokerage", &brokerages)
+		Group("brokerage").
+		Order("total_recommendations DESC, brokerage ASC").
+		Scan(&stats)
 
 	if result.Error != nil {
-		return nil, stockviewer.StorageError{Operation: "get_distinct_brokerages", Err: result.Error}
+		return nil, stockviewer.StorageError{Operation: "get_brokerage_stats", Err: result.Error}
 	}
-	return brokerages, nil
+
+	for i := range stats {
+		stats[i].AvgScore = math.Round(stats[i].AvgScore*100) / 100
+	}
+	return stats, nil
 }
 
-func (s *Storage) GetDistinctRatings(ctx context.Context) ([]string, error) {
-	var ratings []string
+func (s *Storage) GetRatingDistribution(ctx context.Context) ([]stockviewer.RatingDistribution, error) {
+	var distribution []stockviewer.RatingDistribution
 	result := s.db.WithContext(ctx).
 		Model(&stockviewer.Stock{}).
-		Distinct("rating_to").
+		Select("rating_to AS rating, COUNT(*) AS count").
 		Where("rating_to != ''").
-		Pluck("rating_to", &ratings)
+		Group("rating_to").
+		Order("count DESC, rating ASC").
+		Scan(&distribution)
+
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_rating_distribution", Err: result.Error}
+	}
+	return distribution, nil
+}
+
+func (s *Storage) GetActionDistribution(ctx context.Context) ([]stockviewer.ActionDistribution, error) {
+	var distribution []stockviewer.ActionDistribution
+	result := s.db.WithContext(ctx).
+		Model(&stockviewer.Stock{}).
+		Select("action, COUNT(*) AS count").
+		Where("action != ''").
+		Group("action").
+		Order("count DESC, action ASC").
+		Scan(&distribution)
 
 	if result.Error != nil {
-		return nil, stockviewer.StorageError{Operation: "get_distinct_ratings", Err: result.Error}
+		return nil, stockviewer.StorageError{Operation: "get_action_distribution", Err: result.Error}
 	}
-	return ratings, nil
+	return distribution, nil
+}
+
+func (s *Storage) GetStockStats(ctx context.Context) (*stockviewer.StockStats, error) {
+	var stats stockviewer.StockStats
+	result := s.db.WithContext(ctx).
+		Model(&stockviewer.Stock{}).
+		Select(`COUNT(*) AS total_stocks,
+			COUNT(DISTINCT ticker) AS distinct_tickers,
+			COUNT(DISTINCT NULLIF(brokerage, '')) AS distinct_brokerages,
+			COALESCE(AVG(recommend_score), 0) AS avg_recommend_score,
+			MAX(updated_at) AS last_updated`).
+		Scan(&stats)
+
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_stock_stats", Err: result.Error}
+	}
+
+	stats.AvgRecommendScore = math.Round(stats.AvgRecommendScore*100) / 100
+	return &stats, nil
+}
+
+func (s *Storage) GetScoreDistribution(ctx context.Context) ([]stockviewer.ScoreBucket, error) {
+	var rows []scoreBucketRow
+	result := scoreDistributionQuery(s.db.WithContext(ctx)).Scan(&rows)
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_score_distribution", Err: result.Error}
+	}
+
+	buckets := make([]stockviewer.ScoreBucket, 0, len(rows))
+	for _, row := range rows {
+		bucket := stockviewer.NewScoreBucket(row.Bucket)
+		bucket.Count = row.Count
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
+
+// GetUpdatedSince returns up to limit stocks after the (since, afterID)
+// cursor, oldest first. Ties on updated_at are broken by id, so a batch that
+// ends inside a run of equal timestamps resumes after its last id.
+func (s *Storage) GetUpdatedSince(ctx context.Context, since time.Time, afterID string, limit int) ([]stockviewer.Stock, error) {
+	var stocks []stockviewer.Stock
+	result := updatedSinceQuery(s.db.WithContext(ctx), since, afterID, limit).Find(&stocks)
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_updated_since", Err: result.Error}
+	}
+	return stocks, nil
+}
+
+func updatedSinceQuery(db *gorm.DB, since time.Time, afterID string, limit int) *gorm.DB {
+	query := db.Model(&stockviewer.Stock{})
+	if afterID == "" {
+		query = query.Where("updated_at > ?", since)
+	} else {
+		query = query.Where("(updated_at, id) > (?, ?)", since, afterID)
+	}
+	return query.
+		Order("updated_at ASC").
+		Order("id ASC").
+		Limit(limit)
+}
+
+func (s *Storage) GetFirstSeenBetween(ctx context.Context, from, to time.Time) ([]stockviewer.NewTicker, error) {
+	var tickers []stockviewer.NewTicker
+	result := firstSeenBetweenQuery(s.db.WithContext(ctx), from, to).Scan(&tickers)
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_first_seen_between", Err: result.Error}
+	}
+	return tickers, nil
+}
+
+// firstSeenBetweenQuery groups all entries by ticker, so a ticker only counts
+// as new when none of its entries predate from.
+func firstSeenBetweenQuery(db *gorm.DB, from, to time.Time) *gorm.DB {
+	return db.Model(&stockviewer.Stock{}).
+		Select("ticker, MAX(company) AS company, MIN(created_at) AS first_seen").
+		Group("ticker").
+		Having("MIN(created_at) >= ? AND MIN(created_at) <= ?", from, to).
+		Order("first_seen ASC").
+		Order("ticker ASC")
+}
+
+func (s *Storage) GetTickerCoverage(ctx context.Context, tickers []string) ([]stockviewer.TickerCoverage, error) {
+	if len(tickers) == 0 {
+		return nil, nil
+	}
+	var coverage []stockviewer.TickerCoverage
+	result := tickerCoverageQuery(s.db.WithContext(ctx), tickers).Scan(&coverage)
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_ticker_coverage", Err: result.Error}
+	}
+	return coverage, nil
+}
+
+func tickerCoverageQuery(db *gorm.DB, tickers []string) *gorm.DB {
+	return db.Model(&stockviewer.Stock{}).
+		Select("ticker, COUNT(*) AS records, COUNT(DISTINCT NULLIF(brokerage, '')) AS brokerages, MIN(created_at) AS first_seen").
+		Where("ticker IN ?", tickers).
+		Group("ticker")
+}
+
+func (s *Storage) GetColdStartStocks(ctx context.Context, minRecords int, since time.Time) ([]stockviewer.Stock, error) {
+	if minRecords <= 0 && since.IsZero() {
+		return nil, nil
+	}
+	var stocks []stockviewer.Stock
+	if err := coldStartQuery(s.db.WithContext(ctx), minRecords, since).Find(&stocks).Error; err != nil {
+		return nil, stockviewer.StorageError{Operation: "get_cold_start_stocks", Err: err}
+	}
+	return stocks, nil
+}
+
+func (s *Storage) GetQuarantinedStocks(ctx context.Context) ([]stockviewer.Stock, error) {
+	var stocks []stockviewer.Stock
+	result := s.db.WithContext(ctx).
+		Where("data_issues IS NOT NULL").
+		Order("updated_at DESC, id").
+		Find(&stocks)
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_quarantined_stocks", Err: result.Error}
+	}
+	return stocks, nil
+}
+
+// coldStartQuery selects every entry of the tickers that are short on
+// records or history. Callers must set at least one of the two conditions.
+func coldStartQuery(db *gorm.DB, minRecords int, since time.Time) *gorm.DB {
+	var conditions []string
+	var args []any
+	if minRecords > 0 {
+		conditions = append(conditions, "COUNT(*) < ?")
+		args = append(args, minRecords)
+	}
+	if !since.IsZero() {
+		conditions = append(conditions, "MIN(created_at) >= ?")
+		args = append(args, since)
+	}
+
+	cold := db.Session(&gorm.Session{NewDB: true}).
+		Model(&stockviewer.Stock{}).
+		Select("ticker").
+		Group("ticker").
+		Having(strings.Join(conditions, " OR "), args...)
+	return db.Model(&stockviewer.Stock{}).
+		Where("ticker IN (?)", cold).
+		Order("ticker ASC").
+		Order("created_at ASC")
+}
+
+// GetTargetConsensus aggregates the non-missing price targets of a ticker
+// first seen since the given time; created_at is used because every sync
+// rewrites updated_at. Postgres-compatible databases compute the
+// median with percentile_cont; other dialects fall back to computing it in Go.
+func (s *Storage) GetTargetConsensus(ctx context.Context, ticker string, since time.Time) (*stockviewer.TargetConsensus, error) {
+	base := s.db.WithContext(ctx).
+		Model(&stockviewer.Stock{}).
+		Where("UPPER(ticker) = ?", strings.ToUpper(ticker)).
+		Where("target_to > 0").
+		Where("created_at >= ?", since)
+
+	var consensus stockviewer.TargetConsensus
+	if s.db.Dialector.Name() == "postgres" {
+		result := base.Select(`COUNT(*) AS count,
+			COALESCE(MIN(target_to), 0) AS min,
+			COALESCE(MAX(target_to), 0) AS max,
+			COALESCE(AVG(target_to), 0) AS mean,
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY target_to), 0) AS median`).
+			Scan(&consensus)
+		if result.Error != nil {
+			return nil, stockviewer.StorageError{Operation: "get_target_consensus", Err: result.Error}
+		}
+		consensus.Spread = consensus.Max - consensus.Min
+	} else {
+		var targets []float64
+		if result := base.Pluck("target_to", &targets); result.Error != nil {
+			return nil, stockviewer.StorageError{Operation: "get_target_consensus", Err: result.Error}
+		}
+		consensus = stockviewer.ConsensusFromTargets(targets)
+	}
+
+	consensus.Ticker = strings.ToUpper(ticker)
+	return &consensus, nil
+}
+
+// GetTargetHistory lists the entries GetTargetConsensus aggregates, in the
+// order they were first observed.
+func (s *Storage) GetTargetHistory(ctx context.Context, ticker string, since time.Time) ([]stockviewer.Stock, error) {
+	var stocks []stockviewer.Stock
+	result := s.db.WithContext(ctx).
+		Where("UPPER(ticker) = ?", strings.ToUpper(ticker)).
+		Where("target_to > 0").
+		Where("created_at >= ?", since).
+		Order("created_at ASC").
+		Order("id ASC").
+		Find(&stocks)
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_target_history", Err: result.Error}
+	}
+	return stocks, nil
+}
+
+// GetTargetConsensuses aggregates the targets of every ticker in one query
+// grouped by ticker, with the same median fallback as GetTargetConsensus.
+func (s *Storage) GetTargetConsensuses(ctx context.Context, tickers []string, since time.Time) ([]stockviewer.TargetConsensus, error) {
+	if len(tickers) == 0 {
+		return nil, nil
+	}
+	base := targetsQuery(s.db.WithContext(ctx), tickers, since)
+
+	var consensuses []stockviewer.TargetConsensus
+	if s.db.Dialector.Name() == "postgres" {
+		if err := consensusesQuery(base).Scan(&consensuses).Error; err != nil {
+			return nil, stockviewer.StorageError{Operation: "get_target_consensuses", Err: err}
+		}
+		for i := range consensuses {
+			consensuses[i].Spread = consensuses[i].Max - consensuses[i].Min
+		}
+		return consensuses, nil
+	}
+
+	var rows []struct {
+		Ticker   string
+		TargetTo float64
+	}
+	if err := base.Select("UPPER(ticker) AS ticker, target_to").Scan(&rows).Error; err != nil {
+		return nil, stockviewer.StorageError{Operation: "get_target_consensuses", Err: err}
+	}
+	targets := make(map[string][]float64)
+	var order []string
+	for _, row := range rows {
+		if _, ok := targets[row.Ticker]; !ok {
+			order = append(order, row.Ticker)
+		}
+		targets[row.Ticker] = append(targets[row.Ticker], row.TargetTo)
+	}
+	for _, ticker := range order {
+		consensus := stockviewer.ConsensusFromTargets(targets[ticker])
+		consensus.Ticker = ticker
+		consensuses = append(consensuses, consensus)
+	}
+	return consensuses, nil
+}
+
+// GetTargetHistories lists the entries GetTargetConsensuses aggregates, in
+// the order they were first observed.
+func (s *Storage) GetTargetHistories(ctx context.Context, tickers []string, since time.Time) ([]stockviewer.Stock, error) {
+	if len(tickers) == 0 {
+		return nil, nil
+	}
+	var stocks []stockviewer.Stock
+	result := targetsQuery(s.db.WithContext(ctx), tickers, since).
+		Order("created_at ASC").
+		Order("id ASC").
+		Find(&stocks)
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "get_target_histories", Err: result.Error}
+	}
+	return stocks, nil
+}
+
+// targetsQuery selects the entries of the upper-case tickers with a price
+// target first seen since the given time.
+func targetsQuery(db *gorm.DB, tickers []string, since time.Time) *gorm.DB {
+	return db.Model(&stockviewer.Stock{}).
+		Where("UPPER(ticker) IN ?", tickers).
+		Where("target_to > 0").
+		Where("created_at >= ?", since)
+}
+
+// consensusesQuery computes the consensus statistics per ticker.
+func consensusesQuery(targets *gorm.DB) *gorm.DB {
+	return targets.Select(`UPPER(ticker) AS ticker,
+			COUNT(*) AS count,
+			MIN(target_to) AS min,
+			MAX(target_to) AS max,
+			AVG(target_to) AS mean,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY target_to) AS median`).
+		Group("UPPER(ticker)")
+}
+
+type scoreBucketRow struct {
+	Bucket int
+	Count  int64
+}
+
+// scoreDistributionQuery counts stocks per score bucket in one pass. Scores
+// of exactly 100 are folded into the last bucket.
+func scoreDistributionQuery(db *gorm.DB) *gorm.DB {
+	return db.Model(&stockviewer.Stock{}).
+		Select("LEAST(GREATEST(CAST(FLOOR(recommend_score / ?) AS INT), 0), ?) AS bucket, COUNT(*) AS count",
+			stockviewer.ScoreBucketWidth, stockviewer.ScoreBucketCount-1).
+		Group("bucket").
+		Order("bucket ASC")
 }
 
 func applyFilters(query *gorm.DB, filter stockviewer.StockFilter) *gorm.DB {
 	if filter.Ticker != "" {
-		query = query.Where("LOWER(ticker) LIKE ?", fmt.Sprintf("%%%s%%", strings.ToLower(filter.Ticker)))
+		if filter.TickerExact {
+			query = query.Where("UPPER(ticker) = ?", strings.ToUpper(filter.Ticker))
+		} else {
+			query = query.Where("ticker ILIKE ?", fmt.Sprintf("%%%s%%", strings.ToLower(filter.Ticker)))
+		}
 	}
 	if filter.Company != "" {
-		query = query.Where("LOWER(company) LIKE ?", fmt.Sprintf("%%%s%%", strings.ToLower(filter.Company)))
+		query = query.Where("company ILIKE ?", fmt.Sprintf("%%%s%%", strings.ToLower(filter.Company)))
+	}
+	switch filter.Notes {
+	case "":
+	case stockviewer.NotesNone:
+		query = query.Where("notes = ''")
+	case stockviewer.NotesAny:
+		query = query.Where("notes != ''")
+	default:
+		query = query.Where("notes ILIKE ?", fmt.Sprintf("%%%s%%", strings.ToLower(filter.Notes)))
+	}
+	if filter.Sector != "" {
+		query = query.Where("sector = ?", filter.Sector)
+	}
+	if filter.Industry != "" {
+		query = query.Where("industry = ?", filter.Industry)
 	}
 	if filter.Brokerage != "" {
 		query = query.Where("brokerage = ?", filter.Brokerage)
@@ -164,49 +817,67 @@ func applyFilters(query *gorm.DB, filter stockviewer.StockFilter) *gorm.DB {
 	if filter.Rating != "" {
 		query = query.Where("rating_to = ?", filter.Rating)
 	}
+	if filter.RatingFrom != "" {
+		query = query.Where("rating_from = ?", filter.RatingFrom)
+	}
 	if filter.Action != "" {
 		query = query.Where("action = ?", filter.Action)
 	}
+	if len(filter.Actions) > 0 {
+		query = query.Where("action IN ?", filter.Actions)
+	}
+	if filter.RatedOnly {
+		query = query.Where("rating_to IN ?", stockviewer.RecognizedRatings())
+	}
 	return query
 }
 
-func applySorting(query *gorm.DB, filter stockviewer.StockFilter) *gorm.DB {
-	sortBy := filter.SortBy
-	if sortBy == "" {
-		sortBy = "recommend_score"
-	}
+// latestPerTickerQuery keeps only the most recently updated row of each ticker
+// among the rows matching the filter. Counting, sorting and pagination then
+// operate on that one-row-per-ticker set.
+func latestPerTickerQuery(db *gorm.DB, filter stockviewer.StockFilter) *gorm.DB {
+	ranked := applyFilters(db.Model(&stockviewer.Stock{}), filter).
+		Select("*, ROW_NUMBER() OVER (PARTITION BY ticker ORDER BY updated_at DESC, id ASC) AS ticker_rank")
 
-	validSortFields := map[string]bool{
-		"ticker":          true,
-		"company":         true,
-		"brokerage":       true,
-		"recommend_score": true,
-		"created_at":      true,
-		"updated_at":      true,
-	}
+	return db.Table("(?) AS latest", ranked).Where("ticker_rank = 1")
+}
 
-	if !validSortFields[sortBy] {
-		sortBy = "recommend_score"
-	}
+// applySorting orders by the filter's sort fields. Callers reject unknown
+// fields and directions with query.NormalizeSort; this only guards the ORDER
+// BY clause, so anything unexpected is skipped rather than interpolated.
+func applySorting(db *gorm.DB, filter stockviewer.StockFilter) *gorm.DB {
+	orders := strings.Split(filter.SortOrder, ",")
+	var clauses []string
+	seen := make(map[string]bool)
+	for i, field := range strings.Split(filter.SortBy, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !query.SortFields[field] || seen[field] {
+			continue
+		}
+		seen[field] = true
 
-	sortOrder := strings.ToUpper(filter.SortOrder)
-	if sortOrder != "ASC" && sortOrder != "DESC" {
-		sortOrder = "DESC"
+		sortOrder := "DESC"
+		if i < len(orders) {
+			if order := strings.ToUpper(strings.TrimSpace(orders[i])); order == "ASC" {
+				sortOrder = order
+			}
+		}
+		clauses = append(clauses, fmt.Sprintf("%s %s", field, sortOrder))
 	}
 
-	return query.Order(fmt.Sprintf("%s %s", sortBy, sortOrder))
+	if len(clauses) == 0 {
+		clauses = []string{"recommend_score DESC"}
+	}
+	return db.Order(strings.Join(clauses, ", "))
 }
 
-func applyPagination(query *gorm.DB, filter stockviewer.StockFilter) *gorm.DB {
+func applyPagination(query *gorm.DB, filter stockviewer.StockFilter, limits stockviewer.PageLimits) *gorm.DB {
 	page := filter.Page
 	if page < 1 {
 		page = 1
 	}
 
-	pageSize := filter.PageSize
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
-	}
+	pageSize := limits.PageSize(filter.PageSize)
 
 	offset := (page - 1) * pageSize
 	return query.Offset(offset).Limit(pageSize)
@@ -0,0 +1,383 @@
+package stocks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+)
+
+// seedStorage returns a Storage over a fresh test schema holding stocks.
+func seedStorage(t *testing.T, stocks ...stockviewer.Stock) *Storage {
+	t.Helper()
+	storage := NewStorage(newTestDB(t))
+	if err := storage.SaveBatch(context.Background(), stocks); err != nil {
+		t.Fatalf("failed to seed stocks: %v", err)
+	}
+	return storage
+}
+
+func stockIDs(stocks []stockviewer.Stock) []string {
+	ids := make([]string, len(stocks))
+	for i, stock := range stocks {
+		ids[i] = stock.ID
+	}
+	return ids
+}
+
+// filterFixtures covers every field applyFilters looks at.
+var filterFixtures = []stockviewer.Stock{
+	{ID: "aapl", Ticker: "AAPL", Company: "Apple Inc.", Brokerage: "Goldman Sachs", Action: "upgraded by",
+		RatingFrom: "Hold", RatingTo: "Buy", Sector: "Technology", Industry: "Hardware", RecommendScore: 90},
+	{ID: "aap", Ticker: "AAP", Company: "Advance Auto Parts", Brokerage: "JP Morgan", Action: "target lowered by",
+		RatingFrom: "Buy", RatingTo: "Sell", Sector: "Consumer", Notes: "Watch margins", RecommendScore: 40},
+	{ID: "msft", Ticker: "MSFT", Company: "Microsoft", Brokerage: "Goldman Sachs", Action: "target raised by",
+		RatingFrom: "Buy", RatingTo: "Buy", Sector: "Technology", Industry: "Software", RecommendScore: 80},
+	{ID: "xyz", Ticker: "XYZ", Company: "Xyz Corp", Brokerage: "Morgan Stanley", Action: "initiated by",
+		RatingTo: "Not Rated", RecommendScore: 50},
+}
+
+func TestStorageDB_GetAllFilters(t *testing.T) {
+	t.Parallel()
+	storage := seedStorage(t, filterFixtures...)
+
+	tests := []struct {
+		name   string
+		filter stockviewer.StockFilter
+		want   []string
+	}{
+		{name: "ticker substring", filter: stockviewer.StockFilter{Ticker: "aap"}, want: []string{"aap", "aapl"}},
+		{name: "ticker exact", filter: stockviewer.StockFilter{Ticker: "aap", TickerExact: true}, want: []string{"aap"}},
+		{name: "company", filter: stockviewer.StockFilter{Company: "micro"}, want: []string{"msft"}},
+		{name: "rating transition", filter: stockviewer.StockFilter{RatingFrom: "Buy", Rating: "Sell"}, want: []string{"aap"}},
+		{name: "brokerage", filter: stockviewer.StockFilter{Brokerage: "Goldman Sachs"}, want: []string{"aapl", "msft"}},
+		{name: "action", filter: stockviewer.StockFilter{Action: "initiated by"}, want: []string{"xyz"}},
+		{
+			name:   "any of actions",
+			filter: stockviewer.StockFilter{Actions: []string{"upgraded by", "target raised by"}},
+			want:   []string{"aapl", "msft"},
+		},
+		{name: "notes text", filter: stockviewer.StockFilter{Notes: "MARGIN"}, want: []string{"aap"}},
+		{name: "with notes", filter: stockviewer.StockFilter{Notes: stockviewer.NotesAny}, want: []string{"aap"}},
+		{name: "without notes", filter: stockviewer.StockFilter{Notes: stockviewer.NotesNone}, want: []string{"aapl", "msft", "xyz"}},
+		{
+			name:   "sector and industry",
+			filter: stockviewer.StockFilter{Sector: "Technology", Industry: "Software"},
+			want:   []string{"msft"},
+		},
+		{name: "rated only", filter: stockviewer.StockFilter{RatedOnly: true}, want: []string{"aap", "aapl", "msft"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			stocks, total, err := storage.GetAll(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := stockIDs(stocks)
+			sort.Strings(got)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) || total != int64(len(tt.want)) {
+				t.Errorf("expected %v (total %d), got %v (total %d)", tt.want, len(tt.want), got, total)
+			}
+		})
+	}
+}
+
+func TestStorageDB_GetAllSorting(t *testing.T) {
+	t.Parallel()
+	storage := seedStorage(t, filterFixtures...)
+
+	tests := []struct {
+		name   string
+		filter stockviewer.StockFilter
+		want   []string
+	}{
+		{name: "default", filter: stockviewer.StockFilter{}, want: []string{"aapl", "msft", "xyz", "aap"}},
+		{
+			name:   "ascending score",
+			filter: stockviewer.StockFilter{SortBy: "recommend_score", SortOrder: "ASC"},
+			want:   []string{"aap", "xyz", "msft", "aapl"},
+		},
+		{
+			name:   "later fields break ties",
+			filter: stockviewer.StockFilter{SortBy: "brokerage,recommend_score", SortOrder: "ASC,ASC"},
+			want:   []string{"msft", "aapl", "aap", "xyz"},
+		},
+		{
+			name:   "second page",
+			filter: stockviewer.StockFilter{SortBy: "recommend_score", SortOrder: "DESC", Page: 2, PageSize: 2},
+			want:   []string{"xyz", "aap"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			stocks, _, err := storage.GetAll(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := stockIDs(stocks); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStorageDB_SearchRanking(t *testing.T) {
+	t.Parallel()
+	storage := seedStorage(t, filterFixtures...)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// Whole words are matched by full-text search, along with the
+		// tickers the query is a prefix of, ranked after an exact ticker.
+		{query: "AAP", want: []string{"aap", "aapl"}},
+		{query: "auto parts", want: []string{"aap"}},
+		// Stemming matches other forms of a word.
+		{query: "advancing", want: []string{"aap"}},
+		{query: "apple", want: []string{"aapl"}},
+		// Within a rank the higher score comes first.
+		{query: "aa", want: []string{"aapl", "aap"}},
+		// Other fragments fall back to substring matching.
+		{query: "adv", want: []string{"aap"}},
+		{query: "soft", want: []string{"msft"}},
+		// Only stop words, so no lexemes: ticker prefixes only.
+		{query: "a", want: []string{"aapl", "aap"}},
+		{query: "nothing", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			t.Parallel()
+			stocks, err := storage.Search(context.Background(), tt.query, 10, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := stockIDs(stocks); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStorageDB_SearchRanksByRelevance(t *testing.T) {
+	t.Parallel()
+	storage := seedStorage(t,
+		stockviewer.Stock{ID: "gold", Ticker: "GOLD", Company: "Barrick Gold", RecommendScore: 10},
+		stockviewer.Stock{ID: "gg", Ticker: "GG", Company: "Gold Gold Mining", RecommendScore: 5},
+		stockviewer.Stock{ID: "gs", Ticker: "GS", Company: "Goldman Sachs", RecommendScore: 90},
+		stockviewer.Stock{ID: "nem", Ticker: "NEM", Company: "Newmont Gold Corp", RecommendScore: 70},
+	)
+
+	stocks, err := storage.Search(context.Background(), "gold", 10, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The exact ticker first, then the company mentioning gold most often,
+	// then by score; Goldman is not the word gold.
+	if got, want := stockIDs(stocks), []string{"gold", "gg", "nem"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	stocks, err = storage.Search(context.Background(), "gold", 2, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stocks) != 2 {
+		t.Errorf("expected the limit to cap results at 2, got %v", stockIDs(stocks))
+	}
+}
+
+func TestStorageDB_SearchRanksExactTickerFirst(t *testing.T) {
+	t.Parallel()
+	storage := seedStorage(t,
+		stockviewer.Stock{ID: "a", Ticker: "A", Company: "Agilent Technologies", RecommendScore: 10},
+		stockviewer.Stock{ID: "aa", Ticker: "AA", Company: "Alcoa", RecommendScore: 50},
+		stockviewer.Stock{ID: "aapl", Ticker: "AAPL", Company: "Apple Inc.", RecommendScore: 90},
+		stockviewer.Stock{ID: "acme", Ticker: "XAC", Company: "Acme Apple Corp", RecommendScore: 95},
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// The exact ticker beats higher scored ticker prefixes, and a
+		// company match never outranks a ticker.
+		{query: "A", want: []string{"a", "aapl", "aa"}},
+		{query: "aa", want: []string{"aa", "aapl"}},
+		{query: "aap", want: []string{"aapl"}},
+		// Only company substrings match, so recommend_score decides.
+		{query: "cm", want: []string{"acme"}},
+		{query: "pp", want: []string{"acme", "aapl"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			t.Parallel()
+			stocks, err := storage.Search(context.Background(), tt.query, 10, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := stockIDs(stocks); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStorageDB_FuzzySearchFindsTypos(t *testing.T) {
+	t.Parallel()
+	storage := seedStorage(t,
+		stockviewer.Stock{ID: "aapl", Ticker: "AAPL", Company: "Apple Inc.", RecommendScore: 90},
+		stockviewer.Stock{ID: "msft", Ticker: "MSFT", Company: "Microsoft Corporation", RecommendScore: 80},
+		stockviewer.Stock{ID: "mu", Ticker: "MU", Company: "Micron Technology", RecommendScore: 70},
+	)
+
+	// Either pg_trgm or the edit distance fallback must find these.
+	for query, want := range map[string]string{"appel": "aapl", "microsft": "msft"} {
+		stocks, err := storage.Search(context.Background(), query, 10, true)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", query, err)
+		}
+		if len(stocks) == 0 || stocks[0].ID != want {
+			t.Errorf("%s: expected %s first, got %v", query, want, stockIDs(stocks))
+			continue
+		}
+		if stocks[0].MatchType != stockviewer.MatchFuzzy {
+			t.Errorf("%s: expected a fuzzy match, got %q", query, stocks[0].MatchType)
+		}
+	}
+
+	stocks, err := storage.Search(context.Background(), "msft", 10, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stocks) == 0 || stocks[0].MatchType != stockviewer.MatchExact {
+		t.Errorf("expected the exact ticker first, got %+v", stocks)
+	}
+}
+
+func TestStorageDB_CountByBrokerage(t *testing.T) {
+	t.Parallel()
+	storage := seedStorage(t, filterFixtures...)
+
+	counts, err := storage.CountByBrokerage(context.Background(), stockviewer.StockFilter{RatedOnly: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []stockviewer.ValueCount{{Value: "Goldman Sachs", Count: 2}, {Value: "JP Morgan", Count: 1}}
+	if fmt.Sprint(counts) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, counts)
+	}
+}
+
+func TestWithSearchPath(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{
+			dsn:  "postgres://root@localhost:26257/defaultdb?sslmode=disable",
+			want: "postgres://root@localhost:26257/defaultdb?options=-c+search_path%3Dtest_1&sslmode=disable",
+		},
+		{
+			dsn:  "host=localhost user=root dbname=defaultdb",
+			want: "host=localhost user=root dbname=defaultdb options='-c search_path=test_1'",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := withSearchPath(tt.dsn, "test_1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("expected %s, got %s", tt.want, got)
+		}
+	}
+}
+
+func TestStorageDB_GetTopRecommendedByBrokerage(t *testing.T) {
+	t.Parallel()
+	storage := seedStorage(t, filterFixtures...)
+
+	stocks, err := storage.GetTopRecommendedByBrokerage(context.Background(), "Goldman Sachs", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := stockIDs(stocks); fmt.Sprint(got) != "[aapl]" {
+		t.Errorf("expected the best scored Goldman Sachs stock, got %v", got)
+	}
+}
+
+func TestStorageDB_GetTickerCoverage(t *testing.T) {
+	t.Parallel()
+	storage := seedStorage(t,
+		stockviewer.Stock{ID: "a1", Ticker: "AAPL", Company: "Apple Inc.", Brokerage: "Goldman Sachs"},
+		stockviewer.Stock{ID: "a2", Ticker: "AAPL", Company: "Apple Inc.", Brokerage: "Goldman Sachs"},
+		stockviewer.Stock{ID: "a3", Ticker: "AAPL", Company: "Apple Inc.", Brokerage: "JP Morgan"},
+		stockviewer.Stock{ID: "a4", Ticker: "AAPL", Company: "Apple Inc."},
+		stockviewer.Stock{ID: "m1", Ticker: "MSFT", Company: "Microsoft"},
+	)
+
+	coverage, err := storage.GetTickerCoverage(context.Background(), []string{"AAPL", "MSFT"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Slice(coverage, func(i, j int) bool { return coverage[i].Ticker < coverage[j].Ticker })
+
+	got := make([]string, len(coverage))
+	for i, c := range coverage {
+		got[i] = fmt.Sprintf("%s:%d/%d", c.Ticker, c.Records, c.Brokerages)
+	}
+	// Repeated and empty brokerages count once and not at all.
+	if fmt.Sprint(got) != "[AAPL:4/2 MSFT:1/0]" {
+		t.Errorf("expected records and distinct brokerages per ticker, got %v", got)
+	}
+}
+
+func TestStorageDB_GetTargetConsensuses(t *testing.T) {
+	t.Parallel()
+	storage := seedStorage(t,
+		stockviewer.Stock{ID: "a1", Ticker: "AAPL", Company: "Apple Inc.", TargetTo: stockviewer.Price(150)},
+		stockviewer.Stock{ID: "a2", Ticker: "AAPL", Company: "Apple Inc.", TargetTo: stockviewer.Price(210)},
+		stockviewer.Stock{ID: "a3", Ticker: "AAPL", Company: "Apple Inc.", TargetTo: stockviewer.Price(185)},
+		stockviewer.Stock{ID: "a4", Ticker: "AAPL", Company: "Apple Inc.", TargetTo: stockviewer.Price(0)},
+		stockviewer.Stock{ID: "m1", Ticker: "MSFT", Company: "Microsoft", TargetTo: stockviewer.Price(300)},
+		stockviewer.Stock{ID: "m2", Ticker: "MSFT", Company: "Microsoft", TargetTo: stockviewer.Price(340)},
+		stockviewer.Stock{ID: "g1", Ticker: "GOOGL", Company: "Alphabet", TargetTo: stockviewer.Price(999)},
+	)
+
+	since := time.Now().Add(-time.Hour)
+	consensuses, err := storage.GetTargetConsensuses(context.Background(), []string{"AAPL", "MSFT", "NONE"}, since)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Slice(consensuses, func(i, j int) bool { return consensuses[i].Ticker < consensuses[j].Ticker })
+	want := []stockviewer.TargetConsensus{
+		{Ticker: "AAPL", Count: 3, Min: 150, Max: 210, Median: 185, Mean: 545.0 / 3, Spread: 60},
+		{Ticker: "MSFT", Count: 2, Min: 300, Max: 340, Median: 320, Mean: 320, Spread: 40},
+	}
+	if len(consensuses) != len(want) {
+		t.Fatalf("expected %+v, got %+v", want, consensuses)
+	}
+	for i := range want {
+		if consensuses[i].Rounded() != want[i].Rounded() {
+			t.Errorf("expected %+v, got %+v", want[i], consensuses[i])
+		}
+	}
+
+	history, err := storage.GetTargetHistories(context.Background(), []string{"AAPL", "MSFT"}, since)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(history) != 5 {
+		t.Errorf("expected the 5 entries with targets, got %v", stockIDs(history))
+	}
+}
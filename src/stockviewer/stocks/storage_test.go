@@ -0,0 +1,587 @@
+package stocks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newDryRunDB returns a postgres-dialect gorm handle that only builds SQL,
+// so query construction can be asserted without a running database.
+func newDryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=localhost user=test dbname=test sslmode=disable"), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open dry-run db: %v", err)
+	}
+	return db
+}
+
+func filterSQL(t *testing.T, filter stockviewer.StockFilter) string {
+	t.Helper()
+	db := newDryRunDB(t)
+	return db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var stocks []stockviewer.Stock
+		return applyFilters(tx.Model(&stockviewer.Stock{}), filter).Find(&stocks)
+	})
+}
+
+func TestApplyFilters_TickerContains(t *testing.T) {
+	sql := filterSQL(t, stockviewer.StockFilter{Ticker: "aa"})
+
+	if !strings.Contains(sql, "ticker ILIKE '%aa%'") {
+		t.Errorf("expected substring ticker match, got %s", sql)
+	}
+}
+
+func TestApplyFilters_TickerExact(t *testing.T) {
+	sql := filterSQL(t, stockviewer.StockFilter{Ticker: "aapl", TickerExact: true})
+
+	if !strings.Contains(sql, "UPPER(ticker) = 'AAPL'") {
+		t.Errorf("expected exact upper-cased ticker match, got %s", sql)
+	}
+	if strings.Contains(sql, "LIKE") {
+		t.Errorf("expected no LIKE clause for exact match, got %s", sql)
+	}
+}
+
+func TestApplyFilters_RatingTransition(t *testing.T) {
+	sql := filterSQL(t, stockviewer.StockFilter{RatingFrom: "Hold", Rating: "Buy"})
+
+	if !strings.Contains(sql, "rating_to = 'Buy'") {
+		t.Errorf("expected rating_to clause, got %s", sql)
+	}
+	if !strings.Contains(sql, "rating_from = 'Hold'") {
+		t.Errorf("expected rating_from clause, got %s", sql)
+	}
+}
+
+func TestApplyFilters_ActionsIn(t *testing.T) {
+	sql := filterSQL(t, stockviewer.StockFilter{Actions: []string{"target raised by", "upgraded by"}})
+
+	if !strings.Contains(sql, "action IN ('target raised by','upgraded by')") {
+		t.Errorf("expected action IN clause, got %s", sql)
+	}
+}
+
+func TestApplyFilters_Notes(t *testing.T) {
+	tests := []struct {
+		notes string
+		want  string
+	}{
+		{"Earnings", "notes ILIKE '%earnings%'"},
+		{stockviewer.NotesNone, "notes = ''"},
+		{stockviewer.NotesAny, "notes != ''"},
+	}
+
+	for _, tt := range tests {
+		sql := filterSQL(t, stockviewer.StockFilter{Notes: tt.notes})
+		if !strings.Contains(sql, tt.want) {
+			t.Errorf("notes %q: expected %s, got %s", tt.notes, tt.want, sql)
+		}
+	}
+}
+
+func TestApplyFilters_SectorAndIndustry(t *testing.T) {
+	sql := filterSQL(t, stockviewer.StockFilter{Sector: "Technology", Industry: "Semiconductors"})
+
+	if !strings.Contains(sql, "sector = 'Technology' AND industry = 'Semiconductors'") {
+		t.Errorf("expected sector and industry clauses, got %s", sql)
+	}
+}
+
+func TestDistinctValuesQuery_PrefixAndLimit(t *testing.T) {
+	db := newDryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var values []stockviewer.ValueCount
+		return distinctValuesQuery(tx, "brokerage", stockviewer.DistinctQuery{Prefix: "Gold", Limit: 5, Offset: 10}).Scan(&values)
+	})
+
+	if !strings.Contains(sql, "LOWER(brokerage) LIKE 'gold%'") {
+		t.Errorf("expected prefix match, got %s", sql)
+	}
+	if !strings.Contains(sql, "LIMIT 5 OFFSET 10") {
+		t.Errorf("expected LIMIT 5 OFFSET 10, got %s", sql)
+	}
+}
+
+func TestDistinctValuesQuery_CountsOrderedByFrequency(t *testing.T) {
+	db := newDryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var values []stockviewer.ValueCount
+		return distinctValuesQuery(tx, "rating_to", stockviewer.DistinctQuery{}).Scan(&values)
+	})
+
+	if !strings.Contains(sql, "rating_to AS value, COUNT(*) AS count") {
+		t.Errorf("expected value and count columns, got %s", sql)
+	}
+	if !strings.Contains(sql, "GROUP BY \"rating_to\" ORDER BY count DESC,rating_to") {
+		t.Errorf("expected count-descending order with value tiebreak, got %s", sql)
+	}
+	if strings.Contains(sql, "LIMIT") {
+		t.Errorf("expected no LIMIT for zero limit, got %s", sql)
+	}
+}
+
+func TestCountByQuery_AppliesFilter(t *testing.T) {
+	db := newDryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var values []stockviewer.ValueCount
+		filter := stockviewer.StockFilter{Rating: "Buy", SortBy: "ticker", Page: 2, PageSize: 10}
+		return countByQuery(tx, "brokerage", filter).Scan(&values)
+	})
+
+	if !strings.Contains(sql, "brokerage AS value, COUNT(*) AS count") || !strings.Contains(sql, "rating_to = 'Buy'") {
+		t.Errorf("expected brokerage counts among Buy ratings, got %s", sql)
+	}
+	if !strings.Contains(sql, "GROUP BY \"brokerage\" ORDER BY count DESC,brokerage") {
+		t.Errorf("expected count-descending order with value tiebreak, got %s", sql)
+	}
+	if strings.Contains(sql, "LIMIT") || strings.Contains(sql, "ticker ASC") || strings.Contains(sql, "ticker DESC") {
+		t.Errorf("expected sorting and pagination to be ignored, got %s", sql)
+	}
+}
+
+func TestCountByQuery_GroupByTicker(t *testing.T) {
+	db := newDryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var values []stockviewer.ValueCount
+		return countByQuery(tx, "action", stockviewer.StockFilter{GroupBy: stockviewer.GroupByTicker}).Scan(&values)
+	})
+
+	if !strings.Contains(sql, "ROW_NUMBER()") || !strings.Contains(sql, "ticker_rank = 1") {
+		t.Errorf("expected counts over the latest entry per ticker, got %s", sql)
+	}
+}
+
+func TestScoreDistributionQuery(t *testing.T) {
+	db := newDryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var rows []scoreBucketRow
+		return scoreDistributionQuery(tx).Scan(&rows)
+	})
+
+	if !strings.Contains(sql, "LEAST(GREATEST(CAST(FLOOR(recommend_score / 10) AS INT), 0), 9) AS bucket") {
+		t.Errorf("expected bucket expression clamped to 0-9, got %s", sql)
+	}
+	if !strings.Contains(sql, "GROUP BY \"bucket\"") {
+		t.Errorf("expected grouping by bucket, got %s", sql)
+	}
+}
+
+func TestApplyFilters_RatedOnly(t *testing.T) {
+	sql := filterSQL(t, stockviewer.StockFilter{RatedOnly: true})
+
+	if !strings.Contains(sql, "rating_to IN ('Buy',") {
+		t.Errorf("expected recognized ratings clause, got %s", sql)
+	}
+
+	sql = filterSQL(t, stockviewer.StockFilter{})
+	if strings.Contains(sql, "rating_to IN") {
+		t.Errorf("expected no rating clause without RatedOnly, got %s", sql)
+	}
+}
+
+func TestApplyPagination_ClampsToLimits(t *testing.T) {
+	db := newDryRunDB(t)
+	limits := stockviewer.PageLimits{DefaultPageSize: 10, MaxPageSize: 50}
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var stocks []stockviewer.Stock
+		return applyPagination(tx.Model(&stockviewer.Stock{}), stockviewer.StockFilter{Page: 2, PageSize: 500}, limits).Find(&stocks)
+	})
+
+	if !strings.Contains(sql, "LIMIT 50 OFFSET 50") {
+		t.Errorf("expected page size clamped to 50, got %s", sql)
+	}
+}
+
+func TestLatestPerTickerQuery(t *testing.T) {
+	db := newDryRunDB(t)
+	filter := stockviewer.StockFilter{Brokerage: "JP Morgan", GroupBy: stockviewer.GroupByTicker, SortBy: "ticker", SortOrder: "ASC", Page: 2, PageSize: 10}
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var stocks []stockviewer.Stock
+		query := latestPerTickerQuery(tx, filter)
+		query = applySorting(query, filter)
+		return applyPagination(query, filter, stockviewer.DefaultPageLimits()).Find(&stocks)
+	})
+
+	for _, want := range []string{
+		"ROW_NUMBER() OVER (PARTITION BY ticker ORDER BY updated_at DESC, id ASC) AS ticker_rank",
+		"brokerage = 'JP Morgan'",
+		") AS latest WHERE ticker_rank = 1",
+		"ORDER BY ticker ASC LIMIT 10 OFFSET 10",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("expected %q in %s", want, sql)
+		}
+	}
+
+	// The filter must be applied inside the window so the surviving row is
+	// the latest matching entry, not the latest entry overall.
+	if strings.Index(sql, "brokerage = 'JP Morgan'") > strings.Index(sql, "AS latest") {
+		t.Errorf("expected filters inside the ranked subquery, got %s", sql)
+	}
+}
+
+func TestLatestPerTickerQuery_Count(t *testing.T) {
+	db := newDryRunDB(t)
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var total int64
+		return latestPerTickerQuery(tx, stockviewer.StockFilter{GroupBy: stockviewer.GroupByTicker}).Count(&total)
+	})
+
+	if !strings.Contains(sql, "SELECT count(*) FROM (SELECT *, ROW_NUMBER()") || !strings.Contains(sql, "WHERE ticker_rank = 1") {
+		t.Errorf("expected count over the one-row-per-ticker set, got %s", sql)
+	}
+}
+
+func sortSQL(t *testing.T, filter stockviewer.StockFilter) string {
+	t.Helper()
+	db := newDryRunDB(t)
+	return db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var stocks []stockviewer.Stock
+		return applySorting(tx.Model(&stockviewer.Stock{}), filter).Find(&stocks)
+	})
+}
+
+func TestApplySorting(t *testing.T) {
+	tests := []struct {
+		name      string
+		sortBy    string
+		sortOrder string
+		want      string
+	}{
+		{"default", "", "", "ORDER BY recommend_score DESC"},
+		{"single field", "ticker", "ASC", "ORDER BY ticker ASC"},
+		{"multiple fields", "ticker,recommend_score", "ASC,DESC", "ORDER BY ticker ASC, recommend_score DESC"},
+		{"score with ticker tiebreaker", "recommend_score,ticker", "DESC,ASC", "ORDER BY recommend_score DESC, ticker ASC"},
+		{"tiebreaker direction", "brokerage, created_at", "desc, asc", "ORDER BY brokerage DESC, created_at ASC"},
+		{"missing direction defaults", "ticker,company", "ASC", "ORDER BY ticker ASC, company DESC"},
+		{"invalid field skipped", "ticker,password,company", "ASC,ASC,ASC", "ORDER BY ticker ASC, company ASC"},
+		{"invalid direction defaults", "ticker", "sideways", "ORDER BY ticker DESC"},
+		{"only invalid fields", "password", "ASC", "ORDER BY recommend_score DESC"},
+		{"duplicate field", "ticker,ticker", "ASC,DESC", "ORDER BY ticker ASC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := sortSQL(t, stockviewer.StockFilter{SortBy: tt.sortBy, SortOrder: tt.sortOrder})
+			if !strings.HasSuffix(sql, tt.want) {
+				t.Errorf("expected %q, got %s", tt.want, sql)
+			}
+		})
+	}
+}
+
+func TestUpdatedSinceQuery(t *testing.T) {
+	db := newDryRunDB(t)
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var stocks []stockviewer.Stock
+		return updatedSinceQuery(tx, since, "", 50).Find(&stocks)
+	})
+
+	if !strings.Contains(sql, "updated_at > '2024-05-01 00:00:00'") {
+		t.Errorf("expected strict updated_at comparison, got %s", sql)
+	}
+	if !strings.Contains(sql, "ORDER BY updated_at ASC,id ASC") {
+		t.Errorf("expected ascending updated_at order with id tiebreak, got %s", sql)
+	}
+	if !strings.Contains(sql, "LIMIT 50") {
+		t.Errorf("expected LIMIT 50, got %s", sql)
+	}
+
+	sql = db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var stocks []stockviewer.Stock
+		return updatedSinceQuery(tx, since, "abc", 50).Find(&stocks)
+	})
+	if !strings.Contains(sql, "(updated_at, id) > ('2024-05-01 00:00:00', 'abc')") {
+		t.Errorf("expected a compound cursor comparison, got %s", sql)
+	}
+}
+
+func TestSearchQuery_Ranking(t *testing.T) {
+	db := newDryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var stocks []stockviewer.Stock
+		return searchQuery(tx, " AA ", 10).Find(&stocks)
+	})
+
+	if !strings.Contains(sql, "ticker ILIKE '%aa%' OR company ILIKE '%aa%'") {
+		t.Errorf("expected substring match on ticker and company, got %s", sql)
+	}
+	want := "ORDER BY CASE WHEN LOWER(ticker) = 'aa' THEN 0 WHEN ticker ILIKE 'aa%' THEN 1 WHEN company ILIKE 'aa%' THEN 2 ELSE 3 END, recommend_score DESC"
+	if !strings.Contains(sql, want) {
+		t.Errorf("expected exact ticker, then prefix, then substring ranking, got %s", sql)
+	}
+	if !strings.HasSuffix(sql, "LIMIT 10") {
+		t.Errorf("expected LIMIT 10, got %s", sql)
+	}
+}
+
+func TestFullTextSearchQuery(t *testing.T) {
+	db := newDryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var stocks []stockviewer.Stock
+		return fullTextSearchQuery(tx, " Auto Parts ", 10).Find(&stocks)
+	})
+
+	if !strings.Contains(sql, "search_vector @@ plainto_tsquery('english', 'Auto Parts') OR ticker ILIKE 'auto parts%'") {
+		t.Errorf("expected a full-text match on search_vector or a ticker prefix, got %s", sql)
+	}
+	want := "ORDER BY CASE WHEN LOWER(ticker) = 'auto parts' THEN 0 WHEN ticker ILIKE 'auto parts%' THEN 1 ELSE 2 END, " +
+		"ts_rank(search_vector, plainto_tsquery('english', 'Auto Parts')) DESC, recommend_score DESC"
+	if !strings.Contains(sql, want) {
+		t.Errorf("expected exact ticker, then ticker prefix, then ts_rank ranking, got %s", sql)
+	}
+	if !strings.HasSuffix(sql, "LIMIT 10") {
+		t.Errorf("expected LIMIT 10, got %s", sql)
+	}
+}
+
+func TestSearch_FallsBackToSubstringWithoutFullTextMatch(t *testing.T) {
+	db := newDryRunDB(t)
+	var queries []string
+	err := db.Callback().Query().After("gorm:query").Register("test:record_queries", func(tx *gorm.DB) {
+		queries = append(queries, tx.Statement.SQL.String())
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	if _, err := NewStorage(db).Search(context.Background(), "aap", 10, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(queries) != 2 || !strings.Contains(queries[0], "search_vector @@") || !strings.Contains(queries[1], "LIKE") {
+		t.Errorf("expected a full-text query, then the substring fallback, got %v", queries)
+	}
+}
+
+func TestConsensusesQuery(t *testing.T) {
+	db := newDryRunDB(t)
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var consensuses []stockviewer.TargetConsensus
+		return consensusesQuery(targetsQuery(tx, []string{"AAPL", "MSFT"}, since)).Scan(&consensuses)
+	})
+
+	if !strings.Contains(sql, "UPPER(ticker) IN ('AAPL','MSFT') AND target_to > 0") {
+		t.Errorf("expected every ticker in one query, got %s", sql)
+	}
+	if !strings.HasSuffix(sql, "GROUP BY UPPER(ticker)") {
+		t.Errorf("expected the targets grouped by ticker, got %s", sql)
+	}
+}
+
+func TestFuzzySearchQuery(t *testing.T) {
+	db := newDryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var stocks []stockviewer.Stock
+		return fuzzySearchQuery(tx, " APPL ", 10, 0.4).Find(&stocks)
+	})
+
+	if !strings.Contains(sql, "ticker ILIKE '%appl%' OR company ILIKE '%appl%' OR similarity(LOWER(ticker), 'appl') >= 0.4 OR similarity(LOWER(company), 'appl') >= 0.4 "+
+		"OR word_similarity('appl', LOWER(company)) >= 0.4") {
+		t.Errorf("expected substring or similarity match, got %s", sql)
+	}
+	want := "ELSE 4 END, GREATEST(similarity(LOWER(ticker), 'appl'), similarity(LOWER(company), 'appl'), word_similarity('appl', LOWER(company))) DESC, recommend_score DESC"
+	if !strings.Contains(sql, want) {
+		t.Errorf("expected similarity-only matches last and ordered by similarity, got %s", sql)
+	}
+	if !strings.HasSuffix(sql, "LIMIT 10") {
+		t.Errorf("expected LIMIT 10, got %s", sql)
+	}
+}
+
+// undefinedFunctionError is what the driver returns for similarity() when
+// pg_trgm is not installed.
+type undefinedFunctionError struct{}
+
+func (undefinedFunctionError) Error() string {
+	return "ERROR: function similarity(text, text) does not exist (SQLSTATE 42883)"
+}
+func (undefinedFunctionError) SQLState() string { return sqlStateUndefinedFunction }
+
+func TestSearch_FuzzyFallsBackWithoutTrigram(t *testing.T) {
+	db := newDryRunDB(t)
+	var fuzzyAttempts int
+	err := db.Callback().Query().After("gorm:query").Register("test:no_pg_trgm", func(tx *gorm.DB) {
+		if strings.Contains(tx.Statement.SQL.String(), "similarity(") {
+			fuzzyAttempts++
+			tx.AddError(undefinedFunctionError{})
+		}
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+	var candidateQueries int
+	err = db.Callback().Query().After("gorm:query").Register("test:record_candidates", func(tx *gorm.DB) {
+		vars := tx.Statement.Vars
+		if strings.Contains(tx.Statement.SQL.String(), "ORDER BY recommend_score DESC") && len(vars) > 0 && vars[0] == "a%" {
+			candidateQueries++
+		}
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+	storage := NewStorage(db)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := storage.Search(ctx, "appl", 10, true); err != nil {
+			t.Fatalf("search %d: expected fallback to substring search, got %v", i+1, err)
+		}
+	}
+
+	if fuzzyAttempts != 1 {
+		t.Errorf("expected fuzzy search to be skipped once pg_trgm is known missing, got %d attempts", fuzzyAttempts)
+	}
+	if candidateQueries != 2 {
+		t.Errorf("expected each search to read edit distance candidates by first letter, got %d", candidateQueries)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"apple", "apple", 0},
+		{"appel", "apple", 2},
+		{"microsft", "microsoft", 1},
+		{"aapl", "appl", 1},
+		{"", "abc", 3},
+		{"nestlé", "nestle", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q): expected %d, got %d", tt.a, tt.b, tt.want, got)
+		}
+	}
+}
+
+func TestRankByEditDistance(t *testing.T) {
+	candidates := []stockviewer.Stock{
+		{ID: "amzn", Ticker: "AMZN", Company: "Amazon.com Inc.", RecommendScore: 95},
+		{ID: "acme", Ticker: "XAC", Company: "Acme Apple Corp", RecommendScore: 40},
+		{ID: "aapl", Ticker: "AAPL", Company: "Apple Inc.", RecommendScore: 90},
+		{ID: "msft", Ticker: "MSFT", Company: "Microsoft Corporation", RecommendScore: 80},
+	}
+
+	tests := []struct {
+		term string
+		want []string
+	}{
+		// Both Apple companies are two edits away, so the score decides.
+		{"appel", []string{"aapl", "acme"}},
+		{"microsft", []string{"msft"}},
+		{"amzm", []string{"amzn"}},
+		// Too short to tolerate typos.
+		{"ap", nil},
+	}
+	for _, tt := range tests {
+		if got := stockIDs(rankByEditDistance(candidates, tt.term)); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.term, tt.want, got)
+		}
+	}
+}
+
+func TestAppendFuzzy(t *testing.T) {
+	matched := []stockviewer.Stock{{ID: "aapl", MatchType: stockviewer.MatchText}}
+	fuzzy := []stockviewer.Stock{{ID: "aapl"}, {ID: "acme"}, {ID: "amzn"}}
+
+	got := appendFuzzy(matched, fuzzy, 2)
+	if fmt.Sprint(stockIDs(got)) != "[aapl acme]" {
+		t.Fatalf("expected the text match, then one fuzzy match up to the limit, got %v", stockIDs(got))
+	}
+	if got[0].MatchType != stockviewer.MatchText || got[1].MatchType != stockviewer.MatchFuzzy {
+		t.Errorf("expected text then fuzzy match types, got %q and %q", got[0].MatchType, got[1].MatchType)
+	}
+}
+
+func TestLabelMatches(t *testing.T) {
+	stocks := labelMatches([]stockviewer.Stock{
+		{Ticker: "APPL", Company: "Appl Corp"},
+		{Ticker: "AAPL", Company: "Apple Inc."},
+		{Ticker: "APLE", Company: "Apple Hospitality"},
+		{Ticker: "ADV", Company: "Advancing Co"},
+	}, " Appl ", stockviewer.MatchFuzzy)
+
+	want := []stockviewer.MatchType{stockviewer.MatchExact, stockviewer.MatchText, stockviewer.MatchText, stockviewer.MatchFuzzy}
+	for i, stock := range stocks {
+		if stock.MatchType != want[i] {
+			t.Errorf("%s: expected %q, got %q", stock.Ticker, want[i], stock.MatchType)
+		}
+	}
+}
+
+func TestFirstSeenBetweenQuery(t *testing.T) {
+	db := newDryRunDB(t)
+	from := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var tickers []stockviewer.NewTicker
+		return firstSeenBetweenQuery(tx, from, from.Add(time.Minute)).Scan(&tickers)
+	})
+
+	if !strings.Contains(sql, `GROUP BY "ticker" HAVING MIN(created_at) >= '2024-05-01 12:00:00' AND MIN(created_at) <= '2024-05-01 12:01:00'`) {
+		t.Errorf("expected tickers grouped by their first entry, got %s", sql)
+	}
+	if !strings.HasSuffix(sql, "ORDER BY first_seen ASC,ticker ASC") {
+		t.Errorf("expected oldest first, got %s", sql)
+	}
+}
+
+func TestColdStartQuery(t *testing.T) {
+	db := newDryRunDB(t)
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		minRecords int
+		since      time.Time
+		want       string
+	}{
+		{"records and age", 3, since, `HAVING COUNT(*) < 3 OR MIN(created_at) >= '2024-05-01 00:00:00'`},
+		{"records only", 3, time.Time{}, `HAVING COUNT(*) < 3)`},
+		{"age only", 0, since, `HAVING MIN(created_at) >= '2024-05-01 00:00:00')`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				var stocks []stockviewer.Stock
+				return coldStartQuery(tx, tt.minRecords, tt.since).Find(&stocks)
+			})
+			if !strings.Contains(sql, `WHERE ticker IN (SELECT "ticker" FROM "stocks" GROUP BY "ticker" `+tt.want) {
+				t.Errorf("unexpected cold-start query: %s", sql)
+			}
+		})
+	}
+}
+
+func TestTickerCoverageQuery(t *testing.T) {
+	db := newDryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var coverage []stockviewer.TickerCoverage
+		return tickerCoverageQuery(tx, []string{"AAPL", "MSFT"}).Scan(&coverage)
+	})
+
+	want := `SELECT ticker, COUNT(*) AS records, COUNT(DISTINCT NULLIF(brokerage, '')) AS brokerages, MIN(created_at) AS first_seen FROM "stocks" WHERE ticker IN ('AAPL','MSFT') GROUP BY "ticker"`
+	if !strings.HasPrefix(sql, want) {
+		t.Errorf("expected %s, got %s", want, sql)
+	}
+}
@@ -0,0 +1,118 @@
+package stocks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer/schema"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDatabaseURL names the variable that points the database-backed tests
+// at a Postgres-compatible server, e.g.
+// postgres://root@localhost:26257/defaultdb?sslmode=disable. Without it those
+// tests are skipped.
+const testDatabaseURL = "TEST_DATABASE_URL"
+
+// newTestDB returns a handle scoped to a schema of its own on the server at
+// TEST_DATABASE_URL, migrated to the newest version. The schema is dropped
+// when the test ends, so tests using it can run in parallel.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(testDatabaseURL)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseURL)
+	}
+
+	config := &gorm.Config{
+		Logger:  logger.Discard,
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	}
+	admin, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		t.Fatalf("failed to connect to %s: %v", testDatabaseURL, err)
+	}
+	closeDB(t, admin)
+
+	name := testSchemaName(t)
+	if err := admin.Exec("CREATE SCHEMA " + name).Error; err != nil {
+		t.Fatalf("failed to create schema %s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		if err := admin.Exec("DROP SCHEMA " + name + " CASCADE").Error; err != nil {
+			t.Errorf("failed to drop schema %s: %v", name, err)
+		}
+	})
+
+	scoped, err := withSearchPath(dsn, name)
+	if err != nil {
+		t.Fatalf("invalid %s: %v", testDatabaseURL, err)
+	}
+	db, err := gorm.Open(postgres.Open(scoped), config)
+	if err != nil {
+		t.Fatalf("failed to connect to schema %s: %v", name, err)
+	}
+	// Cleanups run last-in first-out, so this closes the scoped pool before
+	// the schema is dropped.
+	closeDB(t, db)
+
+	store, err := schema.NewStorage(db)
+	if err != nil {
+		t.Fatalf("failed to prepare migrations: %v", err)
+	}
+	migrator := schema.NewMigrator(store, schema.Migrations(), schema.Supported)
+	if err := migrator.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate schema %s: %v", name, err)
+	}
+	return db
+}
+
+func closeDB(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get connection pool: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+}
+
+// testSchemaName is unique per call, so subtests and reruns of a test never
+// share a schema. Only lowercase letters, digits and underscores are used,
+// so the name needs no quoting.
+func testSchemaName(t *testing.T) string {
+	t.Helper()
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatalf("failed to generate schema name: %v", err)
+	}
+	return "test_" + hex.EncodeToString(suffix)
+}
+
+// withSearchPath makes every connection opened with dsn resolve unqualified
+// table names in the named schema. It accepts both URL and keyword/value
+// DSNs and uses the options startup parameter, which Postgres and
+// CockroachDB both honor.
+func withSearchPath(dsn, name string) (string, error) {
+	option := "-c search_path=" + name
+	if !strings.Contains(dsn, "://") {
+		return fmt.Sprintf("%s options='%s'", dsn, option), nil
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("options", option)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,59 @@
+package syncprogress
+
+import "sync"
+
+// subscriberBuffer is how many updates a slow subscriber may fall behind
+// before further updates are dropped for it.
+const subscriberBuffer = 16
+
+// Update is one progress report of a running sync.
+type Update struct {
+	Processed int
+	Estimated int
+}
+
+// Broadcaster fans sync progress out to every current subscriber. Its
+// Publish method is meant to be passed to stocks.WithSyncProgress.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan Update]struct{}
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[chan Update]struct{})}
+}
+
+// Publish sends an update to every subscriber. It never blocks: a
+// subscriber whose buffer is full misses the update.
+func (b *Broadcaster) Publish(processed, estimated int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	update := Update{Processed: processed, Estimated: estimated}
+	for ch := range b.subscribers {
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every update published from now on
+// and a function that unsubscribes and closes it.
+func (b *Broadcaster) Subscribe() (<-chan Update, func()) {
+	ch := make(chan Update, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
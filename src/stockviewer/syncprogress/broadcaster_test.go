@@ -0,0 +1,43 @@
+package syncprogress
+
+import "testing"
+
+func TestBroadcaster_FansOutToSubscribers(t *testing.T) {
+	b := NewBroadcaster()
+	first, unsubscribeFirst := b.Subscribe()
+	second, unsubscribeSecond := b.Subscribe()
+	defer unsubscribeSecond()
+
+	b.Publish(100, 250)
+
+	for i, ch := range []<-chan Update{first, second} {
+		if got := <-ch; got != (Update{Processed: 100, Estimated: 250}) {
+			t.Errorf("subscriber %d: unexpected update %+v", i, got)
+		}
+	}
+
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("expected the channel to be closed after unsubscribing")
+	}
+
+	b.Publish(200, 250)
+	if got := <-second; got.Processed != 200 {
+		t.Errorf("expected the remaining subscriber to get the update, got %+v", got)
+	}
+}
+
+func TestBroadcaster_PublishDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	b := NewBroadcaster()
+	updates, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < subscriberBuffer+10; i++ {
+		b.Publish(i, subscriberBuffer+10)
+	}
+
+	if len(updates) != subscriberBuffer {
+		t.Errorf("expected %d buffered updates, got %d", subscriberBuffer, len(updates))
+	}
+}
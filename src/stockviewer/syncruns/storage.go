@@ -0,0 +1,125 @@
+package syncruns
+
+import (
+	"context"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Storage struct {
+	db *gorm.DB
+}
+
+// NewStorage expects the schema to be in place; see package schema.
+func NewStorage(db *gorm.DB) *Storage {
+	return &Storage{db: db}
+}
+
+func (s *Storage) Create(ctx context.Context, run *stockviewer.SyncRun) error {
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		return stockviewer.StorageError{Operation: "create_sync_run", Err: err}
+	}
+	return nil
+}
+
+func (s *Storage) Heartbeat(ctx context.Context, id uint, at time.Time) error {
+	result := s.db.WithContext(ctx).
+		Model(&stockviewer.SyncRun{}).
+		Where("id = ?", id).
+		Update("heartbeat_at", at)
+
+	if result.Error != nil {
+		return stockviewer.StorageError{Operation: "sync_run_heartbeat", Err: result.Error}
+	}
+	return nil
+}
+
+func (s *Storage) Finish(ctx context.Context, run *stockviewer.SyncRun) error {
+	if err := s.db.WithContext(ctx).Save(run).Error; err != nil {
+		return stockviewer.StorageError{Operation: "finish_sync_run", Err: err}
+	}
+	return nil
+}
+
+func (s *Storage) AbortStale(ctx context.Context, instanceID string, staleBefore time.Time, note string) ([]stockviewer.SyncRun, error) {
+	var aborted []stockviewer.SyncRun
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := staleRunsQuery(tx, instanceID, staleBefore).Find(&aborted).Error; err != nil {
+			return err
+		}
+		if len(aborted) == 0 {
+			return nil
+		}
+
+		now := time.Now().UTC()
+		ids := make([]uint, len(aborted))
+		for i := range aborted {
+			ids[i] = aborted[i].ID
+			aborted[i].Status = stockviewer.SyncRunAborted
+			aborted[i].FinishedAt = &now
+			aborted[i].Note = note
+		}
+		return tx.Model(&stockviewer.SyncRun{}).
+			Where("id IN ?", ids).
+			Updates(map[string]any{
+				"status":      stockviewer.SyncRunAborted,
+				"finished_at": now,
+				"note":        note,
+			}).Error
+	})
+
+	if err != nil {
+		return nil, stockviewer.StorageError{Operation: "abort_stale_sync_runs", Err: err}
+	}
+	return aborted, nil
+}
+
+// staleRunsQuery selects in_progress runs that are either left over from an
+// earlier process of instanceID or have not heartbeated since staleBefore,
+// locking them so two instances starting together do not both abort the
+// same run.
+func staleRunsQuery(db *gorm.DB, instanceID string, staleBefore time.Time) *gorm.DB {
+	return db.Model(&stockviewer.SyncRun{}).
+		Where("status = ?", "in_progress").
+		Where("instance_id = ? OR heartbeat_at < ?", instanceID, staleBefore).
+		Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+func (s *Storage) List(ctx context.Context, limit int) ([]stockviewer.SyncRun, error) {
+	var runs []stockviewer.SyncRun
+	result := s.db.WithContext(ctx).
+		Order("started_at DESC, id DESC").
+		Limit(limit).
+		Find(&runs)
+
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "list_sync_runs", Err: result.Error}
+	}
+	return runs, nil
+}
+
+func (s *Storage) CountByStatus(ctx context.Context, since time.Time) (map[string]int, error) {
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	if err := statusCountsQuery(s.db.WithContext(ctx), since).Scan(&rows).Error; err != nil {
+		return nil, stockviewer.StorageError{Operation: "count_sync_runs_by_status", Err: err}
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+func statusCountsQuery(db *gorm.DB, since time.Time) *gorm.DB {
+	return db.Model(&stockviewer.SyncRun{}).
+		Select("status, COUNT(*) AS count").
+		Where("started_at >= ? AND status <> ?", since, "in_progress").
+		Group("status")
+}
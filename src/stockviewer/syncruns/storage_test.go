@@ -0,0 +1,55 @@
+package syncruns
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func newDryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=localhost user=test dbname=test sslmode=disable"), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open dry-run db: %v", err)
+	}
+	return db
+}
+
+func TestStaleRunsQuery(t *testing.T) {
+	db := newDryRunDB(t)
+
+	staleBefore := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var runs []stockviewer.SyncRun
+		return staleRunsQuery(tx, "pod-a", staleBefore).Find(&runs)
+	})
+
+	if !strings.Contains(sql, "status = 'in_progress' AND (instance_id = 'pod-a' OR heartbeat_at < '2024-05-01 12:00:00')") {
+		t.Errorf("expected in-progress runs of this instance or with a stale heartbeat, got %s", sql)
+	}
+	if !strings.HasSuffix(sql, "FOR UPDATE") {
+		t.Errorf("expected the stale runs to be locked, got %s", sql)
+	}
+}
+
+func TestStatusCountsQuery(t *testing.T) {
+	db := newDryRunDB(t)
+
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var rows []map[string]any
+		return statusCountsQuery(tx, since).Find(&rows)
+	})
+
+	want := "SELECT status, COUNT(*) AS count FROM \"sync_runs\" WHERE started_at >= '2024-05-01 12:00:00' AND status <> 'in_progress' GROUP BY \"status\""
+	if sql != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, sql)
+	}
+}
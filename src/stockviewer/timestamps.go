@@ -0,0 +1,18 @@
+package stockviewer
+
+import (
+	"time"
+)
+
+// ParseTimestamp parses an RFC3339 timestamp, accepting both the "Z" and
+// numeric offset forms, and returns it normalized to UTC.
+func ParseTimestamp(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, ValidationError{
+			Field:   "timestamp",
+			Message: "must be an RFC3339 timestamp, e.g. 2024-05-01T00:00:00Z",
+		}
+	}
+	return t.UTC(), nil
+}
@@ -0,0 +1,68 @@
+package stockviewer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withLocalTimezone(t *testing.T, loc *time.Location) {
+	t.Helper()
+	original := time.Local
+	time.Local = loc
+	t.Cleanup(func() {
+		time.Local = original
+	})
+}
+
+func TestParseTimestamp_ZuluAndOffset(t *testing.T) {
+	withLocalTimezone(t, time.FixedZone("UTC-5", -5*60*60))
+
+	zulu, err := ParseTimestamp("2024-05-01T05:00:00Z")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	offset, err := ParseTimestamp("2024-05-01T00:00:00-05:00")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !zulu.Equal(offset) {
+		t.Errorf("expected %s and %s to be the same instant", zulu, offset)
+	}
+	if zulu.Location() != time.UTC || offset.Location() != time.UTC {
+		t.Errorf("expected parsed timestamps in UTC, got %s and %s", zulu.Location(), offset.Location())
+	}
+}
+
+func TestParseTimestamp_Invalid(t *testing.T) {
+	if _, err := ParseTimestamp("2024-05-01 00:00:00"); err == nil {
+		t.Error("expected error for non-RFC3339 timestamp")
+	}
+}
+
+func TestStockMarshalJSON_EmitsUTC(t *testing.T) {
+	loc := time.FixedZone("UTC+9", 9*60*60)
+	withLocalTimezone(t, loc)
+
+	stock := Stock{
+		ID:        "test-id",
+		CreatedAt: time.Date(2024, 5, 1, 9, 0, 0, 0, loc),
+		UpdatedAt: time.Date(2024, 5, 2, 9, 30, 0, 0, loc),
+	}
+
+	data, err := json.Marshal(stock)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := string(data)
+	if !strings.Contains(body, `"created_at":"2024-05-01T00:00:00Z"`) {
+		t.Errorf("expected created_at in UTC, got %s", body)
+	}
+	if !strings.Contains(body, `"updated_at":"2024-05-02T00:30:00Z"`) {
+		t.Errorf("expected updated_at in UTC, got %s", body)
+	}
+}
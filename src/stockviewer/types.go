@@ -2,6 +2,10 @@ package stockviewer
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"time"
 )
 
@@ -10,102 +14,876 @@ type Rating string
 type Action string
 
 const (
-	RatingBuy            Rating = "Buy"
-	RatingNeutral        Rating = "Neutral"
-	RatingMarketPerform  Rating = "Market Perform"
-	RatingSell           Rating = "Sell"
-	RatingSpeculative    Rating = "Speculative"
-	RatingHold           Rating = "Hold"
-	RatingOutperform     Rating = "Outperform"
-	RatingUnderperform   Rating = "Underperform"
+	RatingBuy           Rating = "Buy"
+	RatingStrongBuy     Rating = "Strong Buy"
+	RatingAccumulate    Rating = "Accumulate"
+	RatingOverweight    Rating = "Overweight"
+	RatingNeutral       Rating = "Neutral"
+	RatingMarketPerform Rating = "Market Perform"
+	RatingEqualWeight   Rating = "Equal Weight"
+	RatingSell          Rating = "Sell"
+	RatingReduce        Rating = "Reduce"
+	RatingUnderweight   Rating = "Underweight"
+	RatingSpeculative   Rating = "Speculative"
+	RatingHold          Rating = "Hold"
+	RatingOutperform    Rating = "Outperform"
+	RatingUnderperform  Rating = "Underperform"
 )
 
+// Rating groups used to bucket analyst ratings into bullish, neutral and
+// bearish sentiment.
+var (
+	BuyRatings  = []string{"Buy", "Strong Buy", "Outperform", "Overweight", "Accumulate"}
+	HoldRatings = []string{"Hold", "Neutral", "Market Perform", "Equal Weight", "Speculative"}
+	SellRatings = []string{"Sell", "Underperform", "Underweight", "Reduce"}
+)
+
+// RecognizedRatings returns every rating that belongs to a sentiment group.
+func RecognizedRatings() []string {
+	ratings := make([]string, 0, len(BuyRatings)+len(HoldRatings)+len(SellRatings))
+	ratings = append(ratings, BuyRatings...)
+	ratings = append(ratings, HoldRatings...)
+	return append(ratings, SellRatings...)
+}
+
+// RatingRecognized reports whether rating maps to a known sentiment group.
+// Unrecognized ratings fall back to a neutral score.
+func RatingRecognized(rating string) bool {
+	for _, recognized := range RecognizedRatings() {
+		if recognized == rating {
+			return true
+		}
+	}
+	return false
+}
+
 const (
 	ActionTargetRaised  Action = "target raised by"
 	ActionTargetLowered Action = "target lowered by"
 	ActionUpgraded      Action = "upgraded by"
 	ActionDowngraded    Action = "downgraded by"
 	ActionInitiated     Action = "initiated by"
+	ActionReiterated    Action = "reiterated by"
+)
+
+type ActionCategory string
+
+const (
+	ActionCategoryPositive ActionCategory = "positive"
+	ActionCategoryNegative ActionCategory = "negative"
+	ActionCategoryNeutral  ActionCategory = "neutral"
 )
 
+// ActionCategories groups analyst actions by the sentiment they express.
+var ActionCategories = map[ActionCategory][]Action{
+	ActionCategoryPositive: {ActionTargetRaised, ActionUpgraded},
+	ActionCategoryNegative: {ActionTargetLowered, ActionDowngraded},
+	ActionCategoryNeutral:  {ActionInitiated, ActionReiterated},
+}
+
+// Actions returns every analyst action, positive ones first, then negative,
+// then neutral.
+func Actions() []Action {
+	var actions []Action
+	for _, category := range []ActionCategory{ActionCategoryPositive, ActionCategoryNegative, ActionCategoryNeutral} {
+		actions = append(actions, ActionCategories[category]...)
+	}
+	return actions
+}
+
 type Stock struct {
-	ID             string    `json:"id" gorm:"primaryKey"`
-	Ticker         string    `json:"ticker" gorm:"index;not null"`
-	Company        string    `json:"company" gorm:"not null"`
-	Brokerage      string    `json:"brokerage"`
-	Action         string    `json:"action"`
-	RatingFrom     string    `json:"rating_from"`
-	RatingTo       string    `json:"rating_to"`
-	TargetFrom     float64   `json:"target_from"`
-	TargetTo       float64   `json:"target_to"`
+	ID         string `json:"id" gorm:"primaryKey"`
+	Ticker     string `json:"ticker" gorm:"index;not null"`
+	Company    string `json:"company" gorm:"not null"`
+	Brokerage  string `json:"brokerage"`
+	Action     string `json:"action"`
+	RatingFrom string `json:"rating_from"`
+	RatingTo   string `json:"rating_to"`
+	// TargetFrom and TargetTo are nil when the feed sent a value that could
+	// not be parsed; DataIssues then says what was received. Zero means the
+	// feed had no target.
+	TargetFrom     *float64  `json:"target_from"`
+	TargetTo       *float64  `json:"target_to"`
 	RecommendScore float64   `json:"recommend_score" gorm:"index"`
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
+	// Sector and Industry come from the optional enrichment step of a sync;
+	// they are empty when enrichment is disabled or has nothing for the
+	// ticker.
+	Sector   string `json:"sector" gorm:"index;not null;default:''"`
+	Industry string `json:"industry" gorm:"not null;default:''"`
+	// Notes and ScoreOverridden are set by analysts through the admin API;
+	// syncs keep them, and keep RecommendScore while it is overridden.
+	Notes           string `json:"notes" gorm:"type:text;not null;default:''"`
+	ScoreOverridden bool   `json:"score_overridden" gorm:"not null;default:false"`
+	// DataIssues lists the fields of a quarantined entry that the feed sent
+	// in an unusable shape; it is empty for clean entries.
+	DataIssues    []DataIssue `json:"data_issues,omitempty" gorm:"type:jsonb;serializer:json"`
+	PrefetchToken string      `json:"prefetch_token,omitempty" gorm:"-"`
+	// UpsidePercent is derived from the targets when the stock is encoded;
+	// it is omitted when there is no previous target to compare against.
+	UpsidePercent *float64 `json:"upside_percent,omitempty" gorm:"-"`
+	// MatchType is set on search results only.
+	MatchType MatchType `json:"match_type,omitempty" gorm:"-"`
+}
+
+// MatchType says how a search result matched the query.
+type MatchType string
+
+const (
+	// MatchExact is a ticker equal to the query.
+	MatchExact MatchType = "exact"
+	// MatchText matched the query's words or contains it as a substring.
+	MatchText MatchType = "text"
+	// MatchFuzzy is only similar to the query, as for a typo.
+	MatchFuzzy MatchType = "fuzzy"
+)
+
+// Price returns a pointer to amount, for filling in Stock targets.
+func Price(amount float64) *float64 {
+	return &amount
+}
+
+// Targets returns TargetFrom and TargetTo, with zero for a nil target.
+func (s Stock) Targets() (from, to float64) {
+	if s.TargetFrom != nil {
+		from = *s.TargetFrom
+	}
+	if s.TargetTo != nil {
+		to = *s.TargetTo
+	}
+	return from, to
+}
+
+// Upside returns the percentage change from TargetFrom to TargetTo.
+// ok is false when either target is missing.
+func (s Stock) Upside() (percent float64, ok bool) {
+	from, to := s.Targets()
+	if from <= 0 || to <= 0 {
+		return 0, false
+	}
+	return (to - from) / from * 100, true
+}
+
+// DataIssue describes a field the feed sent in a shape that could not be
+// parsed. Shape is the JSON type received, e.g. "null" or "object", and Raw
+// the value as received, truncated.
+type DataIssue struct {
+	Field string `json:"field"`
+	Shape string `json:"shape"`
+	Raw   string `json:"raw"`
+}
+
+// MarshalJSON emits timestamps in UTC so clients see the same RFC3339 values
+// regardless of the server's local timezone, and fills in upside_percent.
+func (s Stock) MarshalJSON() ([]byte, error) {
+	type stockJSON Stock
+	out := stockJSON(s)
+	out.CreatedAt = out.CreatedAt.UTC()
+	out.UpdatedAt = out.UpdatedAt.UTC()
+	out.UpsidePercent = nil
+	if upside, ok := s.Upside(); ok {
+		rounded := math.Round(upside*100) / 100
+		out.UpsidePercent = &rounded
+	}
+	return json.Marshal(out)
 }
 
 type StockRecommendation struct {
-	Stock          Stock   `json:"stock"`
-	Score          float64 `json:"score"`
-	Reason         string  `json:"reason"`
-	Rank           int     `json:"rank"`
+	Stock Stock   `json:"stock"`
+	Score float64 `json:"score"`
+	// Confidence grades from 0 to 1 how much data backs Score: a recognized
+	// rating, both price targets, the number of distinct brokerages covering
+	// the ticker and how closely their recent price targets agree. A high
+	// Score with low Confidence rests on little or conflicting data.
+	Confidence float64 `json:"confidence"`
+	// Breakdown is omitted from compact responses.
+	Breakdown *ScoreBreakdown `json:"breakdown,omitempty"`
+	Reason    string          `json:"reason"`
+	Rank      int             `json:"rank"`
+	// ColdStart marks a ticker with too little history for its score to be
+	// compared with established ones; see TickerCoverage.
+	ColdStart bool `json:"cold_start"`
+	// Tier grades Score from A (80 and up) to F (below 35).
+	Tier string `json:"tier"`
+}
+
+// ScoreBreakdown holds the weighted contribution of each component to a
+// recommendation score; the components add up to the score. RecencyFactor is
+// the multiplier already applied to each component for the entry's age.
+type ScoreBreakdown struct {
+	RatingComponent      float64 `json:"rating_component"`
+	ActionComponent      float64 `json:"action_component"`
+	PriceTargetComponent float64 `json:"price_target_component"`
+	RecencyFactor        float64 `json:"recency_factor"`
 }
 
 type SyncStatus struct {
-	LastSync      time.Time `json:"last_sync"`
-	TotalRecords  int       `json:"total_records"`
-	NewRecords    int       `json:"new_records"`
-	UpdatedRecords int      `json:"updated_records"`
-	Status        string    `json:"status"`
+	LastSync         time.Time `json:"last_sync"`
+	TotalRecords     int       `json:"total_records"`
+	NewRecords       int       `json:"new_records"`
+	UpdatedRecords   int       `json:"updated_records"`
+	DuplicateRecords int       `json:"duplicate_records"`
+	Status           string    `json:"status"`
+	Error            string    `json:"error,omitempty"`
+	// UpstreamService and UpstreamStatus name the external API whose failure
+	// Error reports and the HTTP status it answered with, zero when no
+	// response arrived. Both are empty when the failure was not upstream.
+	UpstreamService string `json:"upstream_service,omitempty"`
+	UpstreamStatus  int    `json:"upstream_status,omitempty"`
+	// SchemaWarnings counts the entries whose fields arrived in an unusable
+	// shape, keyed by "field:shape", e.g. "target_to:null".
+	SchemaWarnings map[string]int `json:"schema_warnings,omitempty"`
+}
+
+// SetError records err as the failure of the sync, with the upstream
+// service and status when err is an ExternalAPIError.
+func (s *SyncStatus) SetError(err error) {
+	s.Error = err.Error()
+	var apiErr ExternalAPIError
+	if errors.As(err, &apiErr) {
+		s.UpstreamService = apiErr.Service
+		s.UpstreamStatus = apiErr.StatusCode
+	}
+}
+
+// PageLimits bounds the page size of paginated listings.
+// AuthenticatedMaxPageSize, when larger than MaxPageSize, raises the cap for
+// authenticated callers; see For.
+type PageLimits struct {
+	DefaultPageSize          int
+	MaxPageSize              int
+	AuthenticatedMaxPageSize int
+}
+
+// DefaultPageLimits returns the limits used when none are configured.
+func DefaultPageLimits() PageLimits {
+	return PageLimits{DefaultPageSize: 20, MaxPageSize: 100}
+}
+
+// WithDefaults fills in any unset limit from DefaultPageLimits.
+func (l PageLimits) WithDefaults() PageLimits {
+	defaults := DefaultPageLimits()
+	if l.MaxPageSize < 1 {
+		l.MaxPageSize = defaults.MaxPageSize
+	}
+	if l.DefaultPageSize < 1 {
+		l.DefaultPageSize = defaults.DefaultPageSize
+	}
+	if l.DefaultPageSize > l.MaxPageSize {
+		l.DefaultPageSize = l.MaxPageSize
+	}
+	return l
+}
+
+// For returns the limits that apply to the caller of the request in ctx.
+func (l PageLimits) For(ctx context.Context) PageLimits {
+	if TierFrom(ctx) == TierAuthenticated && l.AuthenticatedMaxPageSize > l.MaxPageSize {
+		l.MaxPageSize = l.AuthenticatedMaxPageSize
+	}
+	return l
+}
+
+// CheckPageSize rejects a requested page size outside 1..MaxPageSize. Zero
+// stands for a size that was not given and passes; PageSize turns it into
+// the default.
+func (l PageLimits) CheckPageSize(size int) error {
+	if size < 0 || size > l.MaxPageSize {
+		return ValidationError{Field: "page_size", Message: fmt.Sprintf("must be between 1 and %d", l.MaxPageSize)}
+	}
+	return nil
+}
+
+// PageSize returns size clamped to the limits; sizes below 1 get the default.
+// Listings validate with CheckPageSize first, so clamping only guards callers
+// that skipped it.
+func (l PageLimits) PageSize(size int) int {
+	if size < 1 {
+		return l.DefaultPageSize
+	}
+	if size > l.MaxPageSize {
+		return l.MaxPageSize
+	}
+	return size
 }
 
+// PaginatedResponse is one page of a stock listing. Totals are int64 to
+// match the row counts returned by the database and are encoded as plain
+// JSON numbers.
 type PaginatedResponse struct {
 	Data       []Stock `json:"data"`
 	Page       int     `json:"page"`
 	PageSize   int     `json:"page_size"`
 	TotalItems int64   `json:"total_items"`
-	TotalPages int     `json:"total_pages"`
+	TotalPages int64   `json:"total_pages"`
+	HasNext    bool    `json:"has_next"`
+	HasPrev    bool    `json:"has_prev"`
+	NextPage   *int    `json:"next_page"`
+	PrevPage   *int    `json:"prev_page"`
+}
+
+// NewPaginatedResponse wraps one page of stocks with its navigation. A page
+// past the end keeps empty data, reports no next page and points back at the
+// last page that has items.
+func NewPaginatedResponse(data []Stock, page, pageSize int, totalItems int64) *PaginatedResponse {
+	if data == nil {
+		data = []Stock{}
+	}
+	resp := &PaginatedResponse{
+		Data:       data,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalItems: totalItems,
+		TotalPages: TotalPages(totalItems, pageSize),
+	}
+
+	if int64(page) < resp.TotalPages {
+		next := page + 1
+		resp.HasNext = true
+		resp.NextPage = &next
+	}
+	if page > 1 && resp.TotalPages > 0 {
+		prev := page - 1
+		if int64(prev) > resp.TotalPages {
+			prev = int(resp.TotalPages)
+		}
+		resp.HasPrev = true
+		resp.PrevPage = &prev
+	}
+	return resp
+}
+
+// MaxRecommendations is how many recommendations a ranking holds; pages are
+// cut from it, so it is also the largest page size.
+// DefaultRecommendationLimit is the page size, or new-coverage limit, used
+// when a request gives none. A limit of zero or out of range counts as none
+// and also gets the default, in the HTTP layer and the service alike.
+const (
+	MaxRecommendations         = 100
+	DefaultRecommendationLimit = 10
+)
+
+// RecommendationFilter selects a page of the recommendation ranking. An
+// empty Brokerage ranks the picks of every brokerage; IncludeNew, which also
+// ranks cold-start tickers, cannot be combined with a brokerage. A Tier keeps
+// only the recommendations graded with it.
+type RecommendationFilter struct {
+	Brokerage  string `form:"brokerage" json:"brokerage"`
+	IncludeNew bool   `form:"include_new" json:"include_new"`
+	Tier       string `form:"tier" json:"tier"`
+	Page       int    `form:"page" json:"page"`
+	PageSize   int    `form:"page_size" json:"page_size"`
+}
+
+// PaginatedRecommendations is one page of the recommendation ranking, with
+// the same navigation as PaginatedResponse. TotalItems counts the
+// recommendations ranked, which never exceeds the ranking's cap.
+type PaginatedRecommendations struct {
+	Data       []StockRecommendation `json:"data"`
+	Page       int                   `json:"page"`
+	PageSize   int                   `json:"page_size"`
+	TotalItems int64                 `json:"total_items"`
+	TotalPages int64                 `json:"total_pages"`
+	HasNext    bool                  `json:"has_next"`
+	HasPrev    bool                  `json:"has_prev"`
+	NextPage   *int                  `json:"next_page"`
+	PrevPage   *int                  `json:"prev_page"`
+}
+
+// NewPaginatedRecommendations wraps one page of recommendations with the
+// navigation NewPaginatedResponse computes for stocks.
+func NewPaginatedRecommendations(data []StockRecommendation, page, pageSize int, totalItems int64) *PaginatedRecommendations {
+	if data == nil {
+		data = []StockRecommendation{}
+	}
+	nav := NewPaginatedResponse(nil, page, pageSize, totalItems)
+	return &PaginatedRecommendations{
+		Data:       data,
+		Page:       nav.Page,
+		PageSize:   nav.PageSize,
+		TotalItems: nav.TotalItems,
+		TotalPages: nav.TotalPages,
+		HasNext:    nav.HasNext,
+		HasPrev:    nav.HasPrev,
+		NextPage:   nav.NextPage,
+		PrevPage:   nav.PrevPage,
+	}
+}
+
+// TotalPages returns how many pages of pageSize are needed for totalItems.
+func TotalPages(totalItems int64, pageSize int) int64 {
+	if pageSize < 1 || totalItems < 1 {
+		return 0
+	}
+	size := int64(pageSize)
+	return (totalItems + size - 1) / size
 }
 
 type StockFilter struct {
-	Ticker    string `form:"ticker"`
-	Company   string `form:"company"`
-	Brokerage string `form:"brokerage"`
-	Rating    string `form:"rating"`
-	Action    string `form:"action"`
-	SortBy    string `form:"sort_by"`
-	SortOrder string `form:"sort_order"`
-	Page      int    `form:"page"`
-	PageSize  int    `form:"page_size"`
+	Ticker         string   `form:"ticker" json:"ticker"`
+	TickerExact    bool     `form:"ticker_exact" json:"ticker_exact"`
+	Company        string   `form:"company" json:"company"`
+	Notes          string   `form:"notes" json:"notes"`
+	Sector         string   `form:"sector" json:"sector"`
+	Industry       string   `form:"industry" json:"industry"`
+	Brokerage      string   `form:"brokerage" json:"brokerage"`
+	Rating         string   `form:"rating" json:"rating"`
+	RatingFrom     string   `form:"rating_from" json:"rating_from"`
+	Action         string   `form:"action" json:"action"`
+	ActionCategory string   `form:"action_category" json:"action_category"`
+	Actions        []string `form:"actions" json:"actions"`
+	IncludeUnrated bool     `form:"include_unrated" json:"include_unrated"`
+	RatedOnly      bool     `form:"-" json:"-"`
+	GroupBy        string   `form:"group_by" json:"group_by"`
+	SortBy         string   `form:"sort_by" json:"sort_by"`
+	SortOrder      string   `form:"sort_order" json:"sort_order"`
+	Page           int      `form:"page" json:"page"`
+	PageSize       int      `form:"page_size" json:"page_size"`
+}
+
+// Notes filter values that match on whether a stock has notes instead of on
+// their text: NotesNone selects stocks nobody has annotated yet and
+// NotesAny the ones that carry notes.
+const (
+	NotesNone = ":none"
+	NotesAny  = ":any"
+)
+
+// GroupByTicker collapses a stock listing to the most recent entry per ticker.
+const GroupByTicker = "ticker"
+
+type BrokerageStats struct {
+	Brokerage            string  `json:"brokerage"`
+	TotalRecommendations int     `json:"total_recommendations"`
+	AvgScore             float64 `json:"avg_score"`
+	BuyCount             int     `json:"buy_count"`
+	SellCount            int     `json:"sell_count"`
+	HoldCount            int     `json:"hold_count"`
+}
+
+// Orderings accepted by StocksService.GetBrokerageStats.
+const (
+	BrokerageSortTotal    = "total_recommendations"
+	BrokerageSortAvgScore = "avg_score"
+)
+
+type RatingDistribution struct {
+	Rating string `json:"rating"`
+	Count  int64  `json:"count"`
+}
+
+type ActionDistribution struct {
+	Action string `json:"action"`
+	Count  int64  `json:"count"`
+}
+
+// ScoreBucket counts stocks whose recommend_score falls in
+// [BucketMin, BucketMax); the last bucket also includes BucketMax.
+type ScoreBucket struct {
+	BucketMin float64 `json:"bucket_min"`
+	BucketMax float64 `json:"bucket_max"`
+	Count     int64   `json:"count"`
+}
+
+// DataQualityReport lists the stocks quarantined because the feed sent a
+// field in an unusable shape, how often each field and shape occurs among
+// them, and the schema warnings of the latest sync.
+type DataQualityReport struct {
+	QuarantinedStocks int              `json:"quarantined_stocks"`
+	Issues            []DataIssueCount `json:"issues"`
+	LastSyncWarnings  map[string]int   `json:"last_sync_warnings"`
+	Stocks            []Stock          `json:"stocks"`
+}
+
+// DataIssueCount is how many quarantined stocks have an issue with Field in
+// the given Shape.
+type DataIssueCount struct {
+	Field string `json:"field"`
+	Shape string `json:"shape"`
+	Count int    `json:"count"`
+}
+
+const (
+	ScoreBucketWidth = 10.0
+	ScoreBucketCount = 10
+)
+
+// NewScoreBucket returns the empty bucket at index i.
+func NewScoreBucket(i int) ScoreBucket {
+	return ScoreBucket{
+		BucketMin: float64(i) * ScoreBucketWidth,
+		BucketMax: float64(i+1) * ScoreBucketWidth,
+	}
+}
+
+// StockStats holds table-wide aggregates over the stored stocks.
+type StockStats struct {
+	TotalStocks        int64      `json:"total_stocks"`
+	DistinctTickers    int64      `json:"distinct_tickers"`
+	DistinctBrokerages int64      `json:"distinct_brokerages"`
+	AvgRecommendScore  float64    `json:"avg_recommend_score"`
+	LastUpdated        *time.Time `json:"last_updated"`
+}
+
+const (
+	EventSyncCompleted = "sync.completed"
+)
+
+// OutboxEvent is a side effect recorded in the same transaction as the data
+// change that triggers it, and dispatched later by the outbox worker.
+type OutboxEvent struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Type        string     `json:"type" gorm:"index;not null"`
+	Payload     string     `json:"payload" gorm:"type:text"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error"`
+	ProcessedAt *time.Time `json:"processed_at" gorm:"index"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// SyncRun is the persisted record of one sync. The instance running it
+// refreshes HeartbeatAt while it works, so a run left in_progress by a
+// process that died can be told apart from one that is still going.
+type SyncRun struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	InstanceID       string     `json:"instance_id" gorm:"not null"`
+	Status           string     `json:"status" gorm:"not null;index"`
+	StartedAt        time.Time  `json:"started_at"`
+	HeartbeatAt      time.Time  `json:"heartbeat_at"`
+	FinishedAt       *time.Time `json:"finished_at"`
+	TotalRecords     int        `json:"total_records"`
+	NewRecords       int        `json:"new_records"`
+	UpdatedRecords   int        `json:"updated_records"`
+	DuplicateRecords int        `json:"duplicate_records"`
+	Error            string     `json:"error,omitempty"`
+	// UpstreamService and UpstreamStatus are those of SyncStatus.
+	UpstreamService string `json:"upstream_service,omitempty"`
+	UpstreamStatus  int    `json:"upstream_status,omitempty"`
+	// Note explains a status set by someone other than the run itself, such
+	// as an abort during startup recovery.
+	Note string `json:"note,omitempty"`
+	// SchemaWarnings is SyncStatus.SchemaWarnings of the finished run.
+	SchemaWarnings map[string]int `json:"schema_warnings,omitempty" gorm:"type:jsonb;serializer:json"`
+}
+
+// SyncRunAborted marks a run whose instance stopped heartbeating before it
+// finished.
+const SyncRunAborted = "aborted"
+
+// SyncPartial marks a sync whose feed failed after delivering some items, or
+// that could not read or save some of its stocks. The rest was saved and
+// Error holds the failure.
+const SyncPartial = "partial"
+
+// SavedData reports whether the run finished and saved what the feed sent,
+// in full or in part.
+func (r SyncRun) SavedData() bool {
+	return (r.Status == "completed" || r.Status == SyncPartial) && r.FinishedAt != nil
+}
+
+// SyncStatus reports the run as the status endpoint shows it.
+func (r SyncRun) SyncStatus() SyncStatus {
+	status := SyncStatus{
+		TotalRecords:     r.TotalRecords,
+		NewRecords:       r.NewRecords,
+		UpdatedRecords:   r.UpdatedRecords,
+		DuplicateRecords: r.DuplicateRecords,
+		Status:           r.Status,
+		Error:            r.Error,
+		UpstreamService:  r.UpstreamService,
+		UpstreamStatus:   r.UpstreamStatus,
+		SchemaWarnings:   r.SchemaWarnings,
+	}
+	if r.SavedData() {
+		status.LastSync = *r.FinishedAt
+	}
+	if r.Status == SyncRunAborted && status.Error == "" {
+		status.Error = r.Note
+	}
+	return status
+}
+
+// SyncSuccessRate is how the sync runs started since Since ended. Partial
+// runs count against the rate, since the feed failed before finishing;
+// Failed covers errored and aborted runs. Rate is Completed over all
+// finished runs, and nil when none finished in the window.
+type SyncSuccessRate struct {
+	Since      time.Time `json:"since"`
+	Completed  int       `json:"completed"`
+	Partial    int       `json:"partial"`
+	Failed     int       `json:"failed"`
+	Rate       *float64  `json:"rate"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// NewSyncSuccessRate builds the rate from finished run counts by status.
+func NewSyncSuccessRate(counts map[string]int, since, computedAt time.Time) SyncSuccessRate {
+	rate := SyncSuccessRate{Since: since, ComputedAt: computedAt}
+	for status, count := range counts {
+		switch status {
+		case "completed":
+			rate.Completed += count
+		case SyncPartial:
+			rate.Partial += count
+		case "in_progress":
+		default:
+			rate.Failed += count
+		}
+	}
+	if finished := rate.Completed + rate.Partial + rate.Failed; finished > 0 {
+		value := float64(rate.Completed) / float64(finished)
+		rate.Rate = &value
+	}
+	return rate
+}
+
+// DeadLetter is a feed item a sync could not turn into a stock. Payload holds
+// the item as received, so data-quality problems can be audited and the item
+// replayed once the cause is fixed.
+type DeadLetter struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Source    string    `json:"source" gorm:"not null;index"`
+	Reason    string    `json:"reason" gorm:"not null"`
+	Payload   string    `json:"payload" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// NewTicker is a ticker whose first entry was created at FirstSeen.
+type NewTicker struct {
+	Ticker    string    `json:"ticker"`
+	Company   string    `json:"company"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// TickerCoverage is how much history a ticker has: the number of entries
+// stored for it, how many distinct brokerages published them and when the
+// first one was created.
+type TickerCoverage struct {
+	Ticker     string    `json:"ticker"`
+	Records    int64     `json:"records"`
+	Brokerages int64     `json:"brokerages"`
+	FirstSeen  time.Time `json:"first_seen"`
+}
+
+// NewTickers lists the tickers first seen between From and To, oldest
+// first. From and To are zero when there is no sync to measure against.
+type NewTickers struct {
+	Tickers []NewTicker
+	From    time.Time
+	To      time.Time
+}
+
+// StockUpdate holds the fields of a stock that may be changed by hand. Nil
+// fields are left as they are.
+type StockUpdate struct {
+	RecommendScore *float64 `json:"recommend_score"`
+	Notes          *string  `json:"notes"`
+}
+
+// StockChanges is a batch of stocks updated after a point in time, in
+// ascending (updated_at, id) order. NextSince and NextAfterID are the cursor
+// for the following poll.
+type StockChanges struct {
+	Stocks      []Stock
+	NextSince   time.Time
+	NextAfterID string
 }
 
 type StocksRepository interface {
+	// Ping reports whether the database can be reached.
+	Ping(ctx context.Context) error
 	Save(ctx context.Context, stock Stock) error
 	SaveBatch(ctx context.Context, stocks []Stock) error
+	SaveBatchWithEvent(ctx context.Context, stocks []Stock, event OutboxEvent) error
 	GetByID(ctx context.Context, id string) (*Stock, error)
-	GetByTicker(ctx context.Context, ticker string) ([]Stock, error)
+	Update(ctx context.Context, id string, updates map[string]interface{}) error
+	GetTickerHistory(ctx context.Context, ticker string, filter StockFilter) ([]Stock, int64, error)
 	GetAll(ctx context.Context, filter StockFilter) ([]Stock, int64, error)
 	GetTopRecommended(ctx context.Context, limit int) ([]Stock, error)
-	Search(ctx context.Context, query string, limit int) ([]Stock, error)
+	GetTopRecommendedByBrokerage(ctx context.Context, brokerage string, limit int) ([]Stock, error)
+	Search(ctx context.Context, query string, limit int, fuzzy bool) ([]Stock, error)
 	Delete(ctx context.Context, id string) error
-	GetDistinctBrokerages(ctx context.Context) ([]string, error)
-	GetDistinctRatings(ctx context.Context) ([]string, error)
+	GetDistinctBrokerages(ctx context.Context, query DistinctQuery) ([]ValueCount, error)
+	GetDistinctRatings(ctx context.Context, query DistinctQuery) ([]ValueCount, error)
+	GetDistinctRatingsFrom(ctx context.Context, query DistinctQuery) ([]ValueCount, error)
+	GetBrokerageStats(ctx context.Context) ([]BrokerageStats, error)
+	GetRatingDistribution(ctx context.Context) ([]RatingDistribution, error)
+	GetActionDistribution(ctx context.Context) ([]ActionDistribution, error)
+	GetStockStats(ctx context.Context) (*StockStats, error)
+	GetScoreDistribution(ctx context.Context) ([]ScoreBucket, error)
+	// CountByBrokerage, CountByRating and CountByAction count the stocks
+	// matching filter per non-empty value of the field, most common first.
+	CountByBrokerage(ctx context.Context, filter StockFilter) ([]ValueCount, error)
+	CountByRating(ctx context.Context, filter StockFilter) ([]ValueCount, error)
+	CountByAction(ctx context.Context, filter StockFilter) ([]ValueCount, error)
+	GetTargetConsensus(ctx context.Context, ticker string, since time.Time) (*TargetConsensus, error)
+	// GetTargetHistory returns the entries of a ticker with a price target
+	// first seen since the given time, oldest first observation first.
+	GetTargetHistory(ctx context.Context, ticker string, since time.Time) ([]Stock, error)
+	// GetTargetConsensuses is GetTargetConsensus for several upper-case
+	// tickers in one grouped query. Tickers without targets are left out.
+	GetTargetConsensuses(ctx context.Context, tickers []string, since time.Time) ([]TargetConsensus, error)
+	// GetTargetHistories is GetTargetHistory for several upper-case tickers
+	// in one query, every ticker's entries mixed in first-observed order.
+	GetTargetHistories(ctx context.Context, tickers []string, since time.Time) ([]Stock, error)
+	// GetUpdatedSince returns stocks ordered by (updated_at, id) after the
+	// cursor (since, afterID). An empty afterID takes every stock updated
+	// strictly after since.
+	GetUpdatedSince(ctx context.Context, since time.Time, afterID string, limit int) ([]Stock, error)
+	// GetFirstSeenBetween returns the tickers whose earliest created_at falls
+	// within [from, to].
+	GetFirstSeenBetween(ctx context.Context, from, to time.Time) ([]NewTicker, error)
+	GetTickerCoverage(ctx context.Context, tickers []string) ([]TickerCoverage, error)
+	// GetColdStartStocks returns every entry of the tickers that have fewer
+	// than minRecords entries or whose first entry is not older than since.
+	// A zero minRecords or since leaves that condition out.
+	GetColdStartStocks(ctx context.Context, minRecords int, since time.Time) ([]Stock, error)
+	// GetQuarantinedStocks returns the entries with data issues, most
+	// recently updated first.
+	GetQuarantinedStocks(ctx context.Context) ([]Stock, error)
+}
+
+type OutboxRepository interface {
+	GetPending(ctx context.Context, maxAttempts int, limit int) ([]OutboxEvent, error)
+	MarkProcessed(ctx context.Context, id uint) error
+	MarkFailed(ctx context.Context, id uint, reason string) error
+}
+
+// MigrationKind tells whether a schema migration is safe while older binaries
+// are still running.
+type MigrationKind string
+
+const (
+	// MigrationExpand only adds to the schema (new tables, nullable or
+	// defaulted columns) so binaries that predate it keep working.
+	MigrationExpand MigrationKind = "expand"
+	// MigrationContract removes or tightens schema that older binaries rely
+	// on; it may only run once every pod is on code that no longer needs it.
+	MigrationContract MigrationKind = "contract"
+)
+
+// AppliedMigration records a schema migration that has run. The kind is
+// stored so binaries that do not know the migration can still judge whether
+// they are compatible with it.
+type AppliedMigration struct {
+	Version   int           `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	Name      string        `json:"name" gorm:"not null"`
+	Kind      MigrationKind `json:"kind" gorm:"not null"`
+	AppliedAt time.Time     `json:"applied_at"`
+}
+
+func (AppliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// SavedView is a named, server-maintained stock listing. Built-in views are
+// defined in code; the rest are stored and managed through the admin API.
+type SavedView struct {
+	Slug        string      `json:"slug" gorm:"primaryKey"`
+	Title       string      `json:"title" gorm:"not null"`
+	Description string      `json:"description"`
+	Filter      StockFilter `json:"filter" gorm:"serializer:json;type:text"`
+	BuiltIn     bool        `json:"built_in" gorm:"-"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// ViewPage is one page of a resolved saved view.
+type ViewPage struct {
+	View   SavedView
+	Result *PaginatedResponse
+}
+
+type ViewRepository interface {
+	List(ctx context.Context) ([]SavedView, error)
+	Get(ctx context.Context, slug string) (*SavedView, error)
+	Save(ctx context.Context, view SavedView) error
+	Delete(ctx context.Context, slug string) error
+}
+
+type ViewsService interface {
+	ListViews(ctx context.Context) ([]SavedView, error)
+	ResolveView(ctx context.Context, slug string, page int) (*ViewPage, error)
+	SaveView(ctx context.Context, view SavedView) (*SavedView, error)
+	DeleteView(ctx context.Context, slug string) error
 }
 
 type StocksFetcher interface {
 	FetchStocks(ctx context.Context) (<-chan StockOrError, error)
 }
 
+// StockEnricher looks up details the stock feed does not carry. Syncs call
+// it once per ticker when enrichment is enabled.
+type StockEnricher interface {
+	EnrichStock(ctx context.Context, ticker string) (sector, industry string, err error)
+}
+
+type SyncRunRepository interface {
+	Create(ctx context.Context, run *SyncRun) error
+	Heartbeat(ctx context.Context, id uint, at time.Time) error
+	Finish(ctx context.Context, run *SyncRun) error
+	// AbortStale marks as aborted, with note, every in_progress run that
+	// belongs to instanceID or whose heartbeat is older than staleBefore, and
+	// returns the runs it changed.
+	AbortStale(ctx context.Context, instanceID string, staleBefore time.Time, note string) ([]SyncRun, error)
+	// List returns the most recent runs, newest first.
+	List(ctx context.Context, limit int) ([]SyncRun, error)
+	// CountByStatus counts the finished runs started at or after since,
+	// keyed by status.
+	CountByStatus(ctx context.Context, since time.Time) (map[string]int, error)
+}
+
+// DeadLetterRepository stores the items syncs had to drop.
+type DeadLetterRepository interface {
+	Record(ctx context.Context, letter DeadLetter) error
+}
+
 type StocksService interface {
+	// Ping reports whether the stocks storage can be reached.
+	Ping(ctx context.Context) error
 	SyncStocks(ctx context.Context) (*SyncStatus, error)
+	StartSync(ctx context.Context) error
+	GetSyncStatus(ctx context.Context) (*SyncStatus, error)
+	GetSyncRuns(ctx context.Context, limit int) ([]SyncRun, error)
+	// GetSyncSuccessRate returns the success rate computed after the last
+	// finished sync or at startup, without reading storage; nil until one
+	// has been computed.
+	GetSyncSuccessRate(ctx context.Context) *SyncSuccessRate
 	GetStock(ctx context.Context, id string) (*Stock, error)
+	UpdateStock(ctx context.Context, id string, update StockUpdate) (*Stock, error)
+	GetStockHistory(ctx context.Context, ticker string, filter StockFilter) (*PaginatedResponse, error)
 	GetStocks(ctx context.Context, filter StockFilter) (*PaginatedResponse, error)
-	SearchStocks(ctx context.Context, query string, limit int) ([]Stock, error)
-	GetFilters(ctx context.Context) (*FiltersResponse, error)
+	SearchStocks(ctx context.Context, query string, limit int, fuzzy bool) ([]Stock, error)
+	GetFilters(ctx context.Context, query FiltersQuery) (*FiltersResponse, error)
+	GetFilterValues(ctx context.Context, field string, query DistinctQuery) (*FilterValues, error)
+	GetBrokerageStats(ctx context.Context, sortBy string) ([]BrokerageStats, error)
+	GetRatingDistribution(ctx context.Context) ([]RatingDistribution, error)
+	GetActionDistribution(ctx context.Context) ([]ActionDistribution, error)
+	GetStats(ctx context.Context) (*StatsResponse, error)
+	GetScoreDistribution(ctx context.Context) ([]ScoreBucket, error)
+	GetDataQualityReport(ctx context.Context) (*DataQualityReport, error)
+	GetTargetConsensus(ctx context.Context, ticker string, windowDays int) (*TargetConsensus, error)
+	// GetTargetConsensuses returns the consensus of up to
+	// MaxConsensusTickers tickers, in the order given.
+	GetTargetConsensuses(ctx context.Context, tickers []string, windowDays int) ([]TargetConsensus, error)
+	GetChanges(ctx context.Context, since time.Time, afterID string, limit int) (*StockChanges, error)
+	GetNewTickers(ctx context.Context, since *time.Time) (*NewTickers, error)
 }
 
 type RecommendationService interface {
-	GetTopRecommendations(ctx context.Context, limit int) ([]StockRecommendation, error)
+	// GetTopRecommendations returns a page of the ranking. Cold-start
+	// tickers are left out unless filter.IncludeNew is set.
+	GetTopRecommendations(ctx context.Context, filter RecommendationFilter) (*PaginatedRecommendations, error)
+	GetNewCoverage(ctx context.Context, limit int) ([]StockRecommendation, error)
 	CalculateScore(stock Stock) float64
+	CacheInvalidator
+}
+
+// CacheInvalidator is implemented by services that cache results derived
+// from stored stocks. The stocks service calls InvalidateCache after every
+// write that can change them.
+type CacheInvalidator interface {
+	InvalidateCache()
 }
 
 type StockOrError struct {
@@ -113,8 +891,78 @@ type StockOrError struct {
 	Error error
 }
 
+// FiltersResponse lists the most common values of each filter field. HasMore
+// tells which lists were cut short; the full lists are paged through
+// GetFilterValues.
 type FiltersResponse struct {
-	Brokerages []string `json:"brokerages"`
-	Ratings    []string `json:"ratings"`
-	Actions    []string `json:"actions"`
+	Brokerages  []string      `json:"brokerages"`
+	Ratings     []string      `json:"ratings"`
+	RatingsFrom []string      `json:"ratings_from"`
+	Actions     []string      `json:"actions"`
+	HasMore     FilterHasMore `json:"has_more"`
+	// Counts is only filled in when FiltersQuery.Counts is set.
+	Counts *FilterCounts `json:"counts,omitempty"`
+}
+
+// FiltersQuery selects what GetFilters returns. Prefix narrows the brokerage
+// and rating values. Counts adds each value's row count among the stocks
+// matching Filter; every facet ignores its own field in Filter, so picking a
+// brokerage narrows the rating and action counts but still lists the other
+// brokerages.
+type FiltersQuery struct {
+	Prefix string
+	Counts bool
+	Filter StockFilter
+}
+
+// FilterCounts lists the values of each facet with their row counts, most
+// common first and cut to the same length as the plain lists.
+type FilterCounts struct {
+	Brokerages []ValueCount `json:"brokerages"`
+	Ratings    []ValueCount `json:"ratings"`
+	Actions    []ValueCount `json:"actions"`
+}
+
+type FilterHasMore struct {
+	Brokerages  bool `json:"brokerages"`
+	Ratings     bool `json:"ratings"`
+	RatingsFrom bool `json:"ratings_from"`
+}
+
+// Filter fields whose distinct values can be paged.
+const (
+	FilterFieldBrokerages  = "brokerages"
+	FilterFieldRatings     = "ratings"
+	FilterFieldRatingsFrom = "ratings_from"
+)
+
+// DistinctQuery narrows and pages a distinct-values lookup. A zero Limit
+// means no limit.
+type DistinctQuery struct {
+	Prefix string
+	Limit  int
+	Offset int
+}
+
+// ValueCount is a distinct field value and how many stocks have it.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// FilterValues is one page of a filter field's values, most common first.
+type FilterValues struct {
+	Field   string       `json:"field"`
+	Values  []ValueCount `json:"values"`
+	Limit   int          `json:"limit"`
+	Offset  int          `json:"offset"`
+	HasMore bool         `json:"has_more"`
+}
+
+type StatsResponse struct {
+	TotalStocks        int64      `json:"total_stocks"`
+	DistinctTickers    int64      `json:"distinct_tickers"`
+	DistinctBrokerages int64      `json:"distinct_brokerages"`
+	AvgRecommendScore  float64    `json:"avg_recommend_score"`
+	LastSync           *time.Time `json:"last_sync"`
 }
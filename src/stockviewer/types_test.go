@@ -0,0 +1,178 @@
+package stockviewer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStockMarshalJSON_UpsidePercent(t *testing.T) {
+	tests := []struct {
+		name        string
+		stock       Stock
+		want        float64
+		wantOmitted bool
+	}{
+		{"raised target", Stock{TargetFrom: Price(150), TargetTo: Price(180)}, 20, false},
+		{"lowered target", Stock{TargetFrom: Price(300), TargetTo: Price(200)}, -33.33, false},
+		{"no previous target", Stock{TargetFrom: Price(0), TargetTo: Price(180)}, 0, true},
+		{"no new target", Stock{TargetFrom: Price(150), TargetTo: Price(0)}, 0, true},
+		{"quarantined target", Stock{TargetFrom: Price(150)}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.stock)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var decoded map[string]any
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+
+			upside, present := decoded["upside_percent"]
+			if tt.wantOmitted {
+				if present {
+					t.Errorf("expected upside_percent to be omitted, got %v", upside)
+				}
+				return
+			}
+			if upside != tt.want {
+				t.Errorf("expected upside_percent %v, got %v", tt.want, upside)
+			}
+		})
+	}
+}
+
+func TestStockMarshalJSON_QuarantinedTarget(t *testing.T) {
+	stock := Stock{
+		TargetFrom: Price(20),
+		DataIssues: []DataIssue{{Field: "target_to", Shape: "object", Raw: `{"amount":"12.5"}`}},
+	}
+
+	data, err := json.Marshal(stock)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+
+	if target, present := decoded["target_to"]; !present || target != nil {
+		t.Errorf("expected target_to to be null, got %v", target)
+	}
+	issues, _ := decoded["data_issues"].([]any)
+	if len(issues) != 1 {
+		t.Errorf("expected one data issue, got %v", decoded["data_issues"])
+	}
+
+	data, _ = json.Marshal(Stock{TargetFrom: Price(20), TargetTo: Price(25)})
+	var clean map[string]any
+	if err := json.Unmarshal(data, &clean); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if _, present := clean["data_issues"]; present {
+		t.Error("expected data_issues to be omitted for a clean stock")
+	}
+}
+
+func TestRatingRecognized(t *testing.T) {
+	for _, rating := range []string{"Buy", "Strong Buy", "Hold", "Market Perform", "Sell", "Underweight"} {
+		if !RatingRecognized(rating) {
+			t.Errorf("expected %q to be recognized", rating)
+		}
+	}
+	for _, rating := range []string{"", "Sector Perform", "buy"} {
+		if RatingRecognized(rating) {
+			t.Errorf("expected %q not to be recognized", rating)
+		}
+	}
+}
+
+func TestTotalPages(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int64
+		pageSize int
+		want     int64
+	}{
+		{"empty", 0, 20, 0},
+		{"exact fit", 40, 20, 2},
+		{"partial last page", 41, 20, 3},
+		{"zero page size", 41, 0, 0},
+		{"beyond int32", 1 << 40, 100, 10995116278},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TotalPages(tt.total, tt.pageSize); got != tt.want {
+				t.Errorf("TotalPages(%d, %d) = %d, want %d", tt.total, tt.pageSize, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPaginatedResponse_Navigation(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     int
+		total    int64
+		wantNext int
+		wantPrev int
+	}{
+		{"empty result set", 1, 0, 0, 0},
+		{"first of three", 1, 50, 2, 0},
+		{"middle page", 2, 50, 3, 1},
+		{"last page exactly full", 2, 40, 0, 1},
+		{"page beyond total", 7, 40, 0, 2},
+		{"page beyond empty set", 3, 0, 0, 0},
+	}
+
+	pageNumber := func(p *int) int {
+		if p == nil {
+			return 0
+		}
+		return *p
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := NewPaginatedResponse(nil, tt.page, 20, tt.total)
+
+			if got := pageNumber(resp.NextPage); got != tt.wantNext || resp.HasNext != (tt.wantNext != 0) {
+				t.Errorf("next: got has_next=%v next_page=%d, want %d", resp.HasNext, got, tt.wantNext)
+			}
+			if got := pageNumber(resp.PrevPage); got != tt.wantPrev || resp.HasPrev != (tt.wantPrev != 0) {
+				t.Errorf("prev: got has_prev=%v prev_page=%d, want %d", resp.HasPrev, got, tt.wantPrev)
+			}
+			if resp.Data == nil {
+				t.Error("expected empty data rather than nil")
+			}
+		})
+	}
+}
+
+func TestNewSyncSuccessRate(t *testing.T) {
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	rate := NewSyncSuccessRate(map[string]int{
+		"completed":    3,
+		SyncPartial:    1,
+		"error":        2,
+		SyncRunAborted: 2,
+		"in_progress":  1,
+	}, since, since.Add(24*time.Hour))
+
+	if rate.Completed != 3 || rate.Partial != 1 || rate.Failed != 4 {
+		t.Errorf("unexpected counts %+v", rate)
+	}
+	if rate.Rate == nil || *rate.Rate != 0.375 {
+		t.Errorf("expected a 0.375 rate, got %v", rate.Rate)
+	}
+
+	if empty := NewSyncSuccessRate(map[string]int{}, since, since); empty.Rate != nil {
+		t.Errorf("expected a nil rate without finished runs, got %v", *empty.Rate)
+	}
+}
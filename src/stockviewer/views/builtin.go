@@ -0,0 +1,39 @@
+package views
+
+import "github.com/user/go-stock-viewer-back/src/stockviewer"
+
+// builtInViews are always available and cannot be changed through the admin
+// API.
+var builtInViews = []stockviewer.SavedView{
+	{
+		Slug:        "top-upgrades",
+		Title:       "Top upgrades",
+		Description: "Highest scoring stocks recently upgraded by an analyst",
+		Filter: stockviewer.StockFilter{
+			Action:   string(stockviewer.ActionUpgraded),
+			SortBy:   "recommend_score",
+			PageSize: 20,
+		},
+	},
+	{
+		Slug:        "target-raises",
+		Title:       "Price target raises",
+		Description: "Most recent price target increases",
+		Filter: stockviewer.StockFilter{
+			Action:   string(stockviewer.ActionTargetRaised),
+			SortBy:   "updated_at,recommend_score",
+			PageSize: 20,
+		},
+	},
+	{
+		Slug:        "latest-by-company",
+		Title:       "Latest by company",
+		Description: "The most recent analyst entry for each ticker",
+		Filter: stockviewer.StockFilter{
+			GroupBy:   stockviewer.GroupByTicker,
+			SortBy:    "ticker",
+			SortOrder: "ASC",
+			PageSize:  50,
+		},
+	},
+}
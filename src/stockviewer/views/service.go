@@ -0,0 +1,209 @@
+// Package views resolves saved views: named filter, sort and page size
+// combinations that clients can link to by slug.
+package views
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/query"
+)
+
+const defaultCacheTTL = time.Minute
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type Service struct {
+	repo       stockviewer.ViewRepository
+	stocks     stockviewer.StocksService
+	builtIn    map[string]stockviewer.SavedView
+	pageLimits stockviewer.PageLimits
+	cacheTTL   time.Duration
+	now        func() time.Time
+	mu         sync.Mutex
+	cache      map[string]cachedPage
+}
+
+type cachedPage struct {
+	page      *stockviewer.ViewPage
+	expiresAt time.Time
+}
+
+// Option customizes optional Service settings.
+type Option func(*Service)
+
+// WithCacheTTL sets how long a resolved view page is served from memory.
+// A zero TTL disables caching.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		if ttl >= 0 {
+			s.cacheTTL = ttl
+		}
+	}
+}
+
+// WithPageLimits sets the page size bounds view filters are validated
+// against.
+func WithPageLimits(limits stockviewer.PageLimits) Option {
+	return func(s *Service) {
+		s.pageLimits = limits.WithDefaults()
+	}
+}
+
+func NewService(repo stockviewer.ViewRepository, stocks stockviewer.StocksService, opts ...Option) *Service {
+	s := &Service{
+		repo:       repo,
+		stocks:     stocks,
+		builtIn:    make(map[string]stockviewer.SavedView, len(builtInViews)),
+		pageLimits: stockviewer.DefaultPageLimits(),
+		cacheTTL:   defaultCacheTTL,
+		now:        time.Now,
+		cache:      make(map[string]cachedPage),
+	}
+	for _, view := range builtInViews {
+		view.BuiltIn = true
+		s.builtIn[view.Slug] = view
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ListViews returns the built-in views followed by the stored ones.
+func (s *Service) ListViews(ctx context.Context) ([]stockviewer.SavedView, error) {
+	stored, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]stockviewer.SavedView, 0, len(builtInViews)+len(stored))
+	for _, view := range builtInViews {
+		views = append(views, s.builtIn[view.Slug])
+	}
+	return append(views, stored...), nil
+}
+
+// ResolveView runs the view's filter for the requested page. Pages are cached
+// per view and page number for the configured TTL.
+func (s *Service) ResolveView(ctx context.Context, slug string, page int) (*stockviewer.ViewPage, error) {
+	if page < 1 {
+		page = query.DefaultPage
+	}
+
+	key := slug + "|" + strconv.Itoa(page)
+	if cached, ok := s.cached(key); ok {
+		return cached, nil
+	}
+
+	view, err := s.view(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := view.Filter
+	filter.Page = page
+	result, err := s.stocks.GetStocks(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &stockviewer.ViewPage{View: *view, Result: result}
+	s.store(key, resolved)
+	return resolved, nil
+}
+
+// SaveView validates and stores a dynamic view, replacing any existing view
+// with the same slug. Built-in slugs cannot be overwritten.
+func (s *Service) SaveView(ctx context.Context, view stockviewer.SavedView) (*stockviewer.SavedView, error) {
+	if !slugPattern.MatchString(view.Slug) {
+		return nil, stockviewer.ValidationError{Field: "slug", Message: "must be lowercase letters, digits and single dashes"}
+	}
+	if _, ok := s.builtIn[view.Slug]; ok {
+		return nil, stockviewer.ValidationError{Field: "slug", Message: "is reserved by a built-in view"}
+	}
+	if view.Title == "" {
+		return nil, stockviewer.ValidationError{Field: "title", Message: "is required"}
+	}
+
+	filter, errs := query.NormalizeStockFilter(view.Filter, s.pageLimits)
+	if len(errs) > 0 {
+		return nil, stockviewer.ValidationError{
+			Field:   "filter." + errs[0].Field,
+			Message: errs[0].Message,
+		}
+	}
+	// The page is chosen by the caller when the view is resolved.
+	filter.Page = 0
+	view.Filter = filter
+	view.BuiltIn = false
+
+	if err := s.repo.Save(ctx, view); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+
+	return &view, nil
+}
+
+// DeleteView removes a dynamic view. Built-in views cannot be removed.
+func (s *Service) DeleteView(ctx context.Context, slug string) error {
+	if _, ok := s.builtIn[slug]; ok {
+		return stockviewer.ValidationError{Field: "slug", Message: "is reserved by a built-in view"}
+	}
+	if err := s.repo.Delete(ctx, slug); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *Service) view(ctx context.Context, slug string) (*stockviewer.SavedView, error) {
+	if view, ok := s.builtIn[slug]; ok {
+		return &view, nil
+	}
+	return s.repo.Get(ctx, slug)
+}
+
+func (s *Service) cached(key string) (*stockviewer.ViewPage, bool) {
+	if s.cacheTTL == 0 {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if !s.now().Before(entry.expiresAt) {
+		delete(s.cache, key)
+		return nil, false
+	}
+	return entry.page, true
+}
+
+func (s *Service) store(key string, page *stockviewer.ViewPage) {
+	if s.cacheTTL == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cache[key] = cachedPage{page: page, expiresAt: s.now().Add(s.cacheTTL)}
+}
+
+// invalidate drops every cached page. Saves and deletes are rare admin
+// operations, so there is no need to track which keys belong to which view.
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cache = make(map[string]cachedPage)
+}
@@ -0,0 +1,250 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/mocks"
+	"github.com/user/go-stock-viewer-back/src/stockviewer/stocks"
+)
+
+func newTestService(stocksRepo *mocks.MockStocksRepository, views *mocks.MockViewRepository, opts ...Option) *Service {
+	stocksService := stocks.NewService(stocksRepo, mocks.NewMockStocksFetcher())
+	return NewService(views, stocksService, opts...)
+}
+
+func TestResolveView_BuiltIn(t *testing.T) {
+	stocksRepo := mocks.NewMockStocksRepository()
+	service := newTestService(stocksRepo, mocks.NewMockViewRepository())
+
+	page, err := service.ResolveView(context.Background(), "top-upgrades", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if page.View.Title != "Top upgrades" || !page.View.BuiltIn {
+		t.Errorf("unexpected view metadata: %+v", page.View)
+	}
+	if stocksRepo.LastFilter.Action != string(stockviewer.ActionUpgraded) {
+		t.Errorf("expected action filter %q, got %q", stockviewer.ActionUpgraded, stocksRepo.LastFilter.Action)
+	}
+	if stocksRepo.LastFilter.Page != 2 {
+		t.Errorf("expected page 2, got %d", stocksRepo.LastFilter.Page)
+	}
+	if page.Result.Page != 2 {
+		t.Errorf("expected result page 2, got %d", page.Result.Page)
+	}
+}
+
+func TestResolveView_Dynamic(t *testing.T) {
+	stocksRepo := mocks.NewMockStocksRepository()
+	views := mocks.NewMockViewRepository(stockviewer.SavedView{
+		Slug:   "goldman-buys",
+		Title:  "Goldman buys",
+		Filter: stockviewer.StockFilter{Brokerage: "Goldman Sachs", Rating: "Buy", PageSize: 5},
+	})
+	service := newTestService(stocksRepo, views)
+
+	page, err := service.ResolveView(context.Background(), "goldman-buys", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if page.View.BuiltIn {
+		t.Error("expected stored view not to be built in")
+	}
+	if stocksRepo.LastFilter.Brokerage != "Goldman Sachs" || stocksRepo.LastFilter.Rating != "Buy" {
+		t.Errorf("view filter not applied: %+v", stocksRepo.LastFilter)
+	}
+	if page.Result.Page != 1 || page.Result.PageSize != 5 {
+		t.Errorf("expected page 1 of size 5, got page %d of size %d", page.Result.Page, page.Result.PageSize)
+	}
+}
+
+func TestResolveView_NotFound(t *testing.T) {
+	service := newTestService(mocks.NewMockStocksRepository(), mocks.NewMockViewRepository())
+
+	_, err := service.ResolveView(context.Background(), "missing", 1)
+	if !errors.Is(err, stockviewer.ErrViewNotFound) {
+		t.Errorf("expected ErrViewNotFound, got %v", err)
+	}
+}
+
+func TestResolveView_CachedPerPage(t *testing.T) {
+	stocksRepo := mocks.NewMockStocksRepository()
+	service := newTestService(stocksRepo, mocks.NewMockViewRepository())
+	ctx := context.Background()
+
+	first, err := service.ResolveView(ctx, "top-upgrades", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stocksRepo.Stocks = nil
+
+	cached, err := service.ResolveView(ctx, "top-upgrades", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cached.Result.TotalItems != first.Result.TotalItems {
+		t.Errorf("expected cached total %d, got %d", first.Result.TotalItems, cached.Result.TotalItems)
+	}
+
+	other, err := service.ResolveView(ctx, "top-upgrades", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if other.Result.TotalItems != 0 {
+		t.Errorf("expected page 2 to be resolved fresh, got %d items", other.Result.TotalItems)
+	}
+}
+
+func TestResolveView_CacheInvalidatedOnSave(t *testing.T) {
+	stocksRepo := mocks.NewMockStocksRepository()
+	views := mocks.NewMockViewRepository(stockviewer.SavedView{Slug: "buys", Title: "Buys"})
+	service := newTestService(stocksRepo, views)
+	ctx := context.Background()
+
+	if _, err := service.ResolveView(ctx, "buys", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := service.SaveView(ctx, stockviewer.SavedView{
+		Slug:   "buys",
+		Title:  "Strong buys",
+		Filter: stockviewer.StockFilter{Rating: "Strong-Buy"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	page, err := service.ResolveView(ctx, "buys", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.View.Title != "Strong buys" {
+		t.Errorf("expected updated title, got %q", page.View.Title)
+	}
+	if stocksRepo.LastFilter.Rating != "Strong-Buy" {
+		t.Errorf("expected updated filter, got %+v", stocksRepo.LastFilter)
+	}
+}
+
+func TestSaveView_ValidatesFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		view  stockviewer.SavedView
+		field string
+	}{
+		{
+			name:  "invalid slug",
+			view:  stockviewer.SavedView{Slug: "Top Picks", Title: "Top picks"},
+			field: "slug",
+		},
+		{
+			name:  "built-in slug",
+			view:  stockviewer.SavedView{Slug: "top-upgrades", Title: "Mine"},
+			field: "slug",
+		},
+		{
+			name:  "missing title",
+			view:  stockviewer.SavedView{Slug: "picks"},
+			field: "title",
+		},
+		{
+			name:  "unknown sort field",
+			view:  stockviewer.SavedView{Slug: "picks", Title: "Picks", Filter: stockviewer.StockFilter{SortBy: "price"}},
+			field: "filter.sort_by",
+		},
+		{
+			name:  "unknown action category",
+			view:  stockviewer.SavedView{Slug: "picks", Title: "Picks", Filter: stockviewer.StockFilter{ActionCategory: "bullish"}},
+			field: "filter.action_category",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			views := mocks.NewMockViewRepository()
+			service := newTestService(mocks.NewMockStocksRepository(), views)
+
+			_, err := service.SaveView(context.Background(), tt.view)
+
+			var validationErr stockviewer.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			if validationErr.Field != tt.field {
+				t.Errorf("expected field %q, got %q", tt.field, validationErr.Field)
+			}
+			if len(views.Views) != 0 {
+				t.Error("expected invalid view not to be stored")
+			}
+		})
+	}
+}
+
+func TestSaveView_NormalizesFilter(t *testing.T) {
+	views := mocks.NewMockViewRepository()
+	service := newTestService(mocks.NewMockStocksRepository(), views)
+
+	saved, err := service.SaveView(context.Background(), stockviewer.SavedView{
+		Slug:   "picks",
+		Title:  "Picks",
+		Filter: stockviewer.StockFilter{Ticker: " AAPL ", Page: 3, PageSize: 100},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stored := views.Views["picks"]
+	if stored.Filter.Ticker != "AAPL" {
+		t.Errorf("expected trimmed ticker, got %q", stored.Filter.Ticker)
+	}
+	if stored.Filter.Page != 0 {
+		t.Errorf("expected page not to be stored, got %d", stored.Filter.Page)
+	}
+	if stored.Filter.PageSize != 100 {
+		t.Errorf("expected page size 100, got %d", stored.Filter.PageSize)
+	}
+	if saved.Filter.Ticker != stored.Filter.Ticker || saved.Filter.PageSize != stored.Filter.PageSize {
+		t.Errorf("expected returned view to match stored view")
+	}
+}
+
+func TestDeleteView(t *testing.T) {
+	views := mocks.NewMockViewRepository(stockviewer.SavedView{Slug: "picks", Title: "Picks"})
+	service := newTestService(mocks.NewMockStocksRepository(), views)
+	ctx := context.Background()
+
+	if err := service.DeleteView(ctx, "picks"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := service.DeleteView(ctx, "picks"); !errors.Is(err, stockviewer.ErrViewNotFound) {
+		t.Errorf("expected ErrViewNotFound, got %v", err)
+	}
+
+	var validationErr stockviewer.ValidationError
+	if err := service.DeleteView(ctx, "top-upgrades"); !errors.As(err, &validationErr) {
+		t.Errorf("expected ValidationError for built-in view, got %v", err)
+	}
+}
+
+func TestListViews(t *testing.T) {
+	views := mocks.NewMockViewRepository(stockviewer.SavedView{Slug: "picks", Title: "Picks"})
+	service := newTestService(mocks.NewMockStocksRepository(), views)
+
+	list, err := service.ListViews(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(list) != len(builtInViews)+1 {
+		t.Fatalf("expected %d views, got %d", len(builtInViews)+1, len(list))
+	}
+	if !list[0].BuiltIn || list[len(list)-1].Slug != "picks" {
+		t.Errorf("expected built-in views first, got %+v", list)
+	}
+}
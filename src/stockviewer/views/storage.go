@@ -0,0 +1,57 @@
+package views
+
+import (
+	"context"
+
+	"github.com/user/go-stock-viewer-back/src/stockviewer"
+	"gorm.io/gorm"
+)
+
+type Storage struct {
+	db *gorm.DB
+}
+
+// NewStorage expects the schema to be in place; see package schema.
+func NewStorage(db *gorm.DB) *Storage {
+	return &Storage{db: db}
+}
+
+func (s *Storage) List(ctx context.Context) ([]stockviewer.SavedView, error) {
+	var views []stockviewer.SavedView
+	result := s.db.WithContext(ctx).Order("slug ASC").Find(&views)
+	if result.Error != nil {
+		return nil, stockviewer.StorageError{Operation: "list_views", Err: result.Error}
+	}
+	return views, nil
+}
+
+func (s *Storage) Get(ctx context.Context, slug string) (*stockviewer.SavedView, error) {
+	var view stockviewer.SavedView
+	result := s.db.WithContext(ctx).Where("slug = ?", slug).First(&view)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, stockviewer.ErrViewNotFound
+		}
+		return nil, stockviewer.StorageError{Operation: "get_view", Err: result.Error}
+	}
+	return &view, nil
+}
+
+func (s *Storage) Save(ctx context.Context, view stockviewer.SavedView) error {
+	result := s.db.WithContext(ctx).Save(&view)
+	if result.Error != nil {
+		return stockviewer.StorageError{Operation: "save_view", Err: result.Error}
+	}
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, slug string) error {
+	result := s.db.WithContext(ctx).Where("slug = ?", slug).Delete(&stockviewer.SavedView{})
+	if result.Error != nil {
+		return stockviewer.StorageError{Operation: "delete_view", Err: result.Error}
+	}
+	if result.RowsAffected == 0 {
+		return stockviewer.ErrViewNotFound
+	}
+	return nil
+}